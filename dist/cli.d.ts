@@ -1,10 +0,0 @@
-#!/usr/bin/env node
-/**
- * CLI entry point for collab-claude-code
- *
- * Usage:
- *   collab-claude-code init       - Install skills, MCP server, and hooks
- *   collab-claude-code uninstall  - Remove all components
- *   collab-claude-code --help     - Show help
- */
-export {};
@@ -1,2 +0,0 @@
-#!/usr/bin/env node
-export {};
@@ -15,13 +15,47 @@
  */
 
 import * as fs from 'fs/promises';
+import * as http from 'http';
 import * as path from 'path';
+import * as zlib from 'zlib';
+import { execFileSync, spawnSync } from 'child_process';
 import { fileURLToPath } from 'url';
 
 const __dirname = path.dirname(fileURLToPath(import.meta.url));
 
 // Import the collab module
 const collab = await import('./dist/collab.js');
+const trivial = await import('./dist/trivial.js');
+const reasons = await import('./dist/reasons.js');
+const ignore = await import('./dist/ignore.js');
+const diff = await import('./dist/diff.js');
+const applyHooks = await import('./dist/apply-hooks.js');
+const declarations = await import('./dist/declarations.js');
+const heatmap = await import('./dist/heatmap.js');
+const explain = await import('./dist/explain.js');
+const budget = await import('./dist/budget.js');
+const scan = await import('./dist/scan.js');
+const check = await import('./dist/check.js');
+const proposalValidation = await import('./dist/proposal-validation.js');
+const doctor = await import('./dist/doctor.js');
+const gateway = await import('./dist/gateway.js');
+const messages = await import('./dist/messages.js');
+const verifyConstraintsModule = await import('./dist/verify-constraints.js');
+const simulate = await import('./dist/simulate.js');
+const logging = await import('./dist/log.js');
+const auditOwnersModule = await import('./dist/audit-owners.js');
+const proposalsModule = await import('./dist/proposals.js');
+const completionsModule = await import('./dist/completions.js');
+const routesModule = await import('./dist/routes.js');
+const signatureModule = await import('./dist/signature.js');
+const goscopeModule = await import('./dist/goscope.js');
+const transferHooksModule = await import('./dist/transfer-hooks.js');
+const testPolicyModule = await import('./dist/test-policy.js');
+const atModule = await import('./dist/at.js');
+const constraintsModule = await import('./dist/constraints.js');
+const warmModule = await import('./dist/warm.js');
+const signoffsModule = await import('./dist/signoffs.js');
+const policyModule = await import('./dist/policy.js');
 
 // Test directory
 const TEST_DIR = path.join(__dirname, '.e2e-test');
@@ -446,6 +480,3838 @@ async function runTests() {
       'Custom policy was overwritten'
     );
 
+    // ========================================
+    section('10. TRIVIAL EDIT CLASSIFICATION');
+    // ========================================
+
+    assert(
+      trivial.isTrivialEdit('const a = 1;', 'const a = 1; // one', 'x.ts'),
+      'Adding a comment is trivial'
+    );
+
+    assert(
+      trivial.isTrivialEdit('if (x) {\n  y();\n}', 'if (x) {\n    y();\n}\n', 'x.ts'),
+      'Re-indenting brace-language code is trivial'
+    );
+
+    assert(
+      !trivial.isTrivialEdit('return 1;', 'return 2;', 'x.ts'),
+      'Changing a value is substantive'
+    );
+
+    assert(
+      !trivial.isTrivialEdit('s = "a b"', 's = "a  b"', 'x.ts'),
+      'Whitespace inside string literals is substantive'
+    );
+
+    assert(
+      !trivial.isTrivialEdit('s = "a // b"', 's = "a // c"', 'x.ts'),
+      'Comment markers inside strings are not comments'
+    );
+
+    assert(
+      !trivial.isTrivialEdit('def f():\n  return 1', 'def f():\n    return 1', 'x.py'),
+      'Python indentation changes are substantive'
+    );
+
+    assert(
+      trivial.isTrivialEdit('x = 1  # old', 'x = 1  # new', 'x.py'),
+      'Changing a Python comment is trivial'
+    );
+
+    // ========================================
+    section('11. ANNOTATION FORMATTING');
+    // ========================================
+
+    const roundTripSources = [
+      ['a.ts', '// @collab trust="READ_ONLY" owner="alice"\nfunction a() {\n  return 1;\n}\n'],
+      ['b.ts', '// @collab ro\n// @collab intent="Keep it fast" constraints=["No allocation", "O(1)"]\nfunction b() {\n}\n'],
+      ['c.py', '# @collab owner="data-team" trust="SUGGEST_ONLY"\ndef c():\n    pass\n'],
+      ['d.ts', '// @collab trust="SUGGEST_ONLY" lines="2-2" intent="Say \'hi\' with a very long intent string that wraps"\nfunction d() {\n  x();\n}\n'],
+    ];
+
+    // Line numbers shift when formatting changes the annotation's height
+    const attrsOf = (a) => JSON.stringify([a.trust, a.owner, a.intent, a.constraints, a.lines]);
+
+    for (const [file, source] of roundTripSources) {
+      const [first] = collab.parseAnnotationContent(source, file).annotations;
+      const formatted = collab.formatAnnotation(first, { filePath: file });
+      const body = source.split('\n').filter(l => !l.includes('@collab')).join('\n');
+      const [second] = collab.parseAnnotationContent(formatted + '\n' + body, file).annotations;
+      const reformatted = collab.formatAnnotation(second, { filePath: file });
+
+      assert(
+        attrsOf(first) === attrsOf(second) && formatted === reformatted,
+        `Round-trips ${file} through parse -> format -> parse`,
+        `Formatted:\n${formatted}\nGot: ${JSON.stringify(second)}`
+      );
+    }
+
+    assert(
+      collab.formatAnnotation({ owner: 'bob', trust: 'READ_ONLY', line_start: 1, line_end: 1 }) ===
+        '// @collab trust="READ_ONLY" owner="bob"',
+      'Orders attributes deterministically'
+    );
+
+    assert(
+      collab.formatAnnotation(
+        { trust: 'AUTONOMOUS', constraints: ['a', 'b'], line_start: 1, line_end: 1 },
+        { filePath: 'x.rb' }
+      ) === '# @collab trust="AUTONOMOUS" constraints=["a", "b"]',
+      'Renders constraints arrays and hash comments'
+    );
+
+    // ========================================
+    section('12. MULTIPLE APPROVALS');
+    // ========================================
+
+    const twoKey = collab.parseAnnotationContent(
+      '// @collab so min_approvals="2"\nfunction pay() {\n  charge();\n}\n',
+      'pay.ts'
+    );
+    assert(
+      twoKey.annotations[0].min_approvals === 2 && twoKey.errors.length === 0,
+      'Parses min_approvals attribute',
+      `Got: ${JSON.stringify(twoKey)}`
+    );
+
+    const badCount = collab.parseAnnotationContent('// @collab min_approvals="0"\nfunction f() {}\n', 'f.ts');
+    assert(badCount.errors.length === 1, 'Rejects non-positive min_approvals');
+
+    const pending = { id: 'x', author: 'claude', min_approvals: 2, approvals: [] };
+    assert(!collab.addApproval(pending, 'claude').counted, 'Self-approval does not count');
+    assert(!collab.addApproval(pending, 'alice').satisfied, 'One approval is not enough for min_approvals=2');
+    assert(!collab.addApproval(pending, 'alice').counted, 'Repeat approver counts once');
+    assert(collab.addApproval(pending, 'bob').satisfied, 'Second distinct approver satisfies min_approvals=2');
+
+    await fs.writeFile('two-key.ts', '// @collab so min_approvals="2"\nfunction pay() {\n  charge();\n}\n');
+    const twoKeyInput = { file_path: 'two-key.ts', description: 'd', new_code: 'x', confidence: 0.5 };
+    const locatedTwoKey = await collab.createProposal({ ...twoKeyInput, old_code: '  charge();' });
+    const recordedTwoKey = await collab.createProposal({ ...twoKeyInput, old_code: '  charge( );', line_start: 3 });
+    let unlocatedTwoKey;
+    await collab.createProposal({ ...twoKeyInput, old_code: '  charge( );' }).catch(e => { unlocatedTwoKey = e; });
+    const newFileTwoKey = await collab.createProposal({ ...twoKeyInput, file_path: 'two-key-new.ts', old_code: '' });
+    await fs.rm('two-key.ts');
+    assert(
+      locatedTwoKey.min_approvals === 2 && recordedTwoKey.min_approvals === 2 && recordedTwoKey.base?.line_start === 3 &&
+        unlocatedTwoKey instanceof collab.ProposalRegionNotFoundError && newFileTwoKey.min_approvals === undefined,
+      'Takes min_approvals from the recorded lines when old_code is not in the file, and refuses proposals with neither',
+      `Got: ${JSON.stringify({ locatedTwoKey, recordedTwoKey, unlocatedTwoKey: String(unlocatedTwoKey) })}`
+    );
+
+    // ========================================
+    section('13. ENVIRONMENT PROFILES');
+    // ========================================
+
+    const profiled = {
+      default_trust: 'SUPERVISED',
+      policies: [{ pattern: 'src/pay*', trust: 'SUGGEST_ONLY' }],
+      profiles: {
+        dev: { branches: ['feature/*'], overrides: { SUGGEST_ONLY: 'AUTONOMOUS', READ_ONLY: 'READ_ONLY' } },
+      },
+    };
+    assert(collab.validateTrustProfiles(profiled) === null, 'Accepts valid profile overrides');
+
+    const relaxed = collab.getTrustLevel({ ...profiled, active_profile: 'dev' }, 'src/pay.ts');
+    assert(
+      relaxed.level === 'AUTONOMOUS' && relaxed.base_level === 'SUGGEST_ONLY' && relaxed.profile === 'dev',
+      'Active profile remaps the resolved level',
+      `Got: ${JSON.stringify(relaxed)}`
+    );
+    assert(collab.getTrustLevel(profiled, 'src/pay.ts').level === 'SUGGEST_ONLY', 'No active profile leaves levels unchanged');
+
+    const unsafe = { ...profiled, profiles: { dev: { overrides: { READ_ONLY: 'AUTONOMOUS' } } } };
+    assert(collab.validateTrustProfiles(unsafe) !== null, 'Rejects profiles that relax READ_ONLY');
+    assert(
+      collab.applyTrustProfile({ ...unsafe, active_profile: 'dev' }, { level: 'READ_ONLY' }).level === 'READ_ONLY',
+      'READ_ONLY is never relaxed at resolution time'
+    );
+
+    // ========================================
+    section('14. GO SCOPE DETECTION');
+    // ========================================
+
+    const goSource = [
+      'package x',
+      '',
+      '// @collab ro',
+      'func Sig(x interface{}) interface{ M() } {',
+      '\treturn x',
+      '}',
+      '',
+      '// @collab so',
+      'var table = map[string]Point{',
+      '\t"a": {X: 1, Y: 2},',
+      '\t"}": {X: 3},',
+      '}',
+      '',
+      '// @collab ro',
+      'func Tricky() string {',
+      '\ts := "}}}"',
+      "\tr := '}'",
+      '\t/* } */',
+      '\treturn s + string(r) // }',
+      '}',
+      '',
+      '// @collab sv',
+      'const Limit = 10',
+      '',
+      'func after() {}',
+    ].join('\n');
+    const goSpans = collab.parseAnnotationContent(goSource, 'scope.go').annotations.map(a => [a.line_start, a.line_end]);
+    const expectedSpans = [[4, 6], [9, 12], [15, 20], [23, 23]];
+    assert(
+      JSON.stringify(goSpans) === JSON.stringify(expectedSpans),
+      'Go scopes ignore braces in signatures, literals, strings, runes, and comments',
+      `Got: ${JSON.stringify(goSpans)}`
+    );
+
+    const brokenGo = collab.parseAnnotationContent('// @collab ro\nfunc f() {\n\ts := "\n}\n', 'broken.go');
+    assert(
+      brokenGo.annotations.length === 1 && brokenGo.errors.length === 1,
+      'Untokenizable Go falls back to brace counting with a diagnostic',
+      `Got: ${JSON.stringify(brokenGo)}`
+    );
+
+    // ========================================
+    section('15. REASON CODES');
+    // ========================================
+
+    const knownCodes = new Set(reasons.reasonCodes().map(r => r.code));
+    const erroring = collab.parseAnnotationContent(
+      [
+        '// @collab trust="READ_ONLYY"',
+        '// @collab rx',
+        '// @collab min_approvals="zero" lines="a-b"',
+        'function f() {',
+        '}',
+        '// @collab:begin lines="1-2"',
+        '// @collab:end',
+      ].join('\n'),
+      'codes.ts'
+    ).errors;
+    assert(
+      erroring.length === 5 && erroring.every(e => knownCodes.has(e.code)),
+      'Every annotation error carries a documented reason code',
+      `Got: ${JSON.stringify(erroring.map(e => e.code))}`
+    );
+    assert(knownCodes.has('read-only-edit'), 'Authorship violations have a reason code');
+    assert(
+      reasons.formatReasonReference().includes('`unknown-alias`'),
+      'Reference page lists reason codes'
+    );
+
+    // ========================================
+    section('16. GITIGNORE EXCLUSION');
+    // ========================================
+
+    const isIgnored = ignore.createIgnoreFilter([
+      ...ignore.parseIgnoreFile('out/\n!out/keep.ts\n*.gen.ts\n/src/gen\n# comment\n'),
+      ...ignore.parseIgnoreFile('*.ts\n!keep.ts\n', 'src/sub'),
+      ...ignore.parseIgnoreFile('third_party/**'),
+    ]);
+    assert(!isIgnored('src/a.ts'), 'Keeps files not matched by any rule');
+    assert(isIgnored('out/o.ts') && isIgnored('src/b.gen.ts'), 'Honors directory and unanchored patterns');
+    assert(isIgnored('src/gen/x.ts') && !isIgnored('lib/src/gen/x.ts'), 'Leading slash anchors to the ignore file');
+    assert(isIgnored('src/sub/y.ts') && !isIgnored('src/sub/keep.ts'), 'Nested ignore files apply with negation');
+    assert(isIgnored('out/keep.ts'), 'Files inside an excluded directory cannot be re-included');
+    assert(isIgnored('third_party/t.ts'), 'Honors ** patterns');
+
+    // ========================================
+    section('17. CONTRADICTORY ANNOTATION LINT');
+    // ========================================
+
+    const contradictory = collab.parseAnnotationContent(
+      '// @collab trust="AUTONOMOUS" constraints=["keep API"]\nfunction f() {\n}\n',
+      'lint.ts'
+    );
+    assert(
+      contradictory.errors.length === 0 &&
+        contradictory.warnings.length === 1 &&
+        contradictory.warnings[0].code === 'autonomous-with-constraints' &&
+        contradictory.warnings[0].line === 1,
+      'Warns on AUTONOMOUS regions with constraints',
+      `Got: ${JSON.stringify(contradictory)}`
+    );
+
+    const consistent = collab.parseAnnotationContent(
+      '// @collab trust="SUGGEST_ONLY" constraints=["keep API"]\nfunction f() {\n}\n// @collab auto\nfunction g() {}\n',
+      'lint.ts'
+    );
+    assert(consistent.warnings.length === 0, 'No warning for consistent annotations');
+
+    // ========================================
+    section('18. GOVERNANCE DIFF');
+    // ========================================
+
+    const govBase = [
+      '// @collab trust="READ_ONLY" owner="security-team"',
+      'function verify() {',
+      '}',
+      '// @collab trust="SUPERVISED"',
+      'function helper() {',
+      '}',
+    ].join('\n');
+    const govHead = [
+      '// @collab trust="SUPERVISED" owner="core"',
+      'function helper() {',
+      '}',
+      '// @collab auto',
+      'function fresh() {',
+      '}',
+    ].join('\n');
+    const govChanges = diff.diffAnnotationContent('gov.ts', govBase, govHead, collab.DEFAULT_TRUST_ALIASES);
+    const kinds = govChanges.map(c => `${c.kind}:${c.priority}`).sort();
+    assert(
+      JSON.stringify(kinds) === JSON.stringify(['owner_added:normal', 'region_added:normal', 'region_removed:high']),
+      'Reports regions added/removed and owner additions',
+      `Got: ${JSON.stringify(kinds)}`
+    );
+    assert(
+      diff.measureCoverage(govBase, 'gov.ts', collab.DEFAULT_TRUST_ALIASES).governed_lines === 4,
+      'Measures governed line coverage'
+    );
+
+    const policyChanges = diff.diffTrustConfigs(
+      {
+        default_trust: 'SUPERVISED',
+        policies: [{ pattern: 'src/payments/**', trust: 'READ_ONLY', owner: 'payments' }, { pattern: 'docs/**', trust: 'AUTONOMOUS' }],
+        regions: [{ file: 'src/a.ts', line_start: 1, line_end: 4, trust: 'SUGGEST_ONLY' }],
+      },
+      {
+        default_trust: 'SUGGEST_ONLY',
+        policies: [{ pattern: 'docs/**', trust: 'AUTONOMOUS' }, { pattern: 'src/payments/**', trust: 'AUTONOMOUS', owner: 'payments' }],
+        profiles: { hotfix: { overrides: { READ_ONLY: 'SUPERVISED' } } },
+      }
+    );
+    assert(
+      JSON.stringify(policyChanges.map(c => [c.symbol, c.kind, c.priority])) === JSON.stringify([
+        ['default_trust', 'upgrade', 'normal'],
+        ['policies["src/payments/**"]', 'downgrade', 'high'],
+        ['regions["src/a.ts:1-4"]', 'policy_removed', 'high'],
+        ['profiles.hotfix.overrides.READ_ONLY', 'downgrade', 'high'],
+      ]) && policyChanges.every(c => c.file === '.collab/trust.yaml' && c.line === 0) &&
+        JSON.stringify(policyChanges[1].owners_before) === JSON.stringify(['payments']),
+      'Diffs resolved trust.yaml settings, pairing policies by pattern and flagging loosened ones',
+      `Got: ${JSON.stringify(policyChanges)}`
+    );
+
+    await fs.mkdir('policy-diff/.collab', { recursive: true });
+    process.chdir('policy-diff');
+    const gitPolicy = (...args) => execFileSync('git', ['-c', 'user.name=e2e', '-c', 'user.email=e2e@example.com', ...args]);
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUPERVISED\npolicies:\n  - pattern: "src/payments/**"\n    trust: READ_ONLY\n');
+    gitPolicy('init', '-q');
+    gitPolicy('add', '-A');
+    gitPolicy('commit', '-qm', 'base');
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUPERVISED\npolicies:\n  - pattern: "src/payments/**"\n    trust: AUTONOMOUS\n');
+    const relaxedPolicy = await diff.diffAnnotations('HEAD');
+    process.chdir(TEST_DIR);
+    await fs.rm('policy-diff', { recursive: true });
+    assert(
+      relaxedPolicy.summary.policy_changes === 1 && relaxedPolicy.summary.high_priority === 1 &&
+        relaxedPolicy.changes[0]?.kind === 'downgrade' &&
+        diff.formatDiffText(relaxedPolicy).includes('HIGH   .collab/trust.yaml: downgrade READ_ONLY -> AUTONOMOUS  policies["src/payments/**"]') &&
+        diff.formatDiffText(relaxedPolicy).includes('trust.yaml changes: 1'),
+      'diff reports a relaxed trust.yaml policy as a high-priority downgrade',
+      `Got: ${JSON.stringify(relaxedPolicy)}`
+    );
+
+    // ========================================
+    section('19. GO BUILD TAGS');
+    // ========================================
+
+    const tagged = collab.parseAnnotationContent(
+      [
+        '//go:build linux && amd64',
+        '// +build linux,amd64',
+        '',
+        'package sys',
+        '',
+        '// @collab ro',
+        '//go:noinline',
+        'func Read() int {',
+        '\treturn 1',
+        '}',
+      ].join('\n'),
+      'sys_linux.go'
+    );
+    assert(
+      tagged.errors.length === 0 &&
+        tagged.annotations.length === 1 &&
+        tagged.annotations[0].line_start === 8 &&
+        tagged.annotations[0].line_end === 10,
+      'Skips build constraints and directives when attaching annotations',
+      `Got: ${JSON.stringify(tagged.annotations)}`
+    );
+
+    const fileLevel = collab.parseAnnotationContent(
+      '// @collab trust="READ_ONLY"\n//go:build windows\n\npackage sys\n\nfunc A() {}\n\nfunc B() {}\n',
+      'sys_windows.go'
+    );
+    assert(
+      fileLevel.annotations.length === 1 &&
+        fileLevel.annotations[0].line_start === 4 &&
+        fileLevel.annotations[0].line_end === 8,
+      'Annotation above //go:build governs the whole file',
+      `Got: ${JSON.stringify(fileLevel.annotations)}`
+    );
+
+    // ========================================
+    section('20. PRE-APPLY HOOKS');
+    // ========================================
+
+    const hookOrder = [];
+    applyHooks.registerPreApplyHook(function requireTests(p) {
+      hookOrder.push('tests');
+      throw new Error(`no tests for ${p.file_path}`);
+    });
+    applyHooks.registerPreApplyHook(async function requireCI() {
+      hookOrder.push('ci');
+      throw new Error('CI is failing');
+    });
+    applyHooks.registerPreApplyHook(() => { hookOrder.push('ok'); });
+
+    const hookProposal = { id: 'h1', file_path: 'src/a.ts', old_code: 'a', new_code: 'b' };
+    const refusals = await applyHooks.runPreApplyHooks(hookProposal);
+    assert(
+      JSON.stringify(hookOrder) === JSON.stringify(['tests', 'ci', 'ok']),
+      'Runs every hook in registration order',
+      `Got: ${JSON.stringify(hookOrder)}`
+    );
+    assert(
+      refusals.length === 2 &&
+        refusals[0].hook === 'requireTests' &&
+        refusals[0].message === 'no tests for src/a.ts' &&
+        refusals[1].message === 'CI is failing',
+      'Aggregates failures from all vetoing hooks',
+      `Got: ${JSON.stringify(refusals)}`
+    );
+
+    applyHooks.clearPreApplyHooks();
+    assert((await applyHooks.runPreApplyHooks(hookProposal)).length === 0, 'No hooks means no veto');
+
+    // ========================================
+    section('21. DECLARATION LOOKUP');
+    // ========================================
+
+    const goDecls = [
+      'package pay',
+      '',
+      '// @collab trust="READ_ONLY" owner="payments"',
+      'func (s *Service) ProcessPayment(amount int) error {',
+      '\treturn nil',
+      '}',
+      '',
+      'func (r Refunds) ProcessPayment() {}',
+    ].join('\n');
+    await fs.writeFile('pay.go', goDecls);
+    const found = declarations.findDeclarations(goDecls, 'pay.go');
+    assert(
+      JSON.stringify(found.map(d => [d.qualified_name, d.line_start, d.line_end])) ===
+        JSON.stringify([['Service.ProcessPayment', 4, 6], ['Refunds.ProcessPayment', 8, 8]]),
+      'Finds Go methods qualified by receiver type',
+      `Got: ${JSON.stringify(found)}`
+    );
+
+    const byName = await declarations.resolveByDeclaration(
+      { default_trust: 'SUPERVISED', policies: [] },
+      'Service.ProcessPayment',
+      'pay.go'
+    );
+    assert(
+      byName.level === 'READ_ONLY' && byName.annotation?.owner === 'payments',
+      'Resolves trust and governing annotation by Type.Method',
+      `Got: ${JSON.stringify(byName)}`
+    );
+
+    let ambiguity = '';
+    try {
+      await declarations.resolveByDeclaration({ default_trust: 'SUPERVISED', policies: [] }, 'ProcessPayment', 'pay.go');
+    } catch (e) {
+      ambiguity = e.message;
+    }
+    assert(ambiguity.startsWith('Ambiguous declaration ProcessPayment'), 'Ambiguous names are an error', `Got: ${ambiguity}`);
+
+    // ========================================
+    section('22. REDACTED REGIONS');
+    // ========================================
+
+    await fs.writeFile(
+      'fixtures.ts',
+      '// @collab trust="SUGGEST_ONLY" redact="true"\nexport function cards() {\n  return ["4111111111111111"];\n}\n'
+    );
+    const secret = '  return ["4111111111111111"];';
+    const redaction = await collab.redactProposalCode('fixtures.ts', secret);
+    assert(
+      redaction.redacted?.line_start === 3 &&
+        !redaction.old_code.includes('4111') &&
+        redaction.old_code.includes(redaction.redacted.sha256),
+      'Replaces redacted region content with a placeholder and hash',
+      `Got: ${JSON.stringify(redaction)}`
+    );
+
+    await fs.writeFile('open.ts', '// @collab trust="SUGGEST_ONLY"\nexport function f() {\n  return 1;\n}\n');
+    const unredacted = await collab.redactProposalCode('open.ts', '  return 1;');
+    assert(unredacted.old_code === '  return 1;' && !unredacted.redacted, 'Leaves other regions intact');
+
+    const badRedact = collab.parseAnnotationContent('// @collab redact="yes"\nfunction f() {}\n', 'r.ts');
+    assert(badRedact.errors[0]?.code === 'invalid-redact', 'Reports invalid redact values');
+
+    // ========================================
+    section('23. STABLE SCAN OUTPUT');
+    // ========================================
+
+    assert(
+      collab.stableStringify({ b: 1, a: { d: [{ z: 1, y: 2 }], c: 3 } }) === '{"a":{"c":3,"d":[{"y":2,"z":1}]},"b":1}',
+      'Serializes JSON with sorted keys at every level'
+    );
+    assert(
+      JSON.stringify(['src/b.ts', 'src/a.ts', 'Src/c.ts', 'src/a/z.ts'].sort(collab.comparePaths)) ===
+        JSON.stringify(['Src/c.ts', 'src/a.ts', 'src/a/z.ts', 'src/b.ts']),
+      'Sorts paths by code point, independent of locale'
+    );
+
+    // ========================================
+    section('24. TRUST HEATMAP');
+    // ========================================
+
+    await fs.writeFile(
+      'heat.ts',
+      'const a = 1;\n// @collab trust="READ_ONLY" owner="sec"\nfunction f() {\n  return a;\n}\n'
+    );
+    const heat = (await heatmap.buildHeatmap(['heat.ts'])).files[0];
+    const heatSum = Object.values(heat.levels).reduce((a, b) => a + b, 0);
+    assert(
+      heat.total_lines === 5 && heatSum === 5 && heat.levels.READ_ONLY === 3,
+      'Counts every line by its resolved trust level',
+      `Got: ${JSON.stringify(heat)}`
+    );
+    assert(heat.dominant_owner === 'sec' && heat.owners.sec === 3, 'Reports the dominant owner');
+
+    // ========================================
+    section('25. BLOCK END ECHO');
+    // ========================================
+
+    const echoed = collab.parseAnnotationContent(
+      '// @collab:begin trust="READ_ONLY" owner="sec"\nfunction a() {}\n// @collab:end trust="READ_ONLY"\n',
+      'echo.ts'
+    );
+    assert(
+      echoed.errors.length === 0 && echoed.annotations.length === 1 && echoed.annotations[0].line_end === 2,
+      'Matching @collab:end echo is validation only',
+      `Got: ${JSON.stringify(echoed)}`
+    );
+
+    const mismatched = collab.parseAnnotationContent(
+      '// @collab:begin trust="READ_ONLY"\nfunction a() {}\n// @collab:end trust="SUPERVISED"\n',
+      'echo.ts'
+    );
+    assert(
+      mismatched.errors.length === 1 &&
+        mismatched.errors[0].code === 'block-end-mismatch' &&
+        mismatched.errors[0].line === 3 &&
+        mismatched.annotations[0].trust === 'READ_ONLY',
+      'Reports @collab:end attributes that differ from @collab:begin',
+      `Got: ${JSON.stringify(mismatched.errors)}`
+    );
+
+    // ========================================
+    section('26. CO-OWNED REGIONS');
+    // ========================================
+
+    const coOwned = '// @collab trust="SUGGEST_ONLY" owner=["auth-team", "crypto-team"]\nexport function derive() {\n  return 1;\n}\n';
+    const coParsed = collab.parseAnnotationContent(coOwned, 'keys.ts').annotations[0];
+    assert(
+      JSON.stringify(coParsed.owner) === JSON.stringify(['auth-team', 'crypto-team']),
+      'Parses owner arrays',
+      `Got: ${JSON.stringify(coParsed)}`
+    );
+    assert(
+      collab.formatAnnotation(coParsed) === '// @collab trust="SUGGEST_ONLY" owner=["auth-team", "crypto-team"]',
+      'Formats owner arrays back to array syntax'
+    );
+
+    await fs.writeFile('keys.ts', coOwned);
+    const notified = await collab.getProposalOwners('keys.ts', '  return 1;');
+    assert(notified.length === 2 && notified.includes('crypto-team'), 'Proposals record every co-owner');
+
+    const ownerDiff = diff.diffAnnotationContent(
+      'keys.ts',
+      coOwned,
+      coOwned.replace(', "crypto-team"', ''),
+      collab.DEFAULT_TRUST_ALIASES
+    );
+    assert(
+      ownerDiff.length === 1 && ownerDiff[0].owner_before === 'auth-team, crypto-team' && ownerDiff[0].owner_after === 'auth-team',
+      'Diff reports co-owner list changes',
+      `Got: ${JSON.stringify(ownerDiff)}`
+    );
+
+    // ========================================
+    section('27. EXPLAIN POLICY');
+    // ========================================
+
+    const explained = await explain.explainPolicy('src/custom/widget.ts');
+    const applying = explained.policies.filter(p => p.applies);
+    assert(
+      explained.policies.length > 0 && explained.policies.every(p => p.source === explained.trust_file),
+      'Tags every policy with the file it came from',
+      `Got: ${JSON.stringify(explained.policies)}`
+    );
+    assert(
+      applying.length === 1 && applying[0].pattern === '**/custom/**' && explained.effective.level === 'READ_ONLY',
+      'Marks the first matching policy as the one that applies',
+      `Got: ${JSON.stringify(applying)}`
+    );
+    assert(
+      explained.aliases.some(a => a.name === 'ro' && a.source === 'built-in default'),
+      'Reports where each alias comes from'
+    );
+
+    // ========================================
+    section('28. UNICODE IDENTIFIERS AND VALUES');
+    // ========================================
+
+    const unicodeGo = 'package größe\n\n// @collab trust="READ_ONLY" owner=["équipe-paiement", "李雷"] intent="Berechnet die Größe"\nfunc Größe(ñ int) int {\n\ts := "}"\n\treturn ñ + len(s)\n}\n\nfunc 𝑓() {}\n';
+    const unicodeParsed = collab.parseAnnotationContent(unicodeGo, 'groesse.go');
+    const unicodeAnnotation = unicodeParsed.annotations[0];
+    assert(
+      unicodeParsed.errors.length === 0 &&
+        unicodeAnnotation.line_start === 4 &&
+        unicodeAnnotation.line_end === 7 &&
+        JSON.stringify(unicodeAnnotation.owner) === JSON.stringify(['équipe-paiement', '李雷']) &&
+        unicodeAnnotation.intent === 'Berechnet die Größe',
+      'Parses multibyte attribute values and scopes non-ASCII Go functions',
+      `Got: ${JSON.stringify(unicodeParsed)}`
+    );
+
+    const unicodeDecls = declarations.findDeclarations(unicodeGo, 'groesse.go').map(d => d.qualified_name);
+    assert(
+      JSON.stringify(unicodeDecls) === JSON.stringify(['Größe', '𝑓']),
+      'Finds declarations with non-ASCII and astral-plane names',
+      `Got: ${JSON.stringify(unicodeDecls)}`
+    );
+
+    // Decomposed umlaut in the file, composed in the query
+    await fs.writeFile('umlaut.py', 'class Über:\n    def gro\u0308\u00dfe(self):\n        return 1\n'.normalize('NFD'));
+    const umlaut = await declarations.findDeclarationsByName('Über.größe', 'umlaut.py');
+    assert(umlaut.length === 1 && umlaut[0].line_start === 2, 'Matches declaration names after NFC normalization');
+
+    const unicodeKey = collab.parseAnnotationContent('// @collab propriétaire=x ro\nfunction a() {}\n', 'a.ts');
+    assert(
+      unicodeKey.errors.length === 0 && unicodeKey.annotations[0].trust === 'READ_ONLY',
+      'Treats non-ASCII attribute keys as whole keys rather than stray aliases',
+      `Got: ${JSON.stringify(unicodeKey.errors)}`
+    );
+
+    // ========================================
+    section('29. TRUST BUDGETS');
+    // ========================================
+
+    const budgetBase = await collab.loadTrustConfig();
+    await fs.mkdir('budgeted/open', { recursive: true });
+    await fs.mkdir('budgeted/core', { recursive: true });
+    await fs.writeFile('budgeted/open/free.ts', 'export function free() {\n  return 1;\n}\n');
+    await fs.writeFile('budgeted/core/locked.ts', '// @collab ro\nexport function locked() {\n  return 1;\n}\n');
+    await collab.saveTrustConfig({
+      ...budgetBase,
+      policies: [{ pattern: 'budgeted/open/**', trust: 'AUTONOMOUS' }, ...budgetBase.policies],
+      budgets: [
+        { pattern: 'budgeted/**', max_autonomous_fraction: 0.25 },
+        { pattern: 'budgeted/core/**', max_autonomous_fraction: 0 },
+      ],
+    });
+
+    const budgetReport = await budget.checkBudgets(['budgeted']);
+    const [treeBudget, coreBudget] = budgetReport.budgets;
+    assert(
+      treeBudget.exceeded &&
+        treeBudget.autonomous_lines === 3 &&
+        treeBudget.total_lines === 7 &&
+        !coreBudget.exceeded &&
+        budgetReport.total_exceeded === 1,
+      'Fails budgets whose resolved AUTONOMOUS share exceeds the limit',
+      `Got: ${JSON.stringify(budgetReport.budgets)}`
+    );
+    assert(
+      JSON.stringify(treeBudget.directories.map(d => [d.directory, d.fraction])) ===
+        JSON.stringify([['budgeted/core', 0], ['budgeted/open', 1]]),
+      'Reports budget usage per directory',
+      `Got: ${JSON.stringify(treeBudget.directories)}`
+    );
+
+    await collab.saveTrustConfig({ ...budgetBase, budgets: [{ pattern: 'budgeted/**', max_autonomous_fraction: 2 }] });
+    let budgetError = '';
+    await budget.checkBudgets(['budgeted']).catch(e => { budgetError = e.message; });
+    assert(budgetError.includes('max_autonomous_fraction'), 'Rejects budgets outside 0 to 1');
+    await collab.saveTrustConfig(budgetBase);
+
+    // ========================================
+    section('30. NDJSON SCAN RECORDS');
+    // ========================================
+
+    await fs.writeFile('records.ts', '// @collab ro owner="sec"\nfunction f() {\n  return 1;\n}\n// @collab bogus\nconst g = 1;\n');
+    const records = scan.summaryRecords(await scan.summarizeFile('records.ts'));
+    assert(
+      JSON.stringify(records.map(r => r.kind)) === JSON.stringify(['file', 'region', 'region', 'error']) &&
+        records.every(r => r.file === 'records.ts') &&
+        records[1].trust === 'READ_ONLY',
+      'Flattens a file summary into one record per region and diagnostic',
+      `Got: ${JSON.stringify(records)}`
+    );
+    assert(
+      records.every(r => !collab.stableStringify(r).includes('\n')),
+      'Serializes each record on a single line'
+    );
+
+    const bannerSummary = await scan.summarizeFile('records.ts');
+    const plainBanner = scan.formatSummaryText(bannerSummary).split('\n');
+    const colorBanner = scan.formatSummaryText(bannerSummary, true);
+    assert(
+      plainBanner[0] === 'records.ts' && plainBanner[2].trim().split(/\s+/).join() === 'Lines,Trust,Owner,Intent' &&
+        /^  2-4 +READ_ONLY +sec$/.test(plainBanner[3]) && !plainBanner.join('\n').includes('\x1b[') &&
+        colorBanner.includes('\x1b[31mREAD_ONLY') && colorBanner.includes('\x1b[1mrecords.ts\x1b[0m'),
+      'Prints a table of each region with its range, level, and owner, colored only on request',
+      `Got: ${JSON.stringify({ plainBanner, colorBanner })}`
+    );
+
+    const savedNoColor = process.env.NO_COLOR;
+    delete process.env.NO_COLOR;
+    const colorOnTty = scan.useColor({ isTTY: true });
+    const colorWhenPiped = scan.useColor({ isTTY: false });
+    process.env.NO_COLOR = '1';
+    const colorWithNoColor = scan.useColor({ isTTY: true });
+    if (savedNoColor === undefined) delete process.env.NO_COLOR;
+    else process.env.NO_COLOR = savedNoColor;
+    assert(
+      colorOnTty && !colorWhenPiped && !colorWithNoColor,
+      'Colors only a terminal, and never with NO_COLOR set'
+    );
+
+    // ========================================
+    section('31. READ_ONLY SMALL HELPER ADVISORY');
+    // ========================================
+
+    await fs.writeFile(
+      'helpers.go',
+      'package helpers\n\n// @collab trust="READ_ONLY"\nfunc clamp(x int) int {\n\treturn x\n}\n\n' +
+        '// @collab trust="READ_ONLY"\nfunc Clamp(x int) int {\n\treturn x\n}\n'
+    );
+    const trustForLint = await collab.loadTrustConfig();
+    const linted = await check.checkFile(trustForLint, 'helpers.go', undefined, { lint: { read_only_helper_max_lines: 5 } });
+    const helperWarnings = linted.violations.filter(v => v.code === 'read-only-small-helper');
+    assert(
+      helperWarnings.length === 1 &&
+        helperWarnings[0].line === 4 &&
+        helperWarnings[0].severity === 'warning' &&
+        helperWarnings[0].message.includes('clamp'),
+      'Warns about READ_ONLY on small unexported Go functions only',
+      `Got: ${JSON.stringify(linted.violations)}`
+    );
+    const unlinted = await check.checkFile(trustForLint, 'helpers.go');
+    assert(!unlinted.violations.some(v => v.code === 'read-only-small-helper'), 'Reports nothing without the setting');
+
+    // ========================================
+    section('32. SCAN AT A GIT REVISION');
+    // ========================================
+
+    await fs.mkdir('history/.collab', { recursive: true });
+    process.chdir('history');
+    const gitIn = (...args) => execFileSync('git', ['-c', 'user.name=e2e', '-c', 'user.email=e2e@example.com', ...args]);
+    await fs.writeFile('.collab/config.yaml', 'aliases:\n  locked: READ_ONLY\n');
+    await fs.writeFile('old.ts', '// @collab locked owner="sec"\nfunction f() {\n  return 1;\n}\n');
+    gitIn('init', '-q');
+    gitIn('add', '-A');
+    gitIn('commit', '-qm', 'locked');
+    await fs.writeFile('old.ts', 'function f() {}\n');
+    await fs.rm('.collab/config.yaml');
+
+    const atRevision = await scan.summarizeFile('old.ts', { revision: 'HEAD' });
+    const inWorktree = await scan.summarizeFile('old.ts');
+    await fs.writeFile('uncommitted.ts', 'const u = 1;\n');
+    const listedAtRevision = await scan.selectListedFiles(['old.ts', 'uncommitted.ts', '.collab'], { revision: 'HEAD' });
+    const revisionContext = await scan.loadScanContext({ revision: 'HEAD' });
+    const fromSource = await scan.summarizeSource('old.ts', '// @collab locked\nfunction f() {}\n', { context: revisionContext });
+    process.chdir(TEST_DIR);
+    assert(
+      atRevision.regions.length === 1 &&
+        atRevision.regions[0].trust === 'READ_ONLY' &&
+        atRevision.errors.length === 0 &&
+        inWorktree.regions.length === 0,
+      'Reads files and config.yaml aliases from the revision, not the working tree',
+      `Got: ${JSON.stringify(atRevision)}`
+    );
+    assert(
+      JSON.stringify(listedAtRevision.files) === JSON.stringify(['old.ts']) &&
+        listedAtRevision.skipped.map(s => `${s.file}:${s.reason}`).join() === 'uncommitted.ts:not found,.collab:not found' &&
+        fromSource.regions[0]?.trust === 'READ_ONLY' && fromSource.errors.length === 0,
+      'Checks listed files against the revision, and summarizes with a context loaded once for the scan',
+      `Got: ${JSON.stringify({ listedAtRevision, fromSource })}`
+    );
+
+    // ========================================
+    section('33. STALE PROPOSALS');
+    // ========================================
+
+    const staleSource = 'const a = 1;\nfunction f() {\n  return a;\n}\n';
+    await fs.writeFile('stale.ts', staleSource);
+    const staleBase = await collab.captureProposalBase('stale.ts', '  return a;');
+    const staleProposal = { id: 'p1', file_path: 'stale.ts', old_code: '  return a;', base: staleBase };
+    const staleConfig = await collab.loadTrustConfig();
+    const staleCode = (source) => {
+      try {
+        proposalValidation.validateProposal(staleProposal, source, staleConfig);
+        return 'ok';
+      } catch (error) {
+        return error instanceof proposalValidation.StaleProposalError ? error.code : `unexpected: ${error.message}`;
+      }
+    };
+    assert(staleBase.line_start === 3 && staleBase.trust === 'SUPERVISED', 'Records the base region when proposing');
+    assert(staleCode(staleSource) === 'ok', 'Accepts a proposal whose base is unchanged');
+    assert(staleCode(staleSource.replace('return a;', 'return a + 1;')) === 'base-changed', 'Detects a changed base');
+    assert(staleCode('// moved\n' + staleSource) === 'region-moved', 'Detects a moved region');
+    assert(
+      staleCode(staleSource.replace('function f', '// @collab ro\nfunction f').replace('const a = 1;\n', '')) === 'trust-changed',
+      'Detects a stricter trust level on the region'
+    );
+
+    // ========================================
+    section('34. SYMBOL POLICIES');
+    // ========================================
+
+    assert(
+      collab.matchesSymbolPattern('Server.LoginHandler', '*Handler') &&
+        collab.matchesSymbolPattern('Server.LoginHandler', 'Server.*') &&
+        !collab.matchesSymbolPattern('Server.LoginHandler', 'Client.*') &&
+        !collab.matchesSymbolPattern('HandlerFactory', '*Handler'),
+      'Matches symbol patterns against trailing name segments'
+    );
+
+    await fs.writeFile(
+      'handlers.ts',
+      'export function loginHandler() {\n  return 1;\n}\n\n// @collab so\nexport function logoutHandler() {\n  return 2;\n}\n'
+    );
+    const symbolBase = await collab.loadTrustConfig();
+    const symbolConfig = { ...symbolBase, symbols: [{ pattern: '*Handler', trust: 'READ_ONLY' }] };
+    const bySymbol = await collab.getTrustLevelWithAnnotations(symbolConfig, 'handlers.ts', 2, 2);
+    const byAnnotation = await collab.getTrustLevelWithAnnotations(symbolConfig, 'handlers.ts', 7, 7);
+    const outside = await collab.getTrustLevelWithAnnotations(symbolConfig, 'handlers.ts', 4, 4);
+    assert(
+      bySymbol.level === 'READ_ONLY' && bySymbol.source === 'symbol' &&
+        byAnnotation.level === 'SUGGEST_ONLY' && byAnnotation.source === 'annotation' &&
+        outside.source !== 'symbol',
+      'Applies symbol policies below annotations and only inside matching declarations',
+      `Got: ${JSON.stringify([bySymbol, byAnnotation, outside])}`
+    );
+
+    await collab.saveTrustConfig(symbolConfig);
+    const explainedSymbols = await explain.explainPolicy('handlers.ts');
+    await collab.saveTrustConfig(symbolBase);
+    assert(
+      explainedSymbols.symbols.length === 1 &&
+        JSON.stringify(explainedSymbols.symbols[0].declarations.map(d => d.qualified_name)) ===
+          JSON.stringify(['loginHandler', 'logoutHandler']),
+      'Explains which declarations each symbol policy matches',
+      `Got: ${JSON.stringify(explainedSymbols.symbols)}`
+    );
+
+    // ========================================
+    section('35. DOCTOR');
+    // ========================================
+
+    await fs.mkdir('preflight/.collab', { recursive: true });
+    process.chdir('preflight');
+    await fs.writeFile(
+      '.collab/trust.yaml',
+      'default_trust: SUPERVISED\nextends:\n  - path: missing.yaml\npolicies:\n  - pattern: "src/**"\n    trust: LOCKED\nowners: [a]\n'
+    );
+    await fs.writeFile('.collab/config.yaml', 'pre_apply_hooks:\n  - hooks/lint.mjs\n');
+    await fs.writeFile('bad.ts', '// @collab trust=NOPE\nconst x = 1;\n');
+    const doctorReport = await doctor.runDoctorChecks(['.']);
+    process.chdir(TEST_DIR);
+    const problemsIn = (name) => doctorReport.checks.find(c => c.name === name).problems;
+    assert(
+      problemsIn('trust.yaml').some(p => p.includes('"owners"')) &&
+        problemsIn('trust.yaml').some(p => p.includes('LOCKED')) &&
+        problemsIn('extends').some(p => p.includes('missing.yaml')) &&
+        problemsIn('config.yaml').some(p => p.includes('hooks/lint.mjs')),
+      'Reports config, extends, and hook problems together',
+      `Got: ${JSON.stringify(doctorReport.checks)}`
+    );
+    assert(
+      doctorReport.checks.map(c => c.name).join() === 'trust.yaml,extends,config.yaml,annotations' &&
+        doctorReport.total_problems === doctorReport.checks.reduce((n, c) => n + c.problems.length, 0),
+      'Runs every check and totals the problems'
+    );
+
+    // ========================================
+    section('36. GENERATED CODE');
+    // ========================================
+
+    assert(
+      collab.isGeneratedSource('// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n') &&
+        collab.isGeneratedSource('// Copyright 2024\n\n# Code generated by mockgen. DO NOT EDIT.\n') &&
+        !collab.isGeneratedSource('package pb\n\n// Code generated by protoc-gen-go. DO NOT EDIT.\n') &&
+        !collab.isGeneratedSource('// Code generated by hand, edit freely\n'),
+      'Recognizes the generated-code header only before the first non-comment line'
+    );
+
+    await fs.writeFile('types.pb.go', '// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n\n// @collab auto\nfunc F() {}\n');
+    const generatedBase = await collab.loadTrustConfig();
+    const generatedLevel = await collab.getTrustLevelWithAnnotations(generatedBase, 'types.pb.go', 6, 6);
+    const unguarded = await collab.getTrustLevelWithAnnotations({ ...generatedBase, generated_trust: false }, 'types.pb.go', 6, 6);
+    assert(
+      generatedLevel.level === 'READ_ONLY' && generatedLevel.source === 'generated' &&
+        unguarded.level === 'AUTONOMOUS' && unguarded.source === 'annotation',
+      'Generated files are READ_ONLY over annotations unless generated_trust is false',
+      `Got: ${JSON.stringify([generatedLevel, unguarded])}`
+    );
+    assert(
+      collab.validateGeneratedTrust({ ...generatedBase, generated_trust: 'LOCKED' }) !== null &&
+        collab.validateGeneratedTrust({ ...generatedBase, generated_trust: 'SUGGEST_ONLY' }) === null,
+      'Validates generated_trust'
+    );
+    assert(
+      reasons.reasonCodes().some(r => r.code === 'generated-file-edit' && r.category === 'authorship'),
+      'Documents the generated-file-edit reason code'
+    );
+
+    // ========================================
+    section('37. FORMATTING ANNOTATIONS IN PLACE');
+    // ========================================
+
+    const unformatted = [
+      'const a = 1;',
+      "//   @collab   owner=alice  ro intent='keeps totals'",
+      'function f() {',
+      '  return a; // @collab so',
+      '}',
+      '// @collab:begin owner=bob trust=SUGGEST_ONLY',
+      'const b = 2;',
+      '// @collab:end',
+      '// @collab trust=NOPE',
+      '',
+    ].join('\r\n');
+    const formatted = collab.formatAnnotationComments(unformatted, 'a.ts');
+    assert(
+      formatted.content === [
+        'const a = 1;',
+        '// @collab trust="READ_ONLY" owner="alice" intent="keeps totals"',
+        'function f() {',
+        '  return a; // @collab so',
+        '}',
+        '// @collab:begin trust="SUGGEST_ONLY" owner="bob"',
+        'const b = 2;',
+        '// @collab:end',
+        '// @collab trust=NOPE',
+        '',
+      ].join('\r\n'),
+      'Rewrites whole-line annotations into canonical form, keeping their layout and line endings',
+      `Got: ${JSON.stringify(formatted.content)}`
+    );
+    assert(
+      formatted.changed === 2 && JSON.stringify(formatted.skipped.map(s => s.line)) === JSON.stringify([4, 9]),
+      'Leaves trailing and invalid annotations as written and reports them'
+    );
+    assert(
+      collab.formatAnnotationComments('# @collab owner=x\n# @collab so\ndef f():\n    pass\n', 'a.py').content ===
+        '# @collab trust="SUGGEST_ONLY"\n# @collab owner="x"\ndef f():\n    pass\n',
+      'Keeps multi-line annotations one attribute per line, in canonical order'
+    );
+    assert(
+      collab.formatAnnotationComments(formatted.content, 'a.ts').changed === 0,
+      'Formatting is idempotent'
+    );
+    assert(
+      collab.formatAnnotationComments('// @collab ro reviewer=sam\nfunction f() {}\n', 'a.ts').skipped[0]?.reason.includes('reviewer'),
+      'Never drops attributes it does not know'
+    );
+    assert(
+      collab.formatAnnotationComments('// @collab so\n// @collab owner=x intent=y\nfunction f() {}\n', 'a.ts', { preserveLineCount: true })
+        .skipped.some(s => s.line === 1),
+      'Can leave annotations alone rather than shift line numbers'
+    );
+
+    // ========================================
+    section('38. HTTP GATEWAY');
+    // ========================================
+
+    const levelsByFile = { 'free.ts': 'AUTONOMOUS', 'review.ts': 'SUGGEST_ONLY', 'locked.ts': 'READ_ONLY' };
+    const gatewayNotified = [];
+    const gatewayOptions = {
+      resolver: { async resolve(file) { return { level: levelsByFile[file], source: 'policy' }; } },
+      notifier: { async proposalCreated(proposal) { gatewayNotified.push(proposal.id); } },
+    };
+    await fs.writeFile('review.ts', 'const a = 1;\nconst b = 2;\n');
+    const decisions = [];
+    for (const file of ['free.ts', 'review.ts', 'locked.ts']) {
+      decisions.push(await gateway.decideEdit({ file_path: file, line_start: 2, content: 'const b = 3;' }, gatewayOptions));
+    }
+    const gatewayProposal = await collab.loadProposal(decisions[1].proposal_id ?? '');
+    assert(
+      decisions.map(d => d.decision).join() === 'allow,propose,deny' &&
+        gatewayProposal?.old_code === 'const b = 2;' && gatewayProposal?.new_code === 'const b = 3;' &&
+        JSON.stringify(gatewayNotified) === JSON.stringify([decisions[1].proposal_id]),
+      'Decides edits and files a proposal for SUGGEST_ONLY code',
+      `Got: ${JSON.stringify(decisions)}`
+    );
+
+    const gatewayServer = http.createServer(gateway.createEditGateway(gatewayOptions));
+    await new Promise(resolve => gatewayServer.listen(0, '127.0.0.1', resolve));
+    const gatewayUrl = `http://127.0.0.1:${gatewayServer.address().port}/`;
+    const callGateway = async (body) => (await fetch(gatewayUrl, { method: 'POST', body: JSON.stringify(body) }));
+    const allowed = await callGateway({ file_path: 'free.ts', content: 'x' });
+    const outsideProject = await callGateway({ file_path: '../outside.ts', content: 'x' });
+    const badRange = await callGateway({ file_path: 'free.ts', line_start: 0, content: 'x' });
+    gatewayServer.close();
+    assert(
+      allowed.status === 200 && (await allowed.json()).decision === 'allow' &&
+        outsideProject.status === 400 && badRange.status === 400,
+      'Serves decisions over HTTP and rejects malformed requests'
+    );
+
+    // A resolver that ignores the signal: the decision must still stop once the client has gone
+    let resolving;
+    const resolveStarted = new Promise(resolve => { resolving = resolve; });
+    const abandonNotified = [];
+    const abandonHandler = gateway.createEditGateway({
+      resolver: {
+        async resolve(file, lineStart, lineEnd, signal) {
+          resolving();
+          await new Promise(resolve => signal.addEventListener('abort', resolve, { once: true }));
+          return { level: 'SUGGEST_ONLY', source: 'policy' };
+        },
+      },
+      notifier: { async proposalCreated(proposal) { abandonNotified.push(proposal.id); } },
+    });
+    let abandonHandled;
+    const abandonServer = http.createServer((req, res) => { abandonHandled = abandonHandler(req, res); });
+    await new Promise(resolve => abandonServer.listen(0, '127.0.0.1', resolve));
+    const proposalsBeforeAbandon = (await collab.loadProposals()).length;
+    const abandonedRequest = http.request({ host: '127.0.0.1', port: abandonServer.address().port, method: 'POST' });
+    abandonedRequest.on('error', () => {});
+    abandonedRequest.end(JSON.stringify({ file_path: 'review.ts', line_start: 1, content: 'const a = 0;' }));
+    await resolveStarted;
+    abandonedRequest.destroy();
+    await abandonHandled;
+    abandonServer.close();
+    assert(
+      (await collab.loadProposals()).length === proposalsBeforeAbandon && abandonNotified.length === 0,
+      'A client disconnecting mid-decision aborts it before any proposal is filed'
+    );
+
+    await fs.writeFile('.collab/config.yaml', 'suggest:\n  auto_approve_trivial: true\n');
+    const proposalsBeforeTrivial = (await collab.loadProposals()).length;
+    const trivialEdit = await gateway.decideEdit(
+      { file_path: 'review.ts', line_start: 2, content: 'const b = 2; // unchanged' }, gatewayOptions
+    );
+    const substantiveEdit = await gateway.decideEdit({ file_path: 'review.ts', line_start: 2, content: 'const b = 4;' }, gatewayOptions);
+    const newFileEdit = await gateway.decideEdit({ file_path: 'review-new.ts', content: '// comment' }, {
+      ...gatewayOptions,
+      resolver: { async resolve() { return { level: 'SUGGEST_ONLY', source: 'policy' }; } },
+    });
+    await fs.rm('.collab/config.yaml');
+    assert(
+      trivialEdit.decision === 'allow' && trivialEdit.auto_approved === true && trivialEdit.proposal_id === undefined &&
+        substantiveEdit.decision === 'propose' && substantiveEdit.auto_approved === undefined &&
+        newFileEdit.decision === 'propose' &&
+        (await collab.loadProposals()).length === proposalsBeforeTrivial + 2,
+      'With suggest.auto_approve_trivial, allows trivial SUGGEST_ONLY edits and proposes the rest, as the pre-edit hook does',
+      `Got: ${JSON.stringify({ trivialEdit, substantiveEdit, newFileEdit })}`
+    );
+
+    // ========================================
+    section('39. REQUIRED TESTS');
+    // ========================================
+
+    assert(
+      collab.parseAnnotationContent('// @collab requires_tests="maybe"\nfunction f() {}\n', 'a.ts').errors[0]?.code === 'invalid-requires-tests',
+      'Rejects requires_tests values other than true and false'
+    );
+    assert(
+      JSON.stringify(diff.testPatternsFor('pkg/ledger.go')) === JSON.stringify(['pkg/ledger_test.go']) &&
+        diff.testPatternsFor('ledger.py', { py: ['tests/{name}_check.py'] })[0] === 'tests/ledger_check.py',
+      'Maps source files to test patterns, with config overrides'
+    );
+
+    await fs.mkdir('tested/pkg', { recursive: true });
+    process.chdir('tested');
+    const gitTested = (...args) => execFileSync('git', ['-c', 'user.name=e2e', '-c', 'user.email=e2e@example.com', ...args]);
+    const ledgerSource = 'package pkg\n\n// @collab requires_tests="true"\nfunc Settle() int {\n\treturn 1\n}\n\nfunc Other() int {\n\treturn 2\n}\n';
+    await fs.writeFile('pkg/ledger.go', ledgerSource);
+    await fs.writeFile('pkg/ledger_test.go', 'package pkg\n');
+    gitTested('init', '-q');
+    gitTested('add', '-A');
+    gitTested('commit', '-qm', 'base');
+
+    await fs.writeFile('pkg/ledger.go', ledgerSource.replace('return 2', 'return 3'));
+    const outsideRegion = await diff.diffAnnotations('HEAD');
+    await fs.writeFile('pkg/ledger.go', ledgerSource.replace('return 1', 'return 4'));
+    const untested = await diff.diffAnnotations('HEAD');
+    await fs.writeFile('pkg/ledger_test.go', 'package pkg\n\n// TestSettle\n');
+    const withTests = await diff.diffAnnotations('HEAD');
+    process.chdir(TEST_DIR);
+    assert(
+      outsideRegion.summary.tests_missing === 0 &&
+        untested.summary.tests_missing === 1 &&
+        untested.changes[0].priority === 'high' &&
+        untested.changes[0].expected_tests[0] === 'pkg/ledger_test.go' &&
+        withTests.summary.tests_missing === 0,
+      'Flags edits to requires_tests regions unless their tests change too',
+      `Got: ${JSON.stringify(untested.changes)}`
+    );
+
+    // ========================================
+    section('40. REGIONS IN A BYTE RANGE');
+    // ========================================
+
+    const selectionSource = '// @collab so\nclass A {\n  // @collab ro\n  f() {\n    return "é";\n  }\n}\n';
+    const byteAt = (text) => Buffer.byteLength(selectionSource.slice(0, selectionSource.indexOf(text)));
+    const selected = collab.regionsInByteRange(selectionSource, 'a.ts', byteAt('return'), byteAt('return') + 3);
+    assert(
+      selected.map(a => a.trust).join() === 'READ_ONLY,SUGGEST_ONLY',
+      'Returns overlapping annotations, innermost first'
+    );
+    const afterAccent = byteAt('é') + Buffer.byteLength('é";\n  }');
+    assert(
+      collab.regionsInByteRange(selectionSource, 'a.ts', afterAccent, afterAccent + 1).map(a => a.trust).join() ===
+        'READ_ONLY,SUGGEST_ONLY' &&
+        collab.regionsInByteRange(selectionSource, 'a.ts', byteAt('}\n}') + 2, byteAt('}\n}') + 2).map(a => a.trust).join() ===
+          'SUGGEST_ONLY' &&
+        collab.regionsInByteRange(selectionSource, 'a.ts', 0, 3).length === 0,
+      'Counts UTF-8 bytes, partial overlaps, and cursors'
+    );
+
+    // ========================================
+    section('41. LOCALIZED MESSAGES');
+    // ========================================
+
+    assert(
+      messages.detectLocale(undefined, { LANG: 'de_DE.UTF-8' }) === 'de-DE' &&
+        messages.detectLocale('fr', { LANG: 'de_DE.UTF-8' }) === 'fr' &&
+        messages.detectLocale(undefined, { LANG: 'C' }) === 'en',
+      'Selects the locale from config, then the environment'
+    );
+    const englishTemplates = messages.englishCatalog();
+    assert(
+      reasons.reasonCodes().every(r => englishTemplates[r.code]),
+      'Ships an English template for every reason code'
+    );
+    await fs.mkdir('.collab/locales', { recursive: true });
+    await fs.writeFile('.collab/locales/de.yaml', 'unknown-trust-level: \'Unbekannte Vertrauensstufe "{value}"\'\n');
+    messages.registerMessageCatalog('de-AT', { 'invalid-redact': 'Ungültig: redact="{value}" {missing}' });
+    const austrian = await messages.loadLocalizer({ locale: 'de_AT' });
+    const localizedErrors = collab.parseAnnotationContent(
+      '// @collab trust="READONLY" redact=maybe min_approvals=0\nfunction f() {}\n', 'a.ts'
+    ).errors;
+    const localized = messages.localizeMessages(localizedErrors, austrian).map(e => e.message);
+    await fs.rm('.collab/locales', { recursive: true });
+    assert(
+      localized[0] === 'Unbekannte Vertrauensstufe "READONLY"' &&
+        localized[1] === localizedErrors[1].message &&
+        localized[2] === localizedErrors[2].message,
+      'Localizes by code, falling back to English for missing entries and params',
+      `Got: ${JSON.stringify(localized)}`
+    );
+
+    // ========================================
+    section('42. CONSTRAINT QUARANTINE');
+    // ========================================
+
+    await fs.mkdir('drift', { recursive: true });
+    await fs.writeFile(
+      'drift/price.ts',
+      '// @collab so constraints=["no floating point", "pure"]\nfunction price(a) {\n  return parseFloat(a);\n}\n'
+    );
+    const verifiedRegions = [];
+    verifyConstraintsModule.registerConstraintVerifier(function noFloats(region) {
+      verifiedRegions.push(region.constraint);
+      if (region.constraint === 'no floating point' && region.code.includes('parseFloat')) {
+        throw new Error('uses parseFloat');
+      }
+    });
+    const firstRun = await verifyConstraintsModule.verifyConstraints(['drift'], { now: new Date('2026-01-01T00:00:00Z') });
+    await verifyConstraintsModule.saveQuarantine(firstRun);
+    const secondRun = await verifyConstraintsModule.verifyConstraints(['drift'], { now: new Date('2026-01-02T00:00:00Z') });
+    await fs.writeFile('drift/price.ts', '// @collab so constraints=["no floating point"]\nfunction price(a) {\n  return a;\n}\n');
+    const fixedRun = await verifyConstraintsModule.verifyConstraints(['drift']);
+    verifyConstraintsModule.clearConstraintVerifiers();
+    assert(
+      JSON.stringify(verifiedRegions.slice(0, 2)) === JSON.stringify(['no floating point', 'pure']) &&
+        firstRun.quarantined.length === 1 &&
+        firstRun.quarantined[0].verifier === 'noFloats' &&
+        firstRun.quarantined[0].message === 'uses parseFloat',
+      'Quarantines regions whose code breaks a constraint',
+      `Got: ${JSON.stringify(firstRun)}`
+    );
+    assert(
+      secondRun.quarantined[0]?.first_seen === '2026-01-01T00:00:00.000Z' && fixedRun.quarantined.length === 0,
+      'Keeps the first quarantine time and releases fixed regions'
+    );
+    let noVerifiers = '';
+    await verifyConstraintsModule.verifyConstraints(['drift']).catch(error => { noVerifiers = error.message; });
+    assert(noVerifiers.includes('No constraint verifiers'), 'Refuses to run without verifiers');
+    await fs.rm('.collab/quarantine.json', { force: true });
+
+    // ========================================
+    section('43. EXTRACTION OUT OF READ_ONLY CODE');
+    // ========================================
+
+    const lockedBase = [
+      'package pay',
+      '',
+      '// @collab ro',
+      'func Charge(amount int) int {',
+      '\tfee := amount / 10',
+      '\tif fee < 1 {',
+      '\t\tfee = 1',
+      '\t}',
+      '\treturn amount + fee',
+      '}',
+      '',
+    ].join('\n');
+    const lockedHead = [
+      'package pay',
+      '',
+      '// @collab ro',
+      'func Charge(amount int) int {',
+      '\treturn amount + computeFee(amount)',
+      '}',
+      '',
+      '// @collab auto',
+      'func computeFee(amount int) int {',
+      '\treturn 0',
+      '}',
+      '',
+    ].join('\n');
+    const noConfig = { default_trust: 'SUPERVISED', policies: [] };
+    const extracted = diff.findExtractedLogic(
+      [{ path: 'pay/charge.go', base: lockedBase, head: lockedHead }], noConfig, noConfig, collab.buildTrustAliases()
+    );
+    assert(
+      extracted.length === 1 &&
+        extracted[0].kind === 'possible_extraction' &&
+        extracted[0].priority === 'normal' &&
+        extracted[0].line === 4 &&
+        extracted[0].lines_before === 7 && extracted[0].lines_after === 3 &&
+        extracted[0].helper?.name === 'computeFee' && extracted[0].helper?.line === 9 && extracted[0].helper?.trust === 'AUTONOMOUS',
+      'Warns when a READ_ONLY function shrinks and calls a new permissive helper',
+      `Got: ${JSON.stringify(extracted)}`
+    );
+    const lockedHelper = diff.findExtractedLogic(
+      [{ path: 'pay/charge.go', base: lockedBase, head: lockedHead.replace('// @collab auto', '// @collab ro') }],
+      noConfig, noConfig, collab.buildTrustAliases()
+    );
+    const existingHelper = diff.findExtractedLogic(
+      [{ path: 'pay/charge.go', base: lockedBase + lockedHead.split('\n').slice(7).join('\n'), head: lockedHead }],
+      noConfig, noConfig, collab.buildTrustAliases()
+    );
+    assert(
+      lockedHelper.length === 0 && existingHelper.length === 0,
+      'Ignores helpers that are locked too or already existed'
+    );
+
+    // ========================================
+    section('44. GO PACKAGE DEFAULTS');
+    // ========================================
+
+    const docSource = '// Package billing computes invoices.\n//\n// @collab:package trust="SUPERVISED" owner="platform"\npackage billing\n';
+    const billingDefault = collab.parsePackageDirective(docSource, 'billing/doc.go');
+    assert(
+      billingDefault?.trust === 'SUPERVISED' && billingDefault.owner === 'platform' && billingDefault.line === 3,
+      'Parses the @collab:package directive in doc.go',
+      `Got: ${JSON.stringify(billingDefault)}`
+    );
+    const packageConfig = { default_trust: 'AUTONOMOUS', policies: [{ pattern: 'billing/tax/**', trust: 'READ_ONLY' }] };
+    const fromPackage = collab.resolveTrustWithAnnotations(
+      packageConfig, 'billing/invoice.go', [], undefined, undefined, [], false, billingDefault
+    );
+    const fromPolicy = collab.resolveTrustWithAnnotations(
+      packageConfig, 'billing/tax/rate.go', [], undefined, undefined, [], false, billingDefault
+    );
+    const invoiceSource = 'package billing\n\n// @collab auto\nfunc Total() int {\n\treturn 0\n}\n';
+    const fromAnnotation = collab.resolveTrustWithAnnotations(
+      packageConfig, 'billing/invoice.go', collab.parseAnnotationContent(invoiceSource, 'billing/invoice.go').annotations,
+      4, 4, [], false, billingDefault
+    );
+    assert(
+      fromPackage.level === 'SUPERVISED' && fromPackage.source === 'package' && fromPackage.owner === 'platform' &&
+        fromPackage.reason === 'Package default (billing/doc.go:3)',
+      'Applies the package default below path policies',
+      `Got: ${JSON.stringify(fromPackage)}`
+    );
+    assert(
+      fromPolicy.level === 'READ_ONLY' && fromAnnotation.level === 'AUTONOMOUS',
+      'Path policies and annotations override the package default'
+    );
+    const misplaced = collab.parseAnnotationContent(
+      '// @collab:package trust="SUPERVISED"\npackage billing\n', 'billing/invoice.go'
+    );
+    assert(
+      misplaced.errors.some(e => e.code === 'misplaced-package-directive'),
+      'Rejects @collab:package outside doc.go'
+    );
+    await fs.mkdir('billing', { recursive: true });
+    await fs.writeFile('billing/doc.go', docSource);
+    await fs.writeFile('billing/invoice.go', 'package billing\n');
+    const billingExplanation = await explain.explainPolicy('billing/invoice.go');
+    assert(
+      billingExplanation.package_default?.file.replace(/\\/g, '/') === 'billing/doc.go' &&
+        explain.formatExplanationText(billingExplanation).includes('Package default (below every policy):'),
+      'explain-policy reports the package default and where it comes from'
+    );
+
+    // ========================================
+    section('45. BATCH PROPOSALS');
+    // ========================================
+
+    await fs.mkdir('batch', { recursive: true });
+    await fs.writeFile(
+      'batch/a.ts',
+      '// @collab so owner="payments-team"\nfunction charge() {\n  return 1;\n}\n\n// @collab so owner="auth-team"\nfunction login() {\n  return 2;\n}\n'
+    );
+    await fs.writeFile('batch/b.ts', '// @collab so owner="payments-team"\nfunction refund() {\n  return 3;\n}\n');
+    const batchEdit = (file, code) => ({ file_path: file, description: 'Rename', old_code: code, new_code: code.replace('return', 'return +'), confidence: 0.9 });
+    const batches = await collab.createBatchProposals([
+      batchEdit('batch/a.ts', '  return 1;'),
+      batchEdit('batch/a.ts', '  return 2;'),
+      batchEdit('batch/b.ts', '  return 3;'),
+    ]);
+    assert(
+      batches.length === 2 &&
+        batches[0].owners?.[0] === 'payments-team' && batches[0].proposals.length === 2 &&
+        batches[1].owners?.[0] === 'auth-team' && batches[1].proposals.length === 1,
+      'Groups proposals into one batch per owner',
+      `Got: ${JSON.stringify(batches.map(b => [b.owners, b.proposals.length]))}`
+    );
+    const batchedProposal = await collab.loadProposal(batches[0].proposals[1].id);
+    assert(
+      batchedProposal?.batch === batches[0].id && batchedProposal.status === 'pending' && batchedProposal.file_path === 'batch/b.ts',
+      'Each region is saved as its own proposal carrying the batch id'
+    );
+    for (const batch of batches) {
+      for (const p of batch.proposals) await collab.deleteProposal(p.id);
+    }
+
+    // ========================================
+    section('46. ANNOTATIONS IN BLOCK COMMENT BODIES');
+    // ========================================
+
+    const javadocSource = [
+      'class Ledger {',
+      '  /**',
+      '   * Converts a charge into ledger entries.',
+      '   *',
+      '   * @collab trust="READ_ONLY" owner="ledger-team"',
+      '   * @collab intent="Double-entry bookkeeping"',
+      '   * @param charge the settled charge',
+      '   */',
+      '  List<Entry> toEntries(Charge charge) {',
+      '    return List.of();',
+      '  }',
+      '',
+      '  /* @collab:begin so */',
+      '  int fee = 1;',
+      '  /* @collab:end */',
+      '}',
+    ].join('\n');
+    const javadoc = collab.parseAnnotationContent(javadocSource, 'Ledger.java');
+    assert(
+      javadoc.annotations[0]?.trust === 'READ_ONLY' &&
+        javadoc.annotations[0].owner === 'ledger-team' &&
+        javadoc.annotations[0].intent === 'Double-entry bookkeeping' &&
+        javadoc.annotations[0].line_start === 9 && javadoc.annotations[0].line_end === 11,
+      'Reads * @collab lines inside a Javadoc comment',
+      `Got: ${JSON.stringify(javadoc.annotations)}`
+    );
+    assert(
+      javadoc.annotations[1]?.trust === 'SUGGEST_ONLY' && javadoc.errors.length === 0,
+      'Ignores the closing */ on block annotation lines',
+      `Got: ${JSON.stringify(javadoc.errors)}`
+    );
+
+    // ========================================
+    section('47. POLICY SIMULATION');
+    // ========================================
+
+    await fs.mkdir('whatif/pay', { recursive: true });
+    await fs.writeFile('whatif/pay/charge.ts', 'function a() {\n  return 1;\n}\n\n// @collab ro\nfunction b() {\n  return 2;\n}\n');
+    await fs.writeFile('whatif/util.py', 'x = 1\ny = 2\n');
+    const currentTrust = await fs.readFile('.collab/trust.yaml', 'utf-8').catch(() => null);
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUPERVISED\npolicies: []\n');
+    await fs.writeFile(
+      'whatif/trust.new.yaml',
+      'default_trust: AUTONOMOUS\npolicies:\n  - pattern: "whatif/pay/**"\n    trust: SUGGEST_ONLY\n    reason: Payments code\n'
+    );
+    const simulation = await simulate.simulatePolicy(['whatif'], { config: 'whatif/trust.new.yaml' });
+    assert(
+      simulation.changes.length === 2 &&
+        simulation.changes[0].file.endsWith('charge.ts') &&
+        simulation.changes[0].line_start === 1 && simulation.changes[0].line_end === 5 &&
+        simulation.changes[0].direction === 'tighter' && simulation.changes[0].reason_after === 'Payments code' &&
+        simulation.changes[1].direction === 'looser' && simulation.changes[1].trust_after === 'AUTONOMOUS',
+      'Reports regions that become tighter or looser under a candidate config',
+      `Got: ${JSON.stringify(simulation.changes)}`
+    );
+    assert(
+      simulation.summary.lines_tighter === 5 && simulation.summary.lines_looser === 2 && simulation.summary.files_changed === 2,
+      'Summarizes the blast radius of a policy change'
+    );
+    let missingCandidate = '';
+    await simulate.simulatePolicy(['whatif'], { config: 'whatif/none.yaml' }).catch(error => { missingCandidate = error.message; });
+    assert(missingCandidate.includes('Cannot read whatif/none.yaml'), 'Fails when the candidate config is missing');
+    if (currentTrust === null) {
+      await fs.rm('.collab/trust.yaml');
+    } else {
+      await fs.writeFile('.collab/trust.yaml', currentTrust);
+    }
+
+    // ========================================
+    section('48. STRUCTURED LOGGING');
+    // ========================================
+
+    const logTime = new Date('2026-01-01T00:00:00Z');
+    assert(
+      logging.formatLogRecord('text', 'info', 'trust check', { file: 'src/a b.ts', trust: 'READ_ONLY' }, logTime) ===
+        'time=2026-01-01T00:00:00.000Z level=INFO msg="trust check" file="src/a b.ts" trust=READ_ONLY',
+      'Formats text log records as key=value pairs'
+    );
+    const jsonRecord = JSON.parse(logging.formatLogRecord('json', 'debug', 'x', { level: 'spoofed', n: 1 }, logTime));
+    assert(jsonRecord.level === 'DEBUG' && jsonRecord.n === 1, 'JSON records keep their own fields over attributes');
+    const logLines = [];
+    const remainingArgs = logging.applyLogArgs(['check', '--log-level=info', '--log-format=json', 'src']);
+    logging.configureLogging({ write: line => logLines.push(JSON.parse(line)) });
+    logging.log.debug('hidden');
+    await check.checkFiles(['whatif']);
+    let badLevel = '';
+    try { logging.applyLogArgs(['--log-level=loud']); } catch (error) { badLevel = error.message; }
+    logging.resetLogging();
+    assert(
+      JSON.stringify(remainingArgs) === JSON.stringify(['check', 'src']) &&
+        logLines.length === 1 && logLines[0].msg === 'check complete' && logLines[0].files === 2,
+      'Applies --log-level and --log-format and filters records below the level',
+      `Got: ${JSON.stringify(logLines)}`
+    );
+    assert(badLevel.includes('Invalid --log-level'), 'Rejects unknown log levels');
+
+    // ========================================
+    section('49. GO TYPE ALIASES AND EMBEDDED FIELDS');
+    // ========================================
+
+    const claimsSource = [
+      'package auth',
+      '',
+      '// @collab ro',
+      'type UserID = string',
+      '',
+      'type Claims struct {',
+      '\tUserID UserID `json:"user_id"`',
+      '\t// @collab trust="READ_ONLY" owner="security-team"',
+      '\tjwt.RegisteredClaims',
+      '\t// @collab so',
+      '\t*Base `json:",inline"`',
+      '\tRoles []string',
+      '}',
+    ].join('\n');
+    const claimsRegions = collab.parseAnnotationContent(claimsSource, 'claims.go').annotations;
+    assert(
+      claimsRegions.length === 3 &&
+        claimsRegions[0].line_start === 4 && claimsRegions[0].line_end === 4 &&
+        claimsRegions[1].line_start === 9 && claimsRegions[1].line_end === 9 && claimsRegions[1].owner === 'security-team' &&
+        claimsRegions[2].line_start === 11 && claimsRegions[2].line_end === 11,
+      'Annotations govern exactly a type alias or an embedded field line',
+      `Got: ${JSON.stringify(claimsRegions)}`
+    );
+    const claimsDeclarations = declarations.findDeclarations(claimsSource, 'claims.go').map(d => `${d.qualified_name}:${d.line_start}-${d.line_end}`);
+    assert(
+      JSON.stringify(claimsDeclarations) ===
+        JSON.stringify(['UserID:4-4', 'Claims:6-13', 'Claims.RegisteredClaims:9-9', 'Claims.Base:11-11']),
+      'Embedded fields are declarations named Type.Field',
+      `Got: ${JSON.stringify(claimsDeclarations)}`
+    );
+
+    // ========================================
+    section('50. OVERLAPPING POLICY GLOBS');
+    // ========================================
+
+    const overlapping = {
+      default_trust: 'SUPERVISED',
+      policies: [
+        { pattern: 'internal/**', trust: 'AUTONOMOUS' },
+        { pattern: 'internal/crypto/**', trust: 'READ_ONLY' },
+        { pattern: 'svc/*/api.ts', trust: 'AUTONOMOUS' },
+        { pattern: 'svc/api/*.ts', trust: 'SUGGEST_ONLY' },
+      ],
+    };
+    const specific = collab.resolveTrustWithAnnotations(overlapping, 'internal/crypto/keys.go', []);
+    const general = collab.resolveTrustWithAnnotations(overlapping, 'internal/log/log.go', []);
+    assert(
+      specific.level === 'READ_ONLY' && specific.pattern === 'internal/crypto/**' &&
+        general.level === 'AUTONOMOUS' && general.pattern === 'internal/**',
+      'The most specific matching glob wins regardless of order',
+      `Got: ${JSON.stringify([specific, general])}`
+    );
+    const tiedMatch = collab.findMatchingPolicy(overlapping.policies, 'svc/api/api.ts');
+    assert(
+      tiedMatch.policy.trust === 'SUGGEST_ONLY' && tiedMatch.ties.length === 1 && tiedMatch.ties[0].pattern === 'svc/*/api.ts',
+      'Equally specific globs with different trust tie, and the more restrictive applies'
+    );
+    await fs.mkdir('svc/api', { recursive: true });
+    await fs.writeFile('svc/api/api.ts', 'export const x = 1;\n');
+    const tiedCheck = await check.checkFile(overlapping, 'svc/api/api.ts', collab.buildTrustAliases());
+    assert(
+      tiedCheck.violations.some(v => v.code === 'ambiguous-policy' && v.severity === 'error'),
+      'check reports ambiguous policies as errors',
+      `Got: ${JSON.stringify(tiedCheck.violations)}`
+    );
+
+    // ========================================
+    section('51. ORPHANED OWNERS');
+    // ========================================
+
+    assert(
+      JSON.stringify(auditOwnersModule.parseRoster('# owners\n*.go @acme/go-team @alice\n/docs/ docs@acme.com\n', 'CODEOWNERS')) ===
+        JSON.stringify(['@acme/go-team', '@alice', 'docs@acme.com']) &&
+        JSON.stringify(auditOwnersModule.parseRoster('- payments-team\nauth-team # core\n', 'teams.yaml')) ===
+          JSON.stringify(['payments-team', 'auth-team']),
+      'Reads owners from CODEOWNERS and plain rosters'
+    );
+    await fs.mkdir('owned', { recursive: true });
+    await fs.writeFile(
+      'owned/pay.ts',
+      '// @collab ro owner="@acme/Payments-Team"\nfunction a() {\n  return 1;\n}\n\n// @collab so owner=["ledger-team", "auth-team"]\nfunction b() {\n  return 2;\n}\n'
+    );
+    await fs.writeFile('owned/teams.txt', 'payments-team\nauth-team\n');
+    const ownerAudit = await auditOwnersModule.auditOwners(['owned'], { roster: 'owned/teams.txt' });
+    assert(
+      ownerAudit.orphaned.length === 1 &&
+        ownerAudit.orphaned[0].owner === 'ledger-team' &&
+        ownerAudit.orphaned[0].regions.length === 1 &&
+        ownerAudit.orphaned[0].regions[0].line_start === 7 && ownerAudit.orphaned[0].regions[0].trust === 'SUGGEST_ONLY',
+      'Lists owners missing from the roster with the regions they govern',
+      `Got: ${JSON.stringify(ownerAudit)}`
+    );
+    let noRoster = '';
+    await auditOwnersModule.auditOwners(['owned'], { roster: 'owned/none.txt' }).catch(error => { noRoster = error.message; });
+    assert(noRoster.includes('Cannot read owned/none.txt'), 'Fails when the roster is missing');
+
+    // ========================================
+    section('52. PROPOSAL LIFECYCLE');
+    // ========================================
+
+    await fs.mkdir('lifecycle', { recursive: true });
+    await fs.writeFile('lifecycle/rate.ts', 'export const rate = 1;\n');
+    const lifecycleProposal = {
+      id: collab.generateId(),
+      created_at: new Date().toISOString(),
+      author: 'claude',
+      status: 'pending',
+      file_path: 'lifecycle/rate.ts',
+      description: 'Raise the rate',
+      rationale: 'Rates went up',
+      old_code: 'export const rate = 1;',
+      new_code: 'export const rate = 2;',
+      confidence: 0.9,
+      risks: [],
+      tests_needed: [],
+      history: [{ status: 'pending', at: new Date().toISOString(), by: 'claude' }],
+    };
+    await collab.saveProposal(lifecycleProposal);
+    const lifecycleOutcome = await proposalsModule.approveProposal(lifecycleProposal, 'alice');
+    const approvedOnDisk = await collab.loadProposal(lifecycleProposal.id);
+    assert(
+      lifecycleOutcome.status === 'approved' && approvedOnDisk.status === 'approved' &&
+        approvedOnDisk.history.map(h => h.status).join(',') === 'pending,approved' &&
+        approvedOnDisk.history[1].by === 'alice',
+      'Approving persists the status and appends to the history',
+      `Got: ${JSON.stringify({ lifecycleOutcome, approvedOnDisk })}`
+    );
+    collab.transitionProposal(approvedOnDisk, 'applied', { by: 'alice' });
+    let finalError = '';
+    try {
+      collab.transitionProposal(approvedOnDisk, 'rejected');
+    } catch (error) {
+      finalError = error instanceof collab.ProposalTransitionError ? error.message : '';
+    }
+    assert(
+      approvedOnDisk.status === 'applied' && finalError.includes('applied is final'),
+      'Applied proposals are final'
+    );
+    const rejectedProposal = { ...lifecycleProposal, id: collab.generateId(), status: 'pending', history: [] };
+    collab.transitionProposal(rejectedProposal, 'rejected', { reason: 'Not now' });
+    let applyRejectedError = '';
+    try {
+      collab.transitionProposal(rejectedProposal, 'applied');
+    } catch (error) {
+      applyRejectedError = error.message;
+    }
+    let approveRejectedError = '';
+    await proposalsModule.approveProposal(rejectedProposal, 'bob').catch(error => { approveRejectedError = error.message; });
+    assert(
+      applyRejectedError.includes('from rejected to applied') && approveRejectedError.includes('it is rejected') &&
+        rejectedProposal.history[0].reason === 'Not now',
+      'Rejected proposals cannot be approved or applied'
+    );
+    await collab.deleteProposal(lifecycleProposal.id);
+
+    // ========================================
+    section('53. TRAILING NOTES');
+    // ========================================
+
+    const noted = collab.parseAnnotationContent(
+      '// @collab trust="READ_ONLY" // locked for audit\nfunction a() {\n  return 1;\n}\n\n' +
+        '// @collab so owner="ledger" pending the Q3 rotation\nfunction b() {\n  return 2;\n}\n\n' +
+        '// @collab intent="see http://x # y" auto\nfunction c() {\n  return 3;\n}\n',
+      'noted.ts'
+    );
+    assert(
+      noted.errors.length === 0 &&
+        noted.annotations[0].trust === 'READ_ONLY' && noted.annotations[0].note === 'locked for audit' &&
+        noted.annotations[1].trust === 'SUGGEST_ONLY' && noted.annotations[1].owner === 'ledger' &&
+        noted.annotations[1].note === 'pending the Q3 rotation' &&
+        noted.annotations[2].intent === 'see http://x # y' && noted.annotations[2].trust === 'AUTONOMOUS' &&
+        noted.annotations[2].note === undefined,
+      'Text after a second comment marker or un-keyed words is kept as a note',
+      `Got: ${JSON.stringify(noted)}`
+    );
+    const typoAfterPair = collab.parseAnnotationContent('// @collab trust="READ_ONLY" rx\nfunction a() {}\n', 'typo.ts');
+    assert(typoAfterPair.errors[0]?.code === 'unknown-alias', 'A single trailing word is still checked as an alias');
+    const levelThenNote = collab.parseAnnotationContent('// @collab READ_ONLY locked for audit\nfunction a() {}\n', 'level.ts');
+    const aliasThenNote = collab.parseAnnotationContent('// @collab ro locked\nfunction a() {}\n', 'alias.ts');
+    assert(
+      levelThenNote.errors.length === 0 && levelThenNote.annotations[0]?.trust === 'READ_ONLY' &&
+        levelThenNote.annotations[0].note === 'locked for audit' &&
+        aliasThenNote.errors.length === 0 && aliasThenNote.annotations[0]?.trust === 'READ_ONLY' &&
+        aliasThenNote.annotations[0].note === 'locked',
+      'A leading trust level or alias without attributes keeps the words after it as a note',
+      `Got: ${JSON.stringify([levelThenNote, aliasThenNote])}`
+    );
+    const unparsed = collab.parseAnnotationContent('// @collab system for trust levels\nfunction a() {}\n', 'prose.ts');
+    assert(
+      unparsed.errors.length === 1 && unparsed.errors[0].code === 'unparsed-annotation' && unparsed.errors[0].line === 1 &&
+        unparsed.annotations[0]?.trust === undefined,
+      'Several bare words that start with no level or alias are one annotation error, not silently skipped',
+      `Got: ${JSON.stringify(unparsed)}`
+    );
+    assert(
+      collab.formatAnnotationComments('// @collab owner="a" ro  kept for audit\nfunction a() {}\n', 'a.ts').content ===
+        '// @collab trust="READ_ONLY" owner="a" // kept for audit\nfunction a() {}\n',
+      'fmt keeps the note after a comment marker'
+    );
+
+    // ========================================
+    section('54. ANNOTATION DELTA');
+    // ========================================
+
+    const deltaBefore = '// @collab ro owner="core"\nfunction keep() {}\n\n// @collab so\nfunction gone() {}\n';
+    const deltaAfter = '\n// @collab sv owner="core" intent="relaxed"\nfunction keep() {}\n\n// @collab auto\nfunction fresh() {}\n';
+    const annotationDelta = diff.diffAnnotationSets(
+      collab.parseAnnotationContent(deltaBefore, 'd.ts').annotations,
+      collab.parseAnnotationContent(deltaAfter, 'd.ts').annotations,
+      { key: diff.symbolKey(deltaBefore, deltaAfter) }
+    );
+    assert(
+      annotationDelta.changed.length === 1 &&
+        annotationDelta.changed[0].key === 'function keep() {}' &&
+        JSON.stringify(annotationDelta.changed[0].changes) ===
+          JSON.stringify([
+            { attribute: 'trust', before: 'READ_ONLY', after: 'SUPERVISED' },
+            { attribute: 'intent', after: 'relaxed' },
+          ]) &&
+        annotationDelta.added[0]?.key === 'function fresh() {}' &&
+        annotationDelta.removed[0]?.key === 'function gone() {}' &&
+        annotationDelta.unchanged === 0,
+      'Pairs regions by the code they govern and lists changed attributes',
+      `Got: ${JSON.stringify(annotationDelta)}`
+    );
+    const byId = diff.diffAnnotationSets(
+      [{ trust: 'READ_ONLY', intent: 'id-1', line_start: 1, line_end: 2 }],
+      [{ trust: 'READ_ONLY', intent: 'id-1', line_start: 40, line_end: 41 }],
+      { key: annotation => annotation.intent }
+    );
+    const byLine = diff.diffAnnotationSets(
+      [{ trust: 'READ_ONLY', line_start: 1, line_end: 2 }],
+      [{ trust: 'READ_ONLY', line_start: 40, line_end: 41 }]
+    );
+    assert(
+      byId.unchanged === 1 && byId.changed.length === 0 && byLine.added.length === 1 && byLine.removed.length === 1,
+      'Callers can supply their own region identity'
+    );
+
+    // ========================================
+    section('55. SCAN FILE LISTS');
+    // ========================================
+
+    assert(
+      JSON.stringify(scan.parseFileList('owned/pay.ts\r\n\n  owned/teams.txt \nowned/gone.ts\n')) ===
+        JSON.stringify(['owned/pay.ts', 'owned/teams.txt', 'owned/gone.ts']),
+      'Reads one path per line from a file list'
+    );
+    const listedFiles = await scan.selectListedFiles(['owned/pay.ts', 'owned/teams.txt', 'owned/gone.ts']);
+    assert(
+      JSON.stringify(listedFiles) ===
+        JSON.stringify({
+          files: ['owned/pay.ts'],
+          skipped: [
+            { file: 'owned/teams.txt', reason: 'unsupported file type' },
+            { file: 'owned/gone.ts', reason: 'not found' },
+          ],
+        }),
+      'Reports listed files that are missing or cannot hold annotations',
+      `Got: ${JSON.stringify(listedFiles)}`
+    );
+
+    // ========================================
+    section('56. ANNOTATION COMPLETIONS');
+    // ========================================
+
+    const vocabulary = {
+      aliases: { ...collab.DEFAULT_TRUST_ALIASES, locked: 'READ_ONLY' },
+      owners: ['auth-team', 'crypto-team', 'payments-team'],
+      owner_groups: [['auth-team', 'crypto-team']],
+    };
+    const values = (context) => completionsModule.completeAnnotation(context, vocabulary).completions.map(c => c.value);
+    assert(
+      JSON.stringify(values('// @collab trust="su')) === JSON.stringify(['SUPERVISED', 'SUGGEST_ONLY']) &&
+        JSON.stringify(values('// @collab owner=["auth-team", "')) === JSON.stringify(vocabulary.owners) &&
+        values('// @collab owner=').includes('["auth-team", "crypto-team"]') &&
+        JSON.stringify(values('# @collab redact=')) === JSON.stringify(['true', 'false']),
+      'Completes trust levels, owners, owner groups, and booleans'
+    );
+    assert(
+      JSON.stringify(values('// @collab trust="READ_ONLY" l')) === JSON.stringify(['lines=', 'labels=', 'locked']) &&
+        !values('// @collab owner="a" ').includes('owner=') &&
+        values('// @collab intent="half a sen').length === 0,
+      'Offers unwritten keys and configured aliases, and nothing inside free text'
+    );
+
+    // ========================================
+    section('57. MISPLACED ANNOTATIONS');
+    // ========================================
+
+    const wrongSide = (source, file) =>
+      collab.parseAnnotationContent(source, file).warnings.filter(w => w.code === 'annotation-after-declaration');
+    const goWrongSide = wrongSide('package users\n\nfunc (s *UserService) Delete(id string) error {\n\t// @collab ro\n\treturn nil\n}\n', 'users.go');
+    assert(
+      goWrongSide.length === 1 && goWrongSide[0].line === 4 && goWrongSide[0].params.opening_line === '3' &&
+        wrongSide('def run(self):\n    # @collab ro\n    pass\n', 'run.py').length === 1,
+      'Warns about an annotation on the first line of a function body'
+    );
+    assert(
+      wrongSide('class UserService {\n  // @collab ro\n  get() {}\n}\n', 'svc.ts').length === 0 &&
+        wrongSide('function f() {\n  if (x) {\n    // @collab ro\n    y();\n  }\n}\n', 'ctl.ts').length === 0 &&
+        wrongSide('// @collab ro\nfunction f() {\n  return 1;\n}\n', 'ok.ts').length === 0,
+      'Leaves annotations above declarations, inside classes, and in nested blocks alone'
+    );
+
+    // ========================================
+    section('58. SCAN BY RECEIVER');
+    // ========================================
+
+    await fs.mkdir('receivers', { recursive: true });
+    const receiverSources = {
+      'receivers/service.go':
+        'package users\n\n// @collab ro\ntype UserService struct {\n\tdb *DB\n}\n\n' +
+        '// @collab so owner="identity-team"\nfunc (s *UserService) Get(id string) error {\n\treturn nil\n}\n\n' +
+        '// @collab ro\nfunc hash(pw string) string {\n\treturn pw\n}\n',
+      'receivers/admin.go': 'package users\n\n// @collab ro\nfunc (s UserService) Delete(id string) error {\n\treturn nil\n}\n',
+    };
+    const receiverSummaries = [];
+    for (const [file, source] of Object.entries(receiverSources)) {
+      await fs.writeFile(file, source);
+      receiverSummaries.push(await scan.summarizeFile(file));
+    }
+    const receiverGroups = scan.groupByReceiver(receiverSummaries, receiverSources);
+    assert(
+      JSON.stringify(receiverGroups.map(g => [g.package, g.receiver, g.regions.map(r => r.declaration ?? '-')])) ===
+        JSON.stringify([
+          ['users', 'UserService', ['UserService.Delete', 'UserService', 'UserService.Get']],
+          ['users', '<package>', ['hash']],
+        ]),
+      'Groups methods from several files under their receiver, and functions under the package',
+      `Got: ${JSON.stringify(receiverGroups)}`
+    );
+
+    // ========================================
+    section('59. STRICT SCAN');
+    // ========================================
+
+    const hygieneCodes = (source, file = 'strict.ts') => collab.parseAnnotationContent(source, file).errors.map(e => e.code);
+    assert(
+      JSON.stringify(hygieneCodes('// @collab:begin ro\nconst a = 1;\n')) === JSON.stringify(['unclosed-block']) &&
+        JSON.stringify(hygieneCodes('const a = 1;\n// @collab:end\nconst s = "@collab:end";\n')) === JSON.stringify(['unmatched-block-end']) &&
+        JSON.stringify(hygieneCodes('// @collab ro trust="AUTONOMOUS"\nfunction f() {}\n')) === JSON.stringify(['conflicting-trust']) &&
+        JSON.stringify(hygieneCodes('// @collab ro\n// @collab trust="SUPERVISED"\nfunction f() {}\n')) === JSON.stringify(['conflicting-trust']) &&
+        hygieneCodes('// @collab ro\n// @collab trust="READ_ONLY"\nfunction f() {}\n').length === 0,
+      'Reports unbalanced blocks and conflicting trust levels'
+    );
+
+    await fs.mkdir('strict', { recursive: true });
+    await fs.writeFile('strict/broken.ts', '// @collab:begin ro\nconst a = 1;\n');
+    await fs.writeFile('strict/broken.go', 'package main\n\n// @collab ro\nfunc g() {\n\ts := `open\n}\n');
+    await fs.writeFile('strict/clean.ts', '// @collab ro\nfunction ok() {}\n');
+    const strictReport = scan.strictReport([
+      await scan.summarizeFile('strict/clean.ts'),
+      await scan.summarizeFile('strict/broken.ts'),
+      await scan.summarizeFile('strict/broken.go'),
+    ]);
+    assert(
+      strictReport.files_scanned === 3 && strictReport.files_with_issues === 2 &&
+        strictReport.annotation_issues === 1 && strictReport.source_issues === 1 &&
+        JSON.stringify(strictReport.issues.map(i => [i.file, i.category, i.code])) ===
+          JSON.stringify([['strict/broken.go', 'source', 'scope-fallback'], ['strict/broken.ts', 'annotation', 'unclosed-block']]),
+      'Aggregates issues across files, with unreadable source as a separate category',
+      `Got: ${JSON.stringify(strictReport)}`
+    );
+    const strictText = scan.formatStrictReportText(strictReport);
+    assert(
+      strictText.includes('Annotation issues (1):\n  strict/broken.ts:1: error unclosed-block:') &&
+        strictText.includes('Source issues (1):\n  strict/broken.go:3: error scope-fallback:') &&
+        scan.formatStrictReportText(scan.strictReport([])) === 'Scanned 0 file(s): no annotation issues',
+      'Prints the strict report grouped by category'
+    );
+
+    // ========================================
+    section('60. NESTED BLOCKS AND INHERIT');
+    // ========================================
+
+    const nestedSource = [
+      '// @collab:begin trust="SUPERVISED" owner="payments-team" constraints=["No new dependencies"]',
+      'function charge() {}',
+      '// @collab:begin trust="AUTONOMOUS"',
+      'function formatReceipt() {}',
+      '// @collab:end',
+      '// @collab:begin trust="AUTONOMOUS" inherit="false"',
+      'function previewReceipt() {}',
+      '// @collab:end',
+      '// @collab:begin owner="growth-team" inherit="false"',
+      'function experimentFlags() {}',
+      '// @collab:end',
+      '// @collab:begin intent="Receipt numbering"',
+      'function nextReceiptNumber() {}',
+      '// @collab:end',
+      '// @collab:end',
+    ].join('\n');
+    const nestedParsed = collab.parseAnnotationContent(nestedSource, 'nested.ts');
+    const nestedConfig = { version: 1, default_trust: 'SUGGEST_ONLY', policies: [] };
+    const nestedAt = (line) => collab.resolveTrustWithAnnotations(nestedConfig, 'nested.ts', nestedParsed.annotations, line, line);
+    assert(
+      nestedParsed.errors.length === 0 && nestedParsed.annotations.length === 5 &&
+        nestedParsed.annotations[0].line_end === 14,
+      'Parses nested blocks, matching each end to its own begin'
+    );
+    const inheritedLevel = nestedAt(4);
+    const freshLevel = nestedAt(7);
+    assert(
+      inheritedLevel.level === 'AUTONOMOUS' && inheritedLevel.owner === 'payments-team' &&
+        JSON.stringify(inheritedLevel.constraints) === JSON.stringify(['No new dependencies']) &&
+        freshLevel.level === 'AUTONOMOUS' && freshLevel.owner === undefined && freshLevel.constraints === undefined,
+      'A sub-block inherits unset attributes, and inherit="false" starts fresh'
+    );
+    assert(
+      nestedAt(10).level === 'SUGGEST_ONLY' && nestedAt(10).source === 'default' &&
+        nestedAt(13).level === 'SUPERVISED' && nestedAt(13).reason.includes('inherited from lines 2-14') &&
+        nestedAt(2).level === 'SUPERVISED',
+      'A break without trust falls back to trust.yaml, and an unset level is inherited'
+    );
+    assert(
+      collab.parseAnnotationContent('// @collab:begin ro inherit="maybe"\nx();\n// @collab:end\n', 'bad.ts').errors[0]?.code === 'invalid-inherit' &&
+        collab.formatAnnotation({ trust: 'AUTONOMOUS', inherit: false, line_start: 1, line_end: 1 }) === '// @collab trust="AUTONOMOUS" inherit="false"',
+      'Validates and formats inherit'
+    );
+
+    // ========================================
+    section('61. GOVERNING COMMENT');
+    // ========================================
+
+    const governedSource = [
+      'const setup = 1;',
+      '  // @collab trust="SUPERVISED"',
+      '  // @collab owner="payments-team" // see RFC-12',
+      '  function charge() {',
+      '    return 1;',
+      '  }',
+      '/* @collab:begin ro owner="security-team" */',
+      'function audit() {}',
+      '// @collab:begin owner="audit-team"',
+      'function auditLog() {}',
+      '// @collab:end',
+      '// @collab:end',
+    ].join('\n');
+    const merged = collab.governingCommentInContent(governedSource, 'charge.ts', 5);
+    assert(
+      merged?.line_start === 2 && merged.line_end === 3 &&
+        merged.text === '// @collab trust="SUPERVISED"\n// @collab owner="payments-team" // see RFC-12' &&
+        merged.annotation.owner === 'payments-team',
+      'Returns every line of a multi-line annotation verbatim'
+    );
+    assert(
+      collab.governingCommentInContent(governedSource, 'charge.ts', 10)?.text === '/* @collab:begin ro owner="security-team" */' &&
+        collab.governingCommentInContent(governedSource, 'charge.ts', 1) === undefined &&
+        collab.governingCommentInContent(governedSource, 'charge.ts', 5, { generated: true }) === undefined,
+      'Returns the block that an inherited level comes from, and nothing where no annotation applies'
+    );
+
+    // ========================================
+    section('62. SIZE LIMITS');
+    // ========================================
+
+    const sizeLint = {
+      max_region_lines: 3,
+      overrides: [
+        { pattern: 'legacy/**', max_region_lines: 0 },
+        { pattern: 'legacy/hot/**', max_region_lines: 2, max_annotations_per_file: 1 },
+      ],
+    };
+    assert(
+      JSON.stringify(check.sizeLimitsFor(sizeLint, 'src/a.ts')) === JSON.stringify({ max_region_lines: 3, max_annotations_per_file: 100 }) &&
+        check.sizeLimitsFor(sizeLint, 'legacy/a.ts').max_region_lines === 0 &&
+        JSON.stringify(check.sizeLimitsFor(sizeLint, 'legacy/hot/a.ts')) === JSON.stringify({ max_region_lines: 2, max_annotations_per_file: 1 }) &&
+        check.sizeLimitsFor(undefined, 'a.ts').max_region_lines === check.DEFAULT_MAX_REGION_LINES,
+      'Resolves size limits from defaults, settings, and the most specific override'
+    );
+
+    await fs.mkdir('sized/legacy', { recursive: true });
+    const sizedSource = '// @collab:begin ro\na();\nb();\nc();\nd();\n// @collab:end\n// @collab so\nfunction e() {}\n';
+    await fs.writeFile('sized/big.ts', sizedSource);
+    await fs.writeFile('sized/legacy/big.ts', sizedSource);
+    const sizedConfig = await collab.loadTrustConfig();
+    const sizedCodes = async (file, lint) =>
+      (await check.checkFile(sizedConfig, file, undefined, { lint })).violations.map(v => `${v.code}:${v.line}`);
+    assert(
+      JSON.stringify(await sizedCodes('sized/big.ts', { max_region_lines: 3, max_annotations_per_file: 1 })) ===
+        JSON.stringify(['region-too-large:1', 'too-many-annotations:1']) &&
+        (await sizedCodes('sized/legacy/big.ts', { max_region_lines: 3, overrides: [{ pattern: 'sized/legacy/**', max_region_lines: 0 }] })).length === 0 &&
+        (await sizedCodes('sized/big.ts', {})).length === 0,
+      'Warns about oversized regions and crowded files, honoring per-directory overrides'
+    );
+
+    // ========================================
+    section('63. ROUTE GOVERNANCE');
+    // ========================================
+
+    assert(
+      routesModule.handlerName('s.deleteUser') === 's.deleteUser' &&
+        routesModule.handlerName('requireAuth(http.HandlerFunc(listUsers))') === 'listUsers' &&
+        routesModule.handlerName('s.handleUsers()') === 's.handleUsers' &&
+        routesModule.handlerName('&AdminHandler{db: db}') === 'AdminHandler.ServeHTTP' &&
+        routesModule.handlerName('func(w http.ResponseWriter, r *http.Request) {') === 'func literal',
+      'Finds the handler behind wrappers, builders, and handler types'
+    );
+
+    await fs.mkdir('routed', { recursive: true });
+    await fs.writeFile(
+      'routed/server.go',
+      'package api\n\n// @collab ro owner="security-team"\nfunc (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {\n}\n\n' +
+        '// @collab trust="AUTONOMOUS"\nfunc listUsers(w http.ResponseWriter, r *http.Request) {\n}\n\n' +
+        'func (s *Server) routes(mux *http.ServeMux) {\n\tmux.HandleFunc("DELETE /users/{id}", s.deleteUser)\n' +
+        '\tmux.Handle("/users", requireAuth(http.HandlerFunc(listUsers)))\n\t// mux.HandleFunc("/old", old)\n}\n'
+    );
+    const extractedRoutes = routesModule.netHttpExtractor(await fs.readFile('routed/server.go', 'utf-8'), 'routed/server.go');
+    assert(
+      JSON.stringify(extractedRoutes.map(r => [r.method, r.path, r.handler, r.line])) ===
+        JSON.stringify([['DELETE', '/users/{id}', 's.deleteUser', 12], ['*', '/users', 'listUsers', 13]]),
+      'Extracts net/http registrations with their method patterns, skipping comments'
+    );
+
+    const routeReport = await routesModule.buildRouteReport(['routed'], {
+      map: routesModule.parseRouteMap('- method: get\n  path: /v2/users\n  handler: listUsers\n', 'map.yaml'),
+    });
+    assert(
+      JSON.stringify(routeReport.routes.map(r => [r.method, r.path, r.trust, r.owner ?? '-', r.declaration?.qualified_name])) ===
+        JSON.stringify([
+          ['*', '/users', 'AUTONOMOUS', '-', 'listUsers'],
+          ['DELETE', '/users/{id}', 'READ_ONLY', 'security-team', 'Server.deleteUser'],
+          ['GET', '/v2/users', 'AUTONOMOUS', '-', 'listUsers'],
+        ]),
+      'Reports each route with its handler\'s trust and owner, including mapped routes',
+      `Got: ${JSON.stringify(routeReport.routes)}`
+    );
+    const routeSpec = routesModule.routesToOpenApi(routeReport);
+    assert(
+      routeSpec.paths['/users/{id}'].delete['x-collab-trust'] === 'READ_ONLY' &&
+        routeSpec.paths['/users/{id}'].delete.tags[0] === 'collab:READ_ONLY' &&
+        routeSpec.paths['/users']['x-collab-any-method'].operationId === 'listUsers',
+      'Prints OpenAPI paths with x-collab extensions'
+    );
+
+    // ========================================
+    section('64. SHADOW MODE');
+    // ========================================
+
+    const shadowEntries = check.shadowAuditEntries(
+      {
+        files: [{
+          file: 'a.ts',
+          violations: [
+            { file: 'a.ts', line: 3, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'edited' },
+            { file: 'a.ts', line: 5, rule: 'annotation', code: 'region-too-large', severity: 'warning', message: 'big' },
+          ],
+        }],
+        total_violations: 1,
+        total_warnings: 1,
+      },
+      'T'
+    );
+    assert(
+      JSON.stringify(shadowEntries) === JSON.stringify([{
+        timestamp: 'T', mode: 'shadow', source: 'check', file_path: 'a.ts', line: 3,
+        code: 'read-only-edit', would_decide: 'fail', message: 'edited',
+      }]),
+      'Records only the violations that would fail the check, marked as shadow'
+    );
+
+    const auditBefore = (await collab.loadAuditEntries()).length;
+    const shadowGateway = {
+      resolver: { async resolve() { return { level: 'READ_ONLY', reason: 'Frozen', source: 'policy' }; } },
+      notifier: { async proposalCreated() { throw new Error('no proposals in shadow mode'); } },
+      shadow: true,
+    };
+    const shadowed = await gateway.decideEdit({ file_path: 'frozen.ts', line_start: 4, content: 'x' }, shadowGateway);
+    const shadowAudit = (await collab.loadAuditEntries()).slice(auditBefore);
+    assert(
+      shadowed.decision === 'allow' && shadowed.shadow_decision === 'deny' &&
+        shadowAudit.length === 1 && shadowAudit[0].mode === 'shadow' && shadowAudit[0].source === 'gateway' &&
+        shadowAudit[0].would_decide === 'deny' && shadowAudit[0].line === 4,
+      'Gateway allows in shadow mode and audits the decision it would have made'
+    );
+
+    // ========================================
+    section('65. COLON SEPARATORS');
+    // ========================================
+
+    const colonParsed = collab.parseAnnotationContent(
+      "// @collab trust: \"READ_ONLY\" owner=\"sec\"\nfunction a() {}\n// @collab owner:['a', 'b'] so see: RFC-12\nfunction b() {}\n",
+      'colon.ts'
+    );
+    assert(
+      colonParsed.errors.length === 0 &&
+        colonParsed.annotations[0].trust === 'READ_ONLY' && colonParsed.annotations[0].owner === 'sec' &&
+        JSON.stringify(colonParsed.annotations[1].owner) === JSON.stringify(['a', 'b']) &&
+        colonParsed.annotations[1].trust === 'SUGGEST_ONLY' && colonParsed.annotations[1].note === 'see: RFC-12',
+      'Accepts key: value for known attributes, mixed with key=value, and leaves other colons to the note'
+    );
+    assert(
+      collab.formatAnnotationComments("// @collab intent: 'x y' trust:SUPERVISED\nfunction c() {}\n", 'colon.ts').content ===
+        '// @collab trust="SUPERVISED" intent="x y"\nfunction c() {}\n',
+      'fmt rewrites colon separators to the canonical key="value" form'
+    );
+    const commaParsed = collab.parseAnnotationContent(
+      '// @collab trust: "READ_ONLY", owner: "x"\nfunction a() {}\n// @collab trust=SUPERVISED, owner=y,z\nfunction b() {}\n' +
+        '// @collab owner="w", ro\nfunction c() {}\n',
+      'comma.ts'
+    );
+    assert(
+      commaParsed.errors.length === 0 &&
+        commaParsed.annotations.map(a => `${a.trust}:${a.owner}`).join() === 'READ_ONLY:x,SUPERVISED:y,z,READ_ONLY:w',
+      'Accepts commas between attributes without reading them as aliases or values',
+      `Got: ${JSON.stringify(commaParsed)}`
+    );
+
+    // ========================================
+    section('66. SIGNATURE COMPARISON');
+    // ========================================
+
+    const lockedGo = '// @collab ro owner="payments-team"\nfunc (s *Service) Charge(ctx context.Context, cents int64) (*Receipt, error) {\n\treturn s.charge(ctx, "}", cents)\n}\n';
+    assert(
+      signatureModule.signatureUnchanged(
+        'go',
+        lockedGo,
+        'func (s *Service) Charge(\n\tctx context.Context,\n\tcents int64, // in cents\n) (*Receipt, error) {\n\treturn nil, nil\n}\n'
+      ) &&
+        !signatureModule.signatureUnchanged('go', lockedGo, 'func (s *Service) Charge(ctx context.Context, cents int) (*Receipt, error) {}') &&
+        !signatureModule.signatureUnchanged('go', lockedGo, 'func (s Service) Charge(ctx context.Context, cents int64) (*Receipt, error) {}'),
+      'Ignores body and formatting changes but not parameter or receiver changes'
+    );
+    const signatureError = (() => {
+      try {
+        signatureModule.signatureUnchanged('go', lockedGo, 'func a() {}\nfunc b() {}\n');
+      } catch (error) {
+        return error;
+      }
+    })();
+    assert(
+      signatureError instanceof signatureModule.SignatureParseError && signatureError.side === 'new' &&
+        signatureError.message.includes('unexpected "func" on line 2') &&
+        JSON.stringify(signatureModule.parseGoSignature('func Map[K comparable, V any](m map[K]V) (out []V)')) ===
+          JSON.stringify({ name: 'Map', params: '(m map[K]V)', results: '(out []V)', type_params: '[K comparable, V any]' }),
+      'Rejects regions that are not a single declaration, naming the side and the reason'
+    );
+
+    // ========================================
+    section('67. REGION LABELS');
+    // ========================================
+
+    const labeledSource = '// @collab ro labels=["pci", "audit-2024"]\nfunction store() {\n}\n\n// @collab so labels=ops\nfunction deploy() {\n}\n';
+    const labeledParsed = collab.parseAnnotationContent(labeledSource, 'labeled.ts');
+    assert(
+      labeledParsed.errors.length === 0 &&
+        JSON.stringify(labeledParsed.annotations.map(a => a.labels)) === JSON.stringify([['pci', 'audit-2024'], ['ops']]) &&
+        collab.formatAnnotation({ trust: 'READ_ONLY', labels: ['pci'], line_start: 1, line_end: 1 }) ===
+          '// @collab trust="READ_ONLY" labels=["pci"]',
+      'Parses labels as a list, from brackets or a single value, and formats them back'
+    );
+
+    await fs.writeFile('labeled.ts', labeledSource);
+    await fs.writeFile('unlabeled.ts', '// @collab ro\nfunction plain() {}\n');
+    const labeledSummaries = [await scan.summarizeFile('labeled.ts'), await scan.summarizeFile('unlabeled.ts')];
+    const pciOnly = scan.filterByLabels(labeledSummaries, ['pci']);
+    assert(
+      pciOnly.length === 1 && pciOnly[0].file === 'labeled.ts' &&
+        JSON.stringify(pciOnly[0].regions.map(r => [r.line_start, r.labels])) === JSON.stringify([[2, ['pci', 'audit-2024']]]) &&
+        scan.filterByLabels(labeledSummaries, ['pci', 'ops'])[0].regions.length === 2 &&
+        scan.formatSummaryText(pciOnly[0]).includes('[pci, audit-2024]'),
+      'Filters scan summaries to regions with a label and drops files without one'
+    );
+
+    // ========================================
+    section('68. UNPARSEABLE FILES');
+    // ========================================
+
+    assert(
+      goscopeModule.goSyntaxError(['package x', '', 'func A() {', '\tm := map[string]int{"}": 1}', '\t_ = m', '}']) === undefined &&
+        goscopeModule.goSyntaxError(['package x', 'func A() {', '\ts := "open', '}']) === 'unterminated literal on line 3' &&
+        goscopeModule.goSyntaxError(['package x', 'func A() {', '}', '}']) === 'unexpected closing bracket on line 4' &&
+        goscopeModule.goSyntaxError(['package x', 'func A() {']) !== undefined,
+      'Reports unterminated literals and unbalanced brackets anywhere in a Go file'
+    );
+
+    await fs.writeFile('broken.go', 'package x\n\n// @collab ro\nfunc A() {\n}\n\n// @collab:begin so\nfunc B() {\n\ts := "open\n}\n// @collab:end\n');
+    const brokenSummary = await scan.summarizeFile('broken.go');
+    assert(
+      brokenSummary.parse_error === 'unterminated literal on line 9' &&
+        JSON.stringify(brokenSummary.regions.map(r => [r.line_start, r.line_end, r.trust])) ===
+          JSON.stringify([[4, 5, 'READ_ONLY'], [8, 10, 'SUGGEST_ONLY']]) &&
+        scan.formatSummaryText(brokenSummary).includes('unparseable: unterminated literal on line 9') &&
+        scan.summaryRecords(brokenSummary)[0].parse_error === brokenSummary.parse_error,
+      'Marks a file that does not tokenize as unparseable and still finds its annotations line by line'
+    );
+
+    // ========================================
+    section('69. OWNERSHIP TRANSFERS');
+    // ========================================
+
+    const transferBase = '// @collab ro owner="payments-team"\nfunction refund() {}\n\n// @collab so owner=["a", "b"]\nfunction split() {}\n\n// @collab owner=["x", "y"]\nfunction pair() {}\n';
+    const transferHead = transferBase
+      .replace('owner="payments-team"', 'owner="billing-team"')
+      .replace('owner=["a", "b"]', 'owner=["a", "c"]')
+      .replace('owner=["x", "y"]', 'owner=["y", "x"]');
+    const transferChanges = diff.diffAnnotationContent('refund.ts', transferBase, transferHead, collab.DEFAULT_TRUST_ALIASES);
+    const transfers = diff.ownershipTransfers(transferChanges);
+    assert(
+      transferChanges.length === 3 && transferChanges.every(c => c.kind === 'owner_changed') &&
+        JSON.stringify(transfers.map(t => [t.line, t.trust, t.from, t.to, t.owner_after])) ===
+          JSON.stringify([[2, 'READ_ONLY', ['payments-team'], ['billing-team'], 'billing-team'], [5, 'SUGGEST_ONLY', ['b'], ['c'], 'a, c']]),
+      'Lists owner changes as transfers from the owners who lost the region to those who gained it, ignoring reorders'
+    );
+
+    const heardTransfers = [];
+    transferHooksModule.registerTransferHook(function record(t) { heardTransfers.push(t.from.join()); });
+    transferHooksModule.registerTransferHook(function broken() { throw new Error('chat is down'); });
+    const transferFailures = await transferHooksModule.runTransferHooks(transfers);
+    transferHooksModule.clearTransferHooks();
+    assert(
+      JSON.stringify(heardTransfers) === JSON.stringify(['payments-team', 'b']) &&
+        transferFailures.length === 2 && transferFailures[0].hook === 'broken' && transferFailures[0].message === 'chat is down' &&
+        transferFailures[1].line === 5,
+      'Runs every transfer hook for every transfer and reports failures per transfer'
+    );
+
+    // ========================================
+    section('70. POLICY TESTS');
+    // ========================================
+
+    assert(
+      JSON.stringify(testPolicyModule.parseEditRanges('--- a/x.go\n+++ b/x.go\n@@ -3,2 +3,3 @@\n-a\n+b\n@@ -0,0 +1 @@\n+c\n')) ===
+        JSON.stringify([{ line_start: 3, line_end: 4 }, { line_start: 1, line_end: 1 }]),
+      'Reads the replaced line range of each hunk, judging insertions by the line they follow'
+    );
+
+    const policySource = 'package pay\n\n// @collab ro\nfunc Refund() {\n}\n\n// @collab so\nfunc Quote() {\n}\n';
+    await fs.mkdir('policy-tests/nested', { recursive: true });
+    await fs.writeFile('policy-tests/pay.json', JSON.stringify([
+      { name: 'refund locked', file: 'pay.go', content: policySource, diff: '@@ -4,1 +4,1 @@\n-func Refund() {\n+func Refund2() {\n', expect: 'deny' },
+      { name: 'quote proposes', content: policySource, diff: '+++ b/pay.go\n@@ -8,1 +8,1 @@\n', expect: 'propose', expect_trust: 'SUGGEST_ONLY' },
+      { name: 'strictest hunk wins', file: 'pay.go', content: policySource, diff: '@@ -8,1 +8,1 @@\n@@ -4,1 +4,1 @@\n', expect: 'propose' },
+    ]));
+    await fs.writeFile('policy-tests/nested/bad.json', JSON.stringify({ file: 'pay.go', diff: '@@ -1 +1 @@', expect: 'maybe' }));
+    const policyReport = await testPolicyModule.testPolicy('policy-tests');
+    assert(
+      JSON.stringify(policyReport.results.map(r => [r.name, r.status, r.actual])) ===
+        JSON.stringify([['nested/bad.json#1', 'error', undefined], ['refund locked', 'pass', 'deny'], ['quote proposes', 'pass', 'propose'], ['strictest hunk wins', 'fail', 'deny']]) &&
+        policyReport.passed === 2 && policyReport.failed === 2 &&
+        testPolicyModule.formatPolicyTestText(policyReport).endsWith('4 fixture(s): 2 passed, 1 failed, 1 could not be run'),
+      'Runs each fixture against the real policy and reports pass, fail, and malformed fixtures'
+    );
+
+    // ========================================
+    section('71. OWNER REGISTRY');
+    // ========================================
+
+    const ownerRegistry = collab.buildOwnerRegistry({ payments: { slack: '#payments', escalation: 'payments-oncall' }, billing: null });
+    const unknownOwner = (() => {
+      try {
+        collab.resolveOwner('growth', ownerRegistry);
+      } catch (error) {
+        return error;
+      }
+    })();
+    assert(
+      JSON.stringify(collab.resolveOwners(['payments', 'billing'], ownerRegistry)) ===
+        JSON.stringify([{ key: 'payments', slack: '#payments', escalation: 'payments-oncall' }, { key: 'billing' }]) &&
+        unknownOwner instanceof collab.UnknownOwnerError && unknownOwner.key === 'growth' &&
+        unknownOwner.message === 'Unknown owner "growth" (known owners: billing, payments)',
+      'Resolves owner keys to their records and rejects keys the registry does not define'
+    );
+    let badRegistry;
+    try {
+      collab.buildOwnerRegistry({ payments: { phone: '555' } });
+    } catch (error) {
+      badRegistry = error.message;
+    }
+    assert(badRegistry?.includes('unknown field "phone"'), 'Rejects owner records with unknown fields');
+
+    await fs.writeFile('owned.ts', '// @collab ro owner=["payments", "growth"]\nfunction refund() {}\n');
+    const ownedWithRegistry = await check.checkFile({ default_trust: 'SUPERVISED', policies: [] }, 'owned.ts', undefined, { owners: ownerRegistry });
+    const ownedFreeForm = await check.checkFile({ default_trust: 'SUPERVISED', policies: [] }, 'owned.ts');
+    assert(
+      JSON.stringify(ownedWithRegistry.violations.map(v => [v.code, v.line, v.params.owner])) === JSON.stringify([['unknown-owner', 1, 'growth']]) &&
+        !ownedFreeForm.violations.some(v => v.code === 'unknown-owner'),
+      'Reports owner keys missing from the registry only when one is configured'
+    );
+
+    await fs.writeFile('.collab/config.yaml', 'owners:\n  payments:\n    slack: "#payments"\n  billing:\n    email: billing@example.com\n');
+    const ownedSummary = await scan.summarizeFile('owned.ts');
+    const contactsHeard = [];
+    transferHooksModule.registerTransferHook(t => { contactsHeard.push(t.contacts); });
+    const contactFailures = await transferHooksModule.runTransferHooks([
+      { file: 'refund.ts', line: 2, symbol: 'refund', owner_before: 'payments', owner_after: 'billing, growth', from: ['payments'], to: ['billing', 'growth'] },
+    ]);
+    transferHooksModule.clearTransferHooks();
+    await fs.rm('.collab/config.yaml');
+    assert(
+      JSON.stringify(ownedSummary.regions[0].contacts) === JSON.stringify([{ key: 'payments', slack: '#payments' }]) &&
+        JSON.stringify(contactsHeard) === JSON.stringify([[{ key: 'payments', slack: '#payments' }, { key: 'billing', email: 'billing@example.com' }]]) &&
+        contactFailures.length === 1 && contactFailures[0].hook === 'owners' && contactFailures[0].message.startsWith('Unknown owner "growth"'),
+      'Passes resolved contacts to scan output and transfer hooks, reporting unknown keys'
+    );
+
+    // ========================================
+    section('72. SCAN PROGRESS');
+    // ========================================
+
+    const progressWrites = [];
+    const ttyProgress = scan.createScanProgress(3, { isTTY: true, write: text => progressWrites.push(text) });
+    ttyProgress.tick();
+    ttyProgress.tick();
+    ttyProgress.tick();
+    ttyProgress.clear();
+    ttyProgress.clear();
+    const pipedWrites = [];
+    const pipedProgress = scan.createScanProgress(3, { isTTY: false, write: text => pipedWrites.push(text) });
+    pipedProgress.tick();
+    pipedProgress.clear();
+    assert(
+      JSON.stringify(progressWrites) === JSON.stringify(['\rScanning 1/3 files', '\rScanning 3/3 files', '\r\x1b[K']) &&
+        pipedWrites.length === 0,
+      'Redraws a throttled file counter on a terminal, always showing the last file, and writes nothing when piped'
+    );
+    assert(
+      scan.formatScanStats({ files: 412, annotations: 96, errors: 2, elapsed_ms: 3420 }) ===
+        'Scanned 412 file(s): 96 annotation(s), 2 error(s) in 3.4s',
+      'Formats the end-of-scan summary'
+    );
+
+    // ========================================
+    section('73. VENDORED CODE');
+    // ========================================
+
+    assert(
+      collab.isVendoredPath('vendor/github.com/acme/ledger/post.go') &&
+        collab.isVendoredPath('./services/pay/vendor/lib/x.ts') &&
+        !collab.isVendoredPath('src/vendors.ts') &&
+        collab.isVendoredPath('third_party/auth/a.go', ['third_party/**']) &&
+        !collab.isVendoredPath('vendor/a.go', ['third_party/**']),
+      'Recognizes vendor/ directories by default and configured patterns instead'
+    );
+
+    const vendoredSource = 'package ledger\n\n// @collab ro owner="ledger-team"\nfunc Post() {\n\tcommit()\n}\n\n// @collab auto\nfunc Helper() {\n}\n';
+    const vendoredEdits = diff.findVendoredEdits(
+      'vendor/acme/ledger/post.go',
+      vendoredSource,
+      [{ line_start: 5, line_end: 9 }],
+      collab.DEFAULT_TRUST_ALIASES
+    );
+    assert(
+      JSON.stringify(vendoredEdits.map(c => [c.kind, c.priority, c.line, c.owner_after, c.changed_lines])) ===
+        JSON.stringify([['vendored_edit', 'high', 4, 'ledger-team', [{ line_start: 5, line_end: 6 }]]]),
+      'Reports changed lines inside vendored READ_ONLY regions as high-priority drift'
+    );
+
+    await fs.mkdir('vendor/acme/ledger', { recursive: true });
+    await fs.writeFile('vendor/acme/ledger/post.go', vendoredSource);
+    await collab.recordAuthorship({
+      timestamp: new Date().toISOString(), author: 'claude', file_path: 'vendor/acme/ledger/post.go', line_start: 5, line_end: 5,
+    });
+    const vendoredCheck = await check.checkFile({ default_trust: 'SUPERVISED', policies: [] }, 'vendor/acme/ledger/post.go');
+    const vendoredWalk = await check.expandPaths(['vendor']);
+    assert(
+      vendoredCheck.violations.some(v => v.code === 'vendored-read-only-edit' && v.message.endsWith('change it upstream and vendor it again')) &&
+        !vendoredCheck.violations.some(v => v.code === 'read-only-edit') &&
+        vendoredWalk.includes(path.join('vendor', 'acme', 'ledger', 'post.go')),
+      'Checks vendored files and reports recorded edits to their READ_ONLY regions distinctly'
+    );
+
+    // ========================================
+    section('74. POSITIONS AND RANGES');
+    // ========================================
+
+    const positionBuffer = 'package a\n\nfunc Größe() {\n}\n';
+    assert(
+      JSON.stringify(collab.positionAt(positionBuffer, 0)) === JSON.stringify({ line: 1, col: 1, offset: 0 }) &&
+        JSON.stringify(collab.positionAt(positionBuffer, 16)) === JSON.stringify({ line: 3, col: 6, offset: 16 }) &&
+        JSON.stringify(collab.positionAt(positionBuffer, 28)) === JSON.stringify({ line: 4, col: 1, offset: 28 }) &&
+        collab.positionAt(positionBuffer, 1000).offset === Buffer.byteLength(positionBuffer),
+      'Converts UTF-8 byte offsets to line and byte column, clamping past the end'
+    );
+    assert(
+      collab.formatRange({ start: { line: 12, col: 5 }, end: { line: 18, col: 1 } }, 'file.go') === 'file.go:12:5-18:1' &&
+        collab.formatRange({ start: { line: 12, col: 5 }, end: { line: 12, col: 9 } }, 'file.go') === 'file.go:12:5-9' &&
+        collab.formatRange(collab.rangeOf({ line_start: 12, line_end: 18 }), 'file.go') === 'file.go:12-18' &&
+        collab.formatRange(collab.rangeOf({ line: 12 }), 'file.go') === 'file.go:12' &&
+        collab.formatRange(collab.rangeOf({ line_start: 3, line_end: 3 })) === '3',
+      'Formats positions and ranges as file:line:col, collapsing what does not change'
+    );
+
+    // ========================================
+    section('75. AGENT IDENTITIES');
+    // ========================================
+
+    const juniorPolicy = { max_trust: 'AUTONOMOUS' };
+    const seniorPolicy = { max_trust: 'SUGGEST_ONLY' };
+    const supervisedRegion = { level: 'SUPERVISED', source: 'policy' };
+    const ownedRegion = { level: 'SUGGEST_ONLY', owner: ['senior', 'payments-team'], source: 'annotation' };
+    assert(
+      gateway.agentDecision(supervisedRegion, 'junior', juniorPolicy).decision === 'propose' &&
+        gateway.agentDecision(supervisedRegion, 'junior', juniorPolicy).reason.includes('max_trust AUTONOMOUS') &&
+        gateway.agentDecision({ level: 'AUTONOMOUS' }, 'junior', juniorPolicy).decision === 'allow' &&
+        gateway.agentDecision(ownedRegion, 'senior', seniorPolicy).decision === 'allow' &&
+        gateway.agentDecision(ownedRegion, 'other', seniorPolicy).decision === 'propose' &&
+        gateway.agentDecision(ownedRegion, 'senior', { max_trust: 'SUPERVISED' }).decision === 'propose' &&
+        gateway.agentDecision({ level: 'READ_ONLY', owner: 'senior' }, 'senior', seniorPolicy).decision === 'deny',
+      'Caps decisions at max_trust and lets owners edit their SUGGEST_ONLY regions'
+    );
+    assert(
+      collab.validateAgentPolicies({}) === null &&
+        collab.validateAgentPolicies({ agents: { senior: seniorPolicy } }) === null &&
+        collab.validateAgentPolicies({ agents: { x: { max_trust: 'READ_ONLY' } } }).includes('cannot be READ_ONLY') &&
+        collab.validateAgentPolicies({ agents: { x: {} } }).includes('max_trust must be one of') &&
+        collab.validateAgentPolicies({ agents: ['x'] }) !== null,
+      'Validates agent policies'
+    );
+
+    await fs.writeFile('.collab/config.yaml', JSON.stringify({ agents: { junior: juniorPolicy } }));
+    const agentGateway = {
+      resolver: { async resolve() { return supervisedRegion; } },
+      notifier: { async proposalCreated() {} },
+    };
+    const juniorEdit = await gateway.decideEdit({ file_path: 'svc.ts', line_start: 2, content: 'x' }, agentGateway, 'junior');
+    const unlistedEdit = await gateway.decideEdit({ file_path: 'svc.ts', line_start: 2, content: 'x' }, agentGateway, 'senior');
+    const anonymousEdit = await gateway.decideEdit({ file_path: 'svc.ts', line_start: 2, content: 'x', author: 'junior' }, agentGateway);
+    const juniorProposal = await collab.loadProposal(juniorEdit.proposal_id ?? '');
+    assert(
+      juniorEdit.decision === 'propose' && juniorEdit.agent === 'junior' && juniorEdit.agent_reason !== undefined &&
+        juniorProposal?.author === 'junior' &&
+        unlistedEdit.decision === 'propose' && unlistedEdit.agent_reason.includes('agent "senior" is not in config.yaml agents') &&
+        anonymousEdit.decision === 'propose' && anonymousEdit.agent === undefined &&
+        anonymousEdit.agent_reason.includes('unauthenticated caller'),
+      'Gateway applies the caller\'s agent policy and caps unlisted and unauthenticated callers',
+      `Got: ${JSON.stringify([juniorEdit, unlistedEdit, anonymousEdit])}`
+    );
+
+    const authenticatedServer = http.createServer(gateway.createEditGateway({
+      ...agentGateway,
+      authenticate(req) {
+        if (req.headers.authorization === 'Bearer junior-token') return 'junior';
+        if (req.headers.authorization) throw new Error('invalid token');
+      },
+    }));
+    await new Promise(resolve => authenticatedServer.listen(0, '127.0.0.1', resolve));
+    const authenticatedUrl = `http://127.0.0.1:${authenticatedServer.address().port}/`;
+    const editAs = async (authorization, author) => (await fetch(authenticatedUrl, {
+      method: 'POST',
+      headers: authorization ? { authorization } : {},
+      body: JSON.stringify({ file_path: 'svc.ts', line_start: 2, content: 'x', author }),
+    }));
+    const tokenEdit = await (await editAs('Bearer junior-token')).json();
+    const claimedEdit = await (await editAs(undefined, 'junior')).json();
+    const rejectedToken = await editAs('Bearer forged');
+    authenticatedServer.close();
+    await fs.rm('.collab/config.yaml');
+    const freeEdit = await gateway.decideEdit({ file_path: 'svc.ts', line_start: 2, content: 'x' }, agentGateway);
+    assert(
+      tokenEdit.agent === 'junior' && claimedEdit.agent === undefined && claimedEdit.decision === 'propose' &&
+        rejectedToken.status === 401 &&
+        freeEdit.decision === 'allow' && freeEdit.agent === undefined,
+      'Takes the agent from authenticate, not the body, and leaves region trust alone when no agents are listed',
+      `Got: ${JSON.stringify([tokenEdit, claimedEdit, freeEdit])}`
+    );
+    for (const edit of [juniorEdit, unlistedEdit, anonymousEdit, tokenEdit, claimedEdit]) {
+      await collab.deleteProposal(edit.proposal_id);
+    }
+
+    // ========================================
+    section('76. PR AUTHORSHIP');
+    // ========================================
+
+    const authorshipRegistry = collab.buildOwnerRegistry({
+      payments: { members: ['alice', 'payments-oncall'] },
+      'payments-oncall': { members: ['bob', 'payments'] },
+    });
+    assert(
+      JSON.stringify(collab.expandOwners(['payments', 'carol'], authorshipRegistry)) ===
+        JSON.stringify(['payments', 'alice', 'payments-oncall', 'bob', 'carol']),
+      'Expands groups through nested and cyclic membership'
+    );
+    assert(
+      (() => { try { collab.buildOwnerRegistry({ payments: { members: 'alice' } }); return false; } catch (e) { return e.message.includes('members must be a list'); } })(),
+      'Rejects members that are not a list of strings'
+    );
+
+    const authorshipRegions = {
+      'pay.go': { level: 'SUGGEST_ONLY', owner: 'payments' },
+      'shared.go': { level: 'SUGGEST_ONLY', owner: ['auth-team', 'bob'] },
+      'auth.go': { level: 'SUGGEST_ONLY', owner: 'auth-team' },
+      'ledger.go': { level: 'READ_ONLY', owner: 'payments' },
+      'util.go': { level: 'AUTONOMOUS' },
+    };
+    const prAuthorship = await gateway.classifyEditsByAuthorship(
+      Object.keys(authorshipRegions).map(file => ({ file_path: file, line_start: 3 })),
+      'bob',
+      { async resolve(file) { return authorshipRegions[file]; } },
+      authorshipRegistry
+    );
+    assert(
+      JSON.stringify(prAuthorship.owned.map(e => [e.file_path, e.via, e.line_end])) ===
+        JSON.stringify([['pay.go', 'payments', 3], ['shared.go', 'bob', 3]]) &&
+        JSON.stringify(prAuthorship.needs_review.map(e => [e.file_path, e.trust_level])) ===
+          JSON.stringify([['auth.go', 'SUGGEST_ONLY'], ['ledger.go', 'READ_ONLY']]),
+      'Classifies owned SUGGEST_ONLY regions apart from those needing review'
+    );
+
+    // ========================================
+    section('77. GITHUB REVIEW COMMENTS');
+    // ========================================
+
+    await fs.writeFile('review-target.go', 'package pay\n\n// @collab ro owner=["payments", "audit"]\nfunc Refund() {\n\tpost()\n\tlog()\n}\n');
+    const reviewReport = {
+      files: [{
+        file: 'review-target.go',
+        violations: [
+          { file: 'review-target.go', line: 5, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'edited' },
+          { file: 'review-target.go', line: 6, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'edited too' },
+        ],
+      }],
+      total_violations: 2,
+      total_warnings: 0,
+    };
+    const reviewConfig = { default_trust: 'SUPERVISED', policies: [] };
+    const fullReview = await check.githubReview(reviewReport, reviewConfig);
+    const diffReview = await check.githubReview(reviewReport, reviewConfig, new Map([['review-target.go', [{ line_start: 5, line_end: 5 }]]]));
+    assert(
+      fullReview.event === 'COMMENT' && fullReview.comments.length === 2 &&
+        fullReview.comments[0].path === 'review-target.go' && fullReview.comments[0].line === 5 && fullReview.comments[0].side === 'RIGHT' &&
+        fullReview.comments[0].body.includes('Owner: payments, audit') &&
+        fullReview.comments[0].body.includes('@collab trust="READ_ONLY" owner=["payments", "audit"]') &&
+        fullReview.comments[0].body.includes('review-target.go:4-7'),
+      'Anchors each violation to its line with the owner and governing annotation'
+    );
+    assert(
+      diffReview.comments.length === 1 && diffReview.comments[0].line === 5 &&
+        diffReview.body.includes('Outside the diff:') && diffReview.body.includes('review-target.go:6: error `read-only-edit`: edited too'),
+      'Moves violations outside the PR diff into the review body'
+    );
+    await fs.rm('review-target.go');
+
+    // ========================================
+    section('78. CONDITIONAL ANNOTATIONS');
+    // ========================================
+
+    const conditionalSource = 'package config\n\n// @collab owner="platform-team"\n// @collab when="path:cmd/prod/**" trust="READ_ONLY"\n// @collab when="path:cmd/**" trust="SUGGEST_ONLY"\n// @collab intent="tool builds only"\nfunc Load() {\n}\n';
+    const conditionalOf = file => collab.parseAnnotationContent(conditionalSource, file).annotations;
+    const prodConditional = conditionalOf('cmd/prod/config.go');
+    const toolConditional = conditionalOf('cmd/tool/config.go');
+    const otherConditional = conditionalOf('internal/config/config.go');
+    assert(
+      prodConditional.length === 1 && prodConditional[0].trust === 'READ_ONLY' && prodConditional[0].owner === 'platform-team' &&
+        prodConditional[0].intent === undefined && prodConditional[0].line_start === 7 &&
+        toolConditional[0].trust === 'SUGGEST_ONLY' && toolConditional[0].intent === 'tool builds only' &&
+        otherConditional.length === 1 && otherConditional[0].trust === undefined && otherConditional[0].owner === 'platform-team',
+      'Applies the first matching when= clause over the base, or the base alone'
+    );
+    const clauseOnly = '// @collab:begin when="path:cmd/prod/**" ro\nconst a = 1\n// @collab:end\n// @collab when="path:cmd/prod/**" ro\nfunc B() {\n}\n';
+    const badWhen = collab.parseAnnotationContent('// @collab ro when="branch:main"\nfunc C() {\n}\n', 'c.go');
+    assert(
+      collab.parseAnnotationContent(clauseOnly, 'cmd/prod/x.go').annotations.length === 2 &&
+        collab.parseAnnotationContent(clauseOnly, 'internal/x.go').annotations.length === 0 &&
+        badWhen.annotations.length === 0 && badWhen.errors.some(e => e.code === 'invalid-when') &&
+        collab.annotationApplies({ when: 'path:cmd/**' }, './cmd/a/b.go') && collab.annotationApplies({}, 'x.go'),
+      'Ignores annotations whose condition does not hold, and reports invalid conditions'
+    );
+    const formattedConditional = collab.formatAnnotationComments(conditionalSource, 'cmd/prod/config.go');
+    const formattedSingle = collab.formatAnnotationComments("// @collab ro when='path:cmd/**'\nfunc D() {\n}\n", 'd.go');
+    assert(
+      formattedConditional.changed === 0 && formattedConditional.skipped[0].reason.includes('stacked conditional') &&
+        formattedSingle.content.startsWith('// @collab when="path:cmd/**" trust="READ_ONLY"'),
+      'Formats a single conditional annotation with when= first and keeps stacked ones as written'
+    );
+
+    // ========================================
+    section('79. TRUST AT CURSOR');
+    // ========================================
+
+    const cursorConfig = { default_trust: 'SUPERVISED', policies: [] };
+    const cursorBuffer = 'package pay\n\n// @collab so owner="payments-team" intent="Implement user auth"\nfunc Login() {\n\tauth()\n}\n\nfunc Other() {\n}\n';
+    const atLogin = await atModule.resolveAt(cursorConfig, 'pay/unsaved.go', cursorBuffer, { line: 5, col: 2 });
+    const atComment = await atModule.resolveAt(cursorConfig, 'pay/unsaved.go', cursorBuffer, { line: 3 });
+    const atOther = await atModule.resolveAt(cursorConfig, 'pay/unsaved.go', cursorBuffer, { line: 8 });
+    assert(
+      atModule.formatTrustAt(atLogin) === 'SUGGEST_ONLY — owner payments-team — "Implement user auth"' &&
+        atLogin.symbol === 'Login' && atLogin.position.col === 2 &&
+        atComment.level === 'SUGGEST_ONLY' &&
+        atModule.formatTrustAt(atOther) === 'SUPERVISED' && atOther.symbol === 'Other',
+      'Summarizes the trust at a cursor in an unsaved buffer on one line'
+    );
+    let atError = '';
+    await atModule.resolveAt(cursorConfig, 'pay/unsaved.go', cursorBuffer, { line: 40 }).catch(e => { atError = e.message; });
+    assert(atError.includes('past the end'), 'Rejects lines past the end of the buffer');
+
+    // ========================================
+    section('80. DESIGN DOCS');
+    // ========================================
+
+    const designDocUrl = 'https://docs.example.com/designs/replication-v2';
+    const designSource = `package store\n\n// @collab so owner="storage-team" design_doc="${designDocUrl}"\nfunc Apply() {\n\twrite()\n}\n`;
+    const designParsed = collab.parseAnnotationContent(designSource, 'store.go');
+    const badDesign = collab.parseAnnotationContent('// @collab so design_doc="wiki/replication"\nfunc B() {\n}\n', 'b.go');
+    assert(
+      designParsed.annotations[0].design_doc === designDocUrl && designParsed.errors.length === 0 &&
+        badDesign.annotations[0].design_doc === 'wiki/replication' && badDesign.errors.some(e => e.code === 'invalid-design-doc') &&
+        collab.formatAnnotation(designParsed.annotations[0]).includes(`design_doc="${designDocUrl}"`),
+      'Parses design_doc links and reports ones that are not http(s) URLs'
+    );
+
+    await fs.writeFile('design-store.go', designSource);
+    const designProposal = await collab.createProposal({
+      file_path: 'design-store.go', description: 'Batch writes', old_code: '\twrite()', new_code: '\twriteBatch()', confidence: 0.8,
+    });
+    const unacknowledged = await proposalsModule.approveProposal(designProposal, 'dana');
+    const acknowledged = await proposalsModule.approveProposal(designProposal, 'dana', { acknowledgeDesignDoc: true });
+    const savedDesignProposal = await collab.loadProposal(designProposal.id);
+    assert(
+      designProposal.design_doc === designDocUrl &&
+        unacknowledged.status === 'design_doc_unacknowledged' && unacknowledged.design_doc === designDocUrl &&
+        acknowledged.status === 'approved' &&
+        savedDesignProposal.approvals.length === 1 && savedDesignProposal.approvals[0].design_doc === designDocUrl &&
+        proposalsModule.formatProposalText(designProposal).includes(`design doc: ${designDocUrl}`),
+      'Approves design-doc proposals only once the approver acknowledges the doc'
+    );
+    await fs.rm('design-store.go');
+
+    // ========================================
+    section('81. BLOCK END GRAMMAR');
+    // ========================================
+
+    const extraEnd = collab.parseAnnotationContent(
+      '// @collab:begin trust="READ_ONLY"\nfunction a() {}\n// @collab:end owner="x" colour="red"\nfunction b() {}\n',
+      'end.ts'
+    );
+    assert(
+      JSON.stringify(extraEnd.errors.map(e => [e.code, e.line])) === JSON.stringify([['invalid-block-end', 3], ['invalid-block-end', 3]]) &&
+        extraEnd.errors.some(e => e.message === '@collab:end sets owner="x"; it takes no attributes, or only ones matching @collab:begin on line 1') &&
+        extraEnd.errors.some(e => e.message.startsWith('@collab:end has unknown attribute "colour"')) &&
+        extraEnd.annotations.length === 1 && extraEnd.annotations[0].owner === undefined && extraEnd.annotations[0].line_end === 2,
+      'Reports attributes the begin line does not set, and still closes the block'
+    );
+    const endpointMention = collab.parseAnnotationContent(
+      '// @collab:begin trust="READ_ONLY"\n// see @collab:endpoint docs\nconst a = 1;\n// @collab:end\n',
+      'end.ts'
+    );
+    assert(
+      endpointMention.errors.length === 0 && endpointMention.annotations[0].line_end === 3,
+      'Does not close a block at words that only start with @collab:end'
+    );
+
+    // ========================================
+    section('82. CONSTRAINT INVENTORY');
+    // ========================================
+
+    const inventory = constraintsModule.allConstraints([
+      {
+        file: 'b.ts',
+        annotations: collab.parseAnnotationContent(
+          '// @collab trust="READ_ONLY" owner="billing" constraints=["no floats", " no floats "]\nconst a = 1;\n',
+          'b.ts'
+        ).annotations,
+      },
+      {
+        file: 'a.ts',
+        annotations: collab.parseAnnotationContent(
+          '// @collab trust="SUPERVISED" constraints=["no floats","idempotent"]\nconst a = 1;\n',
+          'a.ts'
+        ).annotations,
+      },
+    ]);
+    assert(
+      JSON.stringify(inventory.map(c => [c.constraint, c.regions.map(r => r.file)])) ===
+        JSON.stringify([['no floats', ['a.ts', 'b.ts']], ['idempotent', ['a.ts']]]) &&
+        inventory[0].regions[1].owner === 'billing' && inventory[0].regions[1].trust === 'READ_ONLY',
+      'Groups identical constraint text across regions, most widely declared first'
+    );
+    const inventoryText = constraintsModule.formatConstraintsText({ checked_files: 2, constraints: inventory });
+    assert(
+      inventoryText.includes('"no floats" (2 region(s)):') &&
+        inventoryText.endsWith('Found 2 distinct constraint(s) declared on 3 region(s) in 2 file(s)'),
+      'Formats the inventory grouped by constraint'
+    );
+
+    // ========================================
+    section('83. PARTIAL BATCH APPLICATION');
+    // ========================================
+
+    const batchSource = 'func a() {\n  return 1\n}\n\nfunc b() {\n  return 2\n}\n\nfunc c() {\n  return 3\n}\n';
+    const batchProposal = (id, oldCode, newCode) => ({
+      id, created_at: '', author: 'claude', status: 'pending', file_path: 'p.go',
+      description: '', old_code: oldCode, new_code: newCode, confidence: 1,
+    });
+    const partialBatch = {
+      id: 'b1',
+      proposals: [
+        batchProposal('pa', '  return 1\n', '  x := 1\n  y := 0\n  return x + y\n'),
+        batchProposal('pb', '  return 2\n', '  return 20\n'),
+        batchProposal('pc', '  return 3\n', '  return 30\n'),
+        batchProposal('pd', 'func gone() {}\n', ''),
+      ],
+    };
+    const partial = collab.applyBatch({ 'p.go': batchSource }, partialBatch, { pa: 'approve', pb: 'reject', pc: 'approve', pd: 'approve' });
+    assert(
+      partial.sources['p.go'] === batchSource.replace('  return 1\n', '  x := 1\n  y := 0\n  return x + y\n').replace('  return 3\n', '  return 30\n') &&
+        JSON.stringify(partial.regions.map(r => [r.outcome, r.line_start ?? null, r.line_end ?? null])) ===
+          JSON.stringify([['applied', 2, 4], ['rejected', null, null], ['applied', 12, 12], ['skipped', null, null]]) &&
+        partial.regions[3].reason === 'old_code is no longer in p.go',
+      'Applies only approved regions and shifts later ranges by the lines earlier ones added'
+    );
+    const overlappingBatch = collab.applyBatch(
+      { 'p.go': batchSource },
+      { id: 'b2', proposals: [batchProposal('oa', 'func a() {\n  return 1\n', 'func a() {\n'), batchProposal('ob', '  return 1\n}\n', '}\n')] },
+      { oa: 'approve', ob: 'approve' }
+    );
+    assert(
+      overlappingBatch.regions[1].outcome === 'skipped' && overlappingBatch.regions[1].reason === 'overlaps proposal oa' &&
+        overlappingBatch.sources['p.go'].startsWith('func a() {\n}\n'),
+      'Skips an approved region that overlaps one already applied'
+    );
+
+    // ========================================
+    section('84. CACHE WARMING');
+    // ========================================
+
+    await fs.mkdir('warm-fixture', { recursive: true });
+    await fs.writeFile('warm-fixture/a.ts', '// @collab trust="READ_ONLY"\nconst a = 1;\n');
+    await fs.writeFile('warm-fixture/b.ts', 'const b = 2;\n');
+    const warmed = await warmModule.warmCaches(['warm-fixture']);
+    await fs.rm('warm-fixture', { recursive: true, force: true });
+    assert(
+      warmed.checked_files === 2 && warmed.annotations === 1 &&
+        warmModule.formatWarmText(warmed).endsWith('parsed 2 file(s) with 1 annotation(s)'),
+      'Parses every file once while warming'
+    );
+    assert(
+      warmModule.formatWarmText({ policies: ['https://p.example/t.yaml'], checked_files: 0, annotations: 0 }) ===
+        '  https://p.example/t.yaml\nWarmed 1 extended policy document(s); parsed 0 file(s) with 0 annotation(s)',
+      'Lists the extended policy documents it warmed'
+    );
+
+    // ========================================
+    section('85. GO STRUCT TAGS');
+    // ========================================
+
+    const taggedStruct = collab.parseAnnotationContent(
+      'package k\n\ntype Key struct {\n\tID  string\n\tRaw []byte `json:"-" collab:"trust=READ_ONLY,owner=crypto-team,intent=Never leaves memory,constraints=[no logging, zeroed]"`\n}\n\nvar tmpl = `collab:"trust=READ_ONLY"`\n',
+      'k.go'
+    );
+    assert(
+      taggedStruct.errors.length === 0 && taggedStruct.annotations.length === 1 &&
+        JSON.stringify(taggedStruct.annotations[0]) === JSON.stringify({
+          trust: 'READ_ONLY', owner: 'crypto-team', intent: 'Never leaves memory',
+          constraints: ['no logging', 'zeroed'], line_start: 5, line_end: 5, comment_start: 5, comment_end: 5,
+        }),
+      'A collab struct tag annotates its field line, and a raw string elsewhere does not'
+    );
+    const badTag = collab.parseAnnotationContent('type T struct {\n\tA int `collab:"trust=READONLY"`\n}\n', 't.go');
+    assert(
+      badTag.errors.length === 1 && badTag.errors[0].code === 'unknown-trust-level' &&
+        badTag.errors[0].line === 2 && badTag.errors[0].suggestion === undefined,
+      'Reports tag errors on the field line without a comment-shaped fix'
+    );
+    assert(
+      collab.parseAnnotationContent('const t = { a: 1 } // `collab:"trust=READ_ONLY"`\nA int `collab:"trust=READ_ONLY"`\n', 't.ts').annotations.length === 0,
+      'Only reads struct tags in Go files'
+    );
+
+    // ========================================
+    section('86. OWNER SIGN-OFFS');
+    // ========================================
+
+    const signoffChanges = diff.diffAnnotationContent(
+      'keys.go',
+      '// @collab trust="READ_ONLY" owner="@acme/crypto-team"\nfunc Sign() {}\n\n// @collab trust="READ_ONLY" owner="crypto-team"\nfunc Verify() {}\n\n// @collab trust="SUPERVISED"\nfunc Hash() {}\n',
+      '// @collab trust="SUPERVISED" owner="@acme/crypto-team"\nfunc Sign() {}\n\n// @collab trust="READ_ONLY" owner="platform"\nfunc Verify() {}\n\n// @collab trust="AUTONOMOUS"\nfunc Hash() {}\n',
+      {}
+    );
+    assert(
+      JSON.stringify(signoffChanges.map(c => [c.kind, c.owners_before ?? null])) ===
+        JSON.stringify([['downgrade', ['@acme/crypto-team']], ['owner_changed', ['crypto-team']], ['downgrade', null]]),
+      'diff records the base owners of downgraded and reassigned regions'
+    );
+    const unsigned = signoffsModule.unauthorizedGovernanceChanges(signoffChanges, [
+      { file: 'keys.go', owner: 'crypto-team', symbol: 'func Sign() {}' },
+    ]);
+    assert(
+      unsigned.length === 1 && unsigned[0].change.kind === 'owner_changed' &&
+        JSON.stringify(unsigned[0].owners) === JSON.stringify(['crypto-team']),
+      'Flags owned changes with no matching sign-off, and skips unowned regions'
+    );
+    assert(
+      signoffsModule.formatSignoffAuditText({
+        base: 'main', head: 'HEAD', signoffs: 1, governed_changes: 2,
+        findings: [{ ...unsigned[0], changed_by: 'Sam <sam@example.com>' }],
+      }).startsWith('keys.go:5: ownership transfer crypto-team -> platform (READ_ONLY) without a sign-off from crypto-team (changed by Sam <sam@example.com>)'),
+      'Names who made an unsigned change'
+    );
+
+    // ========================================
+    section('87. TEST FILE DEFAULT');
+    // ========================================
+
+    const testSource = 'package pay\n\nimport (\n\t"fmt"\n\t"testing"\n)\n\nfunc TestRefund(t *testing.T) {}\n';
+    assert(
+      collab.isGoTestSource('pay/refund_test.go', testSource) &&
+        collab.isGoTestSource('pay/x_test.go', 'package pay\n\nimport "testing"\n') &&
+        !collab.isGoTestSource('pay/refund.go', testSource) &&
+        !collab.isGoTestSource('pay/example_test.go', 'package pay\n\nfunc Example() {}\n'),
+      'Detects Go test files by their _test.go name and testing import'
+    );
+    const testConfig = {
+      default_trust: 'SUPERVISED',
+      test_trust: 'AUTONOMOUS',
+      policies: [{ pattern: '**/crypto/**', trust: 'READ_ONLY' }],
+    };
+    const testAnnotations = collab.parseAnnotationContent(
+      testSource.replace('func TestRefund', '// @collab trust="SUGGEST_ONLY"\nfunc TestRefund'),
+      'pay/refund_test.go'
+    ).annotations;
+    const inTest = collab.resolveTrustWithAnnotations(testConfig, 'pay/refund_test.go', [], 1, 1, [], false, undefined, true);
+    assert(
+      inTest.level === 'AUTONOMOUS' && inTest.source === 'test' &&
+        collab.resolveTrustWithAnnotations(testConfig, 'pay/refund_test.go', testAnnotations, 9, 9, [], false, undefined, true).level === 'SUGGEST_ONLY' &&
+        collab.resolveTrustWithAnnotations(testConfig, 'src/crypto/keys_test.go', [], 1, 1, [], false, undefined, true).level === 'READ_ONLY' &&
+        collab.resolveTrustWithAnnotations(testConfig, 'pay/refund.go', [], 1, 1).level === 'SUPERVISED',
+      'test_trust replaces the default in test files; annotations and path policies still win'
+    );
+    assert(
+      collab.validateTestTrust({ ...testConfig, test_trust: 'LOOSE' }) === 'test_trust must be a trust level (got LOOSE)',
+      'Rejects an unknown test_trust level'
+    );
+    await fs.mkdir('shadowtest/src/pay', { recursive: true });
+    await fs.writeFile('shadowtest/src/pay/refund_test.go', testSource);
+    await fs.mkdir('shadowtest/src/crypto', { recursive: true });
+    await fs.writeFile('shadowtest/src/crypto/keys_test.go', testSource);
+    const lockedTest = await check.checkFile(testConfig, 'shadowtest/src/crypto/keys_test.go', collab.buildTrustAliases());
+    const broadTestConfig = { ...testConfig, policies: [...testConfig.policies, { pattern: 'shadowtest/src/**', trust: 'SUPERVISED' }] };
+    const shadowedTest = await check.checkFile(broadTestConfig, 'shadowtest/src/pay/refund_test.go', collab.buildTrustAliases());
+    const ownTestPolicy = await check.checkFile(
+      { ...broadTestConfig, policies: [...broadTestConfig.policies, { pattern: 'shadowtest/src/**/*_test.go', trust: 'AUTONOMOUS' }] },
+      'shadowtest/src/pay/refund_test.go',
+      collab.buildTrustAliases()
+    );
+    assert(
+      shadowedTest.violations.some(v => v.code === 'shadowed-test-trust' && v.severity === 'warning' && v.params.pattern === '"shadowtest/src/**"') &&
+        !ownTestPolicy.violations.some(v => v.code === 'shadowed-test-trust') &&
+        !lockedTest.violations.some(v => v.code === 'shadowed-test-trust'),
+      'check warns when a broad path policy hides test_trust, but not for READ_ONLY locks or tests with their own policy',
+      `Got: ${JSON.stringify([shadowedTest.violations, ownTestPolicy.violations, lockedTest.violations])}`
+    );
+    await fs.rm('shadowtest', { recursive: true, force: true });
+
+    // ========================================
+    section('88. CONFIG MERGE PROVENANCE');
+    // ========================================
+
+    const orgLayer = {
+      source: 'org.yaml',
+      document: {
+        default_trust: 'SUGGEST_ONLY',
+        test_trust: 'AUTONOMOUS',
+        policies: [{ pattern: 'infra/**', trust: 'READ_ONLY' }],
+        profiles: { dev: { overrides: { SUGGEST_ONLY: 'SUPERVISED' } }, ci: {} },
+      },
+    };
+    const localLayer = {
+      source: 'trust.yaml',
+      document: {
+        default_trust: 'SUPERVISED',
+        policies: [{ pattern: 'src/**', trust: 'SUPERVISED' }],
+        profiles: { dev: { overrides: {} } },
+        extends: ['org.yaml'],
+      },
+    };
+    const mergedConfig = policyModule.mergeConfigs([orgLayer, localLayer]);
+    assert(
+      mergedConfig.config.default_trust === 'SUPERVISED' && mergedConfig.config.test_trust === 'AUTONOMOUS' &&
+        JSON.stringify(mergedConfig.config.policies.map(p => p.pattern)) === JSON.stringify(['src/**', 'infra/**']) &&
+        JSON.stringify(mergedConfig.config.profiles.dev) === JSON.stringify({ overrides: {} }),
+      'Later layers win scalars and profiles; list entries are appended, the last layer first'
+    );
+    assert(
+      collab.stableStringify(mergedConfig.provenance) === collab.stableStringify({
+        default_trust: 'trust.yaml', test_trust: 'org.yaml', extends: 'trust.yaml',
+        'policies[0]': 'trust.yaml', 'policies[1]': 'org.yaml',
+        'profiles.ci': 'org.yaml', 'profiles.dev': 'trust.yaml',
+      }),
+      'Records the layer every merged setting came from'
+    );
+    assert(
+      JSON.stringify(policyModule.mergePolicy(localLayer.document, [orgLayer.document])) === JSON.stringify(mergedConfig.config),
+      'mergePolicy merges exactly as mergeConfigs'
+    );
+
+    // ========================================
+    section('89. GO INIT FUNCTIONS AND VAR GROUPS');
+    // ========================================
+
+    const initSource = [
+      'package keys',                                      // 1
+      '',
+      '// @collab trust="READ_ONLY" owner="crypto-team"',
+      'var (',                                             // 4
+      '\tsigningKey = mustLoadKey("signing")',
+      '\tverifiers  = map[string]Verifier{',
+      '\t\t"ed25519": ed25519Verifier{},',
+      '\t}',
+      ')',                                                 // 9
+      '',
+      '// @collab trust="SUPERVISED"',
+      'func init() {',                                     // 12
+      '\tregister("ed25519")',
+      '}',
+      '',
+      'func init() { register("rsa") }',                   // 16
+      '',
+      'const (',                                           // 18
+      '\tmodeA Mode = iota',
+      '\tmodeB',
+      '\t_',
+      ')',
+      '',
+      '// @collab trust="READ_ONLY"',
+      'var fallback, backup = mustLoadKey("fallback"), mustLoadKey("backup")', // 25
+    ].join('\n');
+    const initParsed = collab.parseAnnotationContent(initSource, 'keys.go');
+    assert(
+      JSON.stringify(initParsed.annotations.map(a => [a.trust, a.line_start, a.line_end])) ===
+        JSON.stringify([['READ_ONLY', 4, 9], ['SUPERVISED', 12, 14], ['READ_ONLY', 25, 25]]) &&
+        initParsed.errors.length === 0,
+      'Annotations govern a whole var group and a single init function',
+      `Got: ${JSON.stringify(initParsed.annotations)}`
+    );
+
+    const initDecls = declarations.findDeclarations(initSource, 'keys.go').map(d => `${d.qualified_name}:${d.line_start}-${d.line_end}`);
+    assert(
+      JSON.stringify(initDecls) === JSON.stringify([
+        'signingKey:5-5', 'verifiers:6-8', 'init:12-14', 'init:16-16',
+        'modeA:19-19', 'modeB:20-20', 'fallback:25-25', 'backup:25-25',
+      ]),
+      'Every package-level var and const name is a declaration, each init its own',
+      `Got: ${JSON.stringify(initDecls)}`
+    );
+
+    const initConfig = { default_trust: 'AUTONOMOUS', policies: [] };
+    const initLevel = line => collab.resolveTrustWithAnnotations(initConfig, 'keys.go', initParsed.annotations, line, line).level;
+    assert(
+      initLevel(7) === 'READ_ONLY' && initLevel(13) === 'SUPERVISED' && initLevel(16) === 'AUTONOMOUS',
+      'Lines inside the group and each init resolve to their own annotation',
+      `Got: ${[7, 13, 16].map(initLevel)}`
+    );
+
+    await fs.writeFile('keys.go', initSource);
+    const verifiersTrust = await declarations.resolveByDeclaration(initConfig, 'verifiers', 'keys.go');
+    assert(
+      verifiersTrust.level === 'READ_ONLY' && verifiersTrust.annotation?.line_start === 4,
+      'A group member resolves to the group annotation by name',
+      `Got: ${JSON.stringify(verifiersTrust)}`
+    );
+    await fs.rm('keys.go');
+
+    // ========================================
+    section('90. IDENTITY RESOLVER');
+    // ========================================
+
+    const identityModule = await import('./dist/identity.js');
+    await fs.writeFile('TEAMS', '# team roster\n@acme/payments  Bob@acme.com carol@acme.com\n@acme/platform bob@acme.com  # on call\n');
+    const identities = identityModule.createIdentityResolver({
+      identities: { 'alice@acme.com': ['auth-team'], 'bob@acme.com': 'payments' },
+      roster: 'TEAMS',
+    });
+    assert(
+      JSON.stringify(await identities.teamsFor('Bob <BOB@acme.com>')) === JSON.stringify(['payments', '@acme/payments', '@acme/platform']) &&
+        JSON.stringify(await identities.teamsFor('carol@acme.com')) === JSON.stringify(['@acme/payments']) &&
+        JSON.stringify(await identities.teamsFor('dave@acme.com')) === '[]',
+      'Merges config identities and the team roster, matching emails case-insensitively'
+    );
+    await fs.rm('TEAMS');
+    assert(
+      JSON.stringify(await identities.teamsFor('alice@acme.com')) === JSON.stringify(['auth-team']),
+      'Reads the roster once and caches lookups'
+    );
+    assert(
+      (() => { try { identityModule.buildIdentityMap({ 'eve@acme.com': [] }); return false; } catch (e) { return e.message.includes('one or more teams'); } })(),
+      'Rejects an identity without teams'
+    );
+
+    const teamAuthorship = await gateway.classifyEditsByAuthorship(
+      Object.keys(authorshipRegions).map(file => ({ file_path: file, line_start: 3 })),
+      'alice@acme.com',
+      { async resolve(file) { return authorshipRegions[file]; } },
+      {},
+      identities
+    );
+    assert(
+      JSON.stringify(teamAuthorship.owned.map(e => [e.file_path, e.via])) ===
+        JSON.stringify([['shared.go', 'auth-team'], ['auth.go', 'auth-team']]),
+      'Classifies regions owned by the author\'s team as owned',
+      `Got: ${JSON.stringify(teamAuthorship)}`
+    );
+
+    const teamRegion = { level: 'SUGGEST_ONLY', owner: 'payments-team' };
+    const teamDecision = gateway.agentDecision(teamRegion, 'bot', { max_trust: 'SUGGEST_ONLY' }, ['@acme/payments-team']);
+    assert(
+      teamDecision.decision === 'allow' && teamDecision.reason.includes('team "payments-team"') &&
+        gateway.agentDecision(teamRegion, 'bot', { max_trust: 'SUGGEST_ONLY' }).decision === 'propose',
+      'An agent may edit SUGGEST_ONLY regions its team owns'
+    );
+
+    // ========================================
+    section('91. TRUST LEVEL SHORTHAND');
+    // ========================================
+
+    const shorthand = line => collab.parseAnnotationContent(`${line}\nfunction rotate() {}\n`, 'rotate.ts');
+    const bareLevel = shorthand('// @collab READ_ONLY owner="security-team"');
+    assert(
+      bareLevel.annotations[0]?.trust === 'READ_ONLY' && bareLevel.annotations[0].owner === 'security-team' &&
+        bareLevel.errors.length === 0,
+      'A bare trust level is shorthand for trust='
+    );
+    const explicitWins = shorthand('// @collab trust="SUPERVISED" READ_ONLY');
+    const levelWins = shorthand('// @collab READ_ONLY so owner="security-team"');
+    assert(
+      explicitWins.annotations[0].trust === 'SUPERVISED' &&
+        explicitWins.errors.map(e => e.message).join() === 'Annotation sets trust to both READ_ONLY and SUPERVISED; SUPERVISED applies' &&
+        levelWins.annotations[0].trust === 'READ_ONLY' &&
+        levelWins.errors.map(e => e.code).join() === 'conflicting-trust',
+      'Explicit trust= wins over a bare level, and a bare level over an alias, reporting the conflict',
+      `Got: ${JSON.stringify([explicitWins, levelWins])}`
+    );
+    const wrongCase = shorthand('// @collab read_only');
+    assert(
+      wrongCase.annotations[0].trust === undefined && wrongCase.errors[0]?.code === 'unknown-alias' &&
+        wrongCase.errors[0].suggestion === '// @collab trust="READ_ONLY"',
+      'A trust level in the wrong case is still an error with a fix'
+    );
+    assert(
+      (() => { try { collab.buildTrustAliases({ READ_ONLY: 'AUTONOMOUS' }); return false; } catch (e) { return e.message.includes('trust level names'); } })() &&
+        shorthand('// @collab SUGGEST_ONLY').annotations[0].trust === 'SUGGEST_ONLY',
+      'Trust level names cannot be redefined as aliases'
+    );
+
+    // ========================================
+    section('92. AST REGION IDENTITY');
+    // ========================================
+
+    const astBefore = [
+      'package billing',
+      '',
+      '// @collab trust="READ_ONLY" owner="billing"',
+      'func (inv *Invoice) Total(rate float64) int {',
+      '\treturn 0',
+      '}',
+      '',
+      '// @collab trust="SUGGEST_ONLY"',
+      'func helper() {}',
+      '',
+      'type Invoice struct {',
+      '\t// @collab trust="READ_ONLY"',
+      '\tAmount int',
+      '}',
+    ].join('\n');
+    const astAfter = [
+      'package billing',
+      '',
+      '// @collab trust="SUPERVISED" owner="billing"',
+      'func (inv *Invoice) Total(',
+      '\trate float64,',
+      '\tcurrency string,',
+      ') int {',
+      '\treturn 0',
+      '}',
+      '',
+      '// @collab trust="SUGGEST_ONLY"',
+      'func renamedHelper() {}',
+      '',
+      'type Invoice struct {',
+      '\t// @collab trust="READ_ONLY"',
+      '\tAmount int',
+      '}',
+    ].join('\n');
+    const astRegions = collab.parseAnnotationContent(astBefore, 'invoice.go').annotations;
+    assert(
+      JSON.stringify(astRegions.map(a => declarations.astIdentity('invoice.go', astBefore, a))) === JSON.stringify([
+        { identity: 'billing.Invoice.Total' },
+        { identity: 'billing.helper' },
+        { error: 'no declaration starts on line 13' },
+      ]) &&
+        declarations.astIdentity('invoice.ts', astBefore, astRegions[0]).error === 'AST identities are only supported for Go',
+      'Identifies Go regions by package, type, and member, or says why not'
+    );
+
+    const astChanges = diff.diffAnnotationContent('invoice.go', astBefore, astAfter, collab.DEFAULT_TRUST_ALIASES);
+    assert(
+      JSON.stringify(astChanges.map(c => [c.kind, c.line, c.symbol])) === JSON.stringify([
+        ['downgrade', 4, 'func (inv *Invoice) Total('],
+        ['region_removed', 9, 'func helper() {}'],
+        ['region_added', 12, 'func renamedHelper() {}'],
+      ]),
+      'Pairs a re-signed function by identity; renaming the symbol itself is still a change',
+      `Got: ${JSON.stringify(astChanges)}`
+    );
+    const astUnparsed = diff.diffAnnotationContent('invoice.go', astBefore, astAfter + '\nvar broken = "', collab.DEFAULT_TRUST_ALIASES);
+    assert(
+      astUnparsed.some(c => c.kind === 'region_removed' && c.symbol.startsWith('func (inv *Invoice) Total(')),
+      'Falls back to first-line pairing when a version does not tokenize'
+    );
+
+    // ========================================
+    section('93. CONSTRAINTS ON EFFECTFUL CODE');
+    // ========================================
+
+    const effectsModule = await import('./dist/effects.js');
+    const refundSource = [
+      'package billing',
+      '',
+      '// @collab trust="SUGGEST_ONLY" owner="billing"',
+      'func (s *Svc) Refund(ctx context.Context, id string) error {',
+      '\t// stripeClient.Refunds.New in a comment is not a call',
+      '\tlog.Print("stripeClient.Charge(x)")',
+      '\ts.stripeClient.Refunds.New(&stripe.RefundParams{Charge: stripe.String(id)})',
+      '\treturn nil',
+      '}',
+      '',
+      '// @collab trust="SUPERVISED" constraints=["Only deletes expired holds"]',
+      'func (s *Svc) Purge(ctx context.Context) error {',
+      '\t_, err := s.tx.ExecContext(ctx, "DELETE FROM holds WHERE expired")',
+      '\treturn err',
+      '}',
+      '',
+      '// @collab trust="SUPERVISED"',
+      'func (s *Svc) Touch(ctx context.Context) error {',
+      '\t_, err := s.tx.ExecContext(ctx, "UPDATE holds SET seen = now()")',
+      '\treturn err',
+      '}',
+      '',
+      '// @collab trust="READ_ONLY"',
+      'func (s *Svc) Drop(ctx context.Context) error {',
+      '\t_, err := s.tx.ExecContext(ctx, "DELETE FROM holds")',
+      '\treturn err',
+      '}',
+    ].join('\n');
+    await fs.writeFile('refund.go', refundSource);
+    const effectfulCalls = ['stripeClient.*', { call: '*.ExecContext', args: '\\bDELETE\\b' }];
+    const effectful = effectsModule.findEffectfulCalls(refundSource, 'refund.go', effectfulCalls);
+    assert(
+      JSON.stringify(effectful.map(c => [c.callee, c.line, c.pattern])) === JSON.stringify([
+        ['s.stripeClient.Refunds.New', 7, 'stripeClient.*'],
+        ['s.tx.ExecContext', 13, '*.ExecContext'],
+        ['s.tx.ExecContext', 25, '*.ExecContext'],
+      ]),
+      'Finds effectful calls outside comments and strings, filtered by their arguments',
+      `Got: ${JSON.stringify(effectful)}`
+    );
+
+    const effectCheck = await check.checkFile(
+      { default_trust: 'AUTONOMOUS', policies: [] }, 'refund.go', undefined, { lint: { effectful_calls: effectfulCalls } }
+    );
+    const unconstrained = effectCheck.violations.filter(v => v.code === 'effectful-without-constraints');
+    assert(
+      unconstrained.length === 1 && unconstrained[0].line === 3 && unconstrained[0].severity === 'error' &&
+        unconstrained[0].message.startsWith('SUGGEST_ONLY region 4-9 calls s.stripeClient.Refunds.New'),
+      'Reports SUGGEST_ONLY and SUPERVISED regions making effectful calls without constraints',
+      `Got: ${JSON.stringify(effectCheck.violations)}`
+    );
+    const quietCheck = await check.checkFile({ default_trust: 'AUTONOMOUS', policies: [] }, 'refund.go');
+    assert(
+      !quietCheck.violations.some(v => v.code === 'effectful-without-constraints') &&
+        (() => { try { effectsModule.validateEffectfulCalls([{ call: 'db.Exec', args: '(' }]); return false; } catch (e) { return e.message.includes('effectful_calls[0].args'); } })(),
+      'Reports nothing without the setting, and rejects invalid argument patterns'
+    );
+    await fs.rm('refund.go');
+
+    // ========================================
+    section('94. BROWSER RESOLVER');
+    // ========================================
+
+    const browserModule = await import('./dist/browser.js');
+    const chargeSource = [
+      'package pay',
+      '',
+      '// @collab trust="READ_ONLY" owner="payments"',
+      'func Charge() {',
+      '\treturn',
+      '}',
+      '',
+      'func Other() {}',
+    ].join('\n');
+    const chargeLine = browserModule.resolveAt(chargeSource, 5, { filePath: 'pay/charge.go' });
+    const otherLine = browserModule.resolveAt(chargeSource, 8, { filePath: 'pay/charge.go' });
+    assert(
+      chargeLine.level === 'READ_ONLY' && chargeLine.owner === 'payments' && chargeLine.annotation?.line_start === 4 &&
+        otherLine.level === 'SUPERVISED' && otherLine.source === 'default' && otherLine.annotation === undefined,
+      'resolveAt resolves a line from the annotations in the source alone',
+      `Got: ${JSON.stringify([chargeLine, otherLine])}`
+    );
+
+    const browserConfig = { default_trust: 'AUTONOMOUS', policies: [{ pattern: 'pay/**', trust: 'SUGGEST_ONLY' }] };
+    const byPolicy = browserModule.resolveAt(chargeSource, 8, { filePath: 'pay/charge.go', config: browserConfig });
+    const byAlias = browserModule.resolveAt('// @collab frozen\nx()\n// @collab trust=BOGUS\ny()\n', 2, {
+      filePath: 'a.js', aliases: { frozen: 'READ_ONLY' },
+    });
+    assert(
+      byPolicy.level === 'SUGGEST_ONLY' && byPolicy.pattern === 'pay/**' &&
+        byAlias.level === 'READ_ONLY' && byAlias.errors.length === 1 && byAlias.errors[0].code === 'unknown-trust-level',
+      'resolveAt applies a given trust.yaml and aliases, and reports annotation errors',
+      `Got: ${JSON.stringify([byPolicy, byAlias])}`
+    );
+
+    // ========================================
+    section('95. NAMED BLOCK ENDS');
+    // ========================================
+
+    const interleaved = collab.parseAnnotationContent([
+      '// @collab:begin id="crypto-core" trust="READ_ONLY"',
+      'deriveKey()',
+      '// @collab:begin id="wire-format" trust="SUPERVISED"',
+      'sign()',
+      '// @collab:end id="crypto-core"',
+      'encodeFrame()',
+      '// @collab:end id="wire-format"',
+    ].join('\n'), 'frame.ts');
+    assert(
+      interleaved.errors.length === 0 &&
+        JSON.stringify(interleaved.annotations.map(a => [a.id, a.line_start, a.line_end])) ===
+          JSON.stringify([['crypto-core', 2, 4], ['wire-format', 4, 6]]),
+      'A named @collab:end closes its block out of nesting order',
+      `Got: ${JSON.stringify(interleaved)}`
+    );
+
+    const innermost = collab.parseAnnotationContent([
+      '// @collab:begin id="outer" trust="READ_ONLY"',
+      '// @collab:begin trust="SUPERVISED"',
+      'inner()',
+      '// @collab:end',
+      '// @collab:end id="outer"',
+    ].join('\n'), 'inner.ts');
+    assert(
+      innermost.errors.length === 0 &&
+        JSON.stringify(innermost.annotations.map(a => [a.trust, a.line_start, a.line_end])) ===
+          JSON.stringify([['READ_ONLY', 2, 4], ['SUPERVISED', 3, 3]]),
+      'An unnamed @collab:end still closes the innermost open block',
+      `Got: ${JSON.stringify(innermost)}`
+    );
+
+    const namedMismatch = collab.parseAnnotationContent([
+      '// @collab:begin id="keys" trust="READ_ONLY"',
+      '// @collab:begin id="keys" trust="SUPERVISED"',
+      'rotate()',
+      '// @collab:end id="keys"',
+      '// @collab:end id="keyz"',
+      '// @collab:end id="keys"',
+    ].join('\n'), 'keys.ts');
+    assert(
+      JSON.stringify(namedMismatch.errors.map(e => [e.line, e.code])) ===
+        JSON.stringify([[2, 'duplicate-block-id'], [5, 'block-not-open']]) &&
+        namedMismatch.annotations.map(a => a.line_end).join(',') === '5,3',
+      'Reports a named end with no open block and an id reused while open',
+      `Got: ${JSON.stringify(namedMismatch)}`
+    );
+
+    // ========================================
+    section('96. VERIFYING AUTHORS');
+    // ========================================
+
+    const verifyAuthorsModule = await import('./dist/verify-authors.js');
+    await fs.mkdir('blamed', { recursive: true });
+    process.chdir('blamed');
+    const gitAs = (name, date, ...args) => execFileSync(
+      'git', ['-c', `user.name=${name}`, '-c', `user.email=${name.toLowerCase()}@acme.com`, ...args],
+      { env: { ...process.env, GIT_AUTHOR_DATE: date, GIT_COMMITTER_DATE: date } }
+    );
+    const keysSource = [
+      '// @collab trust="READ_ONLY" owner="crypto-team"',
+      'export function sign() {',
+      '  return 1;',
+      '}',
+      '',
+      '// @collab trust="SUGGEST_ONLY" owner="@acme/crypto-team"',
+      'export function verify() {',
+      '  return 2;',
+      '}',
+      '',
+      '// @collab trust="SUPERVISED" owner="crypto-team"',
+      'export function other() {}',
+      '',
+    ].join('\n');
+    await fs.writeFile('keys.ts', keysSource);
+    gitAs('Alice', '2026-01-01T00:00:00Z', 'init', '-q');
+    gitAs('Alice', '2026-01-01T00:00:00Z', 'add', '-A');
+    gitAs('Alice', '2026-01-01T00:00:00Z', 'commit', '-qm', 'keys');
+    await fs.writeFile('keys.ts', keysSource.replace('return 2;', 'return 3;').replace('other() {}', 'other() { return 0; }'));
+    gitAs('Bob', '2026-02-01T00:00:00Z', 'commit', '-qam', 'bypass');
+    await fs.writeFile('keys.ts', keysSource.replace('return 2;', 'return 3;').replace('return 1;', 'return 5;'));
+    await fs.writeFile('untracked.ts', '// @collab trust="READ_ONLY" owner="crypto-team"\nexport const k = 1;\n');
+
+    const cryptoTeam = identityModule.createIdentityResolver({ identities: { 'alice@acme.com': 'crypto-team' } });
+    const authorAudit = await verifyAuthorsModule.verifyAuthors([], { identities: cryptoTeam });
+    const everyoneOwns = await verifyAuthorsModule.verifyAuthors([], {
+      identities: identityModule.createIdentityResolver({ identities: { 'alice@acme.com': 'crypto-team', 'bob@acme.com': 'crypto-team' } }),
+    });
+    process.chdir(TEST_DIR);
+    assert(
+      authorAudit.checked_files === 2 && authorAudit.audited_regions === 2 &&
+        JSON.stringify(authorAudit.findings.map(f => [f.file, f.line_start, f.trust, f.author, f.date.slice(0, 10)])) ===
+          JSON.stringify([['keys.ts', 7, 'SUGGEST_ONLY', 'Bob <bob@acme.com>', '2026-02-01']]),
+      'Flags restricted regions last committed by someone outside the owning team',
+      `Got: ${JSON.stringify(authorAudit)}`
+    );
+    assert(
+      everyoneOwns.findings.length === 0 &&
+        verifyAuthorsModule.formatAuthorAuditText(everyoneOwns) ===
+          'Verified 2 owned region(s) in 2 file(s): all last changed by an owner',
+      'Passes when the last author is on the owning team, skipping uncommitted lines and untracked files',
+      `Got: ${JSON.stringify(everyoneOwns)}`
+    );
+
+    await fs.mkdir('blamed-policy/.collab', { recursive: true });
+    await fs.mkdir('blamed-policy/src/payments', { recursive: true });
+    process.chdir('blamed-policy');
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUPERVISED\npolicies:\n  - pattern: "src/payments/**"\n    trust: READ_ONLY\n    owner: payments-team\n');
+    await fs.writeFile('src/payments/ledger.ts', 'export function post() {\n  return 1;\n}\n');
+    gitAs('Alice', '2026-01-01T00:00:00Z', 'init', '-q');
+    gitAs('Alice', '2026-01-01T00:00:00Z', 'add', '-A');
+    gitAs('Alice', '2026-01-01T00:00:00Z', 'commit', '-qm', 'ledger');
+    await fs.writeFile('src/payments/ledger.ts', 'export function post() {\n  return 2;\n}\n');
+    gitAs('Bob', '2026-02-01T00:00:00Z', 'commit', '-qam', 'bypass');
+    const policyAudit = await verifyAuthorsModule.verifyAuthors(['src'], {
+      identities: identityModule.createIdentityResolver({ identities: { 'alice@acme.com': 'payments-team' } }),
+    });
+    process.chdir(TEST_DIR);
+    await fs.rm('blamed-policy', { recursive: true });
+    assert(
+      policyAudit.audited_regions === 1 &&
+        JSON.stringify(policyAudit.findings.map(f => [f.file, f.line_start, f.line_end, f.trust, f.source, f.pattern, f.author])) ===
+          JSON.stringify([['src/payments/ledger.ts', 1, 3, 'READ_ONLY', 'policy', 'src/payments/**', 'Bob <bob@acme.com>']]) &&
+        verifyAuthorsModule.formatAuthorAuditText(policyAudit).includes('READ_ONLY code (trust.yaml policy "src/payments/**") owned by payments-team'),
+      'Flags a READ_ONLY file owned only through a trust.yaml policy when a non-owner changed it',
+      `Got: ${JSON.stringify(policyAudit)}`
+    );
+
+    // ========================================
+    section('97. ONE VIOLATION PER REGION');
+    // ========================================
+
+    const noisySource = [
+      '// @collab trust="READONLY" min_approvals="0"',
+      'export function f() {}',
+      '',
+      '// @collab trust="AUTONOMOUS" constraints=["y"]',
+      'export function g() {}',
+      '',
+      '// @collab trust="BOGUS"',
+      'export function h() {}',
+    ].join('\n');
+    await fs.writeFile('noisy.ts', noisySource);
+    const noisyReport = await check.checkFiles(['noisy.ts']);
+    const noisyAnnotations = new Map([['noisy.ts', collab.parseAnnotationContent(noisySource, 'noisy.ts').annotations]]);
+    const deduped = check.dedupeByRegion(noisyReport, noisyAnnotations, { listSuppressed: true });
+    const dedupedFile = deduped.files[0];
+    assert(
+      JSON.stringify(dedupedFile.violations.map(v => [v.line, v.code, v.suppressed_count])) === JSON.stringify([
+        [1, 'unknown-trust-level', 1],
+        [7, 'unknown-trust-level', undefined],
+        [4, 'autonomous-with-constraints', undefined],
+      ]) &&
+        JSON.stringify(dedupedFile.suppressed.map(v => v.code)) === JSON.stringify(['invalid-min-approvals']) &&
+        deduped.total_suppressed === 1 && deduped.total_violations === noisyReport.total_violations,
+      'Keeps the first most severe violation per region and lists the rest as suppressed',
+      `Got: ${JSON.stringify(deduped)}`
+    );
+
+    const mixedSeverity = check.dedupeByRegion({
+      files: [{
+        file: 'mixed.ts',
+        violations: [
+          { file: 'mixed.ts', line: 3, rule: 'annotation', code: 'region-too-large', severity: 'warning', message: 'w' },
+          { file: 'mixed.ts', line: 5, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'e' },
+          { file: 'mixed.ts', line: 20, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'outside' },
+        ],
+      }],
+      total_violations: 2,
+      total_warnings: 1,
+    }, new Map([['mixed.ts', [{ trust: 'READ_ONLY', line_start: 4, line_end: 10, comment_start: 3, comment_end: 3 }]]]));
+    assert(
+      JSON.stringify(mixedSeverity.files[0].violations.map(v => [v.line, v.suppressed_count])) ===
+        JSON.stringify([[5, 1], [20, undefined]]) &&
+        check.formatText(mixedSeverity).includes('mixed.ts:5: [read-only-edit] e (+1 more in this region)') &&
+        check.formatText(mixedSeverity).endsWith('(1 collapsed by --dedupe=region)'),
+      'Errors outrank warnings in a region, and the text output counts what was collapsed',
+      `Got: ${JSON.stringify(mixedSeverity)}`
+    );
+    assert(
+      check.dedupeByRegion(noisyReport, noisyAnnotations).files[0].suppressed === undefined,
+      'Leaves the collapsed violations out unless asked to list them'
+    );
+
+    const governedReport = {
+      files: [{
+        file: 'src/governed.ts',
+        violations: [
+          { file: 'src/governed.ts', line: 2, rule: 'annotation', code: 'unknown-owner', severity: 'error', message: 'owner' },
+          { file: 'src/governed.ts', line: 30, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'locked' },
+          { file: 'src/governed.ts', line: 41, rule: 'annotation', code: 'unknown-owner', severity: 'error', message: 'a' },
+          { file: 'src/governed.ts', line: 44, rule: 'read-only-edit', code: 'read-only-edit', severity: 'error', message: 'b' },
+        ],
+      }],
+      total_violations: 4,
+      total_parse_errors: 0,
+      total_warnings: 0,
+    };
+    const governedConfig = {
+      default_trust: 'SUPERVISED',
+      policies: [],
+      regions: [
+        { file: 'governed.ts', line_start: 25, line_end: 35, trust: 'READ_ONLY' },
+        { file: 'governed.ts', line_start: 40, line_end: 45, trust: 'SUGGEST_ONLY' },
+      ],
+    };
+    const keptLines = deduplicated => JSON.stringify(deduplicated.files[0].violations.map(v => [v.line, v.suppressed_count]));
+    assert(
+      keptLines(check.dedupeByRegion(governedReport, new Map())) === JSON.stringify([[2, 3]]) &&
+        keptLines(check.dedupeByRegion(governedReport, new Map(), { config: governedConfig })) ===
+          JSON.stringify([[2, undefined], [30, undefined], [41, 1]]) &&
+        keptLines(check.dedupeByRegion(governedReport, new Map(), { config: governedConfig, priority: ['read-only-edit'] })) ===
+          JSON.stringify([[2, undefined], [30, undefined], [44, 1]]) &&
+        keptLines(check.dedupeByRegion(governedReport, new Map(), { priority: ['nothing', 'read-only-edit'] })) === JSON.stringify([[30, 3]]),
+      'trust.yaml regions are regions of their own, and the configured priority picks the violation kept',
+      `Got: ${keptLines(check.dedupeByRegion(governedReport, new Map(), { config: governedConfig, priority: ['read-only-edit'] }))}`
+    );
+    await fs.rm('noisy.ts');
+
+    // ========================================
+    section('98. GO ASSEMBLY AND CGO PREAMBLES');
+    // ========================================
+
+    const asmAnnotations = collab.parseAnnotationContent([
+      '#include "textflag.h"',
+      '',
+      '// @collab trust="READ_ONLY" owner="crypto"',
+      'TEXT ·mulAdd(SB), NOSPLIT, $0-32',
+      '\tMOVQ a+0(FP), AX',
+      'loop:',
+      '\tJNE loop',
+      '\tRET',
+      '',
+      '; @collab trust="SUGGEST_ONLY"',
+      'TEXT ·other(SB), NOSPLIT, $0',
+      '\tRET',
+      '',
+      'TEXT ·third(SB), NOSPLIT, $0',
+      '// @collab trust="SUPERVISED"',
+      'done:',
+      '\tRET',
+      'again:',
+      '\tRET',
+    ].join('\n'), 'mul_amd64.s').annotations;
+    assert(
+      JSON.stringify(asmAnnotations.map(a => [a.trust, a.line_start, a.line_end])) === JSON.stringify([
+        ['READ_ONLY', 4, 8], ['SUGGEST_ONLY', 11, 12], ['SUPERVISED', 16, 17],
+      ]) && collab.ANNOTATABLE_EXTENSIONS.includes('s'),
+      'Scopes assembly annotations to a TEXT function or a label, in // and ; comments',
+      `Got: ${JSON.stringify(asmAnnotations)}`
+    );
+
+    const cgoResult = collab.parseAnnotationContent([
+      'package ffi',
+      '',
+      '/*',
+      '#include <stdint.h>',
+      '',
+      '// @collab trust="READ_ONLY" owner="crypto"',
+      'static uint64_t mix(uint64_t x) {',
+      '  return x * 31;',
+      '}',
+      '',
+      '// @collab trust="SUGGEST_ONLY"',
+      'extern int helper(int);',
+      '*/',
+      'import "C"',
+      '',
+      '// @collab trust="SUPERVISED"',
+      'func Mix(x uint64) uint64 {',
+      '\treturn uint64(C.mix(C.uint64_t(x)))',
+      '}',
+    ].join('\n'), 'ffi.go');
+    const lineCgo = collab.parseAnnotationContent([
+      'package ffi',
+      '',
+      '// // @collab trust="READ_ONLY"',
+      '// static int f(int x) {',
+      '//   return x;',
+      '// }',
+      'import "C"',
+    ].join('\n'), 'ffi_lines.go');
+    assert(
+      cgoResult.errors.length === 0 &&
+        JSON.stringify(cgoResult.annotations.map(a => [a.trust, a.line_start, a.line_end])) === JSON.stringify([
+          ['READ_ONLY', 7, 9], ['SUGGEST_ONLY', 12, 12], ['SUPERVISED', 17, 19],
+        ]) &&
+        JSON.stringify(lineCgo.annotations.map(a => [a.line_start, a.line_end])) === JSON.stringify([[4, 6]]),
+      'Scopes annotations in a cgo preamble to the C declaration below them',
+      `Got: ${JSON.stringify([cgoResult, lineCgo])}`
+    );
+
+    // ========================================
+    section('99. GOVERNANCE MANIFEST');
+    // ========================================
+
+    const manifestModule = await import('./dist/manifest.js');
+    const { createHash } = await import('crypto');
+    await fs.mkdir('manifested/.collab', { recursive: true });
+    process.chdir('manifested');
+    await fs.writeFile('.collab/trust.yaml', [
+      'default_trust: SUPERVISED',
+      'policies: []',
+      'regions:',
+      '  - file: pay.ts',
+      '    line_start: 6',
+      '    line_end: 6',
+      '    trust: SUGGEST_ONLY',
+      'budgets:',
+      '  - pattern: "**"',
+      '    max_autonomous_fraction: 0.5',
+      '',
+    ].join('\n'));
+    await fs.writeFile('.collab/config.yaml', 'agents:\n  bot:\n    max_trust: SUPERVISED\n');
+    const paySource = [
+      '// @collab trust="READ_ONLY" owner="payments" constraints=["idempotent"]',
+      'export function charge() {',
+      '  return 1;',
+      '}',
+      '',
+      'export function refund() {}',
+      '',
+    ].join('\n');
+    await fs.writeFile('pay.ts', paySource);
+    const manifest = await manifestModule.buildManifest([]);
+    const againManifest = await manifestModule.buildManifest([]);
+    process.chdir(TEST_DIR);
+    const paySha = createHash('sha256').update(paySource).digest('hex');
+    assert(
+      manifest.schema_version === manifestModule.MANIFEST_SCHEMA_VERSION && manifest.tool.version === '1.0.0' &&
+        manifest.files.length === 1 && manifest.files[0].sha256 === paySha &&
+        manifest.content_hash === createHash('sha256').update(`${paySha}  pay.ts\n`).digest('hex') &&
+        JSON.stringify(manifest.files[0].regions.map(r => [r.line_start, r.line_end, r.level, r.owner, r.constraints, r.source])) ===
+          JSON.stringify([[2, 4, 'READ_ONLY', 'payments', ['idempotent'], 'annotation'], [6, 6, 'SUGGEST_ONLY', undefined, undefined, 'region']]),
+      'Lists every resolved region with its provenance, and hashes the files as sha256sum would',
+      `Got: ${JSON.stringify(manifest)}`
+    );
+    assert(
+      manifest.defaults.trust === 'SUPERVISED' && manifest.defaults.generated === 'READ_ONLY' &&
+        manifest.caps.budgets[0].max_autonomous_fraction === 0.5 && manifest.caps.agents.bot.max_trust === 'SUPERVISED' &&
+        manifest.config.regions.length === 1 &&
+        collab.stableStringify({ ...manifest, generated_at: '' }) === collab.stableStringify({ ...againManifest, generated_at: '' }),
+      'Captures the merged config, its caps and defaults, and is identical across runs but for generated_at',
+      `Got: ${JSON.stringify(manifest)}`
+    );
+    await fs.rm('manifested', { recursive: true });
+    const chargeRegion = { file: 'pay/charge.ts', line_start: 1, line_end: 2, trust: 'READ_ONLY' };
+    assert(
+      collab.regionMatchesFile(chargeRegion, 'src/pay/charge.ts') && collab.regionMatchesFile(chargeRegion, './pay/charge.ts') &&
+        !collab.regionMatchesFile(chargeRegion, 'src/xpay/charge.ts') &&
+        collab.resolveTrustWithAnnotations(
+          { default_trust: 'SUPERVISED', policies: [], regions: [{ ...chargeRegion, file: 'payments.ts' }] }, 'xpayments.ts', [], 1, 1
+        ).level === 'SUPERVISED',
+      'trust.yaml regions name a file by whole path segments, not any suffix'
+    );
+
+    // ========================================
+    section('100. CHECK EXIT CODES AND JUNIT');
+    // ========================================
+
+    await fs.mkdir('exit-codes/.collab', { recursive: true });
+    process.chdir('exit-codes');
+    await fs.writeFile(
+      '.collab/trust.yaml',
+      'default_trust: SUPERVISED\npolicies:\n  - pattern: "b*.ts"\n    trust: READ_ONLY\n  - pattern: "*h.ts"\n    trust: SUPERVISED\n'
+    );
+    await fs.writeFile('clean.ts', '// @collab trust="SUPERVISED"\nexport const a = 1;\n');
+    await fs.writeFile('breach.ts', 'export const b = 1;\n');
+    await fs.writeFile('broken.ts', '// @collab trust="READONLY"\nexport const c = 1;\n');
+    const runCheckQuietly = async (...args) => {
+      const output = [];
+      const [log, error] = [console.log, console.error];
+      console.log = (...parts) => output.push(parts.join(' '));
+      console.error = () => {};
+      try {
+        return { code: await check.runCheck(args), output: output.join('\n') };
+      } finally {
+        console.log = log;
+        console.error = error;
+      }
+    };
+    const cleanRun = await runCheckQuietly('clean.ts');
+    const breachRun = await runCheckQuietly('--format=junit', 'clean.ts', 'breach.ts');
+    const brokenRun = await runCheckQuietly('--format=json', 'breach.ts', 'broken.ts');
+    const badOptionRun = await runCheckQuietly('--format=yaml');
+    process.chdir(TEST_DIR);
+    await fs.rm('exit-codes', { recursive: true });
+    assert(
+      cleanRun.code === 0 && breachRun.code === 1 && brokenRun.code === 2 && badOptionRun.code === 2,
+      'Exits 0 when clean, 1 for violations, and 2 for malformed annotations or bad arguments',
+      `Got: ${[cleanRun, breachRun, brokenRun, badOptionRun].map(r => r.code).join(', ')}`
+    );
+    const brokenReport = JSON.parse(brokenRun.output);
+    assert(
+      brokenReport.total_parse_errors === 1 && brokenReport.total_violations === 2 &&
+        brokenReport.files.find(f => f.file === 'broken.ts').parse_errors === 1,
+      'Counts malformed annotations apart from the violations they are listed with',
+      `Got: ${brokenRun.output}`
+    );
+    assert(
+      breachRun.output.startsWith('<?xml version="1.0" encoding="UTF-8"?>') &&
+        breachRun.output.includes('<testsuites name="collab-check" tests="2" failures="1" errors="0">') &&
+        breachRun.output.includes('<testcase classname="collab" name="clean.ts"/>') &&
+        /<testcase classname="collab" name="breach\.ts">\s*<failure type="ambiguous-policy" message="[^"]+">breach\.ts:1: /.test(breachRun.output),
+      'Writes one JUnit testcase per file, with a failure per error-severity violation',
+      `Got: ${breachRun.output}`
+    );
+
+    // ========================================
+    section('101. ARCHIVE SCANNING');
+    // ========================================
+
+    const archive = await import('./dist/archive.js');
+    const makeTar = files => {
+      const blocks = [];
+      for (const [name, text] of files) {
+        const data = Buffer.from(text);
+        const header = Buffer.alloc(512);
+        header.write(name, 0);
+        header.write(`${data.length.toString(8).padStart(11, '0')}\0`, 124);
+        header.write('0', 156);
+        blocks.push(header, data, Buffer.alloc((512 - (data.length % 512)) % 512));
+      }
+      return Buffer.concat([...blocks, Buffer.alloc(1024)]);
+    };
+    // Entries are [name, data, method]; with method 8, data is already raw deflate
+    const makeZip = files => {
+      const locals = [];
+      const centrals = [];
+      let offset = 0;
+      for (const [name, data, method] of files) {
+        const nameBytes = Buffer.from(name);
+        const local = Buffer.alloc(30);
+        local.writeUInt32LE(0x04034b50, 0);
+        local.writeUInt16LE(method, 8);
+        local.writeUInt32LE(data.length, 18);
+        local.writeUInt16LE(nameBytes.length, 26);
+        const central = Buffer.alloc(46);
+        central.writeUInt32LE(0x02014b50, 0);
+        central.writeUInt16LE(method, 10);
+        central.writeUInt32LE(data.length, 20);
+        central.writeUInt16LE(nameBytes.length, 28);
+        central.writeUInt32LE(offset, 42);
+        locals.push(local, nameBytes, data);
+        centrals.push(central, nameBytes);
+        offset += 30 + nameBytes.length + data.length;
+      }
+      const directory = Buffer.concat(centrals);
+      const eocd = Buffer.alloc(22);
+      eocd.writeUInt32LE(0x06054b50, 0);
+      eocd.writeUInt16LE(files.length, 8);
+      eocd.writeUInt16LE(files.length, 10);
+      eocd.writeUInt32LE(directory.length, 12);
+      eocd.writeUInt32LE(offset, 16);
+      return Buffer.concat([...locals, directory, eocd]);
+    };
+
+    const releaseTgz = zlib.gzipSync(makeTar([
+      ['pkg/src/pay.ts', 'export const x = 1;\n// @collab trust="READ_ONLY" owner="payments"\nexport function pay() {}\n'],
+      ['pkg/README.md', '@collab trust="BOGUS"\n'],
+      ['pkg/src/a.go', '// @collab trust="SUGGEST_ONLY"\nfunc A() {}\n'],
+    ]));
+    const tgzResults = await archive.scanArchive(releaseTgz, 'tgz', { aliases: {} });
+    assert(
+      JSON.stringify(tgzResults.map(r => [r.path, r.annotations.map(a => [a.line_start, a.trust, a.owner]), r.errors.length])) ===
+        JSON.stringify([['pkg/src/a.go', [[2, 'SUGGEST_ONLY', undefined]], 0], ['pkg/src/pay.ts', [[3, 'READ_ONLY', 'payments']], 0]]),
+      'Scans the source files of a gzipped tar in path order, keeping in-archive paths',
+      `Got: ${JSON.stringify(tgzResults)}`
+    );
+
+    const bigSource = `// @collab trust="READ_ONLY"\nexport const big = "${'x'.repeat(4096)}";\n`;
+    const releaseZip = makeZip([
+      ['src/stored.ts', Buffer.from('// @collab trust="SUPERVISED"\nexport const s = 1;\n'), 0],
+      ['src/big.ts', zlib.deflateRawSync(bigSource), 8],
+      ['assets/logo.png', Buffer.from('not deflate data at all'), 8],
+    ]);
+    const zipResults = await archive.scanArchive(releaseZip, 'zip', { aliases: {} });
+    let cappedError;
+    try {
+      archive.readZipEntries(releaseZip, { include: archive.isAnnotatableEntry, maxEntryBytes: 1024 });
+    } catch (error) {
+      cappedError = error;
+    }
+    assert(
+      JSON.stringify(zipResults.map(r => [r.path, r.annotations[0]?.trust])) ===
+        JSON.stringify([['src/big.ts', 'READ_ONLY'], ['src/stored.ts', 'SUPERVISED']]) &&
+        /Zip entry "src\/big\.ts" is larger than 1024 bytes uncompressed/.test(cappedError?.message),
+      'Reads stored and deflated zip entries, never inflates non-source entries, and caps inflated size',
+      `Got: ${JSON.stringify(zipResults)} / ${cappedError?.message}`
+    );
+
+    await fs.writeFile('release.tar', makeTar([['lib/ok.ts', '// @collab trust="SUPERVISED"\nexport const ok = 1;\n']]));
+    await fs.writeFile('broken.tar', makeTar([['lib/bad.ts', '// @collab trust="READONLY"\nexport const bad = 1;\n']]));
+    const scanArchiveQuietly = async (...args) => {
+      const output = [];
+      const [log, error] = [console.log, console.error];
+      console.log = (...parts) => output.push(parts.join(' '));
+      console.error = (...parts) => output.push(parts.join(' '));
+      try {
+        return { code: await archive.runScanArchive(args), output: output.join('\n') };
+      } finally {
+        console.log = log;
+        console.error = error;
+      }
+    };
+    const okArchiveRun = await scanArchiveQuietly('release.tar');
+    const badArchiveRun = await scanArchiveQuietly('broken.tar');
+    const unknownArchiveRun = await scanArchiveQuietly('release.rar');
+    await fs.rm('release.tar');
+    await fs.rm('broken.tar');
+    assert(
+      okArchiveRun.code === 0 &&
+        okArchiveRun.output === 'lib/ok.ts:2  SUPERVISED\nScanned 1 file(s) in release.tar: 1 annotation(s)' &&
+        badArchiveRun.code === 2 && badArchiveRun.output.includes('lib/bad.ts:1: [unknown-trust-level]') &&
+        unknownArchiveRun.code === 2 && unknownArchiveRun.output.startsWith('Unknown archive format'),
+      'scan-archive prints each annotation and exits 2 on malformed annotations or an unknown format',
+      `Got: ${JSON.stringify([okArchiveRun, badArchiveRun, unknownArchiveRun])}`
+    );
+
+    // ========================================
+    section('102. REMOTE EXTENDS');
+    // ========================================
+
+    const orgPolicyText = 'default_trust: SUGGEST_ONLY\npolicies:\n  - pattern: "infra/**"\n    trust: READ_ONLY\n';
+    const orgPolicyPin = createHash('sha256').update(orgPolicyText).digest('hex');
+    let policyFetches = 0;
+    let policyStatus = 200;
+    const policyServer = http.createServer((req, res) => {
+      policyFetches++;
+      res.writeHead(policyStatus, { 'Content-Type': 'text/yaml' });
+      res.end(policyStatus === 200 ? orgPolicyText : 'unavailable');
+    });
+    await new Promise(resolve => policyServer.listen(0, '127.0.0.1', resolve));
+    const orgPolicyUrl = `http://127.0.0.1:${policyServer.address().port}/org.yaml`;
+    const extendsRoot = path.join(TEST_DIR, 'remote-extends');
+    const extendsOptions = { rootDir: extendsRoot };
+    const policyCacheFile = async () => path.join(
+      extendsRoot, policyModule.POLICY_CACHE_DIR, (await fs.readdir(path.join(extendsRoot, policyModule.POLICY_CACHE_DIR)))[0]
+    );
+    const ageCache = async (ms) => {
+      const then = new Date(Date.now() - ms);
+      await fs.utimes(await policyCacheFile(), then, then);
+    };
+
+    const fetchedPolicies = await policyModule.loadExtendedPolicies(orgPolicyUrl, extendsOptions);
+    const cachedPolicies = await policyModule.loadExtendedPolicies(orgPolicyUrl, extendsOptions);
+    const fetchesWhileFresh = policyFetches;
+    await policyModule.loadExtendedPolicies(orgPolicyUrl, { ...extendsOptions, refresh: true });
+    const fetchesAfterRefresh = policyFetches;
+    await ageCache(2 * 60 * 60 * 1000);
+    await policyModule.loadExtendedPolicies(orgPolicyUrl, extendsOptions);
+    const fetchesAfterExpiry = policyFetches;
+    assert(
+      fetchedPolicies[0]?.default_trust === 'SUGGEST_ONLY' && fetchedPolicies[0].policies[0].pattern === 'infra/**' &&
+        JSON.stringify(cachedPolicies) === JSON.stringify(fetchedPolicies) &&
+        fetchesWhileFresh === 1 && fetchesAfterRefresh === 2 && fetchesAfterExpiry === 3,
+      'Fetches an extended URL once, reuses the cache within its TTL, and refetches when asked or expired',
+      `Got: ${JSON.stringify({ fetchedPolicies, fetchesWhileFresh, fetchesAfterRefresh, fetchesAfterExpiry })}`
+    );
+
+    policyStatus = 503;
+    await ageCache(2 * 60 * 60 * 1000);
+    const originalWarn = console.error;
+    const refreshWarnings = [];
+    console.error = (...parts) => refreshWarnings.push(parts.join(' '));
+    let stalePolicies;
+    try {
+      stalePolicies = await policyModule.loadExtendedPolicies(orgPolicyUrl, extendsOptions);
+    } finally {
+      console.error = originalWarn;
+    }
+    const offlineUnknown = await policyModule.loadExtendedPolicies(`${orgPolicyUrl}?never`, { ...extendsOptions, offline: true });
+    let unreachableError;
+    await policyModule.loadExtendedPolicies(`${orgPolicyUrl}?new`, extendsOptions).catch(error => { unreachableError = error; });
+    assert(
+      stalePolicies[0]?.default_trust === 'SUGGEST_ONLY' && /could not refresh .* \(HTTP 503\); using cached copy/.test(refreshWarnings[0]) &&
+        offlineUnknown.length === 0 && /Cannot fetch extended policy .*\?new: HTTP 503/.test(unreachableError?.message),
+      'Falls back to a stale copy when a refresh fails, and skips never-fetched URLs offline',
+      `Got: ${JSON.stringify({ refreshWarnings, offlineUnknown, unreachable: unreachableError?.message })}`
+    );
+
+    policyStatus = 200;
+    await fs.rm(extendsRoot, { recursive: true });
+    const pinnedPolicies = await policyModule.loadExtendedPolicies({ url: orgPolicyUrl, sha256: orgPolicyPin }, extendsOptions);
+    await ageCache(30 * 24 * 60 * 60 * 1000);
+    const fetchesBeforePinnedReuse = policyFetches;
+    await policyModule.loadExtendedPolicies({ url: orgPolicyUrl, sha256: orgPolicyPin.toUpperCase() }, extendsOptions);
+    let pinError;
+    await policyModule.loadExtendedPolicies({ url: orgPolicyUrl, sha256: '0'.repeat(64) }, extendsOptions)
+      .catch(error => { pinError = error; });
+    const cacheAfterBadPin = await fs.readFile(await policyCacheFile(), 'utf-8');
+    policyServer.close();
+    await fs.rm(extendsRoot, { recursive: true });
+    assert(
+      pinnedPolicies[0]?.default_trust === 'SUGGEST_ONLY' && policyFetches === fetchesBeforePinnedReuse + 1 &&
+        new RegExp(`sha256 mismatch \\(pinned 0{64}, got ${orgPolicyPin}\\)`).test(pinError?.message) &&
+        cacheAfterBadPin === orgPolicyText,
+      'Verifies a sha256 pin, reuses a pinned copy past the TTL, and rejects content that fails the pin',
+      `Got: ${JSON.stringify({ fetches: policyFetches, error: pinError?.message })}`
+    );
+
+    // ========================================
+    section('103. HOOK TRUST RESOLUTION');
+    // ========================================
+
+    const hookUtils = await import('./dist/hooks/utils.js');
+    const hookConfig = {
+      default_trust: 'SUPERVISED',
+      policies: [{ pattern: 'src/**', trust: 'SUGGEST_ONLY' }],
+      regions: [{ file: 'pay/charge.ts', line_start: 1, line_end: 5, trust: 'READ_ONLY' }],
+      test_trust: 'AUTONOMOUS',
+      profiles: { relaxed: { overrides: { SUGGEST_ONLY: 'SUPERVISED' } } },
+      active_profile: 'relaxed',
+    };
+    const hookPackage = { file: 'pkg/doc.go', line: 2, trust: 'READ_ONLY', owner: 'pkg-team' };
+    const hookCases = [
+      ['src/pay/charge.ts', 2, undefined, false],
+      ['src/xpay/charge.ts', 2, undefined, false],
+      ['pkg/a.go', undefined, hookPackage, false],
+      ['pkg/a_test.go', undefined, hookPackage, true],
+    ];
+    const hookResolved = [];
+    for (const [file, line, pkg, testFile] of hookCases) {
+      hookResolved.push(await hookUtils.getTrustLevel(hookConfig, file, line, line, pkg, testFile));
+    }
+    assert(
+      hookCases.every(([file, line, pkg, testFile], i) =>
+        JSON.stringify(hookResolved[i]) ===
+          JSON.stringify(collab.resolveTrustWithAnnotations(hookConfig, file, [], line, line, [], false, pkg, testFile))
+      ) &&
+        hookResolved.map(r => r.level).join() === 'READ_ONLY,SUPERVISED,READ_ONLY,AUTONOMOUS',
+      'Hooks resolve regions, profiles, test files, and package defaults exactly as the CLI does'
+    );
+
+    await fs.mkdir('hook-project/.collab', { recursive: true });
+    await fs.mkdir('hook-project/pkg', { recursive: true });
+    process.chdir('hook-project');
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUPERVISED\nprofiles:\n  dev: {}\n');
+    await fs.writeFile('.collab/config.yaml', 'aliases:\n  locked: READ_ONLY\n');
+    await fs.writeFile('pkg/doc.go', 'package pkg\n\n// @collab:package locked owner=["a", "b"]\n');
+    process.env.COLLAB_PROFILE = 'missing';
+    const hookTrustConfig = await hookUtils.loadTrustConfig();
+    delete process.env.COLLAB_PROFILE;
+    const hookPackageDefault = await hookUtils.loadPackageDefault('pkg/a.go');
+    process.chdir(TEST_DIR);
+    await fs.rm('hook-project', { recursive: true });
+    assert(
+      hookTrustConfig?.active_profile === undefined && JSON.stringify(hookTrustConfig.policies) === '[]' &&
+        hookPackageDefault?.trust === 'READ_ONLY' && JSON.stringify(hookPackageDefault.owner) === JSON.stringify(['a', 'b']),
+      'Hooks ignore an unknown COLLAB_PROFILE and read package defaults with config.yaml aliases',
+      `Got: ${JSON.stringify({ hookTrustConfig, hookPackageDefault })}`
+    );
+
+    const editedSource = 'const a = 1;\nfunction f() {\n  return a;\n}\nconst a2 = 1;\n';
+    assert(
+      JSON.stringify(hookUtils.findEditedLines(editedSource, 'function f() {\n  return a;\n}')) ===
+        JSON.stringify({ line_start: 2, line_end: 4 }) &&
+        hookUtils.findEditedLines(editedSource, ' = 1;') === undefined &&
+        hookUtils.findEditedLines(editedSource, 'missing') === undefined &&
+        (await hookUtils.getTrustLevel(hookConfig, 'pay/charge.ts', 2, 4)).level === 'READ_ONLY' &&
+        (await hookUtils.getTrustLevel(hookConfig, 'pay/charge.ts', 6, 6)).level === 'SUPERVISED',
+      'The pre-edit hook resolves an edit by the lines its old_string spans, when it occurs once'
+    );
+
+    // The hook itself, run as Claude Code runs it: tool input on stdin, the decision in the exit code
+    const preEditHook = fileURLToPath(new URL('./dist/hooks/pre-edit.js', import.meta.url));
+    const runPreEdit = (input) =>
+      spawnSync(process.execPath, [...process.execArgv, preEditHook], { input: JSON.stringify(input), encoding: 'utf-8' });
+    await fs.mkdir('hook-annotated/.collab', { recursive: true });
+    process.chdir('hook-annotated');
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUPERVISED\n');
+    await fs.writeFile('.collab/config.yaml', 'aliases:\n  locked: READ_ONLY\n');
+    await fs.writeFile(
+      'keys.ts',
+      'export function free() {\n  return 0;\n}\n\n// @collab locked owner="security-team"\nexport function sign() {\n  return 1;\n}\n'
+    );
+    const lockedEdit = runPreEdit({ file_path: 'keys.ts', old_string: '  return 1;', new_string: '  return 2;' });
+    const freeHookEdit = runPreEdit({ file_path: 'keys.ts', old_string: '  return 0;', new_string: '  return 3;' });
+    process.chdir(TEST_DIR);
+    await fs.rm('hook-annotated', { recursive: true });
+    assert(
+      lockedEdit.status === 1 && lockedEdit.stderr.includes('BLOCKED: keys.ts is marked READ_ONLY') &&
+        lockedEdit.stderr.includes('Owner: security-team') && freeHookEdit.status === 0,
+      'The pre-edit hook blocks an edit inside an annotated READ_ONLY function and allows one outside it',
+      `Got: ${JSON.stringify({ lockedEdit: [lockedEdit.status, lockedEdit.stderr], freeHookEdit: [freeHookEdit.status, freeHookEdit.stderr] })}`
+    );
+
+    await fs.mkdir('hook-suggest/.collab', { recursive: true });
+    process.chdir('hook-suggest');
+    await fs.writeFile('.collab/trust.yaml', 'default_trust: SUGGEST_ONLY\n');
+    await fs.writeFile('review.ts', 'const r = 1;\n');
+    const substantiveHookEdit = { file_path: 'review.ts', old_string: 'const r = 1;', new_string: 'const r = 2;' };
+    const trivialHookEdit = { file_path: 'review.ts', old_string: 'const r = 1;', new_string: 'const r = 1; // one' };
+    const warnedEdit = runPreEdit(substantiveHookEdit);
+    await fs.writeFile('.collab/config.yaml', 'suggest:\n  auto_approve_trivial: true\n  block_direct_edits: true\n');
+    const blockedSuggestEdit = runPreEdit(substantiveHookEdit);
+    const approvedTrivialEdit = runPreEdit(trivialHookEdit);
+    process.chdir(TEST_DIR);
+    await fs.rm('hook-suggest', { recursive: true });
+    assert(
+      warnedEdit.status === 0 && warnedEdit.stderr.includes('WARNING: review.ts is marked SUGGEST_ONLY') &&
+        blockedSuggestEdit.status === 1 && blockedSuggestEdit.stderr.includes('BLOCKED: review.ts is marked SUGGEST_ONLY') &&
+        approvedTrivialEdit.status === 0 && approvedTrivialEdit.stderr.includes('Auto-approved trivial edit'),
+      'The pre-edit hook warns about SUGGEST_ONLY edits by default and blocks them only with suggest.block_direct_edits',
+      `Got: ${JSON.stringify([warnedEdit, blockedSuggestEdit, approvedTrivialEdit].map(r => [r.status, r.stderr]))}`
+    );
+
     // ========================================
     section('SUMMARY');
     // ========================================
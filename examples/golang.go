@@ -1,8 +1,8 @@
 // Package examples demonstrates all annotation patterns supported by
 // collab-claude-code for trust level management in Go.
 //
-// Go uses brace-based scope detection, so annotations automatically
-// apply to the entire function/block following them.
+// Annotations apply to the entire declaration following them, as the Go
+// parser delimits it. See golang_linux.go for files with build constraints.
 package examples
 
 import (
@@ -385,6 +385,7 @@ func (s *UserService) UpdateProfile(ctx context.Context, userID string, updates
 // Claims represents JWT claims.
 type Claims struct {
 	UserID string `json:"user_id"`
+	// @collab trust="READ_ONLY" owner="security-team"
 	jwt.RegisteredClaims
 }
 
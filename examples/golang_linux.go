@@ -0,0 +1,35 @@
+// @collab trust="SUPERVISED" owner="platform-team"
+// @collab intent="Linux-only syscall wrappers"
+//go:build linux && amd64
+// +build linux,amd64
+
+// Package examples shows annotations in a file behind build constraints.
+//
+// The annotation above the //go:build line sits on the package clause, so it
+// governs the whole file. Build constraints and //go: directives are skipped
+// when attaching annotations, and the file is scanned even when the current
+// platform would exclude it from the build.
+package examples
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// @collab trust="READ_ONLY" owner="kernel-team"
+// @collab constraints=["Must not allocate", "Must match the kernel ABI"]
+//go:noinline
+func rawIoctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// @collab trust="SUGGEST_ONLY"
+func PageSize() int {
+	return syscall.Getpagesize()
+}
+
+// @collab trust="AUTONOMOUS"
+func isLinux() bool { return true }
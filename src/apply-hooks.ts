@@ -0,0 +1,80 @@
+/**
+ * Pre-apply hooks
+ *
+ * A PreApplyHook runs after a proposal has collected its approvals and before
+ * it is handed back to be applied. Throwing (or rejecting) vetoes the
+ * proposal; the error message is reported to the caller and the proposal
+ * stays pending so it can be retried once the hook is satisfied.
+ *
+ * Hooks come from two places, and run in this order:
+ *
+ * 1. Hooks registered with registerPreApplyHook, in registration order
+ * 2. Modules listed under `pre_apply_hooks` in config.yaml, in list order
+ *
+ * Every hook runs even after one fails, so a refusal lists all the reasons at
+ * once rather than one per attempt.
+ */
+
+import * as path from "path";
+import { pathToFileURL } from "url";
+import { Proposal, loadCollabConfig } from "./collab.js";
+
+export type PreApplyHook = (proposal: Proposal) => void | Promise<void>;
+
+export interface PreApplyFailure {
+  hook: string; // Function name or module path
+  message: string;
+}
+
+const registeredHooks: PreApplyHook[] = [];
+
+export function registerPreApplyHook(hook: PreApplyHook): void {
+  registeredHooks.push(hook);
+}
+
+export function clearPreApplyHooks(): void {
+  registeredHooks.length = 0;
+}
+
+// A config module must export the hook as its default export or as `preApply`
+async function loadHookModule(modulePath: string): Promise<PreApplyHook> {
+  const loaded = await import(pathToFileURL(path.resolve(modulePath)).href);
+  const hook = loaded.default ?? loaded.preApply;
+  if (typeof hook !== "function") {
+    throw new Error("module does not export a pre-apply hook (default or `preApply`)");
+  }
+  return hook as PreApplyHook;
+}
+
+/**
+ * Run every hook against the proposal. Returns one failure per hook that
+ * threw or could not be loaded; an empty list means the proposal may be applied.
+ */
+export async function runPreApplyHooks(proposal: Proposal): Promise<PreApplyFailure[]> {
+  const hooks: { name: string; load: () => Promise<PreApplyHook> }[] = [
+    ...registeredHooks.map(hook => ({ name: hook.name || "anonymous", load: async () => hook })),
+    ...((await loadCollabConfig()).pre_apply_hooks ?? []).map(modulePath => ({
+      name: modulePath,
+      load: () => loadHookModule(modulePath),
+    })),
+  ];
+
+  const failures: PreApplyFailure[] = [];
+  for (const { name, load } of hooks) {
+    try {
+      const hook = await load();
+      // Each hook gets its own copy so one cannot alter what the next sees
+      await hook(structuredClone(proposal));
+    } catch (error) {
+      failures.push({ hook: name, message: error instanceof Error ? error.message : String(error) });
+    }
+  }
+  return failures;
+}
+
+export function formatPreApplyFailures(failures: PreApplyFailure[]): string {
+  return [
+    `Proposal refused by ${failures.length} pre-apply hook(s):`,
+    ...failures.map(f => `  - ${f.hook}: ${f.message}`),
+  ].join("\n");
+}
@@ -0,0 +1,362 @@
+/**
+ * Annotation scanning inside tar and zip archives
+ *
+ * Reads the archive in memory and parses each supported source file without
+ * extracting anything to disk. Nested archives (an archive inside an archive)
+ * are not descended into, and ZIP64 or encrypted zip entries are skipped.
+ * Only entries with an annotatable extension are decompressed, and no entry
+ * may inflate past MAX_ENTRY_BYTES, nor a gzipped tar past MAX_ARCHIVE_BYTES:
+ *
+ *   collab-claude-code scan-archive <archive> [--format=text|json]
+ *
+ * Exit codes follow the check command:
+ *   0 = Archive scanned, every annotation well-formed
+ *   2 = Tool or parse error (unreadable or oversized archive, unknown format,
+ *       or a malformed @collab annotation inside it)
+ */
+
+import { createReadStream } from "fs";
+import * as path from "path";
+import * as zlib from "zlib";
+
+import {
+  ANNOTATABLE_EXTENSIONS,
+  AnnotationError,
+  ParsedAnnotation,
+  TrustLevel,
+  compareRegions,
+  comparePaths,
+  formatOwner,
+  formatRange,
+  loadTrustAliases,
+  parseAnnotationContent,
+  rangeOf,
+  stableStringify,
+} from "./collab.js";
+import { EXIT_CLEAN, EXIT_TOOL_ERROR } from "./check.js";
+
+// ============================================
+// Types
+// ============================================
+
+export type ArchiveFormat = "tar" | "tgz" | "zip";
+
+export interface ArchiveEntry {
+  path: string; // Path inside the archive
+  data: Buffer;
+}
+
+export interface ArchiveFileResult {
+  path: string; // Path inside the archive
+  annotations: ParsedAnnotation[];
+  errors: AnnotationError[];
+  warnings: AnnotationError[];
+}
+
+export interface ArchiveReadOptions {
+  include?: (entryPath: string) => boolean; // Entries to read; the others are never decompressed
+  maxEntryBytes?: number; // Largest uncompressed entry (default MAX_ENTRY_BYTES)
+}
+
+export interface ArchiveScanOptions {
+  aliases?: Record<string, TrustLevel>;
+  signal?: AbortSignal;
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const MAX_ENTRY_BYTES = 16 * 1024 * 1024;
+export const MAX_ARCHIVE_BYTES = 512 * 1024 * 1024; // A .tgz after gunzip
+
+// Source files scanArchive parses, by the extension of their path inside the archive
+export function isAnnotatableEntry(entryPath: string): boolean {
+  return ANNOTATABLE_EXTENSIONS.includes(path.posix.extname(entryPath).toLowerCase().slice(1));
+}
+
+function tooLarge(what: string, limit: number): Error {
+  return new Error(`${what} is larger than ${limit} bytes uncompressed`);
+}
+
+// ============================================
+// Format Detection
+// ============================================
+
+export function detectArchiveFormat(fileName: string): ArchiveFormat | null {
+  const lower = fileName.toLowerCase();
+  if (lower.endsWith(".tar.gz") || lower.endsWith(".tgz")) return "tgz";
+  if (lower.endsWith(".tar")) return "tar";
+  if (lower.endsWith(".zip") || lower.endsWith(".jar")) return "zip";
+  return null;
+}
+
+// ============================================
+// Tar
+// ============================================
+
+const TAR_BLOCK = 512;
+
+function readTarString(buf: Buffer, offset: number, length: number): string {
+  const end = buf.indexOf(0, offset);
+  return buf.toString("utf-8", offset, end === -1 || end > offset + length ? offset + length : end);
+}
+
+function readTarSize(buf: Buffer, offset: number): number {
+  // Base-256 encoding for sizes that do not fit in 11 octal digits
+  if (buf[offset] & 0x80) {
+    let size = 0;
+    for (let i = offset + 1; i < offset + 12; i++) size = size * 256 + buf[i];
+    return size;
+  }
+  return parseInt(readTarString(buf, offset, 12).trim() || "0", 8);
+}
+
+function parsePaxPath(data: Buffer): string | undefined {
+  // Records look like "<len> key=value\n"
+  for (const record of data.toString("utf-8").split("\n")) {
+    const match = /^\d+ path=(.*)$/.exec(record);
+    if (match) return match[1];
+  }
+  return undefined;
+}
+
+export function readTarEntries(buf: Buffer, options: ArchiveReadOptions = {}): ArchiveEntry[] {
+  const { include = () => true, maxEntryBytes = MAX_ENTRY_BYTES } = options;
+  const entries: ArchiveEntry[] = [];
+  let offset = 0;
+  let longName: string | undefined;
+
+  while (offset + TAR_BLOCK <= buf.length) {
+    const header = buf.subarray(offset, offset + TAR_BLOCK);
+    if (header.every(b => b === 0)) break; // End-of-archive marker
+
+    const size = readTarSize(buf, offset + 124);
+    const type = String.fromCharCode(header[156] || 0x30);
+    const prefix = readTarString(buf, offset + 345, 155);
+    const name = readTarString(buf, offset, 100);
+    const dataStart = offset + TAR_BLOCK;
+    const data = buf.subarray(dataStart, dataStart + size);
+
+    if (dataStart + size > buf.length) {
+      throw new Error(`Truncated tar archive at entry "${name}"`);
+    }
+
+    if (type === "L") {
+      longName = readTarString(data, 0, data.length); // GNU long name for the next entry
+    } else if (type === "x") {
+      longName = parsePaxPath(data) ?? longName;
+    } else {
+      if (type === "0" || type === "\0" || type === "7") {
+        const fullName = longName ?? (prefix ? `${prefix}/${name}` : name);
+        if (include(fullName)) {
+          if (size > maxEntryBytes) throw tooLarge(`Tar entry "${fullName}"`, maxEntryBytes);
+          entries.push({ path: fullName, data });
+        }
+      }
+      longName = undefined;
+    }
+
+    offset = dataStart + Math.ceil(size / TAR_BLOCK) * TAR_BLOCK;
+  }
+
+  return entries;
+}
+
+// ============================================
+// Zip
+// ============================================
+
+const ZIP_EOCD_SIGNATURE = 0x06054b50;
+const ZIP_CENTRAL_SIGNATURE = 0x02014b50;
+const ZIP_LOCAL_SIGNATURE = 0x04034b50;
+
+export function readZipEntries(buf: Buffer, options: ArchiveReadOptions = {}): ArchiveEntry[] {
+  const { include = () => true, maxEntryBytes = MAX_ENTRY_BYTES } = options;
+  // The end-of-central-directory record is within the last 64KiB + 22 bytes
+  let eocd = -1;
+  for (let i = buf.length - 22; i >= Math.max(0, buf.length - 65557); i--) {
+    if (buf.readUInt32LE(i) === ZIP_EOCD_SIGNATURE) {
+      eocd = i;
+      break;
+    }
+  }
+  if (eocd === -1) {
+    throw new Error("Not a zip archive: end of central directory not found");
+  }
+
+  const count = buf.readUInt16LE(eocd + 10);
+  let offset = buf.readUInt32LE(eocd + 16);
+  const entries: ArchiveEntry[] = [];
+
+  for (let n = 0; n < count; n++) {
+    if (buf.readUInt32LE(offset) !== ZIP_CENTRAL_SIGNATURE) {
+      throw new Error("Corrupt zip archive: bad central directory entry");
+    }
+
+    const flags = buf.readUInt16LE(offset + 8);
+    const method = buf.readUInt16LE(offset + 10);
+    const compressedSize = buf.readUInt32LE(offset + 20);
+    const nameLength = buf.readUInt16LE(offset + 28);
+    const extraLength = buf.readUInt16LE(offset + 30);
+    const commentLength = buf.readUInt16LE(offset + 32);
+    const localOffset = buf.readUInt32LE(offset + 42);
+    const name = buf.toString("utf-8", offset + 46, offset + 46 + nameLength);
+    offset += 46 + nameLength + extraLength + commentLength;
+
+    const encrypted = (flags & 0x1) !== 0;
+    const zip64 = compressedSize === 0xffffffff || localOffset === 0xffffffff;
+    if (name.endsWith("/") || encrypted || zip64 || !include(name)) continue;
+
+    if (buf.readUInt32LE(localOffset) !== ZIP_LOCAL_SIGNATURE) {
+      throw new Error(`Corrupt zip archive: bad local header for "${name}"`);
+    }
+    const dataStart =
+      localOffset + 30 + buf.readUInt16LE(localOffset + 26) + buf.readUInt16LE(localOffset + 28);
+    const raw = buf.subarray(dataStart, dataStart + compressedSize);
+
+    if (method === 0) {
+      if (raw.length > maxEntryBytes) throw tooLarge(`Zip entry "${name}"`, maxEntryBytes);
+      entries.push({ path: name, data: raw });
+    } else if (method === 8) {
+      entries.push({ path: name, data: inflateCapped(raw, maxEntryBytes, `Zip entry "${name}"`) });
+    }
+    // Other compression methods are skipped
+  }
+
+  return entries;
+}
+
+// The size recorded in headers is the archive's claim; the cap holds whatever it says
+function inflateCapped(raw: Buffer, limit: number, what: string): Buffer {
+  try {
+    return zlib.inflateRawSync(raw, { maxOutputLength: limit });
+  } catch (error) {
+    if ((error as NodeJS.ErrnoException).code === "ERR_BUFFER_TOO_LARGE") throw tooLarge(what, limit);
+    throw error;
+  }
+}
+
+function gunzipCapped(buf: Buffer, limit: number): Buffer {
+  try {
+    return zlib.gunzipSync(buf, { maxOutputLength: limit });
+  } catch (error) {
+    if ((error as NodeJS.ErrnoException).code === "ERR_BUFFER_TOO_LARGE") throw tooLarge("Gzipped tar archive", limit);
+    throw error;
+  }
+}
+
+// ============================================
+// Scanning
+// ============================================
+
+async function readAll(input: Buffer | NodeJS.ReadableStream): Promise<Buffer> {
+  if (Buffer.isBuffer(input)) return input;
+
+  const chunks: Buffer[] = [];
+  for await (const chunk of input) {
+    chunks.push(typeof chunk === "string" ? Buffer.from(chunk) : chunk);
+  }
+  return Buffer.concat(chunks);
+}
+
+export function readArchiveEntries(buf: Buffer, format: ArchiveFormat, options: ArchiveReadOptions = {}): ArchiveEntry[] {
+  switch (format) {
+    case "tar":
+      return readTarEntries(buf, options);
+    case "tgz":
+      return readTarEntries(gunzipCapped(buf, MAX_ARCHIVE_BYTES), options);
+    case "zip":
+      return readZipEntries(buf, options);
+  }
+}
+
+export async function scanArchive(
+  input: Buffer | NodeJS.ReadableStream,
+  format: ArchiveFormat,
+  options: ArchiveScanOptions = {}
+): Promise<ArchiveFileResult[]> {
+  const { signal } = options;
+  const aliases = options.aliases ?? (await loadTrustAliases());
+  const entries = readArchiveEntries(await readAll(input), format, { include: isAnnotatableEntry });
+
+  const results: ArchiveFileResult[] = [];
+  for (const entry of entries) {
+    signal?.throwIfAborted();
+    const { annotations, errors, warnings } = parseAnnotationContent(
+      entry.data.toString("utf-8"),
+      entry.path,
+      { aliases }
+    );
+    results.push({ path: entry.path, annotations: annotations.sort(compareRegions), errors, warnings });
+  }
+
+  return results.sort((a, b) => comparePaths(a.path, b.path));
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatArchiveText(archive: string, results: ArchiveFileResult[]): string {
+  const lines: string[] = [];
+  for (const result of results) {
+    for (const a of result.annotations) {
+      const owner = formatOwner(a.owner);
+      lines.push(`${formatRange(rangeOf(a), result.path)}  ${a.trust ?? "-"}${owner ? `  ${owner}` : ""}`);
+    }
+    for (const e of result.errors) lines.push(`${formatRange(rangeOf(e), result.path)}: [${e.code}] ${e.message}`);
+    for (const w of result.warnings) {
+      lines.push(`${formatRange(rangeOf(w), result.path)}: warning: [${w.code}] ${w.message}`);
+    }
+  }
+
+  const annotations = results.reduce((sum, r) => sum + r.annotations.length, 0);
+  const errors = results.reduce((sum, r) => sum + r.errors.length, 0);
+  lines.push(
+    `Scanned ${results.length} file(s) in ${archive}: ${annotations} annotation(s)` +
+      (errors > 0 ? `, ${errors} malformed` : "")
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runScanArchive(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  const archives: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      archives.push(arg);
+    }
+  }
+  if (archives.length !== 1) {
+    console.error("Usage: collab-claude-code scan-archive <archive> [--format=text|json]");
+    return EXIT_TOOL_ERROR;
+  }
+
+  const [archive] = archives;
+  const archiveFormat = detectArchiveFormat(archive);
+  if (!archiveFormat) {
+    console.error(`Unknown archive format: ${archive} (expected .tar, .tar.gz, .tgz, .zip, or .jar)`);
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const results = await scanArchive(createReadStream(archive), archiveFormat);
+    console.log(format === "json" ? stableStringify(results, 2) : formatArchiveText(archive, results));
+    return results.some(r => r.errors.length > 0) ? EXIT_TOOL_ERROR : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,166 @@
+/**
+ * at command for collab-claude-code
+ *
+ * The trust level at an editor's cursor, for status lines in vim, Emacs, and
+ * any other editor that can run a command:
+ *
+ *   collab-claude-code at --file=src/pay.go --line=42 [--col=7] [--stdin] [--format=text|json]
+ *
+ * Prints one line such as `SUGGEST_ONLY — owner payments-team — "Refunds are idempotent"`,
+ * or the full resolution as JSON. With --stdin the file's content is read from
+ * standard input, so an unsaved buffer is resolved as the editor shows it;
+ * --file still names the path that trust.yaml policies and the language are
+ * looked up by. On a @collab comment line, the cursor shows what that
+ * annotation governs.
+ *
+ * Exit codes:
+ *   0 = Trust level printed
+ *   2 = Tool error (bad arguments, unreadable file, line past the end, invalid config)
+ */
+
+import * as fs from "fs/promises";
+
+import {
+  Position,
+  TrustConfig,
+  TrustResult,
+  formatOwner,
+  generatedTrustLevel,
+  isGeneratedSource,
+  isGoTestSource,
+  loadPackageDefault,
+  loadTrustAliases,
+  parseAnnotationContent,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import { CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, loadTrustConfigStrict } from "./check.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface TrustAt extends TrustResult {
+  file: string;
+  position: Position;
+  symbol?: string; // Qualified name of the innermost declaration around the cursor
+}
+
+// ============================================
+// Resolution
+// ============================================
+
+/**
+ * Resolve the trust level at a cursor in `content`, the text of `filePath`
+ * (possibly unsaved), exactly as collab_check_trust resolves that line.
+ */
+export async function resolveAt(
+  config: TrustConfig,
+  filePath: string,
+  content: string,
+  position: Position
+): Promise<TrustAt> {
+  const lineCount = content.replace(/\r\n/g, "\n").replace(/\n$/, "").split("\n").length;
+  if (position.line > lineCount) {
+    throw new CheckToolError(`Line ${position.line} is past the end of ${filePath} (${lineCount} line(s))`);
+  }
+
+  const aliases = await loadTrustAliases();
+  const { annotations } = parseAnnotationContent(content, filePath, { aliases });
+  const declarations = findDeclarations(content, filePath);
+  const generated = generatedTrustLevel(config) !== undefined && isGeneratedSource(content);
+  const packageDefault = await loadPackageDefault(filePath, { aliases });
+
+  const comment = annotations.find(
+    a => a.comment_start !== undefined && a.comment_start <= position.line && position.line <= (a.comment_end ?? a.comment_start)
+  );
+  const line = comment ? comment.line_start : position.line;
+  const testFile = isGoTestSource(filePath, content);
+  const trust = resolveTrustWithAnnotations(
+    config, filePath, annotations, line, line, declarations, generated, packageDefault, testFile
+  );
+
+  const symbol = declarations
+    .filter(d => d.line_start <= line && line <= d.line_end)
+    .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start))[0];
+  return { ...trust, file: filePath, position, symbol: symbol?.qualified_name };
+}
+
+// `SUGGEST_ONLY — owner payments-team — "Implement user auth"`
+export function formatTrustAt(result: TrustAt): string {
+  const parts: string[] = [result.level];
+  const owner = formatOwner(result.owner);
+  if (owner) parts.push(`owner ${owner}`);
+  if (result.intent) parts.push(`"${result.intent}"`);
+  return parts.join(" — ");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+async function readStdin(): Promise<string> {
+  const chunks: Buffer[] = [];
+  for await (const chunk of process.stdin) chunks.push(chunk as Buffer);
+  return Buffer.concat(chunks).toString("utf-8");
+}
+
+function parsePositive(name: string, value: string): number {
+  if (!/^\d+$/.test(value) || parseInt(value, 10) < 1) {
+    throw new CheckToolError(`--${name} must be a positive integer`);
+  }
+  return parseInt(value, 10);
+}
+
+export async function runAt(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let file: string | undefined;
+  let line: string | undefined;
+  let col: string | undefined;
+  let profile: string | undefined;
+  let stdin = false;
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--file=")) {
+      file = arg.slice("--file=".length);
+    } else if (arg.startsWith("--line=")) {
+      line = arg.slice("--line=".length);
+    } else if (arg.startsWith("--col=")) {
+      col = arg.slice("--col=".length);
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--stdin") {
+      stdin = true;
+    } else {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    }
+  }
+
+  if (file === undefined || line === undefined) {
+    console.error("Usage: collab-claude-code at --file=<file> --line=<line> [--col=<col>] [--stdin] [--format=text|json] [--profile=<name>]");
+    return EXIT_TOOL_ERROR;
+  }
+  const filePath = file;
+
+  try {
+    const position: Position = { line: parsePositive("line", line) };
+    if (col !== undefined) position.col = parsePositive("col", col);
+    const content = stdin
+      ? await readStdin()
+      : await fs.readFile(filePath, "utf-8").catch(() => {
+          throw new CheckToolError(`Cannot read ${filePath}`);
+        });
+    const config = await loadTrustConfigStrict(profile);
+    const result = await resolveAt(config, filePath, content, position);
+    console.log(format === "json" ? stableStringify(result, 2) : formatTrustAt(result));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
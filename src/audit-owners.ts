@@ -0,0 +1,256 @@
+/**
+ * audit-owners command for collab-claude-code
+ *
+ * Finds owners that still govern code but no longer exist: every owner named
+ * by annotations, Go package defaults, and trust.yaml policies is looked up
+ * in a roster, and the ones missing from it are listed with the regions they
+ * govern, grouped by owner so each can be reassigned in one pass.
+ *
+ * The roster is a CODEOWNERS file (owners are the entries after each pattern)
+ * or any other file listing one owner per line. `@org/team` in the roster
+ * matches `team` in an annotation and the reverse; case is ignored.
+ *
+ * Exit codes follow the check command:
+ *   0 = Every owner is in the roster
+ *   1 = One or more orphaned owners
+ *   2 = Tool error (bad arguments, no roster)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+
+import {
+  TrustLevel,
+  comparePaths,
+  fileExists,
+  formatRange,
+  loadTrustAliases,
+  ownerList,
+  parseAnnotationContent,
+  parsePackageDirective,
+  rangeOf,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  EXIT_VIOLATIONS,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface OwnedRegion {
+  kind: "annotation" | "package" | "policy" | "symbol";
+  trust?: TrustLevel;
+  file?: string; // For annotations and package defaults
+  line_start?: number;
+  line_end?: number;
+  pattern?: string; // For trust.yaml policies and symbol policies
+}
+
+export interface OrphanedOwner {
+  owner: string;
+  regions: OwnedRegion[];
+}
+
+export interface OwnerAuditReport {
+  roster: string;
+  known_owners: number; // Distinct owners in the roster
+  checked_files: number;
+  referenced_owners: number; // Distinct owners referenced anywhere
+  orphaned: OrphanedOwner[];
+}
+
+export interface AuditOwnersOptions extends CheckOptions {
+  roster?: string; // Defaults to the first CODEOWNERS file found
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const CODEOWNERS_LOCATIONS = ["CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"];
+
+// ============================================
+// Roster
+// ============================================
+
+export function parseRoster(content: string, rosterPath: string): string[] {
+  const codeowners = path.basename(rosterPath) === "CODEOWNERS";
+  const owners: string[] = [];
+  for (const raw of content.replace(/\r\n/g, "\n").split("\n")) {
+    const line = raw.replace(/(^|\s)#.*$/, "").trim();
+    if (!line) continue;
+    // CODEOWNERS: "<pattern> <owner>..."; anything else: owners, optionally as a YAML list
+    const words = line.replace(/^-\s+/, "").split(/[\s,]+/).map(w => w.replace(/^["']|["']$/g, "")).filter(Boolean);
+    owners.push(...(codeowners ? words.slice(1) : words));
+  }
+  return owners;
+}
+
+// "@acme/Payments-Team" and "payments-team" are the same owner
+export function ownerKey(owner: string): string {
+  const name = owner.trim().replace(/^@/, "").toLowerCase();
+  return name.includes("@") ? name : name.slice(name.lastIndexOf("/") + 1);
+}
+
+async function findRoster(explicit?: string): Promise<string> {
+  if (explicit) {
+    if (!(await fileExists(explicit))) throw new CheckToolError(`Cannot read ${explicit}`);
+    return explicit;
+  }
+  for (const candidate of CODEOWNERS_LOCATIONS) {
+    if (await fileExists(candidate)) return candidate;
+  }
+  throw new CheckToolError(`No roster given and no CODEOWNERS file found (looked in ${CODEOWNERS_LOCATIONS.join(", ")})`);
+}
+
+// ============================================
+// Audit
+// ============================================
+
+export async function auditOwners(paths: string[], options: AuditOwnersOptions = {}): Promise<OwnerAuditReport> {
+  const { signal } = options;
+  const rosterPath = await findRoster(options.roster);
+  const known = new Set(parseRoster(await fs.readFile(rosterPath, "utf-8"), rosterPath).map(ownerKey));
+  const config = await loadTrustConfigStrict(options.profile);
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+  const files = await expandPaths(paths, options);
+
+  const governed = new Map<string, OwnedRegion[]>();
+  const add = (owner: string | string[] | undefined, region: OwnedRegion) => {
+    for (const name of ownerList(owner)) {
+      governed.set(name, [...(governed.get(name) ?? []), region]);
+    }
+  };
+
+  for (const policy of config.policies) {
+    add(policy.owner, { kind: "policy", pattern: policy.pattern, trust: policy.trust });
+  }
+  for (const symbol of config.symbols ?? []) {
+    add(symbol.owner, { kind: "symbol", pattern: symbol.pattern, trust: symbol.trust });
+  }
+
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    for (const annotation of parseAnnotationContent(content, file, { aliases }).annotations) {
+      add(annotation.owner, {
+        kind: "annotation",
+        file,
+        line_start: annotation.line_start,
+        line_end: annotation.line_end,
+        trust: annotation.trust,
+      });
+    }
+    const packageDefault = path.basename(file) === "doc.go" ? parsePackageDirective(content, file, { aliases }) : undefined;
+    if (packageDefault) {
+      add(packageDefault.owner, {
+        kind: "package",
+        file,
+        line_start: packageDefault.line,
+        line_end: packageDefault.line,
+        trust: packageDefault.trust,
+      });
+    }
+  }
+
+  const orphaned = [...governed]
+    .filter(([owner]) => !known.has(ownerKey(owner)))
+    .map(([owner, regions]) => ({ owner, regions }))
+    .sort((a, b) => comparePaths(a.owner, b.owner));
+
+  return {
+    roster: rosterPath,
+    known_owners: known.size,
+    checked_files: files.length,
+    referenced_owners: governed.size,
+    orphaned,
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+function describeRegion(region: OwnedRegion): string {
+  const trust = region.trust ?? "-";
+  switch (region.kind) {
+    case "policy":
+      return `trust.yaml policy "${region.pattern}"  ${trust}`;
+    case "symbol":
+      return `trust.yaml symbol policy "${region.pattern}"  ${trust}`;
+    case "package":
+      return `${formatRange(rangeOf({ line: region.line_start! }), region.file)}  ${trust}  (package default)`;
+    default:
+      return `${formatRange(rangeOf({ line_start: region.line_start!, line_end: region.line_end! }), region.file)}  ${trust}`;
+  }
+}
+
+export function formatOwnerAuditText(report: OwnerAuditReport): string {
+  const lines: string[] = [];
+  for (const { owner, regions } of report.orphaned) {
+    lines.push(`${owner} (${regions.length} region(s)):`);
+    for (const region of regions) lines.push(`  ${describeRegion(region)}`);
+  }
+
+  const count = report.orphaned.length;
+  const regions = report.orphaned.reduce((sum, o) => sum + o.regions.length, 0);
+  lines.push(
+    `Audited ${report.referenced_owners} owner(s) in ${report.checked_files} file(s) and trust.yaml ` +
+      `against ${report.roster} (${report.known_owners} known): ` +
+      (count === 0 ? "no orphaned owners" : `${count} orphaned owner(s) governing ${regions} region(s)`)
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runAuditOwners(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let roster: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--roster=")) {
+      roster = arg.slice("--roster=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await auditOwners(paths, { roster, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatOwnerAuditText(report));
+    return report.orphaned.length > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,80 @@
+/**
+ * Browser entry point for collab-claude-code
+ *
+ * Resolves trust for one line of a file held in memory, for editors and code
+ * review UIs that run in the browser and have the file's text but no checkout:
+ *
+ *   import { resolveAt } from "collab-claude-code/dist/browser.js";
+ *   resolveAt(src, 42, { filePath: "pkg/pay/charge.go" }).level; // "SUPERVISED"
+ *
+ * This module only calls the pure parser and resolver: nothing here reads
+ * files, runs git, or fetches policies, so a bundle built from it needs no
+ * Node APIs at run time. See "Browser use" in the README for the subset of
+ * trust.yaml it honors and the bundler settings it needs.
+ */
+
+import {
+  AnnotationError,
+  ParsedAnnotation,
+  TrustConfig,
+  TrustResult,
+  buildTrustAliases,
+  governingAnnotation,
+  isGeneratedSource,
+  isGoTestSource,
+  parseAnnotationContent,
+  resolveTrustWithAnnotations,
+} from "./collab.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface ResolveAtOptions {
+  filePath?: string; // Repo-relative; picks the comment syntax and the trust.yaml patterns that apply
+  config?: TrustConfig; // Parsed trust.yaml; its `extends` are not fetched
+  aliases?: Record<string, string>; // config.yaml `aliases`
+}
+
+export interface ResolvedLine extends TrustResult {
+  annotation?: ParsedAnnotation; // The annotation the level came from, if any
+  errors: AnnotationError[]; // Annotations in src that failed to parse
+}
+
+// ============================================
+// Constants
+// ============================================
+
+const DEFAULT_FILE_PATH = "file.ts";
+
+// ============================================
+// Resolution
+// ============================================
+
+/**
+ * The trust that applies to `line` (1-indexed) of `src`. Without a config,
+ * only the annotations in `src` govern and every other line is SUPERVISED,
+ * as with a repo that has no trust.yaml. Throws on invalid aliases.
+ */
+export function resolveAt(src: string, line: number, options: ResolveAtOptions = {}): ResolvedLine {
+  const filePath = options.filePath ?? DEFAULT_FILE_PATH;
+  const aliases = buildTrustAliases(options.aliases);
+  const { annotations, errors } = parseAnnotationContent(src, filePath, { aliases });
+
+  const config: TrustConfig = options.config ?? { default_trust: "SUPERVISED", policies: [] };
+  const result = resolveTrustWithAnnotations(
+    config,
+    filePath,
+    annotations,
+    line,
+    line,
+    findDeclarations(src, filePath),
+    isGeneratedSource(src),
+    undefined,
+    isGoTestSource(filePath, src)
+  );
+
+  const governing = result.source === "annotation" ? governingAnnotation(annotations, line, line) : undefined;
+  return governing ? { ...result, annotation: governing.annotation, errors } : { ...result, errors };
+}
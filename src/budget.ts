@@ -0,0 +1,156 @@
+/**
+ * Budget command for collab-claude-code
+ *
+ * Enforces the `budgets` in trust.yaml: caps on the fraction of lines in
+ * matching files that may resolve to AUTONOMOUS. Lines are resolved exactly
+ * as the heatmap resolves them, including the default level and the active
+ * profile's overrides, so a budget measures what the agent may actually edit.
+ *
+ * Exit codes:
+ *   0 = Every budget is within its limit
+ *   1 = One or more budgets exceeded
+ *   2 = Tool error (bad arguments, unreadable file, invalid trust.yaml)
+ */
+
+import * as path from "path";
+
+import { TrustBudget, comparePaths, matchesPattern, stableStringify } from "./collab.js";
+import { CheckOptions, EXIT_CLEAN, EXIT_TOOL_ERROR, EXIT_VIOLATIONS, loadTrustConfigStrict } from "./check.js";
+import { buildHeatmap } from "./heatmap.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface BudgetUsage {
+  autonomous_lines: number;
+  total_lines: number;
+  fraction: number; // 0 when there are no lines
+}
+
+export interface DirectoryBudgetUsage extends BudgetUsage {
+  directory: string;
+}
+
+export interface BudgetResult extends TrustBudget, BudgetUsage {
+  exceeded: boolean;
+  directories: DirectoryBudgetUsage[]; // Matching files grouped by parent directory
+}
+
+export interface BudgetReport {
+  profile?: string;
+  budgets: BudgetResult[];
+  total_exceeded: number;
+}
+
+// ============================================
+// Evaluation
+// ============================================
+
+function usage(autonomous_lines: number, total_lines: number): BudgetUsage {
+  return { autonomous_lines, total_lines, fraction: total_lines === 0 ? 0 : autonomous_lines / total_lines };
+}
+
+export async function checkBudgets(paths: string[], options: CheckOptions = {}): Promise<BudgetReport> {
+  const config = await loadTrustConfigStrict(options.profile);
+  const budgets = config.budgets ?? [];
+  const heatmap = budgets.length > 0 ? await buildHeatmap(paths, options) : { files: [] };
+
+  const results = budgets.map((budget): BudgetResult => {
+    const files = heatmap.files.filter(f => matchesPattern(f.file.replace(/\\/g, "/"), budget.pattern));
+
+    const byDirectory = new Map<string, { autonomous: number; total: number }>();
+    for (const f of files) {
+      const directory = path.posix.dirname(f.file.replace(/\\/g, "/"));
+      const entry = byDirectory.get(directory) ?? { autonomous: 0, total: 0 };
+      entry.autonomous += f.levels.AUTONOMOUS;
+      entry.total += f.total_lines;
+      byDirectory.set(directory, entry);
+    }
+
+    const autonomous = files.reduce((sum, f) => sum + f.levels.AUTONOMOUS, 0);
+    const total = files.reduce((sum, f) => sum + f.total_lines, 0);
+    const overall = usage(autonomous, total);
+    return {
+      ...budget,
+      ...overall,
+      exceeded: overall.fraction > budget.max_autonomous_fraction,
+      directories: [...byDirectory.entries()]
+        .map(([directory, d]) => ({ directory, ...usage(d.autonomous, d.total) }))
+        .sort((a, b) => comparePaths(a.directory, b.directory)),
+    };
+  });
+
+  return {
+    profile: config.active_profile,
+    budgets: results,
+    total_exceeded: results.filter(r => r.exceeded).length,
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+function percent(fraction: number): string {
+  return `${(fraction * 100).toFixed(1).replace(/\.0$/, "")}%`;
+}
+
+export function formatBudgetText(report: BudgetReport): string {
+  const lines: string[] = [];
+  for (const b of report.budgets) {
+    const status = b.exceeded ? "EXCEEDED" : "ok";
+    lines.push(
+      `${b.pattern}: ${percent(b.fraction)} AUTONOMOUS (${b.autonomous_lines}/${b.total_lines} lines), ` +
+        `allowed ${percent(b.max_autonomous_fraction)} - ${status}${b.reason ? ` (${b.reason})` : ""}`
+    );
+    if (!b.exceeded) continue;
+
+    // Directories over the limit on their own are where to start tightening
+    for (const d of b.directories.filter(d => d.fraction > b.max_autonomous_fraction)) {
+      lines.push(`  ${d.directory}: ${percent(d.fraction)} (${d.autonomous_lines}/${d.total_lines} lines)`);
+    }
+  }
+
+  const checked = `Checked ${report.budgets.length} budget(s)` + (report.profile ? ` [profile: ${report.profile}]` : "");
+  lines.push(
+    report.total_exceeded === 0 ? `${checked}: all within limits` : `${checked}: ${report.total_exceeded} exceeded`
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runBudget(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await checkBudgets(paths, { profile, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatBudgetText(report));
+    return report.total_exceeded > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,976 @@
+/**
+ * Check command for collab-claude-code
+ *
+ * Validates @collab annotations and authorship records for CI.
+ *
+ * Exit codes:
+ *   0 = Clean, no violations (warnings alone do not fail)
+ *   1 = One or more error-severity violations found
+ *   2 = Tool or parse error (bad arguments, unreadable file, invalid trust.yaml
+ *       or config.yaml, or a malformed @collab annotation)
+ *
+ * Malformed annotations are listed with the other violations; they only
+ * change the exit code, so CI can tell a broken annotation from a breach.
+ *
+ * In shadow mode (--shadow, or enforcement: shadow in config.yaml) violations
+ * are reported and appended to .collab/audit.jsonl, but the exit code is 0.
+ *
+ * --format=github-comments prints the body of a GitHub "create a review"
+ * request: a comment on each violation's line (the right side of the PR
+ * diff), naming the region's owner and governing annotation. With
+ * --base=<rev>, violations on lines the diff does not touch, which GitHub
+ * cannot anchor, are listed in the review body instead.
+ *
+ * --dedupe=region keeps only the most severe violation of each governed
+ * region, so a PR bot posts one comment per region; the others are counted
+ * on it, and with --list-suppressed listed under `suppressed` in JSON. See
+ * dedupeByRegion.
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+import { glob } from "glob";
+
+import {
+  ANNOTATABLE_EXTENSIONS,
+  AUDIT_FILE,
+  AuditEntry,
+  COLLAB_DIR,
+  CollabConfig,
+  OwnerContact,
+  ParsedAnnotation,
+  TrustResult,
+  formatAnnotation,
+  formatOwner,
+  formatRange,
+  governingAnnotation,
+  loadCollabConfig,
+  loadOwnerRegistry,
+  ownerList,
+  TRUST_FILE,
+  TrustConfig,
+  TrustLevel,
+  fileExists,
+  findMatchingPolicy,
+  loadTrustAliases,
+  matchesPattern,
+  parseAnnotationContent,
+  parseAnnotations,
+  patternSpecificity,
+  loadAuthorship,
+  getTrustLevelWithAnnotations,
+  isGoTestSource,
+  isShadowMode,
+  isVendoredPath,
+  rangeOf,
+  recordAuditEntries,
+  regionMatchesFile,
+  resolveNestedAnnotation,
+  selectTrustProfile,
+  validateGeneratedTrust,
+  validateTestTrust,
+  validateTrustBudgets,
+  validateTrustProfiles,
+} from "./collab.js";
+import { ReasonCodeId } from "./reasons.js";
+import { loadLocalizer, localizeMessages } from "./messages.js";
+import { loadIgnoreFilter } from "./ignore.js";
+import { loadExtendedPolicies, mergePolicy } from "./policy.js";
+import { findDeclarations } from "./declarations.js";
+import { EffectfulCall, findEffectfulCalls, validateEffectfulCalls } from "./effects.js";
+import { listChangedFilesWithRenames, listChangedLines, readFileAtRevision } from "./git.js";
+import { log } from "./log.js";
+
+// ============================================
+// Types
+// ============================================
+
+export type CheckFormat = "text" | "json" | "junit" | "github-comments";
+
+export interface Violation {
+  file: string;
+  line: number;
+  rule: string; // Category: "annotation", "policy", or "read-only-edit"
+  code: ReasonCodeId; // Stable code from reasonCodes()
+  severity: "error" | "warning";
+  message: string;
+  params?: Record<string, string>; // Values for the code's message template (see messages.ts)
+  suggestion?: string; // Replacement for the offending line, if the fix is unambiguous
+  suppressed_count?: number; // With --dedupe=region: other violations in its region collapsed into it
+}
+
+export interface FileCheckResult {
+  file: string;
+  violations: Violation[];
+  parse_errors?: number; // Of the error-severity violations, how many are malformed annotations
+  suppressed?: Violation[]; // With --dedupe=region --list-suppressed: the violations collapsed into others
+}
+
+export interface CheckOptions {
+  signal?: AbortSignal; // Aborts between files and inside glob/file reads
+  profile?: string; // Environment profile from trust.yaml; detected from the branch if unset
+  noIgnore?: boolean; // Also check .gitignore'd and config-excluded files
+  lint?: CollabConfig["lint"]; // Advisory settings; checkFiles reads them from config.yaml
+  owners?: Record<string, OwnerContact>; // Owner registry; checkFiles reads it from config.yaml, unset means free-form
+  vendor?: string[]; // Vendored path patterns; checkFiles reads them from config.yaml
+}
+
+export interface CheckReport {
+  profile?: string;
+  files: FileCheckResult[];
+  total_violations: number; // Error-severity violations only
+  total_parse_errors: number; // Of total_violations, malformed annotations
+  total_warnings: number;
+  total_suppressed?: number; // With --dedupe=region; the totals above still count them
+}
+
+// One entry of a review's `comments`, in the shape of GitHub's pull request review API
+export interface GithubReviewComment {
+  path: string;
+  line: number; // In the PR's head version of the file
+  side: "RIGHT";
+  body: string;
+}
+
+export interface GithubReview {
+  event: "COMMENT";
+  body: string;
+  comments: GithubReviewComment[];
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const EXIT_CLEAN = 0;
+export const EXIT_VIOLATIONS = 1;
+export const EXIT_TOOL_ERROR = 2; // Also for malformed annotations
+
+export const DEFAULT_MAX_REGION_LINES = 500;
+export const DEFAULT_MAX_ANNOTATIONS_PER_FILE = 100;
+
+const CHECK_FORMATS: CheckFormat[] = ["text", "json", "junit", "github-comments"];
+const DEDUPE_MODES = ["region"];
+
+const SOURCE_GLOB = `**/*.{${ANNOTATABLE_EXTENSIONS.join(",")}}`;
+
+const CHECK_IGNORE = [
+  "**/node_modules/**",
+  "**/.git/**",
+  "**/dist/**",
+  "**/build/**",
+  "**/.next/**",
+  "**/target/**",
+  "**/__pycache__/**",
+  "**/venv/**",
+  "**/.venv/**",
+  // vendor/ is walked: vendored libraries' annotations govern their code (see isVendoredPath)
+];
+
+// Thrown for failures of the tool itself rather than of the checked code
+export class CheckToolError extends Error {}
+
+// ============================================
+// Checking
+// ============================================
+
+// With a revision, trust.yaml is read from that commit instead of the working tree;
+// trustPath reads another file in its place, e.g. a candidate config to simulate
+export async function loadTrustConfigStrict(
+  profile?: string,
+  revision?: string,
+  trustPath = path.join(COLLAB_DIR, TRUST_FILE)
+): Promise<TrustConfig> {
+  const label = revision ? `${revision}:${trustPath}` : trustPath;
+  const content = revision
+    ? await readFileAtRevision(revision, trustPath)
+    : (await fileExists(trustPath)) ? await fs.readFile(trustPath, "utf-8") : null;
+  if (content === null) {
+    if (profile) {
+      throw new CheckToolError(`Unknown trust profile: ${profile} (no ${label})`);
+    }
+    return { default_trust: "SUPERVISED", policies: [] };
+  }
+
+  let config: TrustConfig;
+  try {
+    let parsed = yaml.parse(content) as TrustConfig | null;
+    if (parsed?.extends) {
+      parsed = mergePolicy(parsed, await loadExtendedPolicies(parsed.extends));
+    }
+    if (!parsed || !parsed.default_trust) {
+      throw new Error("missing default_trust");
+    }
+    const configError =
+      validateTrustProfiles(parsed) ?? validateTrustBudgets(parsed) ?? validateGeneratedTrust(parsed) ??
+      validateTestTrust(parsed);
+    if (configError) {
+      throw new Error(configError);
+    }
+    config = { ...parsed, policies: parsed.policies ?? [] };
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    throw new CheckToolError(`Invalid ${label}: ${message}`);
+  }
+
+  try {
+    return { ...config, active_profile: await selectTrustProfile(config, profile) };
+  } catch (error) {
+    throw new CheckToolError(error instanceof Error ? error.message : String(error));
+  }
+}
+
+// Files named explicitly are always checked; directory contents skip ignored files
+export async function expandPaths(paths: string[], options: CheckOptions = {}): Promise<string[]> {
+  const { signal } = options;
+  const isIgnored = await loadIgnoreFilter(".", {
+    noIgnore: options.noIgnore,
+    exclude: (await loadCollabConfig()).exclude,
+    signal,
+  });
+
+  if (paths.length === 0) {
+    const found = await glob(SOURCE_GLOB, { ignore: CHECK_IGNORE, nodir: true, signal });
+    return found.filter(f => !isIgnored(f)).sort();
+  }
+
+  const files: string[] = [];
+  for (const p of paths) {
+    signal?.throwIfAborted();
+    const stat = await fs.stat(p).catch(() => {
+      throw new CheckToolError(`Cannot read ${p}`);
+    });
+
+    if (stat.isDirectory()) {
+      const found = await glob(SOURCE_GLOB, { cwd: p, ignore: CHECK_IGNORE, nodir: true, signal });
+      files.push(
+        ...found
+          .map(f => path.join(p, f))
+          .filter(f => !isIgnored(path.relative(".", f)))
+          .sort()
+      );
+    } else {
+      files.push(p);
+    }
+  }
+  return files;
+}
+
+// READ_ONLY annotations scoped to an unexported Go function of at most maxLines lines
+function smallReadOnlyHelpers(
+  content: string,
+  filePath: string,
+  annotations: ParsedAnnotation[],
+  maxLines: number
+): Violation[] {
+  if (!filePath.endsWith(".go")) return [];
+  const locked = annotations.filter(a => a.trust === "READ_ONLY");
+  if (locked.length === 0) return [];
+
+  const lines = content.split(/\r?\n/);
+  const violations: Violation[] = [];
+  for (const decl of findDeclarations(content, filePath)) {
+    const size = decl.line_end - decl.line_start + 1;
+    // Go exports names that start with an upper-case letter; package-level vars are not helpers
+    if (/^\p{Lu}/u.test(decl.name) || size > maxLines || !/^func\b/.test(lines[decl.line_start - 1])) continue;
+    if (!locked.some(a => a.line_start === decl.line_start && a.line_end === decl.line_end)) continue;
+    violations.push({
+      file: filePath,
+      line: decl.line_start,
+      rule: "annotation",
+      code: "read-only-small-helper",
+      severity: "warning",
+      message: `READ_ONLY on unexported ${decl.qualified_name} (${size} line(s)) may be unnecessary; ` +
+        "small internal helpers rarely need a lock",
+      params: { name: decl.qualified_name, lines: String(size) },
+    });
+  }
+  return violations;
+}
+
+// SUPERVISED and SUGGEST_ONLY regions calling effectful code without saying what must hold
+async function unconstrainedEffects(
+  config: TrustConfig,
+  content: string,
+  filePath: string,
+  annotations: ParsedAnnotation[],
+  calls: EffectfulCall[]
+): Promise<Violation[]> {
+  const lines = content.split(/\r?\n/);
+  const violations: Violation[] = [];
+  for (const annotation of annotations) {
+    const { line_start: start, line_end: end } = annotation;
+    const constraints = resolveNestedAnnotation(annotations, start, end).attributes.constraints ?? [];
+    if (constraints.some(c => c.trim() !== "")) continue;
+
+    // Calls inside a nested annotation are that annotation's to declare
+    const nested = annotations.filter(
+      a => a !== annotation && start <= a.line_start && a.line_end <= end && a.line_end - a.line_start < end - start
+    );
+    const call = findEffectfulCalls(lines.slice(start - 1, end).join("\n"), filePath, calls)
+      .map(site => ({ ...site, line: start + site.line - 1 }))
+      .find(site => !nested.some(a => a.line_start <= site.line && site.line <= a.line_end));
+    if (!call) continue;
+
+    const trust = await getTrustLevelWithAnnotations(config, filePath, start, end);
+    if (trust.level !== "SUPERVISED" && trust.level !== "SUGGEST_ONLY") continue;
+    const range = `${start}-${end}`;
+    violations.push({
+      file: filePath,
+      line: annotation.comment_start ?? start,
+      rule: "annotation",
+      code: "effectful-without-constraints",
+      severity: "error",
+      message: `${trust.level} region ${range} calls ${call.callee} (effectful: "${call.pattern}") on line ${call.line} ` +
+        "but declares no constraints; add constraints=[...] saying what edits must preserve",
+      params: { level: trust.level, lines: range, call: call.callee, pattern: call.pattern, line: String(call.line) },
+    });
+  }
+  return violations;
+}
+
+export interface SizeLimits {
+  max_region_lines: number; // 0 = no limit
+  max_annotations_per_file: number;
+}
+
+// The defaults, then the lint settings, then matching overrides from least to most specific
+export function sizeLimitsFor(lint: CollabConfig["lint"], filePath: string): SizeLimits {
+  const limits: SizeLimits = {
+    max_region_lines: lint?.max_region_lines ?? DEFAULT_MAX_REGION_LINES,
+    max_annotations_per_file: lint?.max_annotations_per_file ?? DEFAULT_MAX_ANNOTATIONS_PER_FILE,
+  };
+  const normalizedPath = filePath.replace(/\\/g, "/");
+  const overrides = (lint?.overrides ?? [])
+    .filter(o => matchesPattern(normalizedPath, o.pattern))
+    .sort((a, b) => {
+      const [sa, sb] = [patternSpecificity(a.pattern), patternSpecificity(b.pattern)];
+      return sa[0] - sb[0] || sa[1] - sb[1];
+    });
+  for (const override of overrides) {
+    if (override.max_region_lines !== undefined) limits.max_region_lines = override.max_region_lines;
+    if (override.max_annotations_per_file !== undefined) limits.max_annotations_per_file = override.max_annotations_per_file;
+  }
+  return limits;
+}
+
+// Runaway regions and files governed line by line
+function oversizedGovernance(filePath: string, annotations: ParsedAnnotation[], limits: SizeLimits): Violation[] {
+  const violations: Violation[] = [];
+  for (const annotation of annotations) {
+    const count = annotation.line_end - annotation.line_start + 1;
+    if (limits.max_region_lines === 0 || count <= limits.max_region_lines) continue;
+    const lines = `${annotation.line_start}-${annotation.line_end}`;
+    violations.push({
+      file: filePath,
+      line: annotation.comment_start ?? annotation.line_start,
+      rule: "annotation",
+      code: "region-too-large",
+      severity: "warning",
+      message: `Annotation governs ${count} lines (${lines}), over the limit of ${limits.max_region_lines}; ` +
+        "govern narrower regions or check where the block ends",
+      params: { count: String(count), lines, limit: String(limits.max_region_lines) },
+    });
+  }
+
+  const limit = limits.max_annotations_per_file;
+  if (limit !== 0 && annotations.length > limit) {
+    violations.push({
+      file: filePath,
+      line: 1,
+      rule: "annotation",
+      code: "too-many-annotations",
+      severity: "warning",
+      message: `File has ${annotations.length} annotations, over the limit of ${limit}; consider a trust.yaml policy for it`,
+      params: { count: String(annotations.length), limit: String(limit) },
+    });
+  }
+  return violations;
+}
+
+export async function checkFile(
+  config: TrustConfig,
+  filePath: string,
+  aliases?: Record<string, TrustLevel>,
+  options: CheckOptions = {}
+): Promise<FileCheckResult> {
+  let content: string;
+  try {
+    content = await fs.readFile(filePath, { encoding: "utf-8", signal: options.signal });
+  } catch {
+    options.signal?.throwIfAborted();
+    throw new CheckToolError(`Cannot read ${filePath}`);
+  }
+
+  const violations: Violation[] = [];
+
+  // 1. Malformed annotations silently weaken protection
+  const { annotations, errors, warnings } = parseAnnotationContent(content, filePath, { aliases });
+  for (const error of errors) {
+    violations.push({
+      file: filePath,
+      line: error.line,
+      rule: "annotation",
+      code: error.code,
+      severity: "error",
+      message: error.message,
+      params: error.params,
+      suggestion: error.suggestion,
+    });
+  }
+
+  // 2. Well-formed annotations whose attributes contradict each other
+  for (const warning of warnings) {
+    violations.push({
+      file: filePath,
+      line: warning.line,
+      rule: "annotation",
+      code: warning.code,
+      severity: "warning",
+      message: warning.message,
+      params: warning.params,
+    });
+  }
+
+  // 3. Owner keys the registry does not define would route notifications nowhere
+  if (options.owners) {
+    for (const annotation of annotations) {
+      for (const key of ownerList(annotation.owner)) {
+        if (Object.hasOwn(options.owners, key)) continue;
+        violations.push({
+          file: filePath,
+          line: annotation.comment_start ?? annotation.line_start,
+          rule: "annotation",
+          code: "unknown-owner",
+          severity: "error",
+          message: `Unknown owner "${key}"; annotations must name an owner key defined under owners in config.yaml`,
+          params: { owner: key },
+        });
+      }
+    }
+  }
+
+  // 5. Equally specific policies that disagree leave the file's trust to a tie-break
+  const policyMatch = findMatchingPolicy(config.policies, filePath.replace(/\\/g, "/"));
+  if (policyMatch && policyMatch.ties.length > 0) {
+    const patterns = [policyMatch.policy, ...policyMatch.ties].map(p => `"${p.pattern}" (${p.trust})`).join(", ");
+    violations.push({
+      file: filePath,
+      line: 1,
+      rule: "policy",
+      code: "ambiguous-policy",
+      severity: "error",
+      message: `Policies ${patterns} match this file equally specifically with different trust; ` +
+        `"${policyMatch.policy.pattern}" applies`,
+      params: { patterns, applied: `"${policyMatch.policy.pattern}"` },
+    });
+  }
+
+  // 9. Path policies outrank test_trust, so a broad one (like those init writes) hides it.
+  // A READ_ONLY policy is taken as a deliberate lock, and a *_test pattern as test trust.
+  const testTrust = config.test_trust;
+  const shadowing = policyMatch?.policy;
+  if (
+    testTrust && shadowing && shadowing.trust !== testTrust && shadowing.trust !== "READ_ONLY" &&
+    !shadowing.pattern.includes("_test") && isGoTestSource(filePath, content)
+  ) {
+    violations.push({
+      file: filePath,
+      line: 1,
+      rule: "policy",
+      code: "shadowed-test-trust",
+      severity: "warning",
+      message: `test_trust (${testTrust}) does not apply: policy "${shadowing.pattern}" (${shadowing.trust}) ` +
+        `outranks it; add a policy for this directory's *_test.go files to set their trust`,
+      params: { test_trust: testTrust, pattern: `"${shadowing.pattern}"`, trust: shadowing.trust },
+    });
+  }
+
+  // 4. Opt-in advisory against locking trivial internals
+  const maxHelperLines = options.lint?.read_only_helper_max_lines;
+  if (maxHelperLines !== undefined) {
+    violations.push(...smallReadOnlyHelpers(content, filePath, annotations, maxHelperLines));
+  }
+
+  // 6. Governance too coarse or too fine to review
+  violations.push(...oversizedGovernance(filePath, annotations, sizeLimitsFor(options.lint, filePath)));
+
+  // 8. Opt-in: code with external effects must say what any edit has to preserve
+  const effectfulCalls = options.lint?.effectful_calls ?? [];
+  if (effectfulCalls.length > 0) {
+    violations.push(...(await unconstrainedEffects(config, content, filePath, annotations, effectfulCalls)));
+  }
+
+  // 7. Recorded LLM edits that landed inside READ_ONLY or generated code
+  const records = [
+    ...(await loadAuthorship(filePath)),
+    ...(path.isAbsolute(filePath) ? [] : await loadAuthorship(path.resolve(filePath))),
+  ];
+  for (const record of records) {
+    const trust = await getTrustLevelWithAnnotations(
+      config,
+      filePath,
+      record.line_start,
+      record.line_end
+    );
+    if (trust.level === "READ_ONLY" && trust.source === "generated") {
+      violations.push({
+        file: filePath,
+        line: record.line_start,
+        rule: "read-only-edit",
+        code: "generated-file-edit",
+        severity: "error",
+        message: `${record.author} edited lines ${record.line_start}-${record.line_end} of a generated file; ` +
+          "change the generator or its input and regenerate instead",
+        params: { author: record.author, lines: `${record.line_start}-${record.line_end}` },
+      });
+    } else if (trust.level === "READ_ONLY" && isVendoredPath(filePath, options.vendor)) {
+      violations.push({
+        file: filePath,
+        line: record.line_start,
+        rule: "read-only-edit",
+        code: "vendored-read-only-edit",
+        severity: "error",
+        message: `${record.author} edited lines ${record.line_start}-${record.line_end} of a vendored READ_ONLY region` +
+          (trust.reason ? ` (${trust.reason})` : "") + "; change it upstream and vendor it again",
+        params: {
+          author: record.author,
+          lines: `${record.line_start}-${record.line_end}`,
+          ...(trust.reason ? { reason: trust.reason } : {}),
+        },
+      });
+    } else if (trust.level === "READ_ONLY") {
+      violations.push({
+        file: filePath,
+        line: record.line_start,
+        rule: "read-only-edit",
+        code: "read-only-edit",
+        severity: "error",
+        message: `${record.author} edited lines ${record.line_start}-${record.line_end} of a READ_ONLY region` +
+          (trust.reason ? ` (${trust.reason})` : ""),
+        params: {
+          author: record.author,
+          lines: `${record.line_start}-${record.line_end}`,
+          ...(trust.reason ? { reason: trust.reason } : {}),
+        },
+      });
+    }
+  }
+
+  return errors.length > 0 ? { file: filePath, violations, parse_errors: errors.length } : { file: filePath, violations };
+}
+
+export async function checkFiles(paths: string[], options: CheckOptions = {}): Promise<CheckReport> {
+  const { signal } = options;
+  signal?.throwIfAborted();
+
+  const config = await loadTrustConfigStrict(options.profile);
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid ${COLLAB_DIR}/config.yaml: ${error.message}`);
+  });
+  const { lint: configLint, vendor: configVendor } = await loadCollabConfig();
+  const lint = options.lint ?? configLint;
+  try {
+    validateEffectfulCalls(lint?.effectful_calls);
+  } catch (error) {
+    throw new CheckToolError(`Invalid ${COLLAB_DIR}/config.yaml: ${(error as Error).message}`);
+  }
+  const vendor = options.vendor ?? configVendor;
+  const owners = options.owners ?? (await loadOwnerRegistry().catch((error: Error) => {
+    throw new CheckToolError(`Invalid ${COLLAB_DIR}/config.yaml: ${error.message}`);
+  }));
+  const files = await expandPaths(paths, options);
+
+  const results: FileCheckResult[] = [];
+  for (const file of files) {
+    signal?.throwIfAborted();
+    const result = await checkFile(config, file, aliases, { ...options, lint, owners, vendor });
+    log.debug("checked file", { file, violations: result.violations.length });
+    results.push(result);
+  }
+
+  const all = results.flatMap(r => r.violations);
+  const report = {
+    profile: config.active_profile,
+    files: results,
+    total_violations: all.filter(v => v.severity === "error").length,
+    total_parse_errors: results.reduce((sum, r) => sum + (r.parse_errors ?? 0), 0),
+    total_warnings: all.filter(v => v.severity === "warning").length,
+  };
+  log.info("check complete", {
+    files: results.length,
+    violations: report.total_violations,
+    parse_errors: report.total_parse_errors,
+    warnings: report.total_warnings,
+    profile: report.profile,
+  });
+  return report;
+}
+
+// ============================================
+// Deduplication
+// ============================================
+
+export interface DedupeOptions {
+  config?: TrustConfig; // Its `regions` are regions too; annotations alone without it
+  priority?: string[]; // Rules or codes, most important first (config.yaml lint.dedupe_priority)
+  listSuppressed?: boolean; // List the collapsed violations under each file's `suppressed`
+}
+
+// The innermost annotation (its comment included) or trust.yaml region around a line, as "start-end";
+// "file" outside any
+function regionKey(spans: { start: number; end: number }[], line: number): string {
+  const around = spans
+    .filter(r => r.start <= line && line <= r.end)
+    .sort((a, b) => a.end - a.start - (b.end - b.start));
+  return around.length > 0 ? `${around[0].start}-${around[0].end}` : "file";
+}
+
+/**
+ * The report with only the most severe violation of each governed region:
+ * the innermost annotation or trust.yaml region around the violation's line,
+ * or the rest of the file. Errors outrank warnings; then a violation whose
+ * code or rule comes earlier in `priority` outranks a later or unlisted one;
+ * among equals the first by line is kept. The kept violation counts
+ * the others as `suppressed_count`, and with `listSuppressed` its file lists
+ * them under `suppressed`. The totals still count every violation, so the
+ * exit code does not change. `annotations` are each file's, by path.
+ */
+export function dedupeByRegion(
+  report: CheckReport,
+  annotations: Map<string, ParsedAnnotation[]>,
+  options: DedupeOptions = {}
+): CheckReport {
+  const { config, priority = [] } = options;
+  const position = (v: Violation) => {
+    const index = priority.findIndex(entry => entry === v.code || entry === v.rule);
+    return index === -1 ? priority.length : index;
+  };
+  const rank = (v: Violation) => (v.severity === "error" ? 0 : 1);
+  let totalSuppressed = 0;
+  const files = report.files.map(result => {
+    const fileAnnotations = annotations.get(result.file) ?? [];
+    const spans = [
+      ...fileAnnotations.map(a => ({ start: a.comment_start ?? a.line_start, end: a.line_end })),
+      ...(config?.regions ?? [])
+        .filter(r => regionMatchesFile(r, result.file))
+        .map(r => ({ start: r.line_start, end: r.line_end })),
+    ];
+
+    const regions = new Map<string, Violation[]>();
+    for (const v of result.violations) {
+      const key = regionKey(spans, v.line);
+      regions.set(key, [...(regions.get(key) ?? []), v]);
+    }
+
+    const kept = new Map<Violation, number>();
+    const suppressed: Violation[] = [];
+    for (const group of regions.values()) {
+      const [top, ...rest] = [...group].sort(
+        (a, b) => rank(a) - rank(b) || position(a) - position(b) || a.line - b.line
+      );
+      kept.set(top, rest.length);
+      suppressed.push(...rest);
+    }
+    if (suppressed.length === 0) return result;
+
+    totalSuppressed += suppressed.length;
+    const violations = result.violations
+      .filter(v => kept.has(v))
+      .map(v => (kept.get(v)! > 0 ? { ...v, suppressed_count: kept.get(v) } : v));
+    return options.listSuppressed
+      ? { ...result, violations, suppressed: suppressed.sort((a, b) => a.line - b.line) }
+      : { ...result, violations };
+  });
+  return { ...report, files, total_suppressed: totalSuppressed };
+}
+
+// ============================================
+// Output Formats
+// ============================================
+
+function escapeXml(text: string): string {
+  return text
+    .replace(/&/g, "&amp;")
+    .replace(/</g, "&lt;")
+    .replace(/>/g, "&gt;")
+    .replace(/"/g, "&quot;")
+    .replace(/'/g, "&apos;");
+}
+
+export function formatText(report: CheckReport): string {
+  const lines: string[] = [];
+  for (const result of report.files) {
+    for (const v of result.violations) {
+      const prefix = v.severity === "warning" ? "warning: " : "";
+      const more = v.suppressed_count ? ` (+${v.suppressed_count} more in this region)` : "";
+      lines.push(`${formatRange(rangeOf(v), v.file)}: ${prefix}[${v.code}] ${v.message}${more}`);
+      if (v.suggestion) {
+        lines.push(`    suggested fix: ${v.suggestion.trim()}`);
+      }
+    }
+  }
+
+  const checked = `Checked ${report.files.length} file(s)` + (report.profile ? ` [profile: ${report.profile}]` : "");
+  const warnings = report.total_warnings > 0 ? `, ${report.total_warnings} warning(s)` : "";
+  const suppressed = report.total_suppressed ? ` (${report.total_suppressed} collapsed by --dedupe=region)` : "";
+  lines.push(
+    (report.total_violations === 0
+      ? `${checked}: no violations${warnings}`
+      : `${checked}: ${report.total_violations} violation(s)${warnings}`) + suppressed
+  );
+  return lines.join("\n");
+}
+
+// Warnings are not failures; they are listed in the testcase's system-out
+export function formatJunit(report: CheckReport): string {
+  const failing = report.files.filter(r => r.violations.some(v => v.severity === "error")).length;
+  const tests = report.files.length;
+
+  const lines = [
+    `<?xml version="1.0" encoding="UTF-8"?>`,
+    `<testsuites name="collab-check" tests="${tests}" failures="${failing}" errors="0">`,
+    `  <testsuite name="collab-check" tests="${tests}" failures="${failing}" errors="0">`,
+  ];
+  if (report.profile) {
+    lines.push(
+      `    <properties>`,
+      `      <property name="profile" value="${escapeXml(report.profile)}"/>`,
+      `    </properties>`
+    );
+  }
+
+  for (const result of report.files) {
+    const name = escapeXml(result.file);
+    if (result.violations.length === 0) {
+      lines.push(`    <testcase classname="collab" name="${name}"/>`);
+      continue;
+    }
+
+    lines.push(`    <testcase classname="collab" name="${name}">`);
+    for (const v of result.violations.filter(v => v.severity === "error")) {
+      lines.push(
+        `      <failure type="${escapeXml(v.code)}" message="${escapeXml(v.message)}">` +
+          `${escapeXml(`${formatRange(rangeOf(v), v.file)}: ${v.message}`)}` +
+          (v.suggestion ? escapeXml(`\nSuggested fix: ${v.suggestion.trim()}`) : "") +
+          `</failure>`
+      );
+    }
+    const warnings = result.violations.filter(v => v.severity === "warning");
+    if (warnings.length > 0) {
+      const text = warnings.map(v => `warning: ${formatRange(rangeOf(v), v.file)}: [${v.code}] ${v.message}`).join("\n");
+      lines.push(`      <system-out>${escapeXml(text)}</system-out>`);
+    }
+    lines.push(`    </testcase>`);
+  }
+
+  lines.push(`  </testsuite>`, `</testsuites>`);
+  return lines.join("\n");
+}
+
+// What governs a violation's line, for a review comment: the annotation, or the trust.yaml setting
+function governanceNote(file: string, trust: TrustResult, annotations: ParsedAnnotation[], line: number): string {
+  const owner = formatOwner(trust.owner);
+  const parts = [`Trust: ${trust.level}`];
+  if (owner) parts.push(`Owner: ${owner}`);
+  const governing = trust.source === "annotation" ? governingAnnotation(annotations, line, line) : undefined;
+  if (governing) {
+    const { annotation } = governing;
+    const text = formatAnnotation(annotation, { filePath: file, maxWidth: Infinity });
+    parts.push(`Governed by \`${text}\` (${formatRange(rangeOf(annotation), file)})`);
+  } else if (trust.pattern) {
+    parts.push(`Governed by trust.yaml policy \`${trust.pattern}\``);
+  } else if (trust.reason) {
+    parts.push(`Governed by: ${trust.reason}`);
+  }
+  return parts.join(" · ");
+}
+
+function reviewCommentBody(v: Violation, note: string): string {
+  const lines = [`**collab ${v.severity}** \`${v.code}\`: ${v.message}`, "", note];
+  if (v.suppressed_count) lines.push("", `${v.suppressed_count} more finding(s) in this region not shown`);
+  if (v.suggestion) lines.push("", "```suggestion", v.suggestion.replace(/\n$/, ""), "```");
+  return lines.join("\n");
+}
+
+/**
+ * The report as a GitHub pull request review: a comment on each violation's
+ * line, right side of the diff. Given `changed` (each file's changed line
+ * ranges in the PR), violations outside them are summarized in the body,
+ * since GitHub only accepts comments on lines in the diff.
+ */
+export async function githubReview(
+  report: CheckReport,
+  config: TrustConfig,
+  changed?: Map<string, { line_start: number; line_end: number }[]>
+): Promise<GithubReview> {
+  const comments: GithubReviewComment[] = [];
+  const outside: string[] = [];
+  for (const result of report.files) {
+    if (result.violations.length === 0) continue;
+    const file = result.file.replace(/\\/g, "/");
+    const annotations = await parseAnnotations(result.file).catch(() => []);
+    for (const v of result.violations) {
+      const trust = await getTrustLevelWithAnnotations(config, result.file, v.line, v.line);
+      const body = reviewCommentBody(v, governanceNote(file, trust, annotations, v.line));
+      const inDiff = !changed || (changed.get(file) ?? []).some(r => r.line_start <= v.line && v.line <= r.line_end);
+      if (inDiff) {
+        comments.push({ path: file, line: v.line, side: "RIGHT", body });
+      } else {
+        outside.push(`- ${formatRange(rangeOf(v), file)}: ${v.severity} \`${v.code}\`: ${v.message}`);
+      }
+    }
+  }
+
+  const warnings = report.total_warnings > 0 ? `, ${report.total_warnings} warning(s)` : "";
+  const summary = `collab-claude-code check: ${report.total_violations} violation(s)${warnings}` +
+    (report.profile ? ` [profile: ${report.profile}]` : "");
+  const body = outside.length > 0 ? [summary, "", "Outside the diff:", ...outside].join("\n") : summary;
+  return { event: "COMMENT", body, comments };
+}
+
+// Changed line ranges of each file between base and the working tree, keyed by path
+async function changedLinesSince(base: string): Promise<Map<string, { line_start: number; line_end: number }[]>> {
+  const changed = new Map<string, { line_start: number; line_end: number }[]>();
+  for (const file of await listChangedFilesWithRenames(base)) {
+    if (file.status === "D") continue;
+    changed.set(file.path, await listChangedLines(base, undefined, file));
+  }
+  return changed;
+}
+
+export function formatReport(report: CheckReport, format: Exclude<CheckFormat, "github-comments">): string {
+  switch (format) {
+    case "json":
+      return JSON.stringify(report, null, 2);
+    case "junit":
+      return formatJunit(report);
+    case "text":
+    default:
+      return formatText(report);
+  }
+}
+
+// ============================================
+// Shadow Mode
+// ============================================
+
+// Audit entries for the violations that would have failed the check
+export function shadowAuditEntries(report: CheckReport, timestamp = new Date().toISOString()): AuditEntry[] {
+  return report.files.flatMap(file =>
+    file.violations
+      .filter(v => v.severity === "error")
+      .map(v => ({
+        timestamp,
+        mode: "shadow" as const,
+        source: "check" as const,
+        file_path: v.file,
+        line: v.line,
+        code: v.code,
+        would_decide: "fail",
+        message: v.message,
+      }))
+  );
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+// Parse failures outrank other violations: a malformed annotation protects nothing
+export function checkExitCode(report: CheckReport): number {
+  if (report.total_parse_errors > 0) return EXIT_TOOL_ERROR;
+  return report.total_violations > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+}
+
+export async function runCheck(args: string[]): Promise<number> {
+  let format: CheckFormat = "text";
+  let profile: string | undefined;
+  let noIgnore = false;
+  let shadow = false;
+  let base: string | undefined;
+  let dedupe = false;
+  let listSuppressed = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg.startsWith("--format=")) {
+      const value = arg.slice("--format=".length) as CheckFormat;
+      if (!CHECK_FORMATS.includes(value)) {
+        console.error(`Unknown format: ${value} (expected ${CHECK_FORMATS.join(", ")})`);
+        return EXIT_TOOL_ERROR;
+      }
+      format = value;
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg === "--shadow") {
+      shadow = true;
+    } else if (arg.startsWith("--base=")) {
+      base = arg.slice("--base=".length);
+    } else if (arg.startsWith("--dedupe=")) {
+      const mode = arg.slice("--dedupe=".length);
+      if (!DEDUPE_MODES.includes(mode)) {
+        console.error(`Unknown dedupe mode: ${mode} (expected ${DEDUPE_MODES.join(", ")})`);
+        return EXIT_TOOL_ERROR;
+      }
+      dedupe = true;
+    } else if (arg === "--list-suppressed") {
+      listSuppressed = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+  if (base !== undefined && format !== "github-comments") {
+    console.error("--base only applies to --format=github-comments");
+    return EXIT_TOOL_ERROR;
+  }
+  if (listSuppressed && !dedupe) {
+    console.error("--list-suppressed only applies with --dedupe=region");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const report = await checkFiles(paths, { profile, noIgnore });
+    shadow ||= isShadowMode(await loadCollabConfig());
+    const localizer = await loadLocalizer();
+    for (const file of report.files) {
+      file.violations = localizeMessages(file.violations, localizer);
+    }
+    // Shaping the output only: shadow mode below still records every violation
+    let shown = report;
+    if (dedupe) {
+      const annotations = new Map<string, ParsedAnnotation[]>();
+      for (const file of report.files.filter(f => f.violations.length > 1)) {
+        annotations.set(file.file, await parseAnnotations(file.file).catch(() => []));
+      }
+      const config = await loadTrustConfigStrict(report.profile);
+      const priority = (await loadCollabConfig()).lint?.dedupe_priority;
+      shown = dedupeByRegion(report, annotations, { config, priority, listSuppressed });
+    }
+    if (format === "github-comments") {
+      const config = await loadTrustConfigStrict(report.profile);
+      const changed = base !== undefined ? await changedLinesSince(base) : undefined;
+      console.log(JSON.stringify(await githubReview(shown, config, changed), null, 2));
+    } else {
+      console.log(formatReport(shown, format));
+    }
+    if (!shadow) return checkExitCode(report);
+
+    const entries = shadowAuditEntries(report);
+    await recordAuditEntries(entries);
+    if (entries.length > 0) {
+      console.error(`Shadow mode: ${entries.length} violation(s) recorded in ${path.join(COLLAB_DIR, AUDIT_FILE)}, not failing`);
+    }
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -6,13 +6,70 @@
  * Usage:
  *   collab-claude-code init       - Install skills, MCP server, and hooks
  *   collab-claude-code uninstall  - Remove all components
+ *   collab-claude-code check      - Validate annotations and authorship (CI)
+ *   collab-claude-code fmt        - Rewrite @collab annotations into canonical form
+ *   collab-claude-code diff       - Report trust changes between git revisions
+ *   collab-claude-code audit-signoffs - Flag downgrades and owner changes no owner signed off on
+ *   collab-claude-code scan       - Summarize trust regions in files
+ *   collab-claude-code scan-archive - Scan annotations inside a tar, tgz, or zip without extracting it
+ *   collab-claude-code at         - One-line trust summary at a cursor, for editor status lines
+ *   collab-claude-code heatmap    - Per-file line counts by trust level
+ *   collab-claude-code budget     - Enforce caps on the fraction of AUTONOMOUS code
+ *   collab-claude-code explain-policy - Show the resolved config for a file, with sources
+ *   collab-claude-code simulate   - Preview trust changes a candidate trust.yaml would make
+ *   collab-claude-code test-policy - Check expected allow/propose/deny decisions for fixture edits
+ *   collab-claude-code doctor     - Validate config, extends sources, and annotations in one pass
+ *   collab-claude-code verify-constraints - Quarantine regions whose code breaks their constraints
+ *   collab-claude-code audit-owners - List owners missing from the roster and the regions they govern
+ *   collab-claude-code verify-authors - Flag owned regions last changed by someone outside the owning team
+ *   collab-claude-code constraints - List every constraint with the regions that declare it
+ *   collab-claude-code manifest   - Governance snapshot of every resolved region and the merged config (JSON)
+ *   collab-claude-code warm       - Fill the extended policy cache and parse every file before serving
+ *   collab-claude-code proposals  - List, show, and move proposals through their lifecycle
+ *   collab-claude-code completions - Annotation completions for editor plugins (JSON)
+ *   collab-claude-code routes      - HTTP routes with their handlers' trust and owners (JSON)
+ *   collab-claude-code reasons    - Print the violation code and trust level reference
  *   collab-claude-code --help     - Show help
+ *
+ * --log-level=debug|info|warn|error and --log-format=text|json may appear
+ * anywhere; logs go to stderr, see log.ts.
  */
 
 import { init, uninstall, showHelp } from "./installer.js";
+import { runCheck } from "./check.js";
+import { runFmt } from "./fmt.js";
+import { runDiff } from "./diff.js";
+import { runAuditSignoffs } from "./signoffs.js";
+import { runScan } from "./scan.js";
+import { runScanArchive } from "./archive.js";
+import { runAt } from "./at.js";
+import { runHeatmap } from "./heatmap.js";
+import { runBudget } from "./budget.js";
+import { runExplainPolicy } from "./explain.js";
+import { runSimulate } from "./simulate.js";
+import { runTestPolicy } from "./test-policy.js";
+import { runDoctor } from "./doctor.js";
+import { runVerifyConstraints } from "./verify-constraints.js";
+import { runAuditOwners } from "./audit-owners.js";
+import { runVerifyAuthors } from "./verify-authors.js";
+import { runManifest } from "./manifest.js";
+import { runConstraints } from "./constraints.js";
+import { runWarm } from "./warm.js";
+import { runProposals } from "./proposals.js";
+import { runCompletions } from "./completions.js";
+import { runRoutes } from "./routes.js";
+import { runReasons } from "./reasons.js";
+import { EXIT_TOOL_ERROR } from "./check.js";
+import { applyLogArgs } from "./log.js";
 
 async function main(): Promise<void> {
-  const args = process.argv.slice(2);
+  let args: string[];
+  try {
+    args = applyLogArgs(process.argv.slice(2));
+  } catch (error) {
+    console.error(`Error: ${(error as Error).message}`);
+    process.exit(EXIT_TOOL_ERROR);
+  }
   const command = args[0];
 
   switch (command) {
@@ -26,6 +83,75 @@ async function main(): Promise<void> {
       await uninstall();
       break;
 
+    case "check":
+      process.exit(await runCheck(args.slice(1)));
+
+    case "fmt":
+      process.exit(await runFmt(args.slice(1)));
+
+    case "diff":
+      process.exit(await runDiff(args.slice(1)));
+
+    case "audit-signoffs":
+      process.exit(await runAuditSignoffs(args.slice(1)));
+
+    case "scan":
+      process.exit(await runScan(args.slice(1)));
+
+    case "scan-archive":
+      process.exit(await runScanArchive(args.slice(1)));
+
+    case "at":
+      process.exit(await runAt(args.slice(1)));
+
+    case "heatmap":
+      process.exit(await runHeatmap(args.slice(1)));
+
+    case "budget":
+      process.exit(await runBudget(args.slice(1)));
+
+    case "explain-policy":
+      process.exit(await runExplainPolicy(args.slice(1)));
+
+    case "simulate":
+      process.exit(await runSimulate(args.slice(1)));
+
+    case "test-policy":
+      process.exit(await runTestPolicy(args.slice(1)));
+
+    case "doctor":
+      process.exit(await runDoctor(args.slice(1)));
+
+    case "verify-constraints":
+      process.exit(await runVerifyConstraints(args.slice(1)));
+
+    case "audit-owners":
+      process.exit(await runAuditOwners(args.slice(1)));
+
+    case "verify-authors":
+      process.exit(await runVerifyAuthors(args.slice(1)));
+
+    case "constraints":
+      process.exit(await runConstraints(args.slice(1)));
+
+    case "manifest":
+      process.exit(await runManifest(args.slice(1)));
+
+    case "warm":
+      process.exit(await runWarm(args.slice(1)));
+
+    case "proposals":
+      process.exit(await runProposals(args.slice(1)));
+
+    case "completions":
+      process.exit(await runCompletions(args.slice(1)));
+
+    case "routes":
+      process.exit(await runRoutes(args.slice(1)));
+
+    case "reasons":
+      process.exit(runReasons(args.slice(1)));
+
     case "--help":
     case "-h":
     case "help":
@@ -1,8 +1,17 @@
+import { createHash } from "crypto";
 import * as fs from "fs/promises";
 import * as path from "path";
 import * as yaml from "yaml";
 import { glob } from "glob";
 
+import type { EffectfulCall } from "./effects.js";
+import { git } from "./git.js";
+import { goDeclarationSpan, goSyntaxError } from "./goscope.js";
+import { loadIgnoreFilter } from "./ignore.js";
+import { PolicySource, loadExtendedPolicies, mergePolicy } from "./policy.js";
+import { ReasonCodeId } from "./reasons.js";
+import { log } from "./log.js";
+
 // ============================================
 // Types
 // ============================================
@@ -12,10 +21,39 @@ export type TrustLevel = "AUTONOMOUS" | "SUGGEST_ONLY" | "READ_ONLY" | "SUPERVIS
 export interface TrustPolicy {
   pattern: string;
   trust: TrustLevel;
-  owner?: string;
+  owner?: string | string[]; // A list for co-owned code
+  reason?: string;
+}
+
+// Default trust for declarations by name, e.g. every "*Handler"
+export interface SymbolPolicy {
+  pattern: string; // Matches trailing name segments: "*Handler", "Server.*"
+  trust: TrustLevel;
+  owner?: string | string[];
   reason?: string;
 }
 
+// What symbol policies need to know about a declaration
+// A point in a file. Lines and columns are 1-indexed; columns and offsets count UTF-8 bytes,
+// as editors' byte ranges do. Most locations are whole lines, so col and offset are optional.
+export interface Position {
+  line: number;
+  col?: number;
+  offset?: number; // From the start of the file, 0-indexed
+}
+
+// Inclusive at both ends; a one-line region starts and ends on the same line
+export interface Range {
+  start: Position;
+  end: Position;
+}
+
+export interface DeclarationSpan {
+  qualified_name: string; // "Type.method" for methods
+  line_start: number;
+  line_end: number;
+}
+
 export interface RegionOverride {
   file: string;
   line_start: number;
@@ -24,19 +62,43 @@ export interface RegionOverride {
   reason?: string;
 }
 
+export interface TrustProfile {
+  branches?: string[]; // Branch globs that select this profile, e.g. "feature/*"
+  overrides?: Partial<Record<TrustLevel, TrustLevel>>; // Resolved level -> effective level
+}
+
+// Cap on the share of resolved lines in matching files that may be AUTONOMOUS
+export interface TrustBudget {
+  pattern: string;
+  max_autonomous_fraction: number; // 0 to 1
+  reason?: string;
+}
+
 export interface TrustConfig {
   default_trust: TrustLevel;
   policies: TrustPolicy[];
   regions?: RegionOverride[];
+  symbols?: SymbolPolicy[];
+  profiles?: Record<string, TrustProfile>;
+  budgets?: TrustBudget[];
+  generated_trust?: TrustLevel | false; // Level for generated files (default READ_ONLY); false to disable
+  test_trust?: TrustLevel; // Default for Go test files (see isGoTestSource) instead of default_trust
+  extends?: PolicySource | PolicySource[]; // Shared policies merged under this config
+  active_profile?: string; // Selected at load time, never saved
 }
 
 export interface TrustResult {
   level: TrustLevel;
   reason?: string;
-  owner?: string;
+  owner?: string | string[];
   intent?: string;
   constraints?: string[];
-  source?: "annotation" | "region" | "policy" | "default";
+  min_approvals?: number;
+  design_doc?: string; // Design doc approvers of proposals here must acknowledge
+  source?: "generated" | "annotation" | "region" | "symbol" | "policy" | "test" | "package" | "default";
+  pattern?: string; // For source "policy": the glob that won
+  profile?: string; // Active environment profile, if any
+  base_level?: TrustLevel; // Level before the profile override, when one applied
 }
 
 export interface Intent {
@@ -51,11 +113,27 @@ export interface Intent {
   non_goals?: string[];
 }
 
+export interface ProposalApproval {
+  approver: string;
+  approved_at: string;
+  design_doc?: string; // The design doc the approver acknowledged, for regions that link one
+}
+
+// pending -> approved -> applied, or rejected from pending or approved
+export type ProposalStatus = "pending" | "approved" | "rejected" | "applied";
+
+export interface ProposalTransition {
+  status: ProposalStatus; // The status entered
+  at: string;
+  by?: string;
+  reason?: string;
+}
+
 export interface Proposal {
   id: string;
   created_at: string;
   author: string;
-  status: "pending" | "approved" | "rejected";
+  status: ProposalStatus;
   file_path: string;
   description: string;
   rationale?: string;
@@ -64,6 +142,33 @@ export interface Proposal {
   confidence: number;
   risks?: string[];
   tests_needed?: string[];
+  min_approvals?: number; // Distinct non-author approvals required (default: 1)
+  design_doc?: string; // Design doc of the region it replaces; every approver must acknowledge it
+  approvals?: ProposalApproval[];
+  redacted?: RedactedCode; // Set when old_code is a placeholder for a redact="true" region
+  owners?: string[]; // Owners of the region it replaces, to be notified
+  base?: ProposalBase; // The region as it was when proposed, to detect stale proposals
+  batch?: string; // Id of the batch it was created in, see createBatchProposals
+  history?: ProposalTransition[]; // Every status it has entered, oldest first; append-only
+}
+
+export interface ProposalBatch {
+  id: string;
+  owners?: string[]; // Owners of every region in the batch; unset for unowned regions
+  proposals: Proposal[]; // One per region, each approved and applied on its own
+}
+
+export interface ProposalBase {
+  line_start: number;
+  line_end: number;
+  sha256: string; // Hash of lines line_start..line_end, LF-joined
+  trust: TrustLevel; // Governing level of those lines
+}
+
+export interface RedactedCode {
+  sha256: string; // Hash of the original old_code
+  line_start: number;
+  line_end: number;
 }
 
 export interface AuthorshipRecord {
@@ -76,13 +181,135 @@ export interface AuthorshipRecord {
   confidence: number;
 }
 
+export type EnforcementMode = "enforce" | "shadow";
+
+// A violation shadow mode let through, appended to .collab/audit.jsonl
+export interface AuditEntry {
+  timestamp: string;
+  mode: "shadow";
+  source: "check" | "pre-edit" | "gateway";
+  file_path: string;
+  line?: number;
+  code?: string; // Reason code for check violations
+  trust?: TrustLevel;
+  would_decide: string; // What enforcement would have done, e.g. "block", "deny", "propose", "fail"
+  message: string;
+}
+
 export interface ParsedAnnotation {
   trust?: TrustLevel;
-  owner?: string;
+  owner?: string | string[]; // owner=["a", "b"] for co-owned regions
   intent?: string;
   constraints?: string[];
+  lines?: string; // Relative "N-M" range within the annotated function
+  min_approvals?: number; // Approvals required for proposals touching this region
+  design_doc?: string; // URL of the design the region implements; approvers must acknowledge it
+  redact?: boolean; // Keep the region's content out of proposals
+  requires_tests?: boolean; // Changes to the region must come with changes to its tests
+  inherit?: boolean; // false: take nothing from enclosing annotations (default true)
+  labels?: string[]; // Free-form tags for a team's own tooling, e.g. ["pci"]; never affect trust
+  note?: string; // Editorial text after the attributes, e.g. "locked for audit"; never parsed
+  when?: string; // Condition for the annotation to apply, e.g. "path:cmd/prod/**" (see annotationApplies)
+  id?: string; // Names a @collab:begin block so `@collab:end id="..."` can close it out of nesting order
   line_start: number;
   line_end: number;
+  comment_start?: number; // The @collab comment lines it was parsed from, 1-indexed
+  comment_end?: number;
+}
+
+// Contact details for an owner key, from config.yaml `owners`
+export interface OwnerContact {
+  name?: string; // Display name, e.g. "Payments Team"
+  slack?: string; // Channel, e.g. "#payments"
+  email?: string;
+  escalation?: string; // Who to go to when the owner does not respond: a person, rotation, or owner key
+  members?: string[]; // For a group: the people or owner keys in it, who count as owners of its regions
+}
+
+// An owner key resolved to its record
+export interface Owner extends OwnerContact {
+  key: string;
+}
+
+// What one agent identity may do, from config.yaml `agents`
+export interface AgentPolicy {
+  // The most restrictive level the agent may edit without review. Regions above it need a
+  // proposal; at SUGGEST_ONLY, only regions the agent owns are edited directly.
+  max_trust: Exclude<TrustLevel, "READ_ONLY">;
+}
+
+// A Go package's default from the `@collab:package` directive in its doc.go
+export interface PackageDefault {
+  file: string; // The doc.go declaring it
+  line: number;
+  trust?: TrustLevel;
+  owner?: string | string[];
+  intent?: string;
+}
+
+export interface ParseOptions {
+  aliases?: Record<string, TrustLevel>; // Bare-word shorthands, e.g. ro -> READ_ONLY
+}
+
+export interface CollabConfig {
+  version?: string;
+  confidence_threshold?: number;
+  auto_record_authorship?: boolean;
+  model?: string;
+  suggest?: {
+    auto_approve_trivial?: boolean;
+    block_direct_edits?: boolean; // The pre-edit hook blocks SUGGEST_ONLY edits it does not auto-approve (default: warns)
+  };
+  aliases?: Record<string, string>;
+  exclude?: string[]; // .gitignore-syntax patterns skipped when scanning
+  vendor?: string[]; // Path patterns of vendored code (default: vendor/ directories); edits to its READ_ONLY regions are reported as drift
+  pre_apply_hooks?: string[]; // Modules that can veto applying a proposal
+  constraint_verifiers?: string[]; // Modules that check code against its annotated constraints
+  route_extractors?: string[]; // Modules that find HTTP route registrations, for the routes command
+  transfer_hooks?: string[]; // Modules told about regions changing owners, for diff --notify
+  owners?: Record<string, OwnerContact>; // Owner keys annotations may name; once set, any other owner is an error
+  agents?: Record<string, AgentPolicy>; // Per-identity trust ceilings for the edit gateway; unlisted agents get region trust as is
+  identities?: Record<string, string | string[]>; // Author (usually an email) -> the teams they belong to, see identity.ts
+  team_roster?: string; // File of "<team> <member>..." lines in CODEOWNERS syntax, merged into identities
+  lint?: {
+    read_only_helper_max_lines?: number; // Flag READ_ONLY on unexported Go functions up to this size
+    max_region_lines?: number; // Warn about annotated regions longer than this (0 turns it off)
+    max_annotations_per_file?: number; // Warn about files with more annotations than this (0 turns it off)
+    overrides?: LintOverride[]; // Size limits for matching paths; more specific patterns win
+    effectful_calls?: EffectfulCall[]; // Calls that need a constraint on SUPERVISED and SUGGEST_ONLY regions making them
+    dedupe_priority?: string[]; // Rules or codes --dedupe=region keeps first, most important first
+  };
+  tests?: Record<string, string[]>; // Test file patterns by source extension, for requires_tests
+  locale?: string; // Language for violation messages, e.g. "de"; defaults to LANG
+  enforcement?: EnforcementMode; // "shadow" records would-be violations to the audit log but never blocks
+}
+
+export interface LintOverride {
+  pattern: string; // Glob as in trust.yaml policies, e.g. "legacy/**"
+  max_region_lines?: number;
+  max_annotations_per_file?: number;
+}
+
+export interface AnnotationError {
+  file: string;
+  line: number; // Line of the offending @collab comment (1-indexed)
+  code: ReasonCodeId;
+  message: string;
+  params?: Record<string, string>; // Values for the code's message template, for localized output
+  suggestion?: string; // Corrected comment line, only when the fix is unambiguous
+}
+
+interface AttributeError {
+  code: ReasonCodeId;
+  message: string;
+  params?: Record<string, string>;
+  fix?: { find: string; replace: string };
+}
+
+export interface AnnotationParseResult {
+  annotations: ParsedAnnotation[];
+  errors: AnnotationError[];
+  warnings: AnnotationError[]; // Well-formed but contradictory annotations
 }
 
 // ============================================
@@ -95,6 +322,53 @@ export const CONFIG_FILE = "config.yaml";
 export const META_DIR = "meta";
 export const INTENTS_DIR = "intents";
 export const PROPOSALS_DIR = "proposals";
+export const AUDIT_FILE = "audit.jsonl";
+export const PROFILE_ENV = "COLLAB_PROFILE";
+
+export const TRUST_LEVELS: TrustLevel[] = ["AUTONOMOUS", "SUPERVISED", "SUGGEST_ONLY", "READ_ONLY"];
+
+// Source file extensions scanned for @collab annotations
+export const ANNOTATABLE_EXTENSIONS = ["ts", "tsx", "js", "jsx", "mjs", "cjs", "py", "go", "s", "rs", "java", "rb", "sh"];
+
+// Higher is more restrictive
+export const TRUST_RESTRICTIVENESS: Record<TrustLevel, number> = {
+  AUTONOMOUS: 0,
+  SUPERVISED: 1,
+  SUGGEST_ONLY: 2,
+  READ_ONLY: 3,
+};
+
+// Built-in annotation shorthands; config.yaml `aliases` can add or override
+export const DEFAULT_TRUST_ALIASES: Record<string, TrustLevel> = {
+  auto: "AUTONOMOUS",
+  sv: "SUPERVISED",
+  so: "SUGGEST_ONLY",
+  ro: "READ_ONLY",
+};
+
+// Attribute keys cannot be aliases, so `@collab trust` is never ambiguous
+export const ANNOTATION_ATTRIBUTES = [
+  "trust",
+  "owner",
+  "intent",
+  "constraints",
+  "lines",
+  "min_approvals",
+  "design_doc",
+  "redact",
+  "requires_tests",
+  "inherit",
+  "labels",
+  "when",
+  "id",
+];
+const ALIAS_NAME_REGEX = /^\p{L}[\p{L}\p{N}_-]*$/u;
+
+// The Go convention most generators follow (protoc, mockgen, stringer, ...), with # for other languages
+const GENERATED_HEADER_REGEX = /^(?:\/\/|#) ?Code generated .* DO NOT EDIT\.\s*$/;
+const LEADING_COMMENT_REGEX = /^\s*(?:\/\/|#|\/\*|\*)/;
+// `import "testing"`, `import t "testing"`, or a "testing" line in an import ( ... ) group
+const TESTING_IMPORT_REGEX = /^\s*import\s+(?:[\w.]+\s+)?"testing"|^\s*import\s*\([^)]*^\s*(?:[\w.]+\s+)?"testing"/m;
 
 // ============================================
 // Utility Functions
@@ -114,6 +388,18 @@ export async function ensureCollabDir(subdir?: string): Promise<string> {
   return dir;
 }
 
+// Owners of a region, whichever form the owner attribute used
+export function ownerList(owner?: string | string[]): string[] {
+  if (owner === undefined) return [];
+  return Array.isArray(owner) ? owner : [owner];
+}
+
+// Owners for display: "auth-team, crypto-team"
+export function formatOwner(owner?: string | string[]): string | undefined {
+  const owners = ownerList(owner);
+  return owners.length > 0 ? owners.join(", ") : undefined;
+}
+
 export async function fileExists(filePath: string): Promise<boolean> {
   try {
     await fs.access(filePath);
@@ -123,20 +409,216 @@ export async function fileExists(filePath: string): Promise<boolean> {
   }
 }
 
+// Code-unit order rather than localeCompare, so paths sort the same on every machine
+export function comparePaths(a: string, b: string): number {
+  const left = a.replace(/\\/g, "/");
+  const right = b.replace(/\\/g, "/");
+  return left < right ? -1 : left > right ? 1 : 0;
+}
+
+// Order of annotations and regions in scan output: start line, then end line
+export function compareRegions(
+  a: { line_start: number; line_end: number },
+  b: { line_start: number; line_end: number }
+): number {
+  return a.line_start - b.line_start || a.line_end - b.line_end;
+}
+
+// JSON with object keys sorted at every level, for output compared across runs
+export function stableStringify(value: unknown, space?: number): string {
+  return JSON.stringify(
+    value,
+    (_key, v: unknown) =>
+      v && typeof v === "object" && !Array.isArray(v)
+        ? Object.fromEntries(Object.keys(v).sort().map(k => [k, (v as Record<string, unknown>)[k]]))
+        : v,
+    space
+  );
+}
+
+// ============================================
+// Positions
+// ============================================
+
+// The range of anything located by line: annotations and declarations (line_start/line_end),
+// or violations and diagnostics (line)
+export function rangeOf(span: { line_start: number; line_end: number } | { line: number }): Range {
+  return "line" in span
+    ? { start: { line: span.line }, end: { line: span.line } }
+    : { start: { line: span.line_start }, end: { line: span.line_end } };
+}
+
+// The position of a UTF-8 byte offset in `content`; offsets past the end clamp to it
+export function positionAt(content: string, offset: number): Position {
+  const offsets = lineByteOffsets(content);
+  const clamped = Math.max(0, Math.min(offset, offsets[offsets.length - 1]));
+  let line = 0;
+  while (line + 1 < offsets.length - 1 && offsets[line + 1] <= clamped) line++;
+  return { line: line + 1, col: clamped - offsets[line] + 1, offset: clamped };
+}
+
+// "12:5", or "12" when the column is unknown
+export function formatPosition(position: Position): string {
+  return position.col === undefined ? `${position.line}` : `${position.line}:${position.col}`;
+}
+
+/**
+ * A range as locations are printed everywhere: "file.go:12:5-18:1", "file.go:12:5-9"
+ * within one line, "file.go:12-18" for whole lines, "file.go:12" for one. The
+ * path is left out if `file` is not given.
+ */
+export function formatRange(range: Range, file?: string): string {
+  const { start, end } = range;
+  let text = formatPosition(start);
+  if (start.col !== undefined && end.col !== undefined) {
+    if (end.line !== start.line) text += `-${formatPosition(end)}`;
+    else if (end.col !== start.col) text += `-${end.col}`;
+  } else if (end.line !== start.line) {
+    text += `-${end.line}`;
+  }
+  return file === undefined ? text : `${file}:${text}`;
+}
+
 // ============================================
 // Annotation Parsing
 // ============================================
 
 // Note: These patterns should NOT have global flag to avoid lastIndex issues
-const ANNOTATION_REGEX = /(?:\/\/|#|\/\*\*?)\s*@collab(?::begin|:end)?\s+(.+?)(?:\*\/)?$/;
-const BLOCK_BEGIN_REGEX = /@collab:begin\s+(.+)/;
-const BLOCK_END_REGEX = /@collab:end/;
-const ATTR_PATTERN = /(\w+)=(?:"([^"]+)"|'([^']+)'|\[([^\]]+)\]|(\S+))/g;
+// `*` alone opens the body lines of a /* ... */ or Javadoc comment
+const ANNOTATION_REGEX = /(?:\/\/|#|\/\*\*?|^\s*\*)\s*@collab(?::begin|:end)?\s+(.+?)\s*(?:\*\/)?$/;
+const BLOCK_BEGIN_REGEX = /@collab:begin\s+(.+?)\s*(?:\*\/)?\s*$/;
+const BLOCK_END_REGEX = /@collab:end\b/; // Not "@collab:endpoint"
+const COMMENT_BLOCK_END_REGEX = /(?:\/\/|#|\/\*\*?|^\s*\*)\s*@collab:end\b/; // Not a mention in a string
+const BLOCK_END_ATTRS_REGEX = /@collab:end\s+(?!\*\/)(.+?)\s*(?:\*\/)?\s*$/; // Optional echo of the begin attributes
+const PACKAGE_DIRECTIVE_REGEX = /^\s*\/\/\s*@collab:package\s+(.+?)\s*$/;
+// A Go struct field whose raw-string tag has a collab key: Key []byte `collab:"trust=READ_ONLY"`
+const STRUCT_TAG_REGEX = /^\s*[\p{L}_][\p{L}\p{N}_]*(?:\s*,\s*[\p{L}_][\p{L}\p{N}_]*)*\s+[^`=:]*`([^`]*)`\s*(?:\/\/.*)?$/u;
+const COLLAB_TAG_REGEX = /(?:^|\s)collab:"((?:[^"\\]|\\.)*)"/;
+// key=value, or key: value for known attributes; "see: RFC-12" after any other word is prose.
+// A comma may separate pairs (`trust: "READ_ONLY", owner: "sec"`), so an unquoted value stops before a
+// comma followed by whitespace or the next key
+const ATTR_KEY_SOURCE = String.raw`([\p{L}\p{N}_]+(?==)|(?<![\p{L}\p{N}_])(?:${ANNOTATION_ATTRIBUTES.join("|")})(?=:))(?:=|:[ \t]*)`;
+const ATTR_PATTERN = new RegExp(ATTR_KEY_SOURCE + String.raw`(?:"([^"]+)"|'([^']+)'|\[([^\]]+)\]|((?:[^\s,]|,(?!\s|$|[\p{L}\p{N}_]+[=:]))+))`, "gu");
+// A comma standing alone between attributes once the pairs are taken out
+const ATTR_SEPARATOR = ",";
+
+// `trust=READ_ONLY,intent=Keys stay in memory` -> `trust=READ_ONLY intent='Keys stay in memory'`
+function structTagAttributes(tagValue: string): string {
+  const items: string[] = [];
+  let depth = 0;
+  let start = 0;
+  const value = tagValue.replace(/\\"/g, '"');
+  for (let i = 0; i <= value.length; i++) {
+    if (value[i] === "[") depth++;
+    else if (value[i] === "]") depth--;
+    else if ((value[i] === "," && depth === 0) || i === value.length) {
+      items.push(value.slice(start, i).trim());
+      start = i + 1;
+    }
+  }
+  return items
+    .filter(Boolean)
+    .map(item => {
+      const eq = item.indexOf("=");
+      const raw = eq === -1 ? "" : item.slice(eq + 1).trim();
+      if (eq === -1 || !/\s/.test(raw) || /^["'[]/.test(raw)) return item;
+      return `${item.slice(0, eq)}='${raw}'`;
+    })
+    .join(" ");
+}
+
+// Replace a whitespace-delimited occurrence of `find`, so "RO" never matches inside owner="ROB"
+function replaceToken(source: string, find: string, replace: string): string | undefined {
+  const escaped = find.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+  const pattern = new RegExp(`(^|\\s)${escaped}(?=\\s|\\*\\/|$)`);
+  return pattern.test(source) ? source.replace(pattern, `$1${replace}`) : undefined;
+}
+
+// Match a misspelled trust level ignoring case and separators: "read-only" -> READ_ONLY
+function normalizeTrustLevel(raw: string): TrustLevel | undefined {
+  const key = raw.toUpperCase().replace(/[^A-Z]/g, "");
+  return TRUST_LEVELS.find(level => level.replace(/_/g, "") === key);
+}
+
+// One annotation naming two trust levels; the last one written applies
+function conflictingTrust(first: TrustLevel, second: TrustLevel): AttributeError {
+  return {
+    code: "conflicting-trust",
+    message: `Annotation sets trust to both ${first} and ${second}; ${second} applies`,
+    params: { first, second },
+  };
+}
+
+// ["a", 'b', c] -> a, b, c
+function parseArrayValue(arrayValue: string): string[] {
+  return arrayValue
+    .split(",")
+    .map(s => s.trim().replace(/^["']|["']$/g, ""));
+}
+
+/**
+ * Split the text after `@collab` into the attributes and a trailing note.
+ * Attribute parsing stops at the first of:
+ *   - a `//`, `#`, or `/*` that starts a word outside any key=value pair:
+ *     `trust="READ_ONLY" // locked for audit`
+ *   - after the last key=value pair, two or more words that do not begin with
+ *     a trust alias or level: `trust="READ_ONLY" locked for audit`
+ *   - without key=value pairs, the first word after the leading trust levels
+ *     and aliases: `READ_ONLY locked for audit`, `ro locked`
+ * A single trailing word after a pair is still read as an alias, so typos are
+ * reported.
+ */
+function splitAnnotationNote(
+  attrString: string,
+  aliases: Record<string, TrustLevel>
+): { attributes: string; note?: string } {
+  const pairs = [...attrString.matchAll(ATTR_PATTERN)].map(m => ({
+    start: m.index!,
+    end: m.index! + m[0].length,
+  }));
+  const inPair = (index: number) => pairs.some(p => index > p.start && index < p.end);
+
+  let cut = attrString.length;
+  let noteStart = cut;
+  for (const m of attrString.matchAll(/(^|\s)(\/\/|#|\/\*)/g)) {
+    const delimiter = m.index! + m[1].length;
+    if (inPair(delimiter)) continue;
+    cut = delimiter;
+    noteStart = delimiter + m[2].length;
+    break;
+  }
+
+  // Un-keyed words after the last pair, past any leading aliases
+  const lastPair = pairs.filter(p => p.end <= cut).pop();
+  if (lastPair) {
+    const words = [...attrString.slice(lastPair.end, cut).matchAll(/\S+/g)].filter(w => w[0] !== ATTR_SEPARATOR);
+    const first = words.findIndex(w => !aliases[w[0]] && !TRUST_LEVELS.includes(w[0] as TrustLevel));
+    if (first !== -1 && words.length - first >= 2) {
+      cut = lastPair.end + words[first].index!;
+      noteStart = cut;
+    }
+  } else {
+    const words = [...attrString.slice(0, cut).matchAll(/\S+/g)];
+    const first = words.findIndex(w => !aliases[w[0]] && !TRUST_LEVELS.includes(w[0] as TrustLevel));
+    if (first > 0) {
+      cut = words[first].index!;
+      noteStart = cut;
+    }
+  }
 
-function parseAttributes(attrString: string): Partial<ParsedAnnotation> {
+  const note = attrString.slice(noteStart).trim();
+  return { attributes: attrString.slice(0, cut), note: note || undefined };
+}
+
+function parseAttributes(
+  annotationText: string,
+  aliases: Record<string, TrustLevel>
+): { attrs: Partial<ParsedAnnotation>; errors: AttributeError[] } {
   const result: Partial<ParsedAnnotation> = {};
+  const errors: AttributeError[] = [];
+  const { attributes: attrString, note } = splitAnnotationNote(annotationText, aliases);
   // Create a new regex instance each time to avoid lastIndex issues with global flag
-  const attrRegex = /(\w+)=(?:"([^"]+)"|'([^']+)'|\[([^\]]+)\]|(\S+))/g;
+  const attrRegex = new RegExp(ATTR_PATTERN.source, "gu");
   let match: RegExpExecArray | null;
 
   while ((match = attrRegex.exec(attrString)) !== null) {
@@ -145,28 +627,232 @@ function parseAttributes(attrString: string): Partial<ParsedAnnotation> {
     const arrayValue = match[4]; // array value
 
     switch (key) {
-      case "trust":
-        if (["AUTONOMOUS", "SUPERVISED", "SUGGEST_ONLY", "READ_ONLY"].includes(value)) {
+      case "trust": {
+        if (TRUST_LEVELS.includes(value as TrustLevel)) {
+          if (result.trust && result.trust !== value) errors.push(conflictingTrust(result.trust, value as TrustLevel));
           result.trust = value as TrustLevel;
+          break;
         }
+        const corrected = normalizeTrustLevel(value ?? "");
+        errors.push({
+          code: "unknown-trust-level",
+          message: `Unknown trust level "${value}" (expected ${TRUST_LEVELS.join(", ")})`,
+          params: { value, expected: TRUST_LEVELS.join(", ") },
+          fix: corrected ? { find: match[0], replace: `trust="${corrected}"` } : undefined,
+        });
         break;
+      }
       case "owner":
-        result.owner = value;
+        result.owner = arrayValue ? parseArrayValue(arrayValue) : value;
         break;
       case "intent":
         result.intent = value;
         break;
       case "constraints":
         if (arrayValue) {
-          result.constraints = arrayValue
-            .split(",")
-            .map(s => s.trim().replace(/^["']|["']$/g, ""));
+          result.constraints = parseArrayValue(arrayValue);
+        }
+        break;
+      case "lines":
+        result.lines = value;
+        break;
+      case "labels":
+        result.labels = (arrayValue ? parseArrayValue(arrayValue) : [value]).filter(Boolean);
+        break;
+      case "min_approvals":
+        if (/^\d+$/.test(value ?? "") && parseInt(value, 10) >= 1) {
+          result.min_approvals = parseInt(value, 10);
+        } else {
+          errors.push({
+            code: "invalid-min-approvals",
+            message: `Invalid min_approvals="${value}": expected a positive integer`,
+            params: { value },
+          });
+        }
+        break;
+      case "design_doc":
+        // Kept even when malformed, so proposals still ask for an acknowledgement
+        result.design_doc = value;
+        if (!DESIGN_DOC_URL_REGEX.test(value ?? "")) {
+          errors.push({
+            code: "invalid-design-doc",
+            message: `Invalid design_doc="${value}": expected an http(s) URL`,
+            params: { value },
+          });
+        }
+        break;
+      case "redact":
+        if (value === "true" || value === "false") {
+          result.redact = value === "true";
+        } else {
+          errors.push({
+            code: "invalid-redact",
+            message: `Invalid redact="${value}": expected "true" or "false"`,
+            params: { value },
+          });
+        }
+        break;
+      case "requires_tests":
+        if (value === "true" || value === "false") {
+          result.requires_tests = value === "true";
+        } else {
+          errors.push({
+            code: "invalid-requires-tests",
+            message: `Invalid requires_tests="${value}": expected "true" or "false"`,
+            params: { value },
+          });
+        }
+        break;
+      case "inherit":
+        if (value === "true" || value === "false") {
+          result.inherit = value === "true";
+        } else {
+          errors.push({
+            code: "invalid-inherit",
+            message: `Invalid inherit="${value}": expected "true" or "false"`,
+            params: { value },
+          });
+        }
+        break;
+      case "id":
+        result.id = value;
+        break;
+      case "when":
+        // Kept as written so the line still starts a conditional clause; an invalid one never applies
+        result.when = value;
+        if (!WHEN_PATH_REGEX.test(value ?? "")) {
+          errors.push({
+            code: "invalid-when",
+            message: `Invalid when="${value}": expected "path:<glob>"`,
+            params: { value },
+          });
         }
         break;
     }
   }
 
-  return result;
+  // Whatever is left outside key=value (or key: value) pairs must be a trust level,
+  // shorthand for trust="<level>", or a trust alias
+  const bareWords = attrString
+    .replace(ATTR_PATTERN, " ")
+    .split(/\s+/)
+    .filter(word => word && word !== ATTR_SEPARATOR);
+
+  // Several words and nothing else, not starting with a level or alias: not something to read word by word
+  const leading = bareWords[0];
+  if (
+    bareWords.length > 1 &&
+    !aliases[leading] &&
+    !TRUST_LEVELS.includes(leading as TrustLevel) &&
+    !new RegExp(ATTR_KEY_SOURCE, "u").test(attrString)
+  ) {
+    const text = attrString.trim();
+    errors.push({
+      code: "unparsed-annotation",
+      message: `@collab "${text}" has no attributes and does not start with a trust level or alias`,
+      params: { text },
+    });
+    if (note) result.note = note;
+    return { attrs: result, errors };
+  }
+
+  // When they disagree, an explicit trust= wins over a level, and a level over an alias;
+  // among equals the last one applies. Either way the disagreement is an error.
+  let trustRank = result.trust ? 0 : 3;
+  const setTrust = (level: TrustLevel, rank: number) => {
+    const applies = rank <= trustRank;
+    if (result.trust && result.trust !== level) {
+      errors.push(applies ? conflictingTrust(result.trust, level) : conflictingTrust(level, result.trust));
+    }
+    if (applies) {
+      result.trust = level;
+      trustRank = rank;
+    }
+  };
+
+  for (const word of bareWords) {
+    if (TRUST_LEVELS.includes(word as TrustLevel)) {
+      setTrust(word as TrustLevel, 1);
+      continue;
+    }
+    const level = aliases[word];
+    if (level) {
+      setTrust(level, 2);
+      continue;
+    }
+
+    // A bare trust level name, or an alias with the wrong case
+    const corrected = normalizeTrustLevel(word);
+    const lowerAlias = aliases[word.toLowerCase()] ? word.toLowerCase() : undefined;
+    errors.push({
+      code: "unknown-alias",
+      message: `Unknown @collab alias "${word}" (known: ${Object.keys(aliases).join(", ")})`,
+      params: { word, known: Object.keys(aliases).join(", ") },
+      fix: corrected
+        ? { find: word, replace: `trust="${corrected}"` }
+        : lowerAlias
+          ? { find: word, replace: lowerAlias }
+          : undefined,
+    });
+  }
+
+  if (note) result.note = note;
+  return { attrs: result, errors };
+}
+
+const WHEN_PATH_REGEX = /^path:(.+)$/;
+const DESIGN_DOC_URL_REGEX = /^https?:\/\/[^\s/]+\S*$/;
+
+/**
+ * Whether an annotation's `when` condition holds for the file it is in.
+ * `path:<glob>` matches the file's path relative to the project root, as
+ * trust.yaml policies do. Annotations without a condition always apply.
+ */
+export function annotationApplies(annotation: Pick<ParsedAnnotation, "when">, filePath: string): boolean {
+  if (annotation.when === undefined) return true;
+  const match = WHEN_PATH_REGEX.exec(annotation.when);
+  if (!match) return false;
+  const relative = path.isAbsolute(filePath) ? path.relative(process.cwd(), filePath) : filePath;
+  return matchesPattern(relative.replace(/\\/g, "/").replace(/^\.\//, ""), match[1]);
+}
+
+// A function signature that opens its body on this line: `func (s *S) Run() error {`,
+// `): Promise<void> {`, `def run(self):`. Control statements such as `if (x) {` are not.
+const FUNCTION_OPENING_REGEX = /\)[^()]*\{\s*$|^\s*(?:async\s+)?def\s/;
+const CONTROL_STATEMENT_REGEX = /^\s*(?:\}\s*)?(?:if|else|for|foreach|while|switch|catch|match|do|try|with|unless|until)\b/;
+
+// The line of the function whose body `annotationIndex` opens, if that is where it sits
+function functionOpenedAbove(lines: string[], annotationIndex: number): number | undefined {
+  let k = annotationIndex - 1;
+  while (k >= 0 && (lines[k].trim() === "" || LEADING_COMMENT_REGEX.test(lines[k]))) k--;
+  if (k < 0 || !FUNCTION_OPENING_REGEX.test(lines[k]) || CONTROL_STATEMENT_REGEX.test(lines[k])) return undefined;
+  return k + 1;
+}
+
+// Resolve a lines="N-M" attribute into absolute line numbers.
+// Offsets are 1-indexed from the first line of the annotated scope.
+function resolveRelativeLines(
+  spec: string,
+  scope: { start: number; end: number }
+): { start: number; end: number } | { error: string; code: ReasonCodeId; params: Record<string, string> } {
+  const match = /^(\d+)(?:\s*-\s*(\d+))?$/.exec(spec.trim());
+  if (!match) {
+    return { error: `Invalid lines="${spec}": expected "N" or "N-M"`, code: "invalid-lines", params: { value: spec } };
+  }
+
+  const from = parseInt(match[1], 10);
+  const to = match[2] !== undefined ? parseInt(match[2], 10) : from;
+  const scopeLength = scope.end - scope.start + 1;
+
+  if (from < 1 || to < from || to > scopeLength) {
+    return {
+      error: `lines="${spec}" is out of range: annotated scope has ${scopeLength} line(s)`,
+      code: "lines-out-of-range",
+      params: { value: spec, scope_lines: String(scopeLength) },
+    };
+  }
+
+  return { start: scope.start + from - 1, end: scope.start + to - 1 };
 }
 
 function getFileExtension(filePath: string): string {
@@ -174,18 +860,106 @@ function getFileExtension(filePath: string): string {
   return ext.startsWith(".") ? ext.slice(1) : ext;
 }
 
+// Leading whitespace as Python counts it. trimStart() would also strip Unicode
+// spaces such as U+00A0 and U+3000, which Python treats as part of the line.
+function indentWidth(line: string): number {
+  return /^[ \t\f]*/.exec(line)![0].length;
+}
+
+// Go assembly: TEXT starts a function, and DATA and GLOBL declare symbols outside any
+const ASM_SYMBOL_REGEX = /^\s*(?:TEXT|DATA|GLOBL)\s/;
+const ASM_LABEL_REGEX = /^\s*[\p{L}_][\p{L}\p{N}_]*:/u;
+const CGO_IMPORT_REGEX = /^\s*import\s+"C"\s*(?:\/\/.*)?$/;
+
+/**
+ * Go assembly scope from the 0-indexed line an annotation precedes: a TEXT
+ * function runs to the next TEXT, DATA, or GLOBL; a label to the next label
+ * or function. Trailing blank and comment lines are left to whatever follows.
+ * Anything else is just that line.
+ */
+function asmScope(lines: string[], defLineIndex: number): { start: number; end: number } {
+  const isFunction = /^\s*TEXT\s/.test(lines[defLineIndex]);
+  if (!isFunction && !ASM_LABEL_REGEX.test(lines[defLineIndex])) {
+    return { start: defLineIndex + 1, end: defLineIndex + 1 };
+  }
+
+  let next = defLineIndex + 1;
+  while (next < lines.length && !ASM_SYMBOL_REGEX.test(lines[next]) && (isFunction || !ASM_LABEL_REGEX.test(lines[next]))) next++;
+  let last = next - 1;
+  while (last > defLineIndex && (lines[last].trim() === "" || /^\s*(?:\/\/|\/\*|\*)/.test(lines[last]))) last--;
+  return { start: defLineIndex + 1, end: last + 1 };
+}
+
+/**
+ * The cgo preamble around a 0-indexed line of a Go file: the comment directly
+ * above `import "C"`, as the 0-indexed lines holding its C code. `strip` is
+ * set for a preamble written as // lines, whose C code follows the marker.
+ */
+function cgoPreamble(lines: string[], index: number): { first: number; last: number; strip: boolean } | undefined {
+  if (/^\s*\/\//.test(lines[index])) {
+    let first = index;
+    let last = index;
+    while (first > 0 && /^\s*\/\//.test(lines[first - 1])) first--;
+    while (last + 1 < lines.length && /^\s*\/\//.test(lines[last + 1])) last++;
+    if (last + 1 < lines.length && CGO_IMPORT_REGEX.test(lines[last + 1])) return { first, last, strip: true };
+  }
+
+  // Inside /* ... */: the opening line is above, and the closing one is right above the import
+  let open = index;
+  while (open >= 0 && !lines[open].includes("/*")) {
+    if (open < index && lines[open].includes("*/")) return undefined;
+    open--;
+  }
+  let close = index;
+  while (close < lines.length && !lines[close].includes("*/")) close++;
+  if (open < 0 || close + 1 >= lines.length || open === close || !CGO_IMPORT_REGEX.test(lines[close + 1])) return undefined;
+  return { first: open + 1, last: close - 1, strip: false };
+}
+
+// C scope inside a cgo preamble by brace counting, never past the preamble
+function cgoScope(
+  lines: string[],
+  annotationLineIndex: number,
+  preamble: { first: number; last: number; strip: boolean }
+): { start: number; end: number } {
+  const code = (i: number) => (preamble.strip ? lines[i].replace(/^\s*\/\//, "") : lines[i]).trim();
+  let def = annotationLineIndex + 1;
+  while (def <= preamble.last && (code(def) === "" || /^(?:\/\/|\/\*|\*)/.test(code(def)))) def++;
+  if (def > preamble.last) return { start: annotationLineIndex + 1, end: annotationLineIndex + 1 };
+
+  let depth = 0;
+  let opened = false;
+  for (let i = def; i <= preamble.last; i++) {
+    for (const char of code(i)) {
+      if (char === "{") {
+        depth++;
+        opened = true;
+      } else if (char === "}") {
+        depth--;
+      }
+    }
+    if (opened ? depth <= 0 : code(i).endsWith(";")) return { start: def + 1, end: i + 1 };
+  }
+  return { start: def + 1, end: opened ? preamble.last + 1 : def + 1 };
+}
+
 function detectAnnotationScope(
   lines: string[],
   annotationLineIndex: number,
   fileExt: string
-): { start: number; end: number } {
+): { start: number; end: number; diagnostic?: string } {
   const startLine = annotationLineIndex + 1; // 1-indexed
 
+  // Go: C code in a cgo preamble is scoped as C, not by the Go tokenizer, which sees a comment
+  const preamble = fileExt === "go" ? cgoPreamble(lines, annotationLineIndex) : undefined;
+  if (preamble) return cgoScope(lines, annotationLineIndex, preamble);
+
   // Find the first non-comment, non-empty line after annotation
   let defLineIndex = annotationLineIndex + 1;
   while (defLineIndex < lines.length) {
     const line = lines[defLineIndex].trim();
-    if (line && !line.startsWith("//") && !line.startsWith("#") && !line.startsWith("/*") && !line.startsWith("*")) {
+    // A block comment's "* text" lines are comments; "*Base" (a Go embedded pointer) is code
+    if (line && !line.startsWith("//") && !line.startsWith("#") && !line.startsWith("/*") && !/^\*(?:\s|\/|$)/.test(line)) {
       break;
     }
     defLineIndex++;
@@ -195,17 +969,18 @@ function detectAnnotationScope(
     return { start: startLine, end: startLine };
   }
 
+  if (fileExt === "s") return asmScope(lines, defLineIndex);
+
   // Python: indentation-based
   if (fileExt === "py") {
-    const defLine = lines[defLineIndex];
-    const baseIndent = defLine.length - defLine.trimStart().length;
+    const baseIndent = indentWidth(lines[defLineIndex]);
     let endLineIndex = defLineIndex;
 
     for (let i = defLineIndex + 1; i < lines.length; i++) {
       const line = lines[i];
       if (line.trim() === "") continue;
 
-      const currentIndent = line.length - line.trimStart().length;
+      const currentIndent = indentWidth(line);
       if (currentIndent <= baseIndent && line.trim() !== "") {
         break;
       }
@@ -215,6 +990,23 @@ function detectAnnotationScope(
     return { start: defLineIndex + 1, end: endLineIndex + 1 };
   }
 
+  // Go: an annotation on the package clause (e.g. above a //go:build line) governs the file
+  if (fileExt === "go" && /^package\s/.test(lines[defLineIndex].trim())) {
+    let lastLineIndex = lines.length - 1;
+    while (lastLineIndex > defLineIndex && lines[lastLineIndex].trim() === "") lastLineIndex--;
+    return { start: defLineIndex + 1, end: lastLineIndex + 1 };
+  }
+
+  // Go: end of the declaration per the Go tokenizer, brace counting if it cannot tokenize
+  let diagnostic: string | undefined;
+  if (fileExt === "go") {
+    const span = goDeclarationSpan(lines, defLineIndex);
+    if ("end" in span) {
+      return { start: defLineIndex + 1, end: span.end + 1 };
+    }
+    diagnostic = `Go source could not be tokenized (${span.error}); scope detected by brace counting`;
+  }
+
   // Brace-based languages: Go, Rust, Java, TypeScript, JavaScript
   if (["go", "rs", "java", "ts", "tsx", "js", "jsx"].includes(fileExt)) {
     let braceCount = 0;
@@ -239,192 +1031,1385 @@ function detectAnnotationScope(
       }
     }
 
-    return { start: defLineIndex + 1, end: endLineIndex + 1 };
+    return { start: defLineIndex + 1, end: endLineIndex + 1, diagnostic };
+  }
+
+  // Fallback: just the next line
+  return { start: defLineIndex + 1, end: defLineIndex + 1 };
+}
+
+// Lines (1-indexed) an annotation directly above the 0-indexed declaration line would govern
+export function detectDeclarationScope(
+  lines: string[],
+  defLineIndex: number,
+  filePath: string
+): { start: number; end: number } {
+  const { start, end } = detectAnnotationScope(lines, defLineIndex - 1, getFileExtension(filePath));
+  return { start, end };
+}
+
+// Why `content` does not tokenize as its language, or undefined. Only Go is
+// checked; annotations in such a file are still found line by line, with
+// scopes estimated by brace counting.
+export function sourceSyntaxError(content: string, filePath: string): string | undefined {
+  if (getFileExtension(filePath) !== "go") return undefined;
+  return goSyntaxError(content.split(/\r\n|\n|\r/));
+}
+
+export function buildTrustAliases(custom: Record<string, string> = {}): Record<string, TrustLevel> {
+  const aliases: Record<string, TrustLevel> = { ...DEFAULT_TRUST_ALIASES };
+
+  for (const [name, level] of Object.entries(custom)) {
+    if (!ALIAS_NAME_REGEX.test(name) || ANNOTATION_ATTRIBUTES.includes(name)) {
+      throw new Error(`Invalid alias name "${name}": must be a bare word and not an attribute name`);
+    }
+    // A bare trust level always means itself, so such an alias could never apply
+    if (TRUST_LEVELS.includes(name as TrustLevel)) {
+      throw new Error(`Invalid alias name "${name}": trust level names cannot be aliases`);
+    }
+    if (!TRUST_LEVELS.includes(level as TrustLevel)) {
+      throw new Error(`Alias "${name}" maps to unknown trust level "${level}"`);
+    }
+    aliases[name] = level as TrustLevel;
+  }
+
+  return aliases;
+}
+
+export async function loadTrustAliases(): Promise<Record<string, TrustLevel>> {
+  const config = await loadCollabConfig();
+  return buildTrustAliases(config.aliases);
+}
+
+// A block's id= as written on its begin or end line, read without parsing the other attributes
+function blockId(attrString: string, aliases: Record<string, TrustLevel>): string | undefined {
+  for (const m of splitAnnotationNote(attrString, aliases).attributes.matchAll(new RegExp(ATTR_PATTERN.source, "gu"))) {
+    if (m[1] === "id") return m[2] || m[3] || m[5];
+  }
+  return undefined;
+}
+
+/**
+ * Pair every @collab:begin with the @collab:end that closes it, as 0-indexed
+ * lines. An end naming an id closes the innermost open block with that id,
+ * however the blocks around it nest; any other end closes the innermost open
+ * block. `claimed` holds every end matched or already reported here.
+ */
+function matchBlocks(
+  lines: string[],
+  aliases: Record<string, TrustLevel>
+): { ends: Map<number, number>; claimed: Set<number>; errors: (AttributeError & { line: number })[] } {
+  const ends = new Map<number, number>();
+  const claimed = new Set<number>();
+  const errors: (AttributeError & { line: number })[] = [];
+  const open: { line: number; id?: string }[] = [];
+
+  for (let j = 0; j < lines.length; j++) {
+    const begin = BLOCK_BEGIN_REGEX.exec(lines[j]);
+    if (begin) {
+      const id = blockId(begin[1], aliases);
+      const reused = id !== undefined ? open.find(b => b.id === id) : undefined;
+      if (reused) {
+        errors.push({
+          line: j + 1,
+          code: "duplicate-block-id",
+          message: `Block id "${id}" is already used by the @collab:begin on line ${reused.line + 1}, which is still open`,
+          params: { id: id!, begin_line: String(reused.line + 1) },
+        });
+      }
+      open.push({ line: j, id });
+    } else if (BLOCK_END_REGEX.test(lines[j])) {
+      const echo = BLOCK_END_ATTRS_REGEX.exec(lines[j]);
+      const id = echo ? blockId(echo[1], aliases) : undefined;
+      let index = id === undefined ? open.length - 1 : -1;
+      for (let k = open.length - 1; id !== undefined && k >= 0; k--) {
+        if (open[k].id === id) {
+          index = k;
+          break;
+        }
+      }
+      if (index === -1) {
+        if (id !== undefined) {
+          claimed.add(j);
+          errors.push({
+            line: j + 1,
+            code: "block-not-open",
+            message: `@collab:end id="${id}" does not close an open @collab:begin`,
+            params: { id },
+          });
+        }
+        continue;
+      }
+      ends.set(open[index].line, j);
+      claimed.add(j);
+      open.splice(index, 1);
+    }
+  }
+  return { ends, claimed, errors };
+}
+
+export function parseAnnotationContent(
+  content: string,
+  filePath: string,
+  options: ParseOptions = {}
+): AnnotationParseResult {
+  const annotations: ParsedAnnotation[] = [];
+  const errors: AnnotationError[] = [];
+  const warnings: AnnotationError[] = [];
+  const aliases = options.aliases ?? DEFAULT_TRUST_ALIASES;
+
+  // Constraints and approvals only mean something if edits are reviewed
+  const lint = (attrs: Partial<ParsedAnnotation>, line: number) => {
+    if (attrs.trust !== "AUTONOMOUS") return;
+    const extras = [
+      attrs.constraints?.length ? "constraints" : null,
+      attrs.min_approvals !== undefined ? "min_approvals" : null,
+    ].filter(Boolean);
+    if (extras.length > 0) {
+      warnings.push({
+        file: filePath,
+        line,
+        code: "autonomous-with-constraints",
+        message: `AUTONOMOUS region also sets ${extras.join(" and ")}, which free edits never enforce; did you mean SUGGEST_ONLY?`,
+        params: { attributes: extras.join(" and ") },
+      });
+    }
+  };
+
+  const parse = (attrString: string, lineIndex: number): Partial<ParsedAnnotation> => {
+    const parsed = parseAttributes(attrString, aliases);
+    for (const { code, message, params, fix } of parsed.errors) {
+      const source = lines[lineIndex];
+      errors.push({
+        file: filePath,
+        line: lineIndex + 1,
+        code,
+        message,
+        params,
+        suggestion: fix ? replaceToken(source, fix.find, fix.replace) : undefined,
+      });
+    }
+    return parsed.attrs;
+  };
+
+  // Normalize line endings - handle both CRLF and LF
+  const fileExt = getFileExtension(filePath);
+  const lines = content.replace(/\r\n/g, "\n").replace(/\r/g, "\n").split("\n")
+    // Assembly listings also start comments with ;, read here as //
+    .map(line => (fileExt === "s" ? line.replace(/^(\s*);/, "$1//") : line));
+
+  // Every @collab:begin paired with its @collab:end up front
+  const blocks = matchBlocks(lines, aliases);
+  for (const { line, ...error } of blocks.errors) errors.push({ file: filePath, line, ...error });
+
+  let i = 0;
+  while (i < lines.length) {
+    const line = lines[i];
+
+    // Package directives govern other files; here they are only validated
+    const packageMatch = PACKAGE_DIRECTIVE_REGEX.exec(line);
+    if (packageMatch) {
+      if (isPackageDocFile(filePath)) {
+        parse(packageMatch[1], i);
+      } else {
+        errors.push({
+          file: filePath,
+          line: i + 1,
+          code: "misplaced-package-directive",
+          message: `@collab:package is only read from ${PACKAGE_DOC_FILE}; it has no effect in ${path.basename(filePath)}`,
+          params: { file: path.basename(filePath) },
+        });
+      }
+      i++;
+      continue;
+    }
+
+    // Check for block begin
+    const blockBeginMatch = BLOCK_BEGIN_REGEX.exec(line);
+    if (blockBeginMatch) {
+      const attrs = parse(blockBeginMatch[1], i);
+      const blockStart = i + 1; // 1-indexed
+
+      if (attrs.lines !== undefined) {
+        errors.push({
+          file: filePath,
+          line: blockStart,
+          code: "lines-on-block",
+          message: "lines= is only supported on function annotations, not @collab:begin blocks",
+        });
+        delete attrs.lines;
+      }
+
+      // Its end, as matchBlocks paired them
+      let blockEnd = blockStart;
+      const j = blocks.ends.get(i);
+      if (j !== undefined) {
+        blockEnd = j; // Line before @collab:end
+
+        // The end line takes no attributes, or only ones echoing the begin line; either
+        // way it closes the block, and only the begin line governs it
+        const echo = BLOCK_END_ATTRS_REGEX.exec(lines[j]);
+        if (echo) {
+          const { note: _endNote, ...echoed } = parse(echo[1], j);
+          const invalidEnd = (detail: string) => errors.push({
+            file: filePath,
+            line: j + 1,
+            code: "invalid-block-end",
+            message: `@collab:end ${detail}; it takes no attributes, or only ones matching @collab:begin on line ${blockStart}`,
+            params: { detail, begin_line: String(blockStart) },
+          });
+          const unknown = [...splitAnnotationNote(echo[1], aliases).attributes.matchAll(new RegExp(ATTR_KEY_SOURCE, "gu"))]
+            .map(m => m[1])
+            .filter(key => !ANNOTATION_ATTRIBUTES.includes(key));
+          for (const key of unknown) invalidEnd(`has unknown attribute "${key}"`);
+          for (const key of Object.keys(echoed) as (keyof ParsedAnnotation)[]) {
+            if (attrs[key] === undefined) {
+              invalidEnd(`sets ${key}=${JSON.stringify(echoed[key])}`);
+            } else if (JSON.stringify(echoed[key]) !== JSON.stringify(attrs[key])) {
+              errors.push({
+                file: filePath,
+                line: j + 1,
+                code: "block-end-mismatch",
+                message: `@collab:end ${key}=${JSON.stringify(echoed[key])} does not match ` +
+                  `@collab:begin ${key}=${JSON.stringify(attrs[key])} on line ${blockStart}`,
+                params: {
+                  key,
+                  end_value: JSON.stringify(echoed[key]),
+                  begin_line: String(blockStart),
+                  begin_value: JSON.stringify(attrs[key]),
+                },
+              });
+            }
+          }
+        }
+      } else {
+        errors.push({
+          file: filePath,
+          line: blockStart,
+          code: "unclosed-block",
+          message: "@collab:begin has no matching @collab:end",
+        });
+      }
+
+      lint(attrs, blockStart);
+      if (!annotationApplies(attrs, filePath)) {
+        i++;
+        continue;
+      }
+      annotations.push({
+        ...attrs,
+        line_start: blockStart + 1, // First line after @collab:begin
+        line_end: blockEnd,
+        comment_start: blockStart,
+        comment_end: blockStart,
+      });
+      i++;
+      continue;
+    }
+
+    // Check for single-line annotation
+    const match = ANNOTATION_REGEX.exec(line);
+    if (match && !BLOCK_END_REGEX.test(line)) {
+      const attrs = parse(match[1], i);
+
+      // Written below the signature instead of above it
+      const openingLine = functionOpenedAbove(lines, i);
+      if (openingLine !== undefined) {
+        warnings.push({
+          file: filePath,
+          line: i + 1,
+          code: "annotation-after-declaration",
+          message:
+            `@collab on the first line of a function body governs the code below it, not the function ` +
+            `opened on line ${openingLine}; move it above the declaration`,
+          params: { opening_line: String(openingLine) },
+        });
+      }
+
+      // Collect consecutive @collab lines (multi-line annotation). A line with
+      // when= starts a conditional clause that the lines after it add to; lines
+      // before the first one are the unconditional base.
+      const clauses: Partial<ParsedAnnotation>[] = attrs.when === undefined ? [{ ...attrs }] : [{}, { ...attrs }];
+      let lastAnnotationLine = i;
+
+      for (let j = i + 1; j < lines.length; j++) {
+        const nextMatch = ANNOTATION_REGEX.exec(lines[j]);
+        if (
+          nextMatch &&
+          !BLOCK_BEGIN_REGEX.test(lines[j]) &&
+          !BLOCK_END_REGEX.test(lines[j])
+        ) {
+          const nextAttrs = parse(nextMatch[1], j);
+          lastAnnotationLine = j;
+          if (nextAttrs.when !== undefined) {
+            clauses.push({ ...nextAttrs });
+            continue;
+          }
+          const current = clauses[clauses.length - 1];
+          if (current.trust && nextAttrs.trust && current.trust !== nextAttrs.trust) {
+            errors.push({ file: filePath, line: j + 1, ...conflictingTrust(current.trust, nextAttrs.trust) });
+          }
+          const notes = [current.note, nextAttrs.note].filter(Boolean);
+          Object.assign(current, nextAttrs, notes.length > 0 ? { note: notes.join("; ") } : {});
+        } else {
+          break;
+        }
+      }
+
+      // The first clause whose condition holds adds to the base; with none, the base alone applies
+      const [base, ...conditional] = clauses;
+      const applying = conditional.find(clause => annotationApplies(clause, filePath));
+      const collectedAttrs: Partial<ParsedAnnotation> = applying ? { ...base, ...applying } : base;
+      if (!applying && conditional.length > 0 && Object.keys(base).length === 0) {
+        i = lastAnnotationLine + 1;
+        continue;
+      }
+
+      // Detect scope of the annotated code
+      let scope = detectAnnotationScope(lines, lastAnnotationLine, fileExt);
+      if (scope.diagnostic) {
+        errors.push({
+          file: filePath,
+          line: i + 1,
+          code: "scope-fallback",
+          message: scope.diagnostic,
+          params: { detail: scope.diagnostic },
+        });
+      }
+
+      // Narrow to a relative line range within the scope if requested.
+      // On error the whole scope stays governed rather than none of it.
+      if (collectedAttrs.lines !== undefined) {
+        const narrowed = resolveRelativeLines(collectedAttrs.lines, scope);
+        if ("error" in narrowed) {
+          errors.push({ file: filePath, line: i + 1, code: narrowed.code, message: narrowed.error, params: narrowed.params });
+        } else {
+          scope = narrowed;
+        }
+      }
+
+      lint(collectedAttrs, i + 1);
+      log.debug("annotation scope", {
+        file: filePath,
+        line: i + 1,
+        line_start: scope.start,
+        line_end: scope.end,
+        relative: collectedAttrs.lines,
+      });
+      annotations.push({
+        ...collectedAttrs,
+        line_start: scope.start,
+        line_end: scope.end,
+        comment_start: i + 1,
+        comment_end: lastAnnotationLine + 1,
+      });
+
+      i = lastAnnotationLine + 1;
+      continue;
+    }
+
+    // A collab struct tag governs its field, exactly as a @collab comment above it would
+    const structTag = fileExt === "go" ? STRUCT_TAG_REGEX.exec(line) : null;
+    const collabTag = structTag ? COLLAB_TAG_REGEX.exec(structTag[1]) : null;
+    if (collabTag) {
+      const reported = errors.length;
+      const { lines: _lines, ...attrs } = parse(structTagAttributes(collabTag[1]), i);
+      // Fixes are written for comments; quoting one into a tag would break the Go source
+      for (const error of errors.slice(reported)) delete error.suggestion;
+      lint(attrs, i + 1);
+      if (annotationApplies(attrs, filePath)) {
+        annotations.push({ ...attrs, line_start: i + 1, line_end: i + 1, comment_start: i + 1, comment_end: i + 1 });
+      }
+      i++;
+      continue;
+    }
+
+    // Every block claims its end line up front, so one unclaimed here closes nothing
+    if (COMMENT_BLOCK_END_REGEX.test(line) && !blocks.claimed.has(i)) {
+      errors.push({
+        file: filePath,
+        line: i + 1,
+        code: "unmatched-block-end",
+        message: "@collab:end does not close any @collab:begin",
+      });
+    }
+
+    i++;
+  }
+
+  log.debug("parsed annotations", {
+    file: filePath,
+    annotations: annotations.length,
+    errors: errors.length,
+    warnings: warnings.length,
+  });
+  return { annotations, errors, warnings };
+}
+
+export async function parseAnnotationsWithErrors(filePath: string): Promise<AnnotationParseResult> {
+  // Invalid alias configuration is surfaced to the caller
+  const aliases = await loadTrustAliases();
+
+  try {
+    const content = await fs.readFile(filePath, "utf-8");
+    return parseAnnotationContent(content, filePath, { aliases });
+  } catch {
+    // File doesn't exist or can't be read
+    return { annotations: [], errors: [], warnings: [] };
+  }
+}
+
+export async function parseAnnotations(filePath: string): Promise<ParsedAnnotation[]> {
+  const result = await parseAnnotationsWithErrors(filePath);
+  return result.annotations;
+}
+
+// UTF-8 byte offset at which each line (0-indexed) starts, plus the content's length
+function lineByteOffsets(content: string): number[] {
+  const offsets = [0];
+  for (const line of content.split("\n")) {
+    offsets.push(offsets[offsets.length - 1] + Buffer.byteLength(line, "utf-8") + 1);
+  }
+  offsets[offsets.length - 1] = Buffer.byteLength(content, "utf-8");
+  return offsets;
+}
+
+/**
+ * Annotations whose governed lines intersect the UTF-8 byte range [start, end)
+ * of `content`, for editors asking what governs a selection. A partly covered
+ * line counts, and an empty range (a cursor) matches the lines it sits on.
+ * Most specific first: the fewest governed lines, then the latest start.
+ */
+export function regionsInByteRange(
+  content: string,
+  filePath: string,
+  start: number,
+  end: number,
+  options: ParseOptions = {}
+): ParsedAnnotation[] {
+  const offsets = lineByteOffsets(content);
+  const { annotations } = parseAnnotationContent(content, filePath, options);
+
+  return annotations
+    .filter(annotation => {
+      const spanStart = offsets[annotation.line_start - 1] ?? offsets[offsets.length - 1];
+      const spanEnd = offsets[Math.min(annotation.line_end, offsets.length - 1)];
+      return start === end ? spanStart <= start && start < spanEnd : start < spanEnd && end > spanStart;
+    })
+    .sort((a, b) =>
+      a.line_end - a.line_start - (b.line_end - b.line_start) || b.line_start - a.line_start
+    );
+}
+
+// ============================================
+// Package Defaults
+// ============================================
+
+export const PACKAGE_DOC_FILE = "doc.go";
+
+function isPackageDocFile(filePath: string): boolean {
+  return path.basename(filePath) === PACKAGE_DOC_FILE;
+}
+
+// The doc.go that may hold a Go file's package directive; undefined for other languages
+export function packageDocPath(filePath: string): string | undefined {
+  return filePath.endsWith(".go") ? path.join(path.dirname(filePath), PACKAGE_DOC_FILE) : undefined;
+}
+
+// The first @collab:package directive in a doc.go's content; errors are reported by parseAnnotationContent
+export function parsePackageDirective(
+  content: string,
+  docPath: string,
+  options: ParseOptions = {}
+): PackageDefault | undefined {
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  for (let i = 0; i < lines.length; i++) {
+    const match = PACKAGE_DIRECTIVE_REGEX.exec(lines[i]);
+    if (!match) continue;
+    const { attrs } = parseAttributes(match[1], options.aliases ?? DEFAULT_TRUST_ALIASES);
+    return { file: docPath, line: i + 1, trust: attrs.trust, owner: attrs.owner, intent: attrs.intent };
+  }
+  return undefined;
+}
+
+// The package default governing a Go file, read from its directory's doc.go
+export async function loadPackageDefault(
+  filePath: string,
+  options: ParseOptions = {}
+): Promise<PackageDefault | undefined> {
+  const docPath = packageDocPath(filePath);
+  if (!docPath) return undefined;
+  let content: string;
+  try {
+    content = await fs.readFile(docPath, "utf-8");
+  } catch {
+    return undefined;
+  }
+  return parsePackageDirective(content, docPath, { aliases: options.aliases ?? (await loadTrustAliases()) });
+}
+
+// ============================================
+// Owner Registry
+// ============================================
+
+const OWNER_CONTACT_FIELDS: (keyof OwnerContact)[] = ["name", "slack", "email", "escalation", "members"];
+
+// Thrown when an owner key is not in the registry
+export class UnknownOwnerError extends Error {
+  readonly key: string;
+
+  constructor(key: string, registry: Record<string, OwnerContact>) {
+    const known = Object.keys(registry).sort(comparePaths);
+    super(`Unknown owner "${key}"` + (known.length > 0 ? ` (known owners: ${known.join(", ")})` : ""));
+    this.name = new.target.name;
+    this.key = key;
+  }
+}
+
+// Validate config.yaml `owners`; throws on the first bad entry
+export function buildOwnerRegistry(owners: unknown): Record<string, OwnerContact> {
+  if (owners === undefined || owners === null) return {};
+  if (typeof owners !== "object" || Array.isArray(owners)) {
+    throw new Error("owners must map owner keys to contact records");
+  }
+  const registry: Record<string, OwnerContact> = {};
+  for (const [key, record] of Object.entries(owners as Record<string, unknown>)) {
+    const contact = record ?? {};
+    if (typeof contact !== "object" || Array.isArray(contact)) {
+      throw new Error(`Owner "${key}" must be a record of ${OWNER_CONTACT_FIELDS.join(", ")}`);
+    }
+    for (const [field, value] of Object.entries(contact)) {
+      if (!OWNER_CONTACT_FIELDS.includes(field as keyof OwnerContact)) {
+        throw new Error(`Owner "${key}" has unknown field "${field}" (expected ${OWNER_CONTACT_FIELDS.join(", ")})`);
+      }
+      if (field === "members") {
+        if (!Array.isArray(value) || value.some(m => typeof m !== "string")) {
+          throw new Error(`Owner "${key}" members must be a list of strings`);
+        }
+      } else if (typeof value !== "string") {
+        throw new Error(`Owner "${key}" ${field} must be a string`);
+      }
+    }
+    registry[key] = contact as OwnerContact;
+  }
+  return registry;
+}
+
+// The owner registry from config.yaml, or undefined when none is configured and owners are free-form
+export async function loadOwnerRegistry(): Promise<Record<string, OwnerContact> | undefined> {
+  const config = await loadCollabConfig();
+  return config.owners === undefined ? undefined : buildOwnerRegistry(config.owners);
+}
+
+/**
+ * The record for an owner key, as an annotation or policy names it. Throws
+ * UnknownOwnerError if the registry does not define the key.
+ */
+export function resolveOwner(key: string, registry: Record<string, OwnerContact>): Owner {
+  if (!Object.hasOwn(registry, key)) throw new UnknownOwnerError(key, registry);
+  return { key, ...registry[key] };
+}
+
+// Every owner of a region resolved; throws UnknownOwnerError for the first unknown key
+export function resolveOwners(owner: string | string[] | undefined, registry: Record<string, OwnerContact>): Owner[] {
+  return ownerList(owner).map(key => resolveOwner(key, registry));
+}
+
+/**
+ * Everyone who counts as an owner of a region: each co-owner, and the members
+ * of each group, expanded through nested groups. Keys the registry lacks stand
+ * for themselves; a group listing itself, directly or not, is expanded once.
+ */
+export function expandOwners(owner: string | string[] | undefined, registry: Record<string, OwnerContact> = {}): string[] {
+  const expanded: string[] = [];
+  const visit = (key: string) => {
+    if (expanded.includes(key)) return;
+    expanded.push(key);
+    if (Object.hasOwn(registry, key)) for (const member of registry[key].members ?? []) visit(member);
+  };
+  for (const key of ownerList(owner)) visit(key);
+  return expanded;
+}
+
+// ============================================
+// Annotation Formatting
+// ============================================
+
+export interface FormatOptions {
+  filePath?: string; // Picks the comment marker; defaults to "//"
+  marker?: "//" | "#"; // Overrides the marker filePath would pick
+  indent?: string;
+  maxWidth?: number; // Wider single-line annotations are split (default: 100)
+}
+
+const HASH_COMMENT_EXTENSIONS = ["py", "rb", "sh", "bash", "yaml", "yml"];
+
+function quoteAttributeValue(key: string, value: string): string {
+  if (!value.includes('"')) return `"${value}"`;
+  if (!value.includes("'")) return `'${value}'`;
+  throw new Error(`Cannot format ${key}: value contains both quote characters`);
+}
+
+function formatArrayValue(key: string, values: string[]): string {
+  const items = values.map(v => {
+    if (v.includes(",") || v.includes("]")) {
+      throw new Error(`Cannot format ${key} "${v}": commas and "]" are not representable`);
+    }
+    return quoteAttributeValue(key, v);
+  });
+  return `[${items.join(", ")}]`;
+}
+
+/**
+ * Render an annotation as canonical `@collab` comment lines.
+ *
+ * Attributes are emitted in a fixed order: id, when, trust, owner, intent,
+ * constraints, lines, min_approvals, design_doc, redact, requires_tests,
+ * inherit, labels. id= leads so a block's name is the first thing on its
+ * line, and when= precedes the rest because it starts a conditional clause.
+ *
+ * Short annotations fit on one line; longer ones get one attribute per line,
+ * which parses back to the same annotation since consecutive lines merge.
+ * A note follows the attributes on the last line, after a second comment marker.
+ */
+export function formatAnnotation(annotation: ParsedAnnotation, options: FormatOptions = {}): string {
+  const { filePath, indent = "", maxWidth = 100 } = options;
+  const marker = options.marker ?? (filePath && HASH_COMMENT_EXTENSIONS.includes(getFileExtension(filePath)) ? "#" : "//");
+
+  const attrs = formatAttributes(annotation);
+  const note = annotation.note ? ` ${marker} ${annotation.note}` : "";
+  const singleLine = `${indent}${marker} @collab ${attrs.join(" ")}${note}`;
+  if (singleLine.length <= maxWidth || attrs.length === 1) {
+    return singleLine;
+  }
+
+  return attrs.map(attr => `${indent}${marker} @collab ${attr}`).join("\n") + note;
+}
+
+function formatAttributes(annotation: Partial<ParsedAnnotation>): string[] {
+  const attrs: string[] = [];
+  if (annotation.id !== undefined) attrs.push(`id=${quoteAttributeValue("id", annotation.id)}`);
+  if (annotation.when !== undefined) attrs.push(`when=${quoteAttributeValue("when", annotation.when)}`);
+  if (annotation.trust) attrs.push(`trust="${annotation.trust}"`);
+  if (Array.isArray(annotation.owner)) {
+    attrs.push(`owner=${formatArrayValue("owner", annotation.owner)}`);
+  } else if (annotation.owner) {
+    attrs.push(`owner=${quoteAttributeValue("owner", annotation.owner)}`);
+  }
+  if (annotation.intent) attrs.push(`intent=${quoteAttributeValue("intent", annotation.intent)}`);
+  if (annotation.constraints && annotation.constraints.length > 0) {
+    attrs.push(`constraints=${formatArrayValue("constraints", annotation.constraints)}`);
+  }
+  if (annotation.lines) attrs.push(`lines="${annotation.lines}"`);
+  if (annotation.min_approvals) attrs.push(`min_approvals="${annotation.min_approvals}"`);
+  if (annotation.design_doc) attrs.push(`design_doc=${quoteAttributeValue("design_doc", annotation.design_doc)}`);
+  if (annotation.redact) attrs.push(`redact="true"`);
+  if (annotation.requires_tests) attrs.push(`requires_tests="true"`);
+  if (annotation.inherit === false) attrs.push(`inherit="false"`);
+  if (annotation.labels && annotation.labels.length > 0) {
+    attrs.push(`labels=${formatArrayValue("labels", annotation.labels)}`);
+  }
+
+  if (attrs.length === 0) {
+    throw new Error("Cannot format an annotation with no attributes");
+  }
+  return attrs;
+}
+
+// ============================================
+// Formatting Annotations In Place
+// ============================================
+
+export interface CommentFormatOptions extends ParseOptions {
+  maxWidth?: number;
+  preserveLineCount?: boolean; // Leave annotations alone if reformatting would add or remove lines
+}
+
+export interface SkippedAnnotation {
+  line: number; // 1-indexed first line of the annotation
+  reason: string;
+}
+
+export interface FormattedContent {
+  content: string;
+  changed: number; // Annotations rewritten
+  skipped: SkippedAnnotation[]; // Annotations left as written
+}
+
+// Only whole-line comments are rewritten; trailing and /* */ annotations are left alone
+const WHOLE_LINE_ANNOTATION_REGEX = /^([ \t]*)(\/\/|#)[ \t]*@collab(:begin|:end)?(?:[ \t]+(.*?))?[ \t]*$/;
+
+/**
+ * Rewrite every `@collab` comment in `content` into the canonical form
+ * formatAnnotation produces: attributes in a fixed order, consistently quoted
+ * and spaced, aliases spelled out as trust="...". Code and other comments are
+ * untouched, and so is any annotation that has errors, unknown attributes, or
+ * would not parse back to the same attributes. Formatting formatted content
+ * changes nothing.
+ */
+export function formatAnnotationComments(
+  content: string,
+  filePath: string,
+  options: CommentFormatOptions = {}
+): FormattedContent {
+  const aliases = options.aliases ?? DEFAULT_TRUST_ALIASES;
+  const maxWidth = options.maxWidth ?? 100;
+
+  // Separators are kept so mixed line endings survive
+  const parts = content.split(/(\r\n|\n|\r)/);
+  const lines = parts.filter((_, index) => index % 2 === 0);
+  const eol = (index: number) => parts[index * 2 + 1] ?? "\n";
+
+  const output: string[] = [];
+  const skipped: SkippedAnnotation[] = [];
+  let changed = 0;
+
+  // Attributes of one comment line, or why it cannot be rewritten safely
+  const attributesOf = (attrString: string): Partial<ParsedAnnotation> | string => {
+    const parsed = parseAttributes(attrString, aliases);
+    if (parsed.errors.length > 0) return parsed.errors[0].message;
+    const unknown = [...splitAnnotationNote(attrString, aliases).attributes.matchAll(new RegExp(ATTR_KEY_SOURCE, "gu"))]
+      .map(m => m[1])
+      .find(key => !ANNOTATION_ATTRIBUTES.includes(key));
+    return unknown ? `unknown attribute "${unknown}"` : parsed.attrs;
+  };
+
+  // Annotations already written across several lines keep one attribute per line
+  const render = (
+    attrs: Partial<ParsedAnnotation>,
+    indent: string,
+    marker: "//" | "#",
+    kind: string,
+    lineCount: number
+  ): string[] => {
+    const { note, ...rest } = attrs;
+    const suffix = note ? ` ${marker} ${note}` : "";
+    if (kind === ":end" && Object.keys(rest).length === 0) return [`${indent}${marker} @collab:end${suffix}`];
+    if (kind !== "") return [`${indent}${marker} @collab${kind} ${formatAttributes(rest).join(" ")}${suffix}`];
+    const width = lineCount > 1 ? 0 : maxWidth;
+    return formatAnnotation({ ...attrs, line_start: 0, line_end: 0 }, { marker, indent, maxWidth: width }).split("\n");
+  };
+
+  let i = 0;
+  while (i < lines.length) {
+    const match = ANNOTATION_REGEX.exec(lines[i]);
+    const isBlockLine = BLOCK_BEGIN_REGEX.test(lines[i]) || BLOCK_END_REGEX.test(lines[i]);
+    if (!match) {
+      output.push(lines[i] + (i < lines.length - 1 ? eol(i) : ""));
+      i++;
+      continue;
+    }
+
+    // The lines the parser merges into one annotation
+    let last = i;
+    while (
+      !isBlockLine &&
+      last + 1 < lines.length &&
+      ANNOTATION_REGEX.test(lines[last + 1]) &&
+      !BLOCK_BEGIN_REGEX.test(lines[last + 1]) &&
+      !BLOCK_END_REGEX.test(lines[last + 1])
+    ) {
+      last++;
+    }
+    const group = lines.slice(i, last + 1);
+    const next = last + 1;
+
+    let rendered: string[] | string;
+    const first = WHOLE_LINE_ANNOTATION_REGEX.exec(group[0]);
+    const members = group.map(line => WHOLE_LINE_ANNOTATION_REGEX.exec(line));
+    if (!first || members.some(m => !m)) {
+      rendered = "only whole-line // and # comments are formatted";
+    } else {
+      const [, indent, marker, kind = ""] = first;
+      const merged: Partial<ParsedAnnotation> = {};
+      rendered = "";
+      for (const [index, member] of members.entries()) {
+        const attrs = attributesOf(member![4] ?? "");
+        if (typeof attrs === "string") {
+          rendered = attrs;
+          break;
+        }
+        if (attrs.when !== undefined && index > 0) {
+          rendered = "stacked conditional annotations are kept as written";
+          break;
+        }
+        if (attrs.note && merged.note) {
+          rendered = "notes on more than one line are kept as written";
+          break;
+        }
+        Object.assign(merged, attrs);
+      }
+      if (rendered === "") {
+        try {
+          rendered = render(merged, indent, marker as "//" | "#", kind, group.length);
+          // Must read back exactly as written, or the rewrite would change governance
+          const reread = rendered.map(line => attributesOf(WHOLE_LINE_ANNOTATION_REGEX.exec(line)?.[4] ?? ""));
+          const roundTrip = Object.assign({}, ...reread.filter(a => typeof a !== "string"));
+          if (reread.some(a => typeof a === "string") || stableStringify(roundTrip) !== stableStringify(merged)) {
+            rendered = "canonical form would not parse back to the same attributes";
+          } else if (options.preserveLineCount && rendered.length !== group.length) {
+            rendered = `reformatting would change the line count (${group.length} -> ${rendered.length})`;
+          }
+        } catch (error) {
+          rendered = error instanceof Error ? error.message : String(error);
+        }
+      }
+    }
+
+    if (typeof rendered === "string") {
+      skipped.push({ line: i + 1, reason: rendered });
+      rendered = group;
+    } else if (rendered.join("\n") !== group.join("\n")) {
+      changed++;
+    }
+
+    rendered.forEach((line, k) => {
+      const isLastLine = k === rendered.length - 1 && next === lines.length;
+      output.push(line + (isLastLine ? "" : eol(Math.min(i + k, last))));
+    });
+    i = next;
+  }
+
+  return { content: output.join(""), changed, skipped };
+}
+
+// ============================================
+// Trust Management
+// ============================================
+
+export async function loadTrustConfig(options: { profile?: string } = {}): Promise<TrustConfig> {
+  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+
+  let config: TrustConfig;
+  try {
+    const content = await fs.readFile(trustPath, "utf-8");
+    config = yaml.parse(content) as TrustConfig;
+  } catch {
+    // Return default config if file doesn't exist
+    return {
+      default_trust: "SUPERVISED",
+      policies: []
+    };
+  }
+
+  // Shared policies: failures surface rather than silently weakening governance
+  if (config.extends) {
+    config = mergePolicy(config, await loadExtendedPolicies(config.extends));
+    config.default_trust ??= "SUPERVISED";
+  }
+
+  config.active_profile = await selectTrustProfile(config, options.profile);
+  return config;
+}
+
+export async function loadCollabConfig(): Promise<CollabConfig> {
+  const configPath = path.join(COLLAB_DIR, CONFIG_FILE);
+
+  try {
+    const content = await fs.readFile(configPath, "utf-8");
+    return (yaml.parse(content) as CollabConfig) || {};
+  } catch {
+    return {};
+  }
+}
+
+export async function saveTrustConfig(config: TrustConfig): Promise<void> {
+  await ensureCollabDir();
+  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+  const { active_profile, ...persisted } = config;
+  await fs.writeFile(trustPath, yaml.stringify(persisted));
+}
+
+// ============================================
+// Environment Profiles
+// ============================================
+
+// Branch name from git, or from the CI environment for detached checkouts
+export async function detectGitBranch(): Promise<string | null> {
+  let branch: string | null = null;
+  try {
+    branch = (await git(["rev-parse", "--abbrev-ref", "HEAD"])).trim();
+  } catch {
+    // Not a git repository
+  }
+  if (!branch || branch === "HEAD") {
+    branch = process.env.GITHUB_HEAD_REF || process.env.GITHUB_REF_NAME || process.env.CI_COMMIT_REF_NAME || null;
+  }
+  return branch;
+}
+
+/**
+ * Pick the active profile: an explicit name (--profile or COLLAB_PROFILE)
+ * wins, otherwise the first profile whose branch globs match the current branch.
+ */
+export async function selectTrustProfile(config: TrustConfig, explicit?: string): Promise<string | undefined> {
+  const name = explicit || process.env[PROFILE_ENV];
+  if (name) {
+    if (!config.profiles?.[name]) {
+      throw new Error(`Unknown trust profile: ${name}`);
+    }
+    return name;
+  }
+
+  const profiles = Object.entries(config.profiles ?? {});
+  if (!profiles.some(([, p]) => p.branches?.length)) return undefined;
+
+  const branch = await detectGitBranch();
+  if (!branch) return undefined;
+
+  return profiles.find(([, p]) => p.branches?.some(pattern => matchesPattern(branch, pattern)))?.[0];
+}
+
+// Returns a description of the first invalid profile setting, if any
+export function validateTrustProfiles(config: TrustConfig): string | null {
+  for (const [name, profile] of Object.entries(config.profiles ?? {})) {
+    for (const [from, to] of Object.entries(profile.overrides ?? {})) {
+      if (!TRUST_LEVELS.includes(from as TrustLevel) || !TRUST_LEVELS.includes(to as TrustLevel)) {
+        return `profile "${name}": invalid override ${from} -> ${to}`;
+      }
+      if (from === "READ_ONLY" && to !== "READ_ONLY") {
+        return `profile "${name}": READ_ONLY cannot be relaxed by a profile`;
+      }
+    }
+  }
+  return null;
+}
+
+export function validateTrustBudgets(config: TrustConfig): string | null {
+  for (const budget of config.budgets ?? []) {
+    if (typeof budget?.pattern !== "string") {
+      return "budget is missing a pattern";
+    }
+    const fraction = budget.max_autonomous_fraction;
+    if (typeof fraction !== "number" || !(fraction >= 0 && fraction <= 1)) {
+      return `budget "${budget.pattern}": max_autonomous_fraction must be a number from 0 to 1`;
+    }
+  }
+  return null;
+}
+
+export function validateGeneratedTrust(config: TrustConfig): string | null {
+  const level = config.generated_trust;
+  if (level === undefined || level === false || TRUST_LEVELS.includes(level)) {
+    return null;
+  }
+  return `generated_trust must be a trust level or false (got ${level})`;
+}
+
+export function validateTestTrust(config: TrustConfig): string | null {
+  const level = config.test_trust;
+  if (level === undefined || TRUST_LEVELS.includes(level)) {
+    return null;
+  }
+  return `test_trust must be a trust level (got ${level})`;
+}
+
+// A Go test file as `go test` sees one: named *_test.go and importing "testing"
+export function isGoTestSource(filePath: string, content: string): boolean {
+  return filePath.endsWith("_test.go") && TESTING_IMPORT_REGEX.test(content.replace(/\r\n/g, "\n"));
+}
+
+async function isGoTestFile(filePath: string): Promise<boolean> {
+  if (!filePath.endsWith("_test.go")) return false;
+  try {
+    return isGoTestSource(filePath, await fs.readFile(filePath, "utf-8"));
+  } catch {
+    return false;
   }
-
-  // Fallback: just the next line
-  return { start: defLineIndex + 1, end: defLineIndex + 1 };
 }
 
-export async function parseAnnotations(filePath: string): Promise<ParsedAnnotation[]> {
-  const annotations: ParsedAnnotation[] = [];
+/**
+ * Whether content carries a generated-code header: a
+ * `// Code generated ... DO NOT EDIT.` line before the first line that is
+ * neither blank nor a comment, as Go tooling defines it.
+ */
+export function isGeneratedSource(content: string): boolean {
+  for (const line of content.replace(/\r\n/g, "\n").split("\n")) {
+    if (GENERATED_HEADER_REGEX.test(line.trimEnd())) return true;
+    if (line.trim() !== "" && !LEADING_COMMENT_REGEX.test(line)) return false;
+  }
+  return false;
+}
 
+async function isGeneratedFile(filePath: string): Promise<boolean> {
   try {
-    const content = await fs.readFile(filePath, "utf-8");
-    // Normalize line endings - handle both CRLF and LF
-    const lines = content.replace(/\r\n/g, "\n").replace(/\r/g, "\n").split("\n");
-    const fileExt = getFileExtension(filePath);
+    return isGeneratedSource(await fs.readFile(filePath, "utf-8"));
+  } catch {
+    return false;
+  }
+}
 
-    let i = 0;
-    while (i < lines.length) {
-      const line = lines[i];
+// The level generated files are governed at, or undefined when the guard is disabled
+export function generatedTrustLevel(config: TrustConfig): TrustLevel | undefined {
+  return config.generated_trust === false ? undefined : config.generated_trust ?? "READ_ONLY";
+}
 
-      // Check for block begin
-      const blockBeginMatch = BLOCK_BEGIN_REGEX.exec(line);
-      if (blockBeginMatch) {
-        const attrs = parseAttributes(blockBeginMatch[1]);
-        const blockStart = i + 1; // 1-indexed
-
-        // Find matching block end
-        let blockEnd = blockStart;
-        for (let j = i + 1; j < lines.length; j++) {
-          if (BLOCK_END_REGEX.test(lines[j])) {
-            blockEnd = j; // Line before @collab:end
-            i = j;
-            break;
-          }
-        }
+/**
+ * Apply the active profile to an already-resolved trust level. Profiles remap
+ * the final level whatever its source, so they also apply to inline
+ * annotations; READ_ONLY is never relaxed.
+ */
+export function applyTrustProfile(config: TrustConfig, result: TrustResult): TrustResult {
+  const name = config.active_profile;
+  if (!name) return result;
+
+  const target = config.profiles?.[name]?.overrides?.[result.level];
+  if (!target || !TRUST_LEVELS.includes(target) || target === result.level || result.level === "READ_ONLY") {
+    return { ...result, profile: name };
+  }
+  return { ...result, level: target, base_level: result.level, profile: name };
+}
 
-        annotations.push({
-          ...attrs,
-          line_start: blockStart + 1, // First line after @collab:begin
-          line_end: blockEnd,
-        });
-        i++;
-        continue;
-      }
+/**
+ * Whether a trust.yaml `regions` entry is about `filePath`: the same path, or
+ * its trailing path segments, so "pay/charge.ts" names "src/pay/charge.ts"
+ * but not "src/xpay/charge.ts".
+ */
+export function regionMatchesFile(region: Pick<RegionOverride, "file">, filePath: string): boolean {
+  const normalize = (p: string) => p.replace(/\\/g, "/").replace(/^\.\//, "");
+  const file = normalize(filePath);
+  const regionFile = normalize(region.file);
+  return file === regionFile || file.endsWith(`/${regionFile}`);
+}
 
-      // Check for single-line annotation
-      const match = ANNOTATION_REGEX.exec(line);
-      if (match && !BLOCK_END_REGEX.test(line)) {
-        const attrs = parseAttributes(match[1]);
-
-        // Collect consecutive @collab lines (multi-line annotation)
-        const collectedAttrs = { ...attrs };
-        let lastAnnotationLine = i;
-
-        for (let j = i + 1; j < lines.length; j++) {
-          const nextMatch = ANNOTATION_REGEX.exec(lines[j]);
-          if (nextMatch && !BLOCK_BEGIN_REGEX.test(lines[j]) && !BLOCK_END_REGEX.test(lines[j])) {
-            const nextAttrs = parseAttributes(nextMatch[1]);
-            Object.assign(collectedAttrs, nextAttrs);
-            lastAnnotationLine = j;
-          } else {
-            break;
-          }
-        }
+export function matchesPattern(filePath: string, pattern: string): boolean {
+  // Simple glob matching
+  // "**" is set aside first so the single-star rule does not rewrite its ".*"
+  const regexPattern = pattern
+    .replace(/\*\*/g, "\0")
+    .replace(/\*/g, "[^/]*")
+    .replace(/\?/g, ".")
+    .replace(/\0/g, ".*");
+  const regex = new RegExp(`^${regexPattern}$`);
+  return regex.test(filePath) || regex.test(filePath.replace(/\\/g, "/"));
+}
 
-        // Detect scope of the annotated code
-        const scope = detectAnnotationScope(lines, lastAnnotationLine, fileExt);
+// Vendored copies of shared libraries. Their own annotations govern them like
+// any other file; edits to their READ_ONLY regions are drift from upstream.
+export const DEFAULT_VENDOR_PATTERNS = ["vendor/**", "**/vendor/**"];
 
-        annotations.push({
-          ...collectedAttrs,
-          line_start: scope.start,
-          line_end: scope.end,
-        });
+export function isVendoredPath(filePath: string, patterns: string[] = DEFAULT_VENDOR_PATTERNS): boolean {
+  const normalized = filePath.replace(/\\/g, "/").replace(/^\.\//, "");
+  return patterns.some(pattern => matchesPattern(normalized, pattern));
+}
 
-        i = lastAnnotationLine + 1;
-        continue;
-      }
+// Literal (wildcard-free) segments, then literal characters: "internal/crypto/**" beats "internal/**"
+export function patternSpecificity(pattern: string): [number, number] {
+  const segments = pattern.replace(/\\/g, "/").split("/").filter(Boolean);
+  return [segments.filter(s => !/[*?]/.test(s)).length, pattern.replace(/[*?]/g, "").length];
+}
 
-      i++;
+export interface PolicyMatch {
+  policy: TrustPolicy;
+  ties: TrustPolicy[]; // Other matches exactly as specific that set a different trust level
+}
+
+/**
+ * The most specific policy matching a path. Equally specific matches with the
+ * same trust resolve to the first listed (local before extended). Ones that
+ * disagree are ambiguous: the most restrictive applies and check reports it.
+ */
+export function findMatchingPolicy(policies: TrustPolicy[], filePath: string): PolicyMatch | undefined {
+  let best: TrustPolicy[] = [];
+  let bestScore: [number, number] = [-1, -1];
+  for (const policy of policies) {
+    if (!matchesPattern(filePath, policy.pattern)) continue;
+    const score = patternSpecificity(policy.pattern);
+    const order = score[0] - bestScore[0] || score[1] - bestScore[1];
+    if (order > 0) {
+      best = [policy];
+      bestScore = score;
+    } else if (order === 0) {
+      best.push(policy);
     }
-  } catch {
-    // File doesn't exist or can't be read
   }
+  if (best.length === 0) return undefined;
 
-  return annotations;
+  const policy = best.reduce((a, b) => (TRUST_RESTRICTIVENESS[b.trust] > TRUST_RESTRICTIVENESS[a.trust] ? b : a));
+  return { policy, ties: best.filter(p => p.trust !== policy.trust) };
 }
 
-// ============================================
-// Trust Management
-// ============================================
+// "*Handler" or "Server.*" against "Server.LoginHandler". A pattern matches the
+// same number of trailing name segments it has, and "*" stays within a segment.
+export function matchesSymbolPattern(qualifiedName: string, pattern: string): boolean {
+  const segments = pattern.split(".").length;
+  const target = qualifiedName.split(".").slice(-segments).join(".");
+  const regexPattern = pattern
+    .split("*")
+    .map(part => part.replace(/[.*+?^${}()|[\]\\]/g, "\\$&"))
+    .join("[^.]*");
+  return new RegExp(`^${regexPattern}$`, "u").test(target);
+}
 
-export async function loadTrustConfig(): Promise<TrustConfig> {
-  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+/**
+ * The symbol policy for a line range: the innermost declaration overlapping
+ * it that some policy matches, and the first policy that matches it.
+ */
+export function findSymbolPolicy(
+  config: TrustConfig,
+  declarations: DeclarationSpan[],
+  lineStart: number,
+  lineEnd: number = lineStart
+): { policy: SymbolPolicy; declaration: DeclarationSpan } | undefined {
+  if (!config.symbols?.length) return undefined;
+  const overlapping = declarations
+    .filter(d => lineStart <= d.line_end && lineEnd >= d.line_start)
+    .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start));
+
+  for (const declaration of overlapping) {
+    const policy = config.symbols.find(p => matchesSymbolPattern(declaration.qualified_name, p.pattern));
+    if (policy) return { policy, declaration };
+  }
+  return undefined;
+}
 
+// Only read when symbol policies exist; declarations.ts imports this module, so it is loaded lazily
+async function loadDeclarationSpans(filePath: string): Promise<DeclarationSpan[]> {
   try {
-    const content = await fs.readFile(trustPath, "utf-8");
-    return yaml.parse(content) as TrustConfig;
+    const content = await fs.readFile(filePath, "utf-8");
+    const { findDeclarations } = await import("./declarations.js");
+    return findDeclarations(content, filePath);
   } catch {
-    // Return default config if file doesn't exist
-    return {
-      default_trust: "SUPERVISED",
-      policies: []
-    };
+    return [];
   }
 }
 
-export async function saveTrustConfig(config: TrustConfig): Promise<void> {
-  await ensureCollabDir();
-  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
-  await fs.writeFile(trustPath, yaml.stringify(config));
+export async function getTrustLevelWithAnnotations(
+  config: TrustConfig,
+  filePath: string,
+  lineStart?: number,
+  lineEnd?: number
+): Promise<TrustResult> {
+  const annotations = lineStart !== undefined ? await parseAnnotations(filePath) : [];
+  const declarations = lineStart !== undefined && config.symbols?.length ? await loadDeclarationSpans(filePath) : [];
+  const generated = generatedTrustLevel(config) !== undefined && (await isGeneratedFile(filePath));
+  const packageDefault = await loadPackageDefault(filePath);
+  const testFile = config.test_trust !== undefined && (await isGoTestFile(filePath));
+  return resolveTrustWithAnnotations(
+    config, filePath, annotations, lineStart, lineEnd, declarations, generated, packageDefault, testFile
+  );
 }
 
-function matchesPattern(filePath: string, pattern: string): boolean {
-  // Simple glob matching
-  const regexPattern = pattern
-    .replace(/\*\*/g, ".*")
-    .replace(/\*/g, "[^/]*")
-    .replace(/\?/g, ".");
-  const regex = new RegExp(`^${regexPattern}$`);
-  return regex.test(filePath) || regex.test(filePath.replace(/\\/g, "/"));
+// Attributes a nested annotation takes from the ones enclosing it
+const INHERITED_ATTRIBUTES = ["trust", "owner", "intent", "constraints", "min_approvals", "design_doc"] as const;
+
+/**
+ * The annotation that governs lines `start`-`end` when annotations nest: the
+ * innermost one containing every line decides, and what it leaves unset is
+ * inherited from the annotations around it, out to and including the first
+ * with inherit="false". `from` is the annotation the trust level came from,
+ * and `inherited` is set when that is not the innermost one. `broken` is set
+ * when the chain stopped at inherit="false", so an unset level falls back to
+ * trust.yaml rather than to an enclosing annotation.
+ */
+export function resolveNestedAnnotation(
+  annotations: ParsedAnnotation[],
+  start: number,
+  end: number
+): { attributes: Partial<ParsedAnnotation>; from?: ParsedAnnotation; inherited: boolean; broken: boolean } {
+  const chain = annotations
+    .filter(a => a.line_start <= start && a.line_end >= end)
+    .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start) || b.line_start - a.line_start);
+
+  const attributes: Partial<ParsedAnnotation> = {};
+  let from: ParsedAnnotation | undefined;
+  for (const annotation of chain) {
+    for (const key of INHERITED_ATTRIBUTES) {
+      if (attributes[key] === undefined && annotation[key] !== undefined) {
+        (attributes as Record<string, unknown>)[key] = annotation[key];
+        if (key === "trust") from = annotation;
+      }
+    }
+    if (annotation.inherit === false) break;
+  }
+  return {
+    attributes,
+    from,
+    inherited: from !== undefined && from !== chain[0],
+    broken: chain.some(a => a.inherit === false),
+  };
 }
 
-export async function getTrustLevelWithAnnotations(
+/**
+ * The annotation whose trust level applies to lines `start`-`end`, with the
+ * attributes it resolves to. Nested annotations are resolved as in
+ * resolveNestedAnnotation; lines not inside any single annotation take the
+ * first one with a trust level they overlap. Undefined when none applies.
+ */
+export function governingAnnotation(
+  annotations: ParsedAnnotation[],
+  start: number,
+  end: number
+): { annotation: ParsedAnnotation; attributes: Partial<ParsedAnnotation>; inherited: boolean } | undefined {
+  const nested = resolveNestedAnnotation(annotations, start, end);
+  if (nested.from) return { annotation: nested.from, attributes: nested.attributes, inherited: nested.inherited };
+  if (nested.broken) return undefined;
+
+  const overlapping = annotations.find(a => a.trust && start <= a.line_end && end >= a.line_start);
+  return overlapping ? { annotation: overlapping, attributes: overlapping, inherited: false } : undefined;
+}
+
+// getTrustLevelWithAnnotations for already-parsed annotations, e.g. when resolving many ranges.
+// Pass the file's declarations for symbol policies to apply, `generated`
+// (see isGeneratedSource) for the generated-code guard to apply, the file's
+// package default (see loadPackageDefault) to use it under every policy, and
+// `testFile` (see isGoTestSource) for trust.yaml test_trust to apply.
+export function resolveTrustWithAnnotations(
   config: TrustConfig,
   filePath: string,
+  annotations: ParsedAnnotation[],
   lineStart?: number,
-  lineEnd?: number
-): Promise<TrustResult> {
+  lineEnd?: number,
+  declarations: DeclarationSpan[] = [],
+  generated = false,
+  packageDefault?: PackageDefault,
+  testFile = false
+): TrustResult {
   // Normalize path
   const normalizedPath = filePath.replace(/\\/g, "/");
 
-  // 1. Check inline annotations first (highest priority)
-  if (lineStart !== undefined) {
-    const annotations = await parseAnnotations(filePath);
-    for (const annotation of annotations) {
-      const end = lineEnd ?? lineStart;
-      if (lineStart <= annotation.line_end && end >= annotation.line_start) {
-        if (annotation.trust) {
-          return {
-            level: annotation.trust,
-            reason: "Inline @collab annotation",
-            owner: annotation.owner,
-            intent: annotation.intent,
-            constraints: annotation.constraints,
-            source: "annotation",
-          };
-        }
-      }
-    }
+  // 0. Generated files are regenerated, not edited, whatever their annotations say
+  const generatedLevel = generated ? generatedTrustLevel(config) : undefined;
+  if (generatedLevel) {
+    return applyTrustProfile(config, {
+      level: generatedLevel,
+      reason: "Generated code (Code generated ... DO NOT EDIT.); change the generator or its input instead",
+      source: "generated",
+    });
+  }
+
+  // 1. Check inline annotations first (highest priority); nested ones before those around them
+  const governing = lineStart !== undefined ? governingAnnotation(annotations, lineStart, lineEnd ?? lineStart) : undefined;
+  if (governing) {
+    const { annotation, attributes, inherited } = governing;
+    return applyTrustProfile(config, {
+      level: attributes.trust!,
+      reason: inherited
+        ? `Inline @collab annotation (trust inherited from lines ${annotation.line_start}-${annotation.line_end})`
+        : "Inline @collab annotation",
+      owner: attributes.owner,
+      intent: attributes.intent,
+      constraints: attributes.constraints,
+      min_approvals: attributes.min_approvals,
+      design_doc: attributes.design_doc,
+      source: "annotation",
+    });
   }
 
   // 2. Check region overrides (from trust.yaml)
   if (config.regions && lineStart !== undefined) {
     for (const region of config.regions) {
-      const regionFile = region.file.replace(/\\/g, "/");
-      if (normalizedPath.endsWith(regionFile) || normalizedPath === regionFile) {
+      if (regionMatchesFile(region, normalizedPath)) {
         const end = lineEnd ?? lineStart;
         if (lineStart <= region.line_end && end >= region.line_start) {
-          return {
+          return applyTrustProfile(config, {
             level: region.trust,
             reason: region.reason,
             source: "region",
-          };
+          });
         }
       }
     }
   }
 
-  // 3. Check pattern policies (in order, first match wins)
-  for (const policy of config.policies) {
-    if (matchesPattern(normalizedPath, policy.pattern)) {
-      return {
-        level: policy.trust,
-        reason: policy.reason,
-        owner: policy.owner,
-        source: "policy",
-      };
+  // 3. Symbol policies for the declaration the lines are in
+  if (lineStart !== undefined) {
+    const match = findSymbolPolicy(config, declarations, lineStart, lineEnd);
+    if (match) {
+      return applyTrustProfile(config, {
+        level: match.policy.trust,
+        reason: match.policy.reason ?? `Symbol policy "${match.policy.pattern}" (${match.declaration.qualified_name})`,
+        owner: match.policy.owner,
+        source: "symbol",
+      });
     }
   }
 
-  // 4. Return default
-  return {
+  // 4. Check pattern policies (most specific wins)
+  const policyMatch = findMatchingPolicy(config.policies, normalizedPath);
+  if (policyMatch) {
+    return applyTrustProfile(config, {
+      level: policyMatch.policy.trust,
+      reason: policyMatch.policy.reason,
+      owner: policyMatch.policy.owner,
+      source: "policy",
+      pattern: policyMatch.policy.pattern,
+    });
+  }
+
+  // 5. Go test files, when trust.yaml sets a default for them
+  if (testFile && config.test_trust) {
+    return applyTrustProfile(config, {
+      level: config.test_trust,
+      reason: "Go test file default (trust.yaml test_trust)",
+      owner: packageDefault?.owner,
+      source: "test",
+    });
+  }
+
+  // 6. The package's doc.go directive, the coarsest in-source setting
+  if (packageDefault?.trust) {
+    return applyTrustProfile(config, {
+      level: packageDefault.trust,
+      reason: `Package default (${packageDefault.file.replace(/\\/g, "/")}:${packageDefault.line})`,
+      owner: packageDefault.owner,
+      intent: packageDefault.intent,
+      source: "package",
+    });
+  }
+
+  // 7. Return default
+  return applyTrustProfile(config, {
     level: config.default_trust,
     reason: "Default trust level",
+    owner: packageDefault?.owner,
     source: "default",
-  };
+  });
+}
+
+export interface GoverningComment {
+  file: string;
+  line_start: number; // The comment's lines, 1-indexed
+  line_end: number;
+  text: string; // As written, from the comment marker on; several lines are joined with "\n"
+  annotation: ParsedAnnotation;
+}
+
+/**
+ * The `@collab` comment that sets the effective trust level of `line`, exactly
+ * as its author wrote it, for "governed by this annotation" panels. Lines the
+ * parser merges into one annotation come back together; for a block it is the
+ * @collab:begin line, and for a level inherited from an enclosing block it is
+ * that block's. Undefined when no annotation sets the level, including in
+ * generated files when the generated-code guard applies (see isGeneratedSource).
+ */
+export function governingCommentInContent(
+  content: string,
+  filePath: string,
+  line: number,
+  options: ParseOptions & { generated?: boolean } = {}
+): GoverningComment | undefined {
+  if (options.generated) return undefined;
+  const { annotations } = parseAnnotationContent(content, filePath, options);
+  const annotation = governingAnnotation(annotations, line, line)?.annotation;
+  if (!annotation?.comment_start || !annotation.comment_end) return undefined;
+
+  const lines = content.replace(/\r\n/g, "\n").replace(/\r/g, "\n").split("\n");
+  const text = lines
+    .slice(annotation.comment_start - 1, annotation.comment_end)
+    .map(source => source.slice(ANNOTATION_REGEX.exec(source)?.index ?? 0).trim())
+    .join("\n");
+  return { file: filePath, line_start: annotation.comment_start, line_end: annotation.comment_end, text, annotation };
+}
+
+// governingCommentInContent for a file on disk, with its aliases and generated-code guard
+export async function governingComment(filePath: string, line: number): Promise<GoverningComment | undefined> {
+  const content = await fs.readFile(filePath, "utf-8");
+  const config = await loadTrustConfig();
+  return governingCommentInContent(content, filePath, line, {
+    aliases: await loadTrustAliases(),
+    generated: generatedTrustLevel(config) !== undefined && isGeneratedSource(content),
+  });
 }
 
 export function getTrustLevel(
@@ -439,39 +2424,38 @@ export function getTrustLevel(
   // Check region overrides first (most specific)
   if (config.regions && lineStart !== undefined) {
     for (const region of config.regions) {
-      const regionFile = region.file.replace(/\\/g, "/");
-      if (normalizedPath.endsWith(regionFile) || normalizedPath === regionFile) {
+      if (regionMatchesFile(region, normalizedPath)) {
         // Check if lines overlap
         const end = lineEnd ?? lineStart;
         if (lineStart <= region.line_end && end >= region.line_start) {
-          return {
+          return applyTrustProfile(config, {
             level: region.trust,
             reason: region.reason,
             source: "region",
-          };
+          });
         }
       }
     }
   }
 
-  // Check pattern policies (in order, first match wins)
-  for (const policy of config.policies) {
-    if (matchesPattern(normalizedPath, policy.pattern)) {
-      return {
-        level: policy.trust,
-        reason: policy.reason,
-        owner: policy.owner,
-        source: "policy",
-      };
-    }
+  // Check pattern policies (most specific wins)
+  const match = findMatchingPolicy(config.policies, normalizedPath);
+  if (match) {
+    return applyTrustProfile(config, {
+      level: match.policy.trust,
+      reason: match.policy.reason,
+      owner: match.policy.owner,
+      source: "policy",
+      pattern: match.policy.pattern,
+    });
   }
 
   // Return default
-  return {
+  return applyTrustProfile(config, {
     level: config.default_trust,
     reason: "Default trust level",
     source: "default",
-  };
+  });
 }
 
 // ============================================
@@ -558,6 +2542,400 @@ export async function deleteProposal(id: string): Promise<void> {
   }
 }
 
+// Find the lines a snippet occupies in a file (first occurrence)
+export function locateCode(content: string, code: string): { line_start: number; line_end: number } | null {
+  const normalized = content.replace(/\r\n/g, "\n");
+  const snippet = code.replace(/\r\n/g, "\n");
+  const index = snippet ? normalized.indexOf(snippet) : -1;
+  if (index === -1) return null;
+
+  const line_start = normalized.slice(0, index).split("\n").length;
+  const line_end = line_start + snippet.replace(/\n$/, "").split("\n").length - 1;
+  return { line_start, line_end };
+}
+
+// The lines a proposal replaces, as the caller recorded them
+export interface ProposalLines {
+  line_start: number;
+  line_end: number;
+}
+
+// Where old_code is in the file, else the recorded lines; null if neither locates it
+function locateProposalCode(content: string, oldCode: string, lines?: ProposalLines): ProposalLines | null {
+  return locateCode(content, oldCode) ?? lines ?? null;
+}
+
+// Trust of the region a proposal replaces, or null if old_code cannot be located
+async function resolveProposalRegion(filePath: string, oldCode: string, lines?: ProposalLines): Promise<TrustResult | null> {
+  try {
+    const content = await fs.readFile(filePath, "utf-8");
+    const location = locateProposalCode(content, oldCode, lines);
+    if (!location) return null;
+
+    return await getTrustLevelWithAnnotations(
+      await loadTrustConfig(),
+      filePath,
+      location.line_start,
+      location.line_end
+    );
+  } catch {
+    return null;
+  }
+}
+
+// Approvals required for a proposal, from the min_approvals of the region it replaces
+export async function getRequiredApprovals(filePath: string, oldCode: string, lines?: ProposalLines): Promise<number> {
+  return (await resolveProposalRegion(filePath, oldCode, lines))?.min_approvals ?? 1;
+}
+
+// The design doc linked from the region a proposal replaces, if any
+export async function getProposalDesignDoc(filePath: string, oldCode: string, lines?: ProposalLines): Promise<string | undefined> {
+  return (await resolveProposalRegion(filePath, oldCode, lines))?.design_doc;
+}
+
+// Everyone who co-owns the region a proposal replaces; any of them may approve it
+export async function getProposalOwners(filePath: string, oldCode: string, lines?: ProposalLines): Promise<string[]> {
+  return ownerList((await resolveProposalRegion(filePath, oldCode, lines))?.owner);
+}
+
+/**
+ * Replace old_code with a placeholder when it overlaps a redact="true" region,
+ * so the region's content never leaves the repository in a proposal. The hash
+ * lets the owner confirm the placeholder still matches the file in place.
+ */
+export async function redactProposalCode(
+  filePath: string,
+  oldCode: string,
+  lines?: ProposalLines
+): Promise<{ old_code: string; redacted?: RedactedCode }> {
+  let content: string;
+  try {
+    content = await fs.readFile(filePath, "utf-8");
+  } catch {
+    return { old_code: oldCode };
+  }
+
+  const location = locateProposalCode(content, oldCode, lines);
+  if (!location) return { old_code: oldCode };
+
+  const sensitive = (await parseAnnotations(filePath)).some(
+    a => a.redact && location.line_start <= a.line_end && location.line_end >= a.line_start
+  );
+  if (!sensitive) return { old_code: oldCode };
+
+  const redacted = { sha256: createHash("sha256").update(oldCode).digest("hex"), ...location };
+  return {
+    old_code: `[redacted: ${filePath}:${location.line_start}-${location.line_end} sha256:${redacted.sha256}]`,
+    redacted,
+  };
+}
+
+// Hash of whole lines (1-indexed, inclusive) as recorded in ProposalBase
+export function hashLines(content: string, lineStart: number, lineEnd: number): string {
+  const lines = content.replace(/\r\n/g, "\n").split("\n").slice(lineStart - 1, lineEnd);
+  return createHash("sha256").update(lines.join("\n")).digest("hex");
+}
+
+// Snapshot of the lines a proposal replaces, or undefined if old_code cannot be located
+export async function captureProposalBase(
+  filePath: string,
+  oldCode: string,
+  lines?: ProposalLines
+): Promise<ProposalBase | undefined> {
+  let content: string;
+  try {
+    content = await fs.readFile(filePath, "utf-8");
+  } catch {
+    return undefined;
+  }
+
+  const location = locateProposalCode(content, oldCode, lines);
+  const trust = await resolveProposalRegion(filePath, oldCode, lines);
+  if (!location || !trust) return undefined;
+  return { ...location, sha256: hashLines(content, location.line_start, location.line_end), trust: trust.level };
+}
+
+// line_start and line_end: the lines old_code replaces, for when it is not in the file verbatim
+export type ProposalInput = Pick<
+  Proposal,
+  "file_path" | "description" | "rationale" | "old_code" | "new_code" | "confidence" | "risks" | "tests_needed" | "batch"
+> & { author?: string } & Partial<ProposalLines>;
+
+// Thrown for a proposal whose old_code is not in its (existing) file and that records no lines
+export class ProposalRegionNotFoundError extends Error {}
+
+// Throws ProposalRegionNotFoundError for a proposal createProposal refuses
+async function assertProposalLocated(input: ProposalInput): Promise<void> {
+  if (proposalLines(input)) return;
+  const content = await fs.readFile(input.file_path, "utf-8").catch(() => null);
+  if (content !== null && input.old_code && !locateCode(content, input.old_code)) {
+    throw new ProposalRegionNotFoundError(
+      `old_code is not in ${input.file_path}; copy it from the file as is, or give the line_start and line_end it replaces`
+    );
+  }
+}
+
+// The recorded lines of a proposal, when both are valid
+function proposalLines(input: ProposalInput): ProposalLines | undefined {
+  const { line_start, line_end } = input;
+  if (!Number.isInteger(line_start) || line_start! < 1) return undefined;
+  const end = line_end ?? line_start!;
+  return Number.isInteger(end) && end >= line_start! ? { line_start: line_start!, line_end: end } : undefined;
+}
+
+/**
+ * Create and save a pending proposal, with the approvals, design doc, owners,
+ * redaction, and base snapshot of the region it replaces filled in from the file.
+ * The region is where old_code is, else the input's line_start and line_end;
+ * with neither, the proposal is refused (ProposalRegionNotFoundError) rather
+ * than filed without its region's approvals. A new file has no region.
+ */
+export async function createProposal(input: ProposalInput): Promise<Proposal> {
+  const { file_path, old_code } = input;
+  const lines = proposalLines(input);
+  await assertProposalLocated(input);
+  const minApprovals = await getRequiredApprovals(file_path, old_code, lines);
+  const designDoc = await getProposalDesignDoc(file_path, old_code, lines);
+  const redaction = await redactProposalCode(file_path, old_code, lines);
+  const owners = await getProposalOwners(file_path, old_code, lines);
+  const base = await captureProposalBase(file_path, old_code, lines);
+
+  const createdAt = new Date().toISOString();
+  const author = input.author ?? "claude";
+  const proposal: Proposal = {
+    id: generateId(),
+    created_at: createdAt,
+    author,
+    status: "pending",
+    file_path,
+    description: input.description,
+    rationale: input.rationale,
+    old_code: redaction.old_code,
+    new_code: input.new_code,
+    confidence: input.confidence,
+    risks: input.risks,
+    tests_needed: input.tests_needed,
+    min_approvals: minApprovals > 1 ? minApprovals : undefined,
+    design_doc: designDoc,
+    redacted: redaction.redacted,
+    owners: owners.length > 0 ? owners : undefined,
+    base,
+    batch: input.batch,
+    history: [{ status: "pending", at: createdAt, by: author }],
+  };
+
+  await saveProposal(proposal);
+  return proposal;
+}
+
+/**
+ * Create a proposal per edit, grouped into one batch per set of owners, so a
+ * refactor touching many regions notifies each owner once. The proposals stay
+ * independent: each records its batch id and is approved, applied, or
+ * rejected by itself. Batches are returned in the order their first edit came.
+ */
+export async function createBatchProposals(inputs: ProposalInput[]): Promise<ProposalBatch[]> {
+  // Refused before any is saved, so a batch is filed whole or not at all
+  for (const input of inputs) await assertProposalLocated(input);
+  const batches = new Map<string, ProposalBatch>();
+  for (const input of inputs) {
+    const owners = await getProposalOwners(input.file_path, input.old_code, proposalLines(input));
+    const key = [...owners].sort().join("\0");
+    let batch = batches.get(key);
+    if (!batch) {
+      batch = { id: generateId(), owners: owners.length > 0 ? owners : undefined, proposals: [] };
+      batches.set(key, batch);
+    }
+    batch.proposals.push(await createProposal({ ...input, batch: batch.id }));
+  }
+  return [...batches.values()];
+}
+
+// A reviewer's call on one region of a batch, keyed by proposal id in applyBatch
+export type BatchDecision = "approve" | "reject";
+
+export interface BatchRegionResult {
+  proposal_id: string;
+  file_path: string;
+  outcome: "applied" | "rejected" | "undecided" | "skipped";
+  line_start?: number; // Where the new code landed, after earlier regions shifted it
+  line_end?: number;
+  reason?: string; // Why an approved region was skipped
+}
+
+export interface BatchApplyResult {
+  sources: Record<string, string>; // Every input file, with the approved regions replaced
+  regions: BatchRegionResult[]; // One per proposal, in batch order
+}
+
+/**
+ * Apply the approved regions of a batch to `sources` (file path -> content)
+ * and leave the rest as they are. Regions are located in the original content,
+ * preferring the lines recorded in each proposal's base, and replaced top to
+ * bottom so each reported range accounts for the lines earlier replacements
+ * added or removed. An approved region is skipped, not applied, when its file
+ * is not in `sources`, its old_code is no longer there or is redacted, it
+ * overlaps a region already applied, or the proposal is rejected or applied.
+ * Nothing is saved; the caller writes the sources and moves the proposals on.
+ */
+export function applyBatch(
+  sources: Record<string, string>,
+  batch: ProposalBatch,
+  decisions: Record<string, BatchDecision>
+): BatchApplyResult {
+  const results = new Map<string, BatchRegionResult>();
+  const located = new Map<string, { proposal: Proposal; index: number; line_start: number }[]>();
+
+  for (const proposal of batch.proposals) {
+    const result: BatchRegionResult = { proposal_id: proposal.id, file_path: proposal.file_path, outcome: "undecided" };
+    results.set(proposal.id, result);
+    const decision = decisions[proposal.id];
+    if (decision === "reject") result.outcome = "rejected";
+    if (decision !== "approve") continue;
+
+    const skip = (reason: string) => {
+      result.outcome = "skipped";
+      result.reason = reason;
+    };
+    const content = sources[proposal.file_path];
+    if (proposal.status === "rejected" || proposal.status === "applied") {
+      skip(`proposal is already ${proposal.status}`);
+    } else if (content === undefined) {
+      skip(`${proposal.file_path} was not provided`);
+    } else if (proposal.redacted) {
+      skip(`old_code is redacted; the owner must apply lines ${proposal.redacted.line_start}-${proposal.redacted.line_end} in place`);
+    } else {
+      const normalized = content.replace(/\r\n/g, "\n");
+      const snippet = proposal.old_code.replace(/\r\n/g, "\n");
+      const lineOf = (index: number) => normalized.slice(0, index).split("\n").length;
+      let index = -1;
+      if (snippet && proposal.base) {
+        // The same code can appear twice; the base says which copy was proposed against
+        const baseOffset = normalized.split("\n").slice(0, proposal.base.line_start - 1).join("\n").length;
+        const candidate = normalized.indexOf(snippet, proposal.base.line_start > 1 ? baseOffset + 1 : 0);
+        if (candidate !== -1 && lineOf(candidate) === proposal.base.line_start) index = candidate;
+      }
+      if (index === -1 && snippet) index = normalized.indexOf(snippet);
+      if (index === -1) {
+        skip(`old_code is no longer in ${proposal.file_path}`);
+      } else {
+        located.set(proposal.file_path, [
+          ...(located.get(proposal.file_path) ?? []),
+          { proposal, index, line_start: lineOf(index) },
+        ]);
+      }
+    }
+  }
+
+  const output = { ...sources };
+  for (const [filePath, regions] of located) {
+    const original = sources[filePath];
+    const eol = original.includes("\r\n") ? "\r\n" : "\n";
+    const normalized = original.replace(/\r\n/g, "\n");
+    let text = "";
+    let consumed = 0; // Offset in the original content copied so far
+    let lineShift = 0; // Lines added (or removed, if negative) by the regions applied so far
+    let previous: Proposal | undefined;
+
+    for (const { proposal, index, line_start } of regions.sort((a, b) => a.index - b.index)) {
+      const result = results.get(proposal.id)!;
+      if (previous && index < consumed) {
+        result.outcome = "skipped";
+        result.reason = `overlaps proposal ${previous.id}`;
+        continue;
+      }
+
+      const oldCode = proposal.old_code.replace(/\r\n/g, "\n");
+      const newCode = proposal.new_code.replace(/\r\n/g, "\n");
+      const lineCount = (code: string) => code.replace(/\n$/, "").split("\n").length;
+      text += normalized.slice(consumed, index) + newCode;
+      consumed = index + oldCode.length;
+
+      result.outcome = "applied";
+      result.line_start = line_start + lineShift;
+      result.line_end = result.line_start + Math.max(lineCount(newCode), 1) - 1;
+      lineShift += (newCode.match(/\n/g) ?? []).length - (oldCode.match(/\n/g) ?? []).length;
+      previous = proposal;
+    }
+
+    text += normalized.slice(consumed);
+    output[filePath] = eol === "\n" ? text : text.replace(/\n/g, eol);
+  }
+
+  return { sources: output, regions: batch.proposals.map(p => results.get(p.id)!) };
+}
+
+export interface ApprovalStatus {
+  counted: boolean; // False for self-approvals and repeat approvers
+  approvals: number; // Distinct approvers other than the author
+  required: number;
+  satisfied: boolean;
+}
+
+export function countApprovals(proposal: Proposal): number {
+  const approvers = new Set(
+    (proposal.approvals ?? [])
+      .map(a => a.approver)
+      .filter(approver => approver !== proposal.author)
+  );
+  return approvers.size;
+}
+
+// For a proposal with a design_doc, the approval records it as acknowledged
+export function addApproval(proposal: Proposal, approver: string): ApprovalStatus {
+  const required = proposal.min_approvals ?? 1;
+  const before = countApprovals(proposal);
+
+  proposal.approvals = [
+    ...(proposal.approvals ?? []),
+    { approver, approved_at: new Date().toISOString(), design_doc: proposal.design_doc },
+  ];
+
+  const approvals = countApprovals(proposal);
+  return {
+    counted: approvals > before,
+    approvals,
+    required,
+    satisfied: approvals >= required,
+  };
+}
+
+// Statuses a proposal may move to from each status; rejected and applied are final
+export const PROPOSAL_TRANSITIONS: Record<ProposalStatus, ProposalStatus[]> = {
+  pending: ["approved", "rejected"],
+  approved: ["applied", "rejected"],
+  rejected: [],
+  applied: [],
+};
+
+// Thrown for a lifecycle step the proposal's current status does not allow
+export class ProposalTransitionError extends Error {}
+
+/**
+ * Move a proposal to a new status and record the step in its history. Throws
+ * ProposalTransitionError if PROPOSAL_TRANSITIONS does not allow it, e.g.
+ * applying a rejected proposal. The proposal is not saved.
+ */
+export function transitionProposal(
+  proposal: Proposal,
+  status: ProposalStatus,
+  options: { by?: string; reason?: string; now?: Date } = {}
+): void {
+  const allowed = PROPOSAL_TRANSITIONS[proposal.status] ?? [];
+  if (!allowed.includes(status)) {
+    throw new ProposalTransitionError(
+      `Cannot move proposal ${proposal.id} from ${proposal.status} to ${status}` +
+        (allowed.length > 0 ? ` (allowed: ${allowed.join(", ")})` : ` (${proposal.status} is final)`)
+    );
+  }
+
+  proposal.status = status;
+  proposal.history = [
+    ...(proposal.history ?? []),
+    { status, at: (options.now ?? new Date()).toISOString(), by: options.by, reason: options.reason },
+  ];
+}
+
 // ============================================
 // Authorship Management
 // ============================================
@@ -590,6 +2968,52 @@ export async function loadAuthorship(filePath: string): Promise<AuthorshipRecord
   }
 }
 
+// ============================================
+// Agent Identities
+// ============================================
+
+// Returns a description of the first invalid agent policy, if any
+export function validateAgentPolicies(config: CollabConfig): string | null {
+  if (config.agents === undefined) return null;
+  if (!config.agents || typeof config.agents !== "object" || Array.isArray(config.agents)) {
+    return "agents must map agent identities to policies";
+  }
+  for (const [agent, policy] of Object.entries(config.agents)) {
+    const level = policy?.max_trust;
+    if (level === "READ_ONLY") return `agent "${agent}": max_trust cannot be READ_ONLY, which no one edits`;
+    if (!TRUST_LEVELS.includes(level as TrustLevel)) {
+      return `agent "${agent}": max_trust must be one of ${TRUST_LEVELS.filter(l => l !== "READ_ONLY").join(", ")}`;
+    }
+  }
+  return null;
+}
+
+// ============================================
+// Audit Log
+// ============================================
+
+export function isShadowMode(config: CollabConfig): boolean {
+  return config.enforcement === "shadow";
+}
+
+export async function recordAuditEntries(entries: AuditEntry[]): Promise<void> {
+  if (entries.length === 0) return;
+  await ensureCollabDir();
+  await fs.appendFile(
+    path.join(COLLAB_DIR, AUDIT_FILE),
+    entries.map(entry => JSON.stringify(entry) + "\n").join("")
+  );
+}
+
+export async function loadAuditEntries(): Promise<AuditEntry[]> {
+  try {
+    const content = await fs.readFile(path.join(COLLAB_DIR, AUDIT_FILE), "utf-8");
+    return content.trim().split("\n").filter(Boolean).map(line => JSON.parse(line) as AuditEntry);
+  } catch {
+    return [];
+  }
+}
+
 // ============================================
 // Status/Reporting
 // ============================================
@@ -717,9 +3141,10 @@ export interface ProjectStructure {
 
 export async function getProjectStructure(
   rootDir: string = ".",
-  options: { includeSamples?: boolean; maxFiles?: number } = {}
+  options: { includeSamples?: boolean; maxFiles?: number; noIgnore?: boolean; signal?: AbortSignal } = {}
 ): Promise<ProjectStructure> {
-  const { includeSamples = false, maxFiles = 200 } = options;
+  const { includeSamples = false, maxFiles = 200, noIgnore, signal } = options;
+  signal?.throwIfAborted();
 
   // Check if trust.yaml already exists
   const trustPath = path.join(rootDir, COLLAB_DIR, TRUST_FILE);
@@ -734,6 +3159,7 @@ export async function getProjectStructure(
   // Get all files in the project
   const allFiles = await glob("**/*", {
     cwd: rootDir,
+    signal,
     ignore: [
       "**/node_modules/**",
       "**/.git/**",
@@ -753,8 +3179,13 @@ export async function getProjectStructure(
     nodir: true,
   });
 
-  // Limit files if needed
-  const files = allFiles.slice(0, maxFiles);
+  // Skip .gitignore'd and config-excluded files, then limit if needed
+  const isIgnored = await loadIgnoreFilter(rootDir, {
+    noIgnore,
+    exclude: (await loadCollabConfig()).exclude,
+    signal,
+  });
+  const files = allFiles.filter(f => !isIgnored(f)).slice(0, maxFiles);
 
   // Extract unique directories
   const directories = [...new Set(files.map(f => {
@@ -804,6 +3235,7 @@ export async function getProjectStructure(
   // Detect frameworks
   const frameworks: string[] = [];
   for (const configFile of configFiles) {
+    signal?.throwIfAborted();
     if (configFile.endsWith("package.json")) {
       try {
         const content = await fs.readFile(path.join(rootDir, configFile), "utf-8");
@@ -844,6 +3276,7 @@ export async function getProjectStructure(
     ).slice(0, 10);
 
     for (const file of sampleCandidates) {
+      signal?.throwIfAborted();
       try {
         const content = await fs.readFile(path.join(rootDir, file), "utf-8");
         // Get first 20 lines
@@ -1108,14 +3541,26 @@ async function detectProjectType(files: string[]): Promise<{
   };
 }
 
-export async function scanProject(rootDir: string = "."): Promise<ProjectScanResult> {
+export async function scanProject(
+  rootDir: string = ".",
+  options: { noIgnore?: boolean; signal?: AbortSignal } = {}
+): Promise<ProjectScanResult> {
+  const { noIgnore, signal } = options;
+  signal?.throwIfAborted();
+
   // Check if trust.yaml already exists
   const trustPath = path.join(rootDir, COLLAB_DIR, TRUST_FILE);
   const existingTrustFile = await fileExists(trustPath);
 
-  // Get all files in the project (excluding common ignore patterns)
-  const files = await glob("**/*", {
+  // Get all files in the project (excluding common, .gitignore'd, and configured patterns)
+  const isIgnored = await loadIgnoreFilter(rootDir, {
+    noIgnore,
+    exclude: (await loadCollabConfig()).exclude,
+    signal,
+  });
+  const allFiles = await glob("**/*", {
     cwd: rootDir,
+    signal,
     ignore: [
       "**/node_modules/**",
       "**/.git/**",
@@ -1130,6 +3575,7 @@ export async function scanProject(rootDir: string = "."): Promise<ProjectScanRes
     ],
     nodir: true,
   });
+  const files = allFiles.filter(f => !isIgnored(f));
 
   // Detect project type
   const { type, languages, frameworks } = await detectProjectType(files);
@@ -1142,7 +3588,12 @@ export async function scanProject(rootDir: string = "."): Promise<ProjectScanRes
   for (const pattern of COMMON_PATTERNS) {
     if (pattern.condition(files) && !addedPatterns.has(pattern.pattern)) {
       // Check if any files match this pattern
-      const matchingFiles = await glob(pattern.pattern, { cwd: rootDir, nodir: true, ignore: ["**/node_modules/**"] });
+      const matchingFiles = await glob(pattern.pattern, {
+        cwd: rootDir,
+        nodir: true,
+        ignore: ["**/node_modules/**"],
+        signal,
+      });
 
       // Always include certain critical patterns even if no files match yet
       const alwaysIncludePatterns = [
@@ -1256,7 +3707,10 @@ export async function initializeCollab(useProjectScan: boolean = true): Promise<
       version: "1.0",
       confidence_threshold: 0.7,
       auto_record_authorship: true,
-      model: "claude-opus-4"
+      model: "claude-opus-4",
+      suggest: {
+        auto_approve_trivial: false
+      }
     };
 
     await fs.writeFile(configPath, yaml.stringify(defaultConfig));
@@ -1314,7 +3768,10 @@ export async function initializeCollabWithPolicies(options: InitOptions = {}): P
       version: "1.0",
       confidence_threshold: 0.7,
       auto_record_authorship: true,
-      model: "claude-opus-4"
+      model: "claude-opus-4",
+      suggest: {
+        auto_approve_trivial: false
+      }
     };
     await fs.writeFile(configPath, yaml.stringify(defaultConfig));
   }
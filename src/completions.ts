@@ -0,0 +1,178 @@
+/**
+ * completions command for collab-claude-code
+ *
+ * Completion data for editor plugins writing @collab annotations. Given the
+ * annotation text up to the cursor, prints JSON listing what may come next:
+ * attribute keys and trust aliases where a new word starts, or values for the
+ * attribute being written. Trust levels, aliases, and owners come from the
+ * project's resolved config (config.yaml aliases and owners, trust.yaml and
+ * its extends sources), so completions use the repository's own vocabulary.
+ *
+ *   collab-claude-code completions --context='// @collab trust="SU'
+ *
+ * Exit codes:
+ *   0 = Completions printed (possibly none)
+ *   2 = Tool error (bad arguments, invalid config)
+ */
+
+import {
+  ANNOTATION_ATTRIBUTES,
+  TRUST_LEVELS,
+  TrustLevel,
+  comparePaths,
+  formatOwner,
+  loadOwnerRegistry,
+  loadTrustAliases,
+  ownerList,
+  stableStringify,
+} from "./collab.js";
+import { CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, loadTrustConfigStrict } from "./check.js";
+import { trustLevelSemantics } from "./reasons.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface Completion {
+  value: string; // Text that replaces `prefix`
+  kind: "attribute" | "alias" | "trust" | "owner" | "owner_group" | "boolean";
+  detail?: string;
+}
+
+export interface CompletionResult {
+  context: string;
+  attribute?: string; // Set when completing a value
+  prefix: string; // The partial word the completions replace
+  completions: Completion[];
+}
+
+export interface CompletionVocabulary {
+  aliases: Record<string, TrustLevel>;
+  owners: string[];
+  owner_groups: string[][]; // Co-owner lists named in trust.yaml
+}
+
+// ============================================
+// Vocabulary
+// ============================================
+
+export async function loadCompletionVocabulary(): Promise<CompletionVocabulary> {
+  const config = await loadTrustConfigStrict();
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+
+  const registry = await loadOwnerRegistry().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+
+  const owners = new Set<string>(Object.keys(registry ?? {}));
+  const groups = new Map<string, string[]>();
+  for (const { owner } of [...config.policies, ...(config.symbols ?? [])]) {
+    const names = ownerList(owner);
+    names.forEach(name => owners.add(name));
+    if (names.length > 1) groups.set(formatOwner(names)!, names);
+  }
+
+  return {
+    aliases,
+    owners: [...owners].sort(comparePaths),
+    owner_groups: [...groups.values()].sort((a, b) => comparePaths(a.join(), b.join())),
+  };
+}
+
+// ============================================
+// Completion
+// ============================================
+
+// key=value (or key: value) pairs already written, so their keys are not offered again
+const WRITTEN_KEY_REGEX = /([\p{L}\p{N}_]+)[=:]/gu;
+
+// The attribute whose value the cursor is in, and the part of it written so far
+const OPEN_VALUE_REGEX = /([\p{L}\p{N}_]+)(?:=|:[ \t]*)(\[(?:[^\]]*,)?\s*)?(["']?)([^"'\s,\]]*)$/u;
+
+export function completeAnnotation(context: string, vocabulary: CompletionVocabulary): CompletionResult {
+  const at = context.lastIndexOf("@collab");
+  const text = at === -1 ? context : context.slice(at).replace(/^@collab(?::begin|:end)?/, "");
+  const semantics = new Map(trustLevelSemantics().map(s => [s.level, s.description]));
+  const matching = (prefix: string, candidates: Completion[]) =>
+    candidates.filter(c => c.value.toLowerCase().startsWith(prefix.toLowerCase()));
+
+  const open = OPEN_VALUE_REGEX.exec(text);
+  if (open) {
+    const [, attribute, list, , prefix] = open;
+    let candidates: Completion[] = [];
+    switch (attribute) {
+      case "trust":
+        candidates = TRUST_LEVELS.map(level => ({ value: level, kind: "trust" as const, detail: semantics.get(level) }));
+        break;
+      case "owner":
+        candidates = vocabulary.owners.map(owner => ({ value: owner, kind: "owner" as const }));
+        // A whole group only fits where the list has not been opened yet
+        if (list === undefined && prefix === "") {
+          candidates.push(
+            ...vocabulary.owner_groups.map(group => ({
+              value: `[${group.map(o => `"${o}"`).join(", ")}]`,
+              kind: "owner_group" as const,
+            }))
+          );
+        }
+        break;
+      case "redact":
+      case "requires_tests":
+        candidates = ["true", "false"].map(value => ({ value, kind: "boolean" as const }));
+        break;
+    }
+    return { context, attribute, prefix, completions: matching(prefix, candidates) };
+  }
+
+  // Free text inside an unclosed quoted value, such as intent="...
+  const prefix = /(\S*)$/.exec(text)![1];
+  if ((text.match(/"/g) ?? []).length % 2 === 1 || (text.match(/'/g) ?? []).length % 2 === 1) {
+    return { context, prefix, completions: [] };
+  }
+
+  // Completing a new word: an attribute key or a trust alias
+  const written = new Set([...text.matchAll(WRITTEN_KEY_REGEX)].map(m => m[1]));
+  const candidates: Completion[] = [
+    ...ANNOTATION_ATTRIBUTES.filter(key => !written.has(key)).map(key => ({ value: `${key}=`, kind: "attribute" as const })),
+    ...Object.entries(vocabulary.aliases)
+      .sort(([a], [b]) => comparePaths(a, b))
+      .map(([alias, level]) => ({ value: alias, kind: "alias" as const, detail: level })),
+  ];
+  return { context, prefix, completions: matching(prefix, candidates) };
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runCompletions(args: string[]): Promise<number> {
+  let context: string | undefined;
+
+  for (const arg of args) {
+    if (arg.startsWith("--context=")) {
+      context = arg.slice("--context=".length);
+    } else if (arg === "--format=json") {
+      // JSON is the only format
+    } else {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    }
+  }
+
+  if (context === undefined) {
+    console.error("Usage: collab-claude-code completions --context=<annotation text before the cursor>");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const result = completeAnnotation(context, await loadCompletionVocabulary());
+    console.log(stableStringify(result, 2));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,170 @@
+/**
+ * constraints command for collab-claude-code
+ *
+ * Lists every constraint declared anywhere in the repo with the regions that
+ * declare it, so a team can see which constraints are in use, spot near
+ * duplicates worded differently, and pick the common ones to write verifiers
+ * for:
+ *
+ *   collab-claude-code constraints [--format=text|json] [--no-ignore] [paths...]
+ *
+ * Identical constraint text (ignoring surrounding whitespace) on several
+ * regions is one entry. Only the annotation that declares a constraint is
+ * listed, not the nested regions that inherit it.
+ *
+ * Exit codes:
+ *   0 = Inventory printed
+ *   2 = Tool error (unreadable file, invalid config)
+ */
+
+import * as fs from "fs/promises";
+
+import {
+  ParsedAnnotation,
+  TrustLevel,
+  comparePaths,
+  formatOwner,
+  formatRange,
+  loadTrustAliases,
+  parseAnnotationContent,
+  rangeOf,
+  stableStringify,
+} from "./collab.js";
+import { CheckOptions, CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, expandPaths } from "./check.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface AnnotatedFile {
+  file: string;
+  annotations: ParsedAnnotation[];
+}
+
+export interface DeclaringRegion {
+  file: string;
+  line_start: number;
+  line_end: number;
+  trust?: TrustLevel;
+  owner?: string | string[];
+}
+
+export interface ConstraintEntry {
+  constraint: string;
+  regions: DeclaringRegion[];
+}
+
+export interface ConstraintInventory {
+  checked_files: number;
+  constraints: ConstraintEntry[];
+}
+
+// ============================================
+// Inventory
+// ============================================
+
+/**
+ * Group the constraints declared by `files` by their text. Entries are sorted
+ * with the most widely declared first, then alphabetically; each entry's
+ * regions are in file and line order.
+ */
+export function allConstraints(files: AnnotatedFile[]): ConstraintEntry[] {
+  const declared = new Map<string, DeclaringRegion[]>();
+  for (const { file, annotations } of files) {
+    for (const annotation of annotations) {
+      // A constraint listed twice on one annotation is still one region
+      for (const constraint of new Set((annotation.constraints ?? []).map(c => c.trim()).filter(Boolean))) {
+        const region: DeclaringRegion = { file, line_start: annotation.line_start, line_end: annotation.line_end };
+        if (annotation.trust) region.trust = annotation.trust;
+        if (annotation.owner) region.owner = annotation.owner;
+        declared.set(constraint, [...(declared.get(constraint) ?? []), region]);
+      }
+    }
+  }
+
+  return [...declared]
+    .map(([constraint, regions]) => ({
+      constraint,
+      regions: regions.sort((a, b) => comparePaths(a.file, b.file) || a.line_start - b.line_start),
+    }))
+    .sort((a, b) => b.regions.length - a.regions.length || comparePaths(a.constraint, b.constraint));
+}
+
+export async function constraintInventory(paths: string[], options: CheckOptions = {}): Promise<ConstraintInventory> {
+  const { signal } = options;
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+  const files = await expandPaths(paths, options);
+
+  const annotated: AnnotatedFile[] = [];
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+    annotated.push({ file, annotations: parseAnnotationContent(content, file, { aliases }).annotations });
+  }
+
+  return { checked_files: files.length, constraints: allConstraints(annotated) };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatConstraintsText(inventory: ConstraintInventory): string {
+  const lines: string[] = [];
+  for (const { constraint, regions } of inventory.constraints) {
+    lines.push(`"${constraint}" (${regions.length} region(s)):`);
+    for (const region of regions) {
+      const range = formatRange(rangeOf({ line_start: region.line_start, line_end: region.line_end }), region.file);
+      const owner = formatOwner(region.owner);
+      lines.push(`  ${range}  ${region.trust ?? "-"}${owner ? `  ${owner}` : ""}`);
+    }
+  }
+
+  const regions = inventory.constraints.reduce((sum, c) => sum + c.regions.length, 0);
+  lines.push(
+    `Found ${inventory.constraints.length} distinct constraint(s) declared on ${regions} region(s) ` +
+      `in ${inventory.checked_files} file(s)`
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runConstraints(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const inventory = await constraintInventory(paths, { noIgnore });
+    console.log(format === "json" ? stableStringify(inventory, 2) : formatConstraintsText(inventory));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
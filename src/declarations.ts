@@ -0,0 +1,389 @@
+/**
+ * Declaration lookup
+ *
+ * Resolves trust for a function, method, type, or Go package-level variable
+ * or constant by name rather than by line, for agents that work at the symbol
+ * level:
+ *
+ *   resolveByDeclaration(config, "PaymentService.ProcessPayment", "src/pay.go")
+ *
+ * Methods are named `Type.Method` (Go receivers, classes, Rust impl blocks,
+ * Ruby classes and modules); `Type::method` and `Type#method` are accepted too.
+ * Nested types are qualified by their enclosing ones (`Outer.Inner.method`),
+ * and a name matches any declaration it is a dotted suffix of, so a bare name
+ * matches every declaration with that name. Declarations are found
+ * with per-language patterns, and their span is the scope an annotation
+ * directly above them would govern. Each name in a Go `var (` or `const (`
+ * group is a declaration of its own lines; the group itself is not one.
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import { glob } from "glob";
+import {
+  ANNOTATABLE_EXTENSIONS,
+  ParsedAnnotation,
+  TrustConfig,
+  TrustResult,
+  detectDeclarationScope,
+  formatRange,
+  getTrustLevelWithAnnotations,
+  loadCollabConfig,
+  parseAnnotations,
+  rangeOf,
+  sourceSyntaxError,
+} from "./collab.js";
+import { loadIgnoreFilter } from "./ignore.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface Declaration {
+  file: string;
+  name: string;
+  qualified_name: string; // "Type.Method" for methods, otherwise the name; always "." separated
+  line_start: number;
+  line_end: number;
+}
+
+export type ASTIdentityResult = { identity: string } | { error: string };
+
+export interface DeclarationTrust extends TrustResult {
+  declaration: Declaration;
+  annotation?: ParsedAnnotation; // The annotation that set the level, if any
+}
+
+interface RawDeclaration {
+  name: string;
+  container?: string; // Set when the syntax names the type, e.g. Go receivers
+  line: number; // 0-indexed
+  isContainer?: boolean; // Classes, impl blocks, modules: methods inside belong to it
+  isGroup?: boolean; // A Go `var (` or `const (` group: not a declaration, each name inside it is
+}
+
+// ============================================
+// Language Patterns
+// ============================================
+
+const CONTROL_KEYWORDS = new Set([
+  "if", "for", "while", "switch", "catch", "return", "new", "throw", "else", "case",
+  "do", "try", "function", "typeof", "await", "super", "this", "synchronized",
+]);
+
+// Identifiers are Unicode letters, combining marks, and digits; `\w` alone is ASCII-only
+function matchGo(line: string, index: number, inType: boolean, inGroup: boolean): RawDeclaration[] {
+  // Each name a package-level var or const declares, including every name in a group; never `_`
+  const names = (list: string) =>
+    list.split(",").map(name => name.trim()).filter(name => name !== "_").map(name => ({ name, line: index }));
+  if (inGroup) {
+    const member = line.match(/^\s+([\p{L}\p{M}\p{N}_]+(?:\s*,\s*[\p{L}\p{M}\p{N}_]+)*)(?=[\s=]|$)/u);
+    return member ? names(member[1]) : [];
+  }
+  // An embedded field is named after its type without package or pointer: `*jwt.Claims` is Claims
+  if (inType) {
+    const embedded = line.match(
+      /^\s+\*?(?:[\p{L}\p{M}\p{N}_]+\.)?([\p{L}\p{M}\p{N}_]+)(?:\[[^\]]*\])?\s*(?:`[^`]*`|"[^"]*")?\s*(?:\/\/.*)?$/u
+    );
+    return embedded ? [{ name: embedded[1], line: index }] : [];
+  }
+  const method = line.match(/^func\s*\(\s*(?:[\p{L}\p{M}\p{N}_]+\s+)?\*?\s*([\p{L}\p{M}\p{N}_]+)(?:\[[^\]]*\])?\s*\)\s*([\p{L}\p{M}\p{N}_]+)/u);
+  if (method) return [{ name: method[2], container: method[1], line: index }];
+  const func = line.match(/^func\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (func) return [{ name: func[1], line: index }];
+  const type = line.match(/^type\s+([\p{L}\p{M}\p{N}_]+)/u);
+  // Struct and interface bodies hold embedded fields and interfaces, named Type.Field
+  if (type) return [{ name: type[1], line: index, isContainer: /\b(?:struct|interface)\s*\{\s*(?:\/\/.*)?$/.test(line) }];
+  if (/^(?:var|const)\s*\(\s*(?:\/\/.*)?$/.test(line)) return [{ name: "", line: index, isGroup: true }];
+  const value = line.match(/^(?:var|const)\s+([\p{L}\p{M}\p{N}_]+(?:\s*,\s*[\p{L}\p{M}\p{N}_]+)*)/u);
+  if (value) return names(value[1]);
+  return [];
+}
+
+function matchPython(line: string, index: number): RawDeclaration[] {
+  const cls = line.match(/^\s*class\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (cls) return [{ name: cls[1], line: index, isContainer: true }];
+  const def = line.match(/^\s*(?:async\s+)?def\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (def) return [{ name: def[1], line: index }];
+  return [];
+}
+
+function matchRuby(line: string, index: number): RawDeclaration[] {
+  const cls = line.match(/^\s*(?:class|module)\s+([\p{L}\p{M}\p{N}_:]+)/u);
+  if (cls) return [{ name: cls[1], line: index, isContainer: true }];
+  const def = line.match(/^\s*def\s+(?:self\.)?([\p{L}\p{M}\p{N}_?!=]+)/u);
+  if (def) return [{ name: def[1], line: index }];
+  return [];
+}
+
+function matchRust(line: string, index: number): RawDeclaration[] {
+  const impl = line.match(/^\s*(?:unsafe\s+)?impl\b(?:<[^{]*?>)?\s+(?:[\p{L}\p{M}\p{N}_:]+(?:<[^{]*?>)?\s+for\s+)?(?:[\p{L}\p{M}\p{N}_]+::)*([\p{L}\p{M}\p{N}_]+)/u);
+  if (impl) return [{ name: impl[1], line: index, isContainer: true }];
+  const trait = line.match(/^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (trait) return [{ name: trait[1], line: index, isContainer: true }];
+  const fn = line.match(/^\s*(?:pub(?:\([^)]*\))?\s+)?(?:(?:const|async|unsafe)\s+|extern\s+"[^"]*"\s+)*fn\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (fn) return [{ name: fn[1], line: index }];
+  const type = line.match(/^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|type|union)\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (type) return [{ name: type[1], line: index }];
+  return [];
+}
+
+function matchJava(line: string, index: number): RawDeclaration[] {
+  const type = line.match(/\b(?:class|interface|enum|record)\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (type && !/^\s*(?:\/\/|\*)/.test(line)) return [{ name: type[1], line: index, isContainer: true }];
+  if (/;\s*$/.test(line) || /^\s*(?:return|new|throw|else|case)\b/.test(line)) return [];
+  const method = line.match(
+    /^\s*(?:@[\p{L}\p{M}\p{N}_]+(?:\([^)]*\))?\s+)*(?:(?:public|protected|private|static|final|abstract|synchronized|native|default|strictfp)\s+)*(?:<[^>]+>\s+)?(?:[\p{L}\p{M}\p{N}_<>[\],.?]+\s+)?([\p{L}\p{M}\p{N}_]+)\s*\(/u
+  );
+  if (method && !CONTROL_KEYWORDS.has(method[1])) return [{ name: method[1], line: index }];
+  return [];
+}
+
+function matchTypeScript(line: string, index: number, inClass: boolean): RawDeclaration[] {
+  const cls = line.match(/^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+([\p{L}\p{M}\p{N}_]+)/u);
+  if (cls) return [{ name: cls[1], line: index, isContainer: true }];
+  // Overload signatures and ambient declarations end in ";"
+  if (/;\s*$/.test(line)) return [];
+  const func = line.match(/^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([\p{L}\p{M}\p{N}_]+)/u);
+  if (func) return [{ name: func[1], line: index }];
+  const arrow = line.match(
+    /^\s*(?:export\s+)?(?:const|let|var)\s+([\p{L}\p{M}\p{N}_]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[\p{L}\p{M}\p{N}_]+\s*=>)/u
+  );
+  if (arrow) return [{ name: arrow[1], line: index }];
+  if (inClass) {
+    const method = line.match(
+      /^\s*(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?\s*(#?[\p{L}\p{M}\p{N}_]+)\s*(?:<[^>]*>)?\s*(?:\(|=\s*(?:async\s+)?\()/u
+    );
+    if (method && !CONTROL_KEYWORDS.has(method[1])) return [{ name: method[1], line: index }];
+  }
+  return [];
+}
+
+function isCommentLine(line: string): boolean {
+  const trimmed = line.trim();
+  return trimmed.startsWith("//") || trimmed.startsWith("#") || trimmed.startsWith("/*") || /^\*(?:\s|\/|$)/.test(trimmed);
+}
+
+// ============================================
+// Declaration Finding
+// ============================================
+
+// NFC so that "Größe" typed by the caller matches a file saved with decomposed umlauts
+export function normalizeDeclarationName(name: string): string {
+  return name.normalize("NFC").trim().replace(/::|#/g, ".");
+}
+
+function indentOf(line: string): number {
+  return line.length - line.trimStart().length;
+}
+
+// Ruby blocks close with `end`, which annotation scoping does not track; use indentation
+function rubySpan(lines: string[], index: number): { start: number; end: number } {
+  const indent = indentOf(lines[index]);
+  for (let i = index + 1; i < lines.length; i++) {
+    if (lines[i].trim() !== "" && indentOf(lines[i]) <= indent) {
+      return { start: index + 1, end: lines[i].trim() === "end" ? i + 1 : i };
+    }
+  }
+  return { start: index + 1, end: lines.length };
+}
+
+export function findDeclarations(content: string, filePath: string): Declaration[] {
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  const ext = path.extname(filePath).toLowerCase().slice(1);
+  const spanOf = (index: number) =>
+    ext === "rb" ? rubySpan(lines, index) : detectDeclarationScope(lines, index, filePath);
+
+  const containers: { name: string; start: number; end: number }[] = [];
+  const bodies: { start: number; end: number }[] = [];
+  const groups: { start: number; end: number }[] = [];
+  const found: Declaration[] = [];
+
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i];
+    // Local functions and closures are not addressable by name
+    if (isCommentLine(line) || bodies.some(b => b.start - 1 < i && i < b.end)) continue;
+
+    // Innermost container whose body holds this line
+    const container = containers.filter(c => c.start - 1 < i && i < c.end).pop();
+
+    let matches: RawDeclaration[];
+    switch (ext) {
+      case "go": {
+        const inGroup = groups.some(g => g.start - 1 < i && i < g.end);
+        matches = matchGo(line, i, container !== undefined, inGroup);
+        break;
+      }
+      case "py": matches = matchPython(line, i); break;
+      case "rb": matches = matchRuby(line, i); break;
+      case "rs": matches = matchRust(line, i); break;
+      case "java": matches = matchJava(line, i); break;
+      case "ts": case "tsx": case "js": case "jsx": case "mjs": case "cjs":
+        matches = matchTypeScript(line, i, container !== undefined);
+        break;
+      default: matches = [];
+    }
+
+    for (const match of matches) {
+      const span = spanOf(match.line);
+      if (match.isGroup) {
+        groups.push(span);
+        continue;
+      }
+      const owner = match.container ?? container?.name;
+      const qualified_name = normalizeDeclarationName(owner ? `${owner}.${match.name}` : match.name);
+      found.push({
+        file: filePath,
+        name: match.name,
+        qualified_name,
+        line_start: span.start,
+        line_end: span.end,
+      });
+      if (match.isContainer) {
+        containers.push({ name: qualified_name, ...span });
+      } else {
+        bodies.push(span);
+      }
+    }
+  }
+
+  return found;
+}
+
+// ============================================
+// Trust Resolution
+// ============================================
+
+// "open" and "Book.open" both match "Acct.Book.open"
+export function matchesDeclaration(declaration: Declaration, query: string): boolean {
+  return declaration.qualified_name === query || declaration.qualified_name.endsWith("." + query);
+}
+
+async function candidateFiles(rootDir: string, signal?: AbortSignal): Promise<string[]> {
+  const isIgnored = await loadIgnoreFilter(rootDir, {
+    exclude: (await loadCollabConfig()).exclude,
+    signal,
+  });
+  const files = await glob(`**/*.{${ANNOTATABLE_EXTENSIONS.join(",")}}`, {
+    cwd: rootDir,
+    nodir: true,
+    signal,
+    ignore: ["**/node_modules/**", "**/.git/**", "**/dist/**", "**/build/**", "**/target/**", "**/vendor/**"],
+  });
+  return files.filter(f => !isIgnored(f)).sort().map(f => path.join(rootDir, f));
+}
+
+/**
+ * Find every declaration named `declName`, in `filePath` if given, otherwise
+ * anywhere in the project.
+ */
+export async function findDeclarationsByName(
+  declName: string,
+  filePath?: string,
+  options: { rootDir?: string; signal?: AbortSignal } = {}
+): Promise<Declaration[]> {
+  const query = normalizeDeclarationName(declName);
+  const files = filePath ? [filePath] : await candidateFiles(options.rootDir ?? ".", options.signal);
+  const simpleName = query.split(".").pop() ?? query;
+
+  const matches: Declaration[] = [];
+  for (const file of files) {
+    options.signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, "utf-8");
+    } catch {
+      if (filePath) throw new Error(`Cannot read ${filePath}`);
+      continue;
+    }
+    if (!content.normalize("NFC").includes(simpleName)) continue;
+    matches.push(...findDeclarations(content, file).filter(d => matchesDeclaration(d, query)));
+  }
+  return matches;
+}
+
+/**
+ * Resolve the trust level governing a whole declaration. The result is the
+ * same as checking the declaration's line range, plus the declaration and the
+ * annotation that decided it. Throws if the name matches nothing, or matches
+ * more than one declaration; pass a file or a `Type.Method` name to narrow it.
+ */
+export async function resolveByDeclaration(
+  config: TrustConfig,
+  declName: string,
+  filePath?: string,
+  options: { rootDir?: string; signal?: AbortSignal } = {}
+): Promise<DeclarationTrust> {
+  const matches = await findDeclarationsByName(declName, filePath, options);
+
+  if (matches.length === 0) {
+    throw new Error(`Declaration ${declName} not found${filePath ? ` in ${filePath}` : ""}`);
+  }
+  if (matches.length > 1) {
+    const candidates = matches.map(d => `${formatRange(rangeOf({ line: d.line_start }), d.file)} (${d.qualified_name})`).join(", ");
+    const hint = filePath ? "use a Type.Method name" : "pass a file or use a Type.Method name";
+    throw new Error(`Ambiguous declaration ${declName}: matches ${candidates}; ${hint}`);
+  }
+
+  const declaration = matches[0];
+  const trust = await getTrustLevelWithAnnotations(config, declaration.file, declaration.line_start, declaration.line_end);
+  const annotation = trust.source === "annotation"
+    ? (await parseAnnotations(declaration.file)).find(
+        a => a.trust && declaration.line_start <= a.line_end && declaration.line_end >= a.line_start
+      )
+    : undefined;
+
+  return { ...trust, declaration, annotation };
+}
+
+// ============================================
+// AST Identity
+// ============================================
+
+const GO_PACKAGE_REGEX = /^package\s+([\p{L}\p{M}\p{N}_]+)/mu;
+
+/**
+ * The identity of a Go region by where it sits in the syntax tree: package,
+ * then type, then member, e.g. `billing.Invoice.Total`. It stays the same
+ * when the region's code is reformatted, its signature changes, or unrelated
+ * siblings are renamed or moved, but not when the symbol itself is renamed.
+ * A region spanning several declarations (a block annotation) is identified
+ * by all of its outermost ones, `billing.Sign+billing.Verify`, so renaming
+ * any of them changes it. `var (` and `const (` groups have none: adding a
+ * name to the group would change it.
+ *
+ * Returns the reason instead when the file is not Go, does not tokenize, or
+ * the region does not start at a declaration (a named struct field, say).
+ * Pass `declarations` when identifying many regions of the same content.
+ */
+export function astIdentity(
+  filePath: string,
+  content: string,
+  region: { line_start: number; line_end: number },
+  declarations?: Declaration[]
+): ASTIdentityResult {
+  if (path.extname(filePath).toLowerCase() !== ".go") return { error: "AST identities are only supported for Go" };
+  const syntaxError = sourceSyntaxError(content, filePath);
+  if (syntaxError) return { error: syntaxError };
+  const pkg = GO_PACKAGE_REGEX.exec(content.normalize("NFC"))?.[1];
+  if (!pkg) return { error: "no package clause" };
+
+  const inside = (declarations ?? findDeclarations(content, filePath)).filter(
+    d => region.line_start <= d.line_start && d.line_end <= region.line_end
+  );
+  // Members of a struct or interface in the region are part of its type's identity
+  const outermost = inside.filter(
+    d => !inside.some(o => o !== d && o.line_start <= d.line_start && d.line_end <= o.line_end &&
+      d.qualified_name.startsWith(o.qualified_name + "."))
+  );
+  // Only comments and blank lines may come before the first one
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  const lead = outermost.length > 0 ? lines.slice(region.line_start - 1, outermost[0].line_start - 1) : [];
+  if (lead.some(l => /^(?:var|const)\s*\(/.test(l))) {
+    return { error: "var and const groups have no AST identity" };
+  }
+  if (outermost.length === 0 || lead.some(l => l.trim() !== "" && !isCommentLine(l))) {
+    return { error: `no declaration starts on line ${region.line_start}` };
+  }
+  return { identity: outermost.map(d => `${pkg}.${d.qualified_name}`).join("+") };
+}
@@ -0,0 +1,977 @@
+/**
+ * Annotation diff between git revisions
+ *
+ * Reports governance changes so they get reviewed even when no code changed:
+ * regions added and removed, trust and owner changes, and the change in
+ * governed line coverage. Renames are detected by git, so a moved file is
+ * compared against its old path, and Go regions are paired by their place in
+ * the syntax tree, so a reformatted or re-signed function is the same region. Downgrades (more permissive), removed
+ * protections, and removed owners are flagged high priority, as are edits to
+ * requires_tests="true" regions that change none of the file's tests, and
+ * edits to READ_ONLY regions of vendored code, which drift from upstream.
+ * Logic moved out of a READ_ONLY Go function into a new, editable helper it
+ * now calls is reported as a normal-priority warning.
+ *
+ * .collab/trust.yaml is compared too, as resolved with its extends: the
+ * default and generated/test levels, `policies`, `symbols`, and `regions`
+ * entries, and profile overrides. Loosening any of them is high priority,
+ * like loosening an annotation.
+ *
+ * An owner change on a region that keeps an owner is also listed as an
+ * ownership transfer, with the owners it passed from and to. `--notify` hands
+ * each transfer to the transfer hooks, so the previous owners can be told.
+ *
+ * Exit codes follow the check command:
+ *   0 = No high-priority changes
+ *   1 = High-priority changes found
+ *   2 = Tool error (bad arguments, unknown revision)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+
+import {
+  ANNOTATION_ATTRIBUTES,
+  COLLAB_DIR,
+  Owner,
+  ParsedAnnotation,
+  TrustConfig,
+  TrustLevel,
+  TRUST_FILE,
+  TRUST_LEVELS,
+  TRUST_RESTRICTIVENESS,
+  formatOwner,
+  formatRange,
+  generatedTrustLevel,
+  isVendoredPath,
+  ownerList,
+  parseAnnotationContent,
+  loadCollabConfig,
+  loadTrustAliases,
+  rangeOf,
+  resolveTrustWithAnnotations,
+  sourceSyntaxError,
+} from "./collab.js";
+import { ChangedFile, listChangedFilesWithRenames, listChangedLines, readFileAtRevision } from "./git.js";
+import { EXIT_CLEAN, EXIT_VIOLATIONS, EXIT_TOOL_ERROR, loadTrustConfigStrict } from "./check.js";
+import { Declaration, astIdentity, findDeclarations } from "./declarations.js";
+import { runTransferHooks } from "./transfer-hooks.js";
+
+// ============================================
+// Types
+// ============================================
+
+export type TrustChangeKind =
+  | "upgrade"        // More restrictive
+  | "downgrade"      // More permissive
+  | "added"          // Trust level added to an existing annotation
+  | "removed"        // Trust level removed from an existing annotation
+  | "region_added"   // New annotation
+  | "region_removed" // Annotation deleted
+  | "owner_added"
+  | "owner_removed"
+  | "owner_changed"
+  | "tests_missing" // A requires_tests region changed without its tests
+  | "vendored_edit" // Code changed inside a READ_ONLY region of a vendored library
+  | "possible_extraction" // A READ_ONLY function shrank and calls a new permissive helper
+  | "policy_added"   // New trust.yaml policy, symbol policy, or region entry
+  | "policy_removed"; // trust.yaml entry deleted
+
+export interface TrustChange {
+  file: string;
+  line: number; // Line in head, or in base for removals; 0 for trust.yaml changes
+  symbol: string; // First line of the annotated code, or the trust.yaml setting: policies["src/pay/**"]
+  kind: TrustChangeKind;
+  priority: "high" | "normal";
+  renamed_from?: string; // Base path when the file was renamed
+  trust_before?: TrustLevel;
+  trust_after?: TrustLevel;
+  owner_before?: string;
+  owner_after?: string;
+  owners_before?: string[]; // The region's owners in base, for downgrades, removals, and owner changes
+  expected_tests?: string[]; // For tests_missing: the patterns a changed test file had to match
+  changed_lines?: { line_start: number; line_end: number }[]; // For vendored_edit: head lines changed in the region
+  // For possible_extraction: the locked function's code lines before and after, and the new helper
+  lines_before?: number;
+  lines_after?: number;
+  helper?: { file: string; line: number; name: string; trust: TrustLevel };
+  transfer?: { from: string[]; to: string[]; trust?: TrustLevel }; // For owner_changed: see OwnershipTransfer
+}
+
+// A region that changed hands: owner_changed, seen from the owners' side
+export interface OwnershipTransfer {
+  file: string;
+  line: number; // Line in head
+  symbol: string;
+  renamed_from?: string;
+  trust?: TrustLevel; // The region's trust level in head
+  owner_before: string;
+  owner_after: string;
+  from: string[]; // Owners in base that no longer own the region; the ones to notify
+  to: string[]; // Owners in head that did not own it in base
+  contacts?: Owner[]; // Records for `from` and `to` when config.yaml has an owner registry, for routing notifications
+}
+
+export interface GovernanceCoverage {
+  governed_lines: number; // Lines inside at least one annotation
+  total_lines: number;
+}
+
+export interface AnnotationDiffReport {
+  base: string;
+  head: string; // "WORKTREE" when comparing against uncommitted files
+  files_compared: number;
+  renames: { from: string; to: string }[];
+  // Over the compared files only; unchanged files do not affect the delta
+  coverage: { before: GovernanceCoverage; after: GovernanceCoverage; delta_lines: number };
+  summary: {
+    regions_added: number;
+    regions_removed: number;
+    trust_changes: number;
+    owner_changes: number;
+    policy_changes: number; // Changes of any kind to trust.yaml
+    tests_missing: number;
+    vendored_edits: number;
+    possible_extractions: number;
+    ownership_transfers: number;
+    high_priority: number;
+  };
+  changes: TrustChange[];
+  transfers: OwnershipTransfer[]; // In change order
+}
+
+// One attribute that differs between two versions of a region
+export interface AttributeChange {
+  attribute: string; // One of ANNOTATION_ATTRIBUTES
+  before?: unknown;
+  after?: unknown;
+}
+
+export interface ChangedAnnotation {
+  key: string;
+  before: ParsedAnnotation;
+  after: ParsedAnnotation;
+  changes: AttributeChange[];
+}
+
+export interface AnnotationDelta {
+  added: { key: string; annotation: ParsedAnnotation }[];
+  removed: { key: string; annotation: ParsedAnnotation }[];
+  changed: ChangedAnnotation[];
+  unchanged: number; // Paired regions whose attributes all match
+}
+
+/**
+ * The identity of a region, the same in both versions when it is the same
+ * region. `version` says which set the annotation comes from, for keys that
+ * read each version's source.
+ */
+export type AnnotationKey = (annotation: ParsedAnnotation, version: "before" | "after") => string;
+
+export interface DiffAnnotationSetsOptions {
+  key?: AnnotationKey; // Default: the region's first line number
+}
+
+// ============================================
+// Diffing
+// ============================================
+
+/**
+ * Key regions by the first line of the code they govern, trimmed, so that
+ * line shifts elsewhere in the file do not break the pairing. `diff` uses it
+ * where astKey has no identity.
+ */
+export function symbolKey(beforeContent: string | null, afterContent: string | null): AnnotationKey {
+  const split = (content: string | null) => (content ?? "").replace(/\r\n/g, "\n").split("\n");
+  const lines = { before: split(beforeContent), after: split(afterContent) };
+  return (annotation, version) => (lines[version][annotation.line_start - 1] ?? "").trim();
+}
+
+/**
+ * Key Go regions by their AST identity (see astIdentity), so reformatting a
+ * region or changing its signature still pairs it, and other regions by
+ * symbolKey. When either version does not tokenize, every region of the file
+ * is keyed by symbolKey, so the two versions' keys stay comparable. This is
+ * the key `diff` uses.
+ */
+export function astKey(filePath: string, beforeContent: string | null, afterContent: string | null): AnnotationKey {
+  const fallback = symbolKey(beforeContent, afterContent);
+  const contents = { before: beforeContent, after: afterContent };
+  const declarations: Partial<Record<"before" | "after", Declaration[]>> = {};
+  const tokenizes = (content: string | null) => content === null || sourceSyntaxError(content, filePath) === undefined;
+  const useIdentity = filePath.endsWith(".go") && tokenizes(beforeContent) && tokenizes(afterContent);
+  return (annotation, version) => {
+    const content = contents[version];
+    if (content === null || !useIdentity) return fallback(annotation, version);
+    declarations[version] ??= findDeclarations(content, filePath);
+    const result = astIdentity(filePath, content, annotation, declarations[version]);
+    // Prefixed so an identity never pairs with a first line that happens to read the same
+    return "identity" in result ? `ast:${result.identity}` : fallback(annotation, version);
+  };
+}
+
+const lineKey: AnnotationKey = annotation => String(annotation.line_start);
+
+/**
+ * Compare two sets of annotations for the same file. Regions are paired by
+ * `options.key`: equal keys pair up in order, first with first, so duplicate
+ * keys (two functions with the same first line) are matched by position among
+ * themselves. Without a key, regions pair only if they start on the same line;
+ * pass symbolKey when both sources are at hand, or a key built from IDs the
+ * caller already tracks. Paired regions are compared attribute by attribute;
+ * notes and line numbers are not governance and are not compared.
+ */
+export function diffAnnotationSets(
+  before: ParsedAnnotation[],
+  after: ParsedAnnotation[],
+  options: DiffAnnotationSetsOptions = {}
+): AnnotationDelta {
+  const key = options.key ?? lineKey;
+
+  const unmatched = new Map<string, ParsedAnnotation[]>();
+  for (const annotation of before) {
+    const k = key(annotation, "before");
+    unmatched.set(k, [...(unmatched.get(k) ?? []), annotation]);
+  }
+
+  const delta: AnnotationDelta = { added: [], removed: [], changed: [], unchanged: 0 };
+  for (const annotation of after) {
+    const k = key(annotation, "after");
+    const previous = unmatched.get(k)?.shift();
+    if (!previous) {
+      delta.added.push({ key: k, annotation });
+      continue;
+    }
+
+    const changes: AttributeChange[] = [];
+    for (const attribute of ANNOTATION_ATTRIBUTES) {
+      const was = previous[attribute as keyof ParsedAnnotation];
+      const now = annotation[attribute as keyof ParsedAnnotation];
+      if (JSON.stringify(was) !== JSON.stringify(now)) {
+        changes.push({ attribute, before: was, after: now });
+      }
+    }
+    if (changes.length > 0) {
+      delta.changed.push({ key: k, before: previous, after: annotation, changes });
+    } else {
+      delta.unchanged++;
+    }
+  }
+
+  for (const [k, queue] of unmatched) {
+    for (const annotation of queue) delta.removed.push({ key: k, annotation });
+  }
+  return delta;
+}
+
+function parseVersion(content: string | null, filePath: string, aliases: Record<string, TrustLevel>): ParsedAnnotation[] {
+  return content === null ? [] : parseAnnotationContent(content, filePath, { aliases }).annotations;
+}
+
+function compareTrust(
+  file: string,
+  symbol: string,
+  line: number,
+  before: TrustLevel | undefined,
+  after: TrustLevel | undefined
+): TrustChange | null {
+  if (before === after) return null;
+
+  if (before && after) {
+    const downgrade = TRUST_RESTRICTIVENESS[after] < TRUST_RESTRICTIVENESS[before];
+    return {
+      file, line, symbol,
+      kind: downgrade ? "downgrade" : "upgrade",
+      priority: downgrade ? "high" : "normal",
+      trust_before: before,
+      trust_after: after,
+    };
+  }
+
+  if (after) {
+    return { file, line, symbol, kind: "added", priority: "normal", trust_after: after };
+  }
+
+  // Losing any protection above AUTONOMOUS is a downgrade in effect
+  return {
+    file, line, symbol,
+    kind: "removed",
+    priority: before !== "AUTONOMOUS" ? "high" : "normal",
+    trust_before: before,
+  };
+}
+
+function compareOwner(
+  file: string,
+  symbol: string,
+  line: number,
+  before: Pick<ParsedAnnotation, "owner">,
+  after: Pick<ParsedAnnotation, "owner" | "trust">
+): TrustChange | null {
+  const ownerBefore = formatOwner(before.owner);
+  const ownerAfter = formatOwner(after.owner);
+  if (ownerBefore === ownerAfter) return null;
+
+  if (!ownerBefore) {
+    return { file, line, symbol, kind: "owner_added", priority: "normal", owner_after: ownerAfter };
+  }
+  if (!ownerAfter) {
+    return { file, line, symbol, kind: "owner_removed", priority: "high", owner_before: ownerBefore };
+  }
+  const from = ownerList(before.owner).filter(o => !ownerList(after.owner).includes(o));
+  const to = ownerList(after.owner).filter(o => !ownerList(before.owner).includes(o));
+  return {
+    file, line, symbol,
+    kind: "owner_changed",
+    priority: "normal",
+    owner_before: ownerBefore,
+    owner_after: ownerAfter,
+    // Reordering co-owners changes the attribute but hands nothing over
+    transfer: from.length > 0 || to.length > 0 ? { from, to, trust: after.trust } : undefined,
+  };
+}
+
+export function ownershipTransfers(changes: TrustChange[]): OwnershipTransfer[] {
+  return changes.flatMap(c =>
+    c.kind === "owner_changed" && c.transfer
+      ? [{
+          file: c.file,
+          line: c.line,
+          symbol: c.symbol,
+          renamed_from: c.renamed_from,
+          trust: c.transfer.trust,
+          owner_before: c.owner_before!,
+          owner_after: c.owner_after!,
+          from: c.transfer.from,
+          to: c.transfer.to,
+        }]
+      : []
+  );
+}
+
+/**
+ * Pair annotations by the code they govern (AST identity in Go, else the
+ * first line of their scope) so that line shifts elsewhere in the file are
+ * not reported as changes. Changes name the region by its first line.
+ */
+export function diffAnnotationContent(
+  filePath: string,
+  baseContent: string | null,
+  headContent: string | null,
+  aliases: Record<string, TrustLevel>
+): TrustChange[] {
+  const symbolOf = symbolKey(baseContent, headContent);
+  const delta = diffAnnotationSets(
+    parseVersion(baseContent, filePath, aliases),
+    parseVersion(headContent, filePath, aliases),
+    { key: astKey(filePath, baseContent, headContent) }
+  );
+
+  const changes: TrustChange[] = [];
+  const push = (change: TrustChange | null) => {
+    if (change) changes.push(change);
+  };
+
+  for (const { annotation: after } of delta.added) {
+    const symbol = symbolOf(after, "after");
+    push({
+      file: filePath,
+      line: after.line_start,
+      symbol,
+      kind: "region_added",
+      priority: "normal",
+      trust_after: after.trust,
+      owner_after: formatOwner(after.owner),
+    });
+  }
+
+  // Who governed the region before a change that loosens it or hands it over
+  const withOwners = (change: TrustChange | null, before: ParsedAnnotation): TrustChange | null => {
+    const owners = ownerList(before.owner);
+    return change && owners.length > 0 && change.kind !== "upgrade" && change.kind !== "added"
+      ? { ...change, owners_before: owners }
+      : change;
+  };
+
+  for (const { before, after } of delta.changed) {
+    const symbol = symbolOf(after, "after");
+    const line = after.line_start;
+    push(withOwners(compareTrust(filePath, symbol, line, before.trust, after.trust), before));
+    push(withOwners(compareOwner(filePath, symbol, line, before, after), before));
+  }
+
+  // Deleting a restrictive or owned annotation drops its protection entirely
+  for (const { annotation: before } of delta.removed) {
+    const symbol = symbolOf(before, "before");
+    const protective = (before.trust !== undefined && before.trust !== "AUTONOMOUS") || before.owner !== undefined;
+    push(withOwners({
+      file: filePath,
+      line: before.line_start,
+      symbol,
+      kind: "region_removed",
+      priority: protective ? "high" : "normal",
+      trust_before: before.trust,
+      owner_before: formatOwner(before.owner),
+    }, before));
+  }
+
+  return changes.sort((a, b) => a.line - b.line);
+}
+
+// ============================================
+// Policy Changes
+// ============================================
+
+const TRUST_YAML = path.join(COLLAB_DIR, TRUST_FILE).replace(/\\/g, "/");
+
+// What one trust.yaml setting governs
+interface GoverningEntry {
+  trust?: TrustLevel;
+  owner?: string | string[];
+}
+
+// Every setting of a resolved trust.yaml that decides a level, by the name changes report it under
+function governingEntries(config: TrustConfig): Map<string, GoverningEntry> {
+  const entries = new Map<string, GoverningEntry>();
+  entries.set("default_trust", { trust: config.default_trust });
+  entries.set("generated_trust", { trust: generatedTrustLevel(config) });
+  if (config.test_trust) entries.set("test_trust", { trust: config.test_trust });
+  for (const policy of config.policies ?? []) {
+    entries.set(`policies[${JSON.stringify(policy.pattern)}]`, { trust: policy.trust, owner: policy.owner });
+  }
+  for (const symbol of config.symbols ?? []) {
+    entries.set(`symbols[${JSON.stringify(symbol.pattern)}]`, { trust: symbol.trust, owner: symbol.owner });
+  }
+  for (const region of config.regions ?? []) {
+    entries.set(`regions[${JSON.stringify(`${region.file}:${region.line_start}-${region.line_end}`)}]`, { trust: region.trust });
+  }
+  return entries;
+}
+
+/**
+ * Changes between two resolved trust.yaml configs, reported against
+ * `file` with line 0 and the setting as the symbol. Entries pair by what
+ * they match (a policy's pattern, a region's file and lines), so reordering
+ * changes nothing; the first of duplicate patterns is compared. Profile
+ * overrides compare by the level each one produces, so adding
+ * `SUGGEST_ONLY: AUTONOMOUS` to a profile is a downgrade.
+ */
+export function diffTrustConfigs(base: TrustConfig, head: TrustConfig, file = TRUST_YAML): TrustChange[] {
+  const changes: TrustChange[] = [];
+  const push = (change: TrustChange | null) => {
+    if (change) changes.push(change);
+  };
+
+  const before = governingEntries(base);
+  const after = governingEntries(head);
+  for (const [symbol, was] of before) {
+    const now = after.get(symbol);
+    const owners = ownerList(was.owner);
+    const withOwners = (change: TrustChange | null) =>
+      change && owners.length > 0 && change.kind !== "upgrade" && change.kind !== "added"
+        ? { ...change, owners_before: owners }
+        : change;
+    if (!now) {
+      const protective = (was.trust !== undefined && was.trust !== "AUTONOMOUS") || was.owner !== undefined;
+      push(withOwners({
+        file, line: 0, symbol,
+        kind: "policy_removed",
+        priority: protective ? "high" : "normal",
+        trust_before: was.trust,
+        owner_before: formatOwner(was.owner),
+      }));
+      continue;
+    }
+    push(withOwners(compareTrust(file, symbol, 0, was.trust, now.trust)));
+    push(withOwners(compareOwner(file, symbol, 0, was, now)));
+  }
+  for (const [symbol, now] of after) {
+    if (before.has(symbol)) continue;
+    push({
+      file, line: 0, symbol,
+      kind: "policy_added",
+      priority: "normal",
+      trust_after: now.trust,
+      owner_after: formatOwner(now.owner),
+    });
+  }
+
+  const profiles = new Set([...Object.keys(base.profiles ?? {}), ...Object.keys(head.profiles ?? {})]);
+  for (const name of profiles) {
+    for (const level of TRUST_LEVELS) {
+      const was = base.profiles?.[name]?.overrides?.[level] ?? level;
+      const now = head.profiles?.[name]?.overrides?.[level] ?? level;
+      push(compareTrust(file, `profiles.${name}.overrides.${level}`, 0, was, now));
+    }
+  }
+  return changes;
+}
+
+// ============================================
+// Test Requirements
+// ============================================
+
+const JS_TEST_PATTERNS = [
+  "{dir}/{name}.test.{ext}",
+  "{dir}/{name}.spec.{ext}",
+  "{dir}/__tests__/{name}.{ext}",
+  "{dir}/__tests__/{name}.test.{ext}",
+];
+
+/**
+ * Where the tests for a source file live, by its extension. {dir}, {name},
+ * and {ext} are the source file's directory, name without extension, and
+ * extension; the rest is a glob (* within a directory, ** across them).
+ * config.yaml `tests` replaces the list for any extension it names.
+ */
+export const DEFAULT_TEST_PATTERNS: Record<string, string[]> = {
+  go: ["{dir}/{name}_test.go"],
+  ts: JS_TEST_PATTERNS,
+  tsx: JS_TEST_PATTERNS,
+  js: JS_TEST_PATTERNS,
+  jsx: JS_TEST_PATTERNS,
+  mjs: JS_TEST_PATTERNS,
+  cjs: JS_TEST_PATTERNS,
+  py: ["{dir}/test_{name}.py", "{dir}/{name}_test.py", "tests/**/test_{name}.py"],
+  rb: ["spec/**/{name}_spec.rb", "test/**/{name}_test.rb"],
+  java: ["src/test/**/{name}Test.java"],
+};
+
+function escapeRegex(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+}
+
+/** The test patterns for a source file, with its placeholders filled in */
+export function testPatternsFor(sourcePath: string, testPatterns: Record<string, string[]> = {}): string[] {
+  const normalized = sourcePath.replace(/\\/g, "/");
+  const ext = path.posix.extname(normalized).slice(1);
+  const values: Record<string, string> = {
+    dir: path.posix.dirname(normalized),
+    name: path.posix.basename(normalized, ext ? `.${ext}` : ""),
+    ext,
+  };
+  return (testPatterns[ext] ?? DEFAULT_TEST_PATTERNS[ext] ?? []).map(template =>
+    template.replace(/\{(dir|name|ext)\}/g, (_, key: string) => values[key]).replace(/^\.\//, "")
+  );
+}
+
+// Placeholders are already filled in, so only glob syntax is special
+function matchesTestPattern(filePath: string, pattern: string): boolean {
+  const regex = pattern
+    .split(/(\*\*\/|\*\*|\*)/)
+    .map(part => (part === "**/" ? "(?:.*/)?" : part === "**" ? ".*" : part === "*" ? "[^/]*" : escapeRegex(part)))
+    .join("");
+  return new RegExp(`^${regex}$`).test(filePath.replace(/\\/g, "/"));
+}
+
+/**
+ * requires_tests regions in `headContent` that overlap `changedLines` when no
+ * file in `changedFiles` matches the region file's test patterns.
+ */
+export function findUntestedChanges(
+  filePath: string,
+  headContent: string,
+  changedLines: { line_start: number; line_end: number }[],
+  changedFiles: string[],
+  aliases: Record<string, TrustLevel>,
+  testPatterns: Record<string, string[]> = {}
+): TrustChange[] {
+  const guarded = parseVersion(headContent, filePath, aliases).filter(
+    annotation =>
+      annotation.requires_tests &&
+      changedLines.some(r => r.line_start <= annotation.line_end && r.line_end >= annotation.line_start)
+  );
+  if (guarded.length === 0) return [];
+
+  const expected = testPatternsFor(filePath, testPatterns);
+  if (changedFiles.some(file => expected.some(pattern => matchesTestPattern(file, pattern)))) return [];
+
+  const symbolOf = symbolKey(null, headContent);
+  return guarded.map(annotation => ({
+    file: filePath,
+    line: annotation.line_start,
+    symbol: symbolOf(annotation, "after"),
+    kind: "tests_missing" as const,
+    priority: "high" as const,
+    expected_tests: expected,
+  }));
+}
+
+// ============================================
+// Vendored Code
+// ============================================
+
+/**
+ * READ_ONLY regions of a vendored file's head that overlap `changedLines`.
+ * The lines belong to the upstream library, so a local change is drift,
+ * whoever made it; the caller decides which files are vendored.
+ */
+export function findVendoredEdits(
+  filePath: string,
+  headContent: string,
+  changedLines: { line_start: number; line_end: number }[],
+  aliases: Record<string, TrustLevel>
+): TrustChange[] {
+  const symbolOf = symbolKey(null, headContent);
+  return parseVersion(headContent, filePath, aliases).flatMap(annotation => {
+    if (annotation.trust !== "READ_ONLY") return [];
+    const overlapping = changedLines
+      .filter(r => r.line_start <= annotation.line_end && r.line_end >= annotation.line_start)
+      .map(r => ({
+        line_start: Math.max(r.line_start, annotation.line_start),
+        line_end: Math.min(r.line_end, annotation.line_end),
+      }));
+    if (overlapping.length === 0) return [];
+    return [{
+      file: filePath,
+      line: annotation.line_start,
+      symbol: symbolOf(annotation, "after"),
+      kind: "vendored_edit" as const,
+      priority: "high" as const,
+      trust_after: annotation.trust,
+      owner_after: formatOwner(annotation.owner),
+      changed_lines: overlapping,
+    }];
+  });
+}
+
+// ============================================
+// Extraction Heuristic
+// ============================================
+
+export interface FileVersions {
+  path: string;
+  base: string | null; // null when added
+  head: string | null; // null when deleted
+}
+
+interface ResolvedDeclaration extends Declaration {
+  trust: TrustLevel;
+  lines: string[]; // The declaration's lines
+}
+
+// Lines that hold code, so reflowed comments and blank lines do not count as a smaller body
+function codeLineCount(lines: string[]): number {
+  return lines.filter(line => {
+    const trimmed = line.trim();
+    return trimmed !== "" && !trimmed.startsWith("//");
+  }).length;
+}
+
+// Go funcs and methods in `content` with the trust each resolves to as a whole
+function resolvedFunctions(
+  filePath: string,
+  content: string,
+  config: TrustConfig,
+  aliases: Record<string, TrustLevel>
+): ResolvedDeclaration[] {
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  const { annotations } = parseAnnotationContent(content, filePath, { aliases });
+  const declarations = findDeclarations(content, filePath);
+  return declarations
+    .filter(d => /^func\b/.test(lines[d.line_start - 1] ?? ""))
+    .map(d => ({
+      ...d,
+      trust: resolveTrustWithAnnotations(config, filePath, annotations, d.line_start, d.line_end, declarations).level,
+      lines: lines.slice(d.line_start - 1, d.line_end),
+    }));
+}
+
+/**
+ * Flag a way around READ_ONLY: moving logic out of a locked Go function into
+ * a new AUTONOMOUS or SUPERVISED function and calling it. Reported when a
+ * function that is READ_ONLY in base has fewer code lines in head and its head
+ * body calls a function that no compared file declared in base. A heuristic,
+ * so the changes are normal priority; helpers may live in any compared file.
+ */
+export function findExtractedLogic(
+  files: FileVersions[],
+  baseConfig: TrustConfig,
+  headConfig: TrustConfig,
+  aliases: Record<string, TrustLevel>
+): TrustChange[] {
+  const goFiles = files.filter(f => f.path.endsWith(".go"));
+  const base = goFiles.map(f => ({ path: f.path, functions: f.base ? resolvedFunctions(f.path, f.base, baseConfig, aliases) : [] }));
+  const head = goFiles.map(f => ({ path: f.path, functions: f.head ? resolvedFunctions(f.path, f.head, headConfig, aliases) : [] }));
+
+  const existing = new Set(base.flatMap(f => f.functions.map(d => d.qualified_name)));
+  const helpers = head
+    .flatMap(f => f.functions)
+    .filter(d => !existing.has(d.qualified_name) && (d.trust === "AUTONOMOUS" || d.trust === "SUPERVISED"));
+  if (helpers.length === 0) return [];
+
+  const changes: TrustChange[] = [];
+  base.forEach(({ path: filePath, functions }, i) => {
+    for (const locked of functions.filter(d => d.trust === "READ_ONLY")) {
+      const after = head[i].functions.find(d => d.qualified_name === locked.qualified_name);
+      if (!after) continue;
+      const linesBefore = codeLineCount(locked.lines);
+      const linesAfter = codeLineCount(after.lines);
+      if (linesAfter >= linesBefore) continue;
+
+      const body = after.lines.slice(1).join("\n");
+      for (const helper of helpers) {
+        const call = new RegExp(`(?:^|[^\\p{L}\\p{N}_])${helper.name}\\s*\\(`, "u");
+        if (helper === after || !call.test(body)) continue;
+        changes.push({
+          file: filePath,
+          line: after.line_start,
+          symbol: (after.lines[0] ?? "").trim(),
+          kind: "possible_extraction",
+          priority: "normal",
+          trust_before: locked.trust,
+          lines_before: linesBefore,
+          lines_after: linesAfter,
+          helper: { file: helper.file, line: helper.line_start, name: helper.qualified_name, trust: helper.trust },
+        });
+      }
+    }
+  });
+  return changes;
+}
+
+export function measureCoverage(
+  content: string | null,
+  filePath: string,
+  aliases: Record<string, TrustLevel>
+): GovernanceCoverage {
+  if (content === null) return { governed_lines: 0, total_lines: 0 };
+
+  const governed = new Set<number>();
+  for (const annotation of parseAnnotationContent(content, filePath, { aliases }).annotations) {
+    for (let line = annotation.line_start; line <= annotation.line_end; line++) governed.add(line);
+  }
+  return { governed_lines: governed.size, total_lines: content.replace(/\r\n/g, "\n").split("\n").length };
+}
+
+// A file new in head changed in every line
+async function changedLinesOf(
+  base: string,
+  head: string | undefined,
+  file: ChangedFile,
+  headContent: string,
+  signal?: AbortSignal
+): Promise<{ line_start: number; line_end: number }[]> {
+  if (file.status === "A") {
+    return [{ line_start: 1, line_end: headContent.replace(/\r\n/g, "\n").split("\n").length }];
+  }
+  return listChangedLines(base, head, file, signal);
+}
+
+export async function diffAnnotations(
+  base: string,
+  head?: string,
+  options: { signal?: AbortSignal } = {}
+): Promise<AnnotationDiffReport> {
+  const { signal } = options;
+  signal?.throwIfAborted();
+
+  const aliases = await loadTrustAliases();
+  const { tests: testPatterns = {}, vendor } = await loadCollabConfig();
+  const files = await listChangedFilesWithRenames(base, head, signal);
+  const changedPaths = files.flatMap(f => (f.old_path ? [f.path, f.old_path] : [f.path]));
+  const versions: FileVersions[] = [];
+
+  const changes: TrustChange[] = [];
+  const renames: { from: string; to: string }[] = [];
+  const before: GovernanceCoverage = { governed_lines: 0, total_lines: 0 };
+  const after: GovernanceCoverage = { governed_lines: 0, total_lines: 0 };
+
+  for (const file of files) {
+    signal?.throwIfAborted();
+    const basePath = file.old_path ?? file.path;
+    const baseContent = file.status === "A" ? null : await readFileAtRevision(base, basePath, signal);
+    const headContent = file.status === "D"
+      ? null
+      : head
+        ? await readFileAtRevision(head, file.path, signal)
+        : await fs.readFile(file.path, { encoding: "utf-8", signal }).catch(() => {
+            signal?.throwIfAborted();
+            return null;
+          });
+
+    for (const change of diffAnnotationContent(file.path, baseContent, headContent, aliases)) {
+      changes.push(file.old_path ? { ...change, renamed_from: file.old_path } : change);
+    }
+    if (file.old_path) renames.push({ from: file.old_path, to: file.path });
+    versions.push({ path: file.path, base: baseContent, head: headContent });
+
+    const untested = headContent !== null && /requires_tests/.test(headContent);
+    const vendored = headContent !== null && isVendoredPath(file.path, vendor) && /@collab/.test(headContent);
+    if (headContent !== null && (untested || vendored)) {
+      const changedLines = await changedLinesOf(base, head, file, headContent, signal);
+      if (untested) {
+        changes.push(...findUntestedChanges(file.path, headContent, changedLines, changedPaths, aliases, testPatterns));
+      }
+      if (vendored) changes.push(...findVendoredEdits(file.path, headContent, changedLines, aliases));
+    }
+
+    for (const [total, content, filePath] of [
+      [before, baseContent, basePath],
+      [after, headContent, file.path],
+    ] as const) {
+      const coverage = measureCoverage(content, filePath, aliases);
+      total.governed_lines += coverage.governed_lines;
+      total.total_lines += coverage.total_lines;
+    }
+  }
+
+  const baseConfig = await loadTrustConfigStrict(undefined, base);
+  const headConfig = head ? await loadTrustConfigStrict(undefined, head) : await loadTrustConfigStrict();
+  changes.push(...diffTrustConfigs(baseConfig, headConfig));
+  if (versions.some(v => v.path.endsWith(".go"))) {
+    changes.push(...findExtractedLogic(versions, baseConfig, headConfig, aliases));
+  }
+
+  const count = (...kinds: TrustChangeKind[]) => changes.filter(c => kinds.includes(c.kind)).length;
+  return {
+    base,
+    head: head ?? "WORKTREE",
+    files_compared: files.length,
+    renames,
+    coverage: { before, after, delta_lines: after.governed_lines - before.governed_lines },
+    summary: {
+      regions_added: count("region_added"),
+      regions_removed: count("region_removed"),
+      trust_changes: count("upgrade", "downgrade", "added", "removed"),
+      owner_changes: count("owner_added", "owner_removed", "owner_changed"),
+      policy_changes: changes.filter(c => c.file === TRUST_YAML && c.line === 0).length,
+      tests_missing: count("tests_missing"),
+      vendored_edits: count("vendored_edit"),
+      possible_extractions: count("possible_extraction"),
+      ownership_transfers: ownershipTransfers(changes).length,
+      high_priority: changes.filter(c => c.priority === "high").length,
+    },
+    changes,
+    transfers: ownershipTransfers(changes),
+  };
+}
+
+// ============================================
+// Output
+// ============================================
+
+export function describeChange(change: TrustChange): string {
+  switch (change.kind) {
+    case "upgrade":
+    case "downgrade":
+      return `${change.kind} ${change.trust_before} -> ${change.trust_after}`;
+    case "added":
+      return `added ${change.trust_after}`;
+    case "removed":
+      return `removed ${change.trust_before}`;
+    case "region_added":
+      return `new region ${change.trust_after ?? "(no trust)"}` + (change.owner_after ? ` owner ${change.owner_after}` : "");
+    case "region_removed":
+      return `region removed (was ${change.trust_before ?? "no trust"}` +
+        (change.owner_before ? `, owner ${change.owner_before}` : "") + ")";
+    case "owner_added":
+      return `owner added ${change.owner_after}`;
+    case "owner_removed":
+      return `owner removed (was ${change.owner_before})`;
+    case "owner_changed":
+      return change.transfer
+        ? `ownership transfer ${change.owner_before} -> ${change.owner_after}` +
+            (change.transfer.trust ? ` (${change.transfer.trust})` : "")
+        : `owner ${change.owner_before} -> ${change.owner_after} (reordered)`;
+    case "tests_missing":
+      return change.expected_tests?.length
+        ? `changed without tests (expected a change to ${change.expected_tests.join(" or ")})`
+        : "changed without tests (no test patterns for this file type; set tests in config.yaml)";
+    case "vendored_edit":
+      return `vendored READ_ONLY code changed (line(s) ${change.changed_lines?.map(r => formatRange(rangeOf(r))).join(", ")}); ` +
+        "change it upstream and vendor it again";
+    case "policy_added":
+      return `new ${change.trust_after ?? "(no trust)"}` + (change.owner_after ? ` owner ${change.owner_after}` : "");
+    case "policy_removed":
+      return `removed (was ${change.trust_before ?? "no trust"}` +
+        (change.owner_before ? `, owner ${change.owner_before}` : "") + ")";
+    case "possible_extraction":
+      return `warning: ${change.trust_before} body shrank ${change.lines_before} -> ${change.lines_after} line(s) ` +
+        `and calls new ${change.helper?.trust} ${change.helper?.name} (${change.helper?.file}:${change.helper?.line}); ` +
+        "check that locked logic was not moved out";
+  }
+}
+
+function percent(coverage: GovernanceCoverage): string {
+  return coverage.total_lines === 0
+    ? "0%"
+    : `${((coverage.governed_lines / coverage.total_lines) * 100).toFixed(1)}%`;
+}
+
+export function formatDiffText(report: AnnotationDiffReport): string {
+  const lines: string[] = [];
+  const ordered = [...report.changes].sort((a, b) =>
+    a.priority === b.priority ? 0 : a.priority === "high" ? -1 : 1
+  );
+
+  for (const change of ordered) {
+    const tag = change.priority === "high" ? "HIGH  " : "normal";
+    const file = change.renamed_from ? `${change.file} (from ${change.renamed_from})` : change.file;
+    const where = change.line > 0 ? formatRange(rangeOf(change), file) : file;
+    lines.push(`${tag} ${where}: ${describeChange(change)}  ${change.symbol}`);
+  }
+
+  for (const { from, to } of report.renames) {
+    lines.push(`renamed ${from} -> ${to}`);
+  }
+
+  const { summary, coverage } = report;
+  const delta = coverage.delta_lines >= 0 ? `+${coverage.delta_lines}` : `${coverage.delta_lines}`;
+  lines.push(
+    `Compared ${report.base}..${report.head} (${report.files_compared} file(s)): ` +
+      `${report.changes.length} change(s), ${summary.high_priority} high priority`,
+    `  regions: +${summary.regions_added} -${summary.regions_removed}, ` +
+      `trust changes: ${summary.trust_changes}, owner changes: ${summary.owner_changes}` +
+      (summary.policy_changes > 0 ? `, trust.yaml changes: ${summary.policy_changes}` : "") +
+      (summary.tests_missing > 0 ? `, untested changes: ${summary.tests_missing}` : "") +
+      (summary.vendored_edits > 0 ? `, vendored edits: ${summary.vendored_edits}` : "") +
+      (summary.possible_extractions > 0 ? `, possible extractions: ${summary.possible_extractions}` : "") +
+      (summary.ownership_transfers > 0 ? `, ownership transfers: ${summary.ownership_transfers}` : ""),
+    `  coverage of compared files: ${percent(coverage.before)} -> ${percent(coverage.after)} (${delta} governed lines)`
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runDiff(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let notify = false;
+  const revisions: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg === "--notify") {
+      notify = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      revisions.push(arg);
+    }
+  }
+
+  if (revisions.length < 1 || revisions.length > 2) {
+    console.error("Usage: collab-claude-code diff <base> [head] [--format=text|json] [--notify]");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const report = await diffAnnotations(revisions[0], revisions[1]);
+    console.log(format === "json" ? JSON.stringify(report, null, 2) : formatDiffText(report));
+    if (notify) {
+      for (const failure of await runTransferHooks(report.transfers)) {
+        console.error(`Warning: transfer hook ${failure.hook} failed for ${formatRange(rangeOf(failure), failure.file)}: ${failure.message}`);
+      }
+    }
+    return report.changes.some(c => c.priority === "high") ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
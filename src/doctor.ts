@@ -0,0 +1,427 @@
+/**
+ * Doctor command for collab-claude-code
+ *
+ * A preflight for CI: validates trust.yaml and config.yaml against the
+ * settings this tool understands, fetches every `extends` source (bypassing
+ * the cache), checks that the pre-apply hooks config.yaml names exist, and
+ * collects annotation errors across the project, all in one pass. Every check
+ * runs even when an earlier one fails, so one report lists every problem.
+ *
+ * Exit codes:
+ *   0 = No problems found
+ *   1 = One or more problems found
+ *   2 = Tool error (bad arguments)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import {
+  COLLAB_DIR,
+  CONFIG_FILE,
+  CollabConfig,
+  TRUST_FILE,
+  TRUST_LEVELS,
+  TrustConfig,
+  TrustLevel,
+  buildOwnerRegistry,
+  buildTrustAliases,
+  fileExists,
+  formatRange,
+  loadOwnerRegistry,
+  loadTrustAliases,
+  rangeOf,
+  stableStringify,
+  validateAgentPolicies,
+  validateGeneratedTrust,
+  validateTestTrust,
+  validateTrustBudgets,
+  validateTrustProfiles,
+} from "./collab.js";
+import {
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  EXIT_VIOLATIONS,
+  checkFile,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+import { PolicyDocument, PolicySource, probePolicySource } from "./policy.js";
+import { buildIdentityMap } from "./identity.js";
+import { validateEffectfulCalls } from "./effects.js";
+import { DEFAULT_LOCALE, loadLocalizer } from "./messages.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface DoctorCheck {
+  name: string;
+  summary: string; // One line, shown whether or not there are problems
+  problems: string[];
+}
+
+export interface DoctorReport {
+  checks: DoctorCheck[];
+  total_problems: number;
+}
+
+// ============================================
+// Constants
+// ============================================
+
+const TRUST_KEYS = [
+  "default_trust",
+  "policies",
+  "regions",
+  "symbols",
+  "profiles",
+  "budgets",
+  "generated_trust",
+  "test_trust",
+  "extends",
+];
+const CONFIG_KEYS = [
+  "version",
+  "confidence_threshold",
+  "auto_record_authorship",
+  "model",
+  "suggest",
+  "aliases",
+  "exclude",
+  "vendor",
+  "pre_apply_hooks",
+  "constraint_verifiers",
+  "route_extractors",
+  "transfer_hooks",
+  "owners",
+  "agents",
+  "identities",
+  "team_roster",
+  "lint",
+  "tests",
+  "locale",
+  "enforcement",
+];
+
+// ============================================
+// Validation
+// ============================================
+
+function isTrustLevel(value: unknown): value is TrustLevel {
+  return TRUST_LEVELS.includes(value as TrustLevel);
+}
+
+function checkOwner(owner: unknown, where: string, problems: string[]): void {
+  if (owner === undefined) return;
+  const owners = Array.isArray(owner) ? owner : [owner];
+  if (owners.length === 0 || owners.some(o => typeof o !== "string" || o.trim() === "")) {
+    problems.push(`${where}: owner must be a non-empty name or list of names`);
+  }
+}
+
+function unknownKeys(document: object, known: string[], label: string): string[] {
+  return Object.keys(document)
+    .filter(key => !known.includes(key))
+    .map(key => `${label}: unknown setting "${key}" (expected one of ${known.join(", ")})`);
+}
+
+// Problems in one trust.yaml-shaped document, local or extended
+export function validatePolicyDocument(document: PolicyDocument, label: string): string[] {
+  const problems = unknownKeys(document, TRUST_KEYS, label);
+
+  if (document.default_trust !== undefined && !isTrustLevel(document.default_trust)) {
+    problems.push(`${label}: default_trust "${document.default_trust}" is not a trust level`);
+  }
+
+  const entries = (key: "policies" | "regions" | "symbols"): Record<string, unknown>[] => {
+    const value = document[key];
+    if (value === undefined) return [];
+    if (!Array.isArray(value)) {
+      problems.push(`${label}: ${key} must be a list`);
+      return [];
+    }
+    return value as Record<string, unknown>[];
+  };
+
+  entries("policies").forEach((policy, i) => {
+    const where = `${label}: policies[${i}]`;
+    if (typeof policy?.pattern !== "string") problems.push(`${where}: missing pattern`);
+    if (!isTrustLevel(policy?.trust)) problems.push(`${where}: trust "${policy?.trust}" is not a trust level`);
+    checkOwner(policy?.owner, where, problems);
+  });
+
+  entries("regions").forEach((region, i) => {
+    const where = `${label}: regions[${i}]`;
+    if (typeof region?.file !== "string") problems.push(`${where}: missing file`);
+    const start = region?.line_start;
+    const end = region?.line_end;
+    if (!Number.isInteger(start) || !Number.isInteger(end) || (start as number) < 1 || (end as number) < (start as number)) {
+      problems.push(`${where}: line_start and line_end must be line numbers with line_start <= line_end`);
+    }
+    if (!isTrustLevel(region?.trust)) problems.push(`${where}: trust "${region?.trust}" is not a trust level`);
+  });
+
+  entries("symbols").forEach((symbol, i) => {
+    const where = `${label}: symbols[${i}]`;
+    if (typeof symbol?.pattern !== "string") problems.push(`${where}: missing pattern`);
+    if (!isTrustLevel(symbol?.trust)) problems.push(`${where}: trust "${symbol?.trust}" is not a trust level`);
+    checkOwner(symbol?.owner, where, problems);
+  });
+
+  const config = document as unknown as TrustConfig;
+  const configError =
+    validateTrustProfiles(config) ?? validateTrustBudgets(config) ?? validateGeneratedTrust(config) ??
+    validateTestTrust(config);
+  if (configError) problems.push(`${label}: ${configError}`);
+  return problems;
+}
+
+// The raw local document, before extends are merged
+async function readTrustDocument(check: DoctorCheck): Promise<PolicyDocument | undefined> {
+  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+  if (!(await fileExists(trustPath))) {
+    check.summary = `${trustPath} not found; every file is SUPERVISED`;
+    return undefined;
+  }
+  try {
+    return (yaml.parse(await fs.readFile(trustPath, "utf-8")) as PolicyDocument | null) ?? {};
+  } catch (error) {
+    check.summary = `${trustPath} could not be parsed`;
+    check.problems.push(`${trustPath}: ${error instanceof Error ? error.message : String(error)}`);
+    return undefined;
+  }
+}
+
+async function checkExtends(document?: PolicyDocument): Promise<DoctorCheck> {
+  const check: DoctorCheck = { name: "extends", summary: "no shared policies", problems: [] };
+  if (!document?.extends) return check;
+
+  const sources: PolicySource[] = Array.isArray(document.extends) ? document.extends : [document.extends];
+  let reachable = 0;
+  for (const source of sources) {
+    try {
+      const layer = await probePolicySource(source);
+      reachable++;
+      check.problems.push(...validatePolicyDocument(layer.document, layer.source));
+    } catch (error) {
+      check.problems.push(error instanceof Error ? error.message : String(error));
+    }
+  }
+  check.summary = `${reachable} of ${sources.length} source(s) reachable`;
+  return check;
+}
+
+// The merged result must load as check and scan load it, including profile selection
+async function loadMergedConfig(
+  check: DoctorCheck,
+  reported: string[],
+  profile?: string
+): Promise<TrustConfig | undefined> {
+  try {
+    const config = await loadTrustConfigStrict(profile);
+    check.summary ||=
+      `${config.policies.length} policy(ies), ${config.regions?.length ?? 0} region(s), ` +
+      `${config.symbols?.length ?? 0} symbol policy(ies)` +
+      (config.active_profile ? `, profile ${config.active_profile}` : "");
+    return config;
+  } catch (error) {
+    check.summary = `${path.join(COLLAB_DIR, TRUST_FILE)} does not load`;
+    const message = error instanceof Error ? error.message : String(error);
+    if (!reported.some(p => message.includes(p))) check.problems.push(message);
+    return undefined;
+  }
+}
+
+async function checkConfigFile(): Promise<DoctorCheck> {
+  const configPath = path.join(COLLAB_DIR, CONFIG_FILE);
+  const check: DoctorCheck = { name: "config.yaml", summary: "", problems: [] };
+
+  if (!(await fileExists(configPath))) {
+    check.summary = `${configPath} not found; using defaults`;
+    return check;
+  }
+
+  let config: CollabConfig;
+  try {
+    config = (yaml.parse(await fs.readFile(configPath, "utf-8")) as CollabConfig | null) ?? {};
+  } catch (error) {
+    check.summary = `${configPath} could not be parsed`;
+    check.problems.push(`${configPath}: ${error instanceof Error ? error.message : String(error)}`);
+    return check;
+  }
+
+  check.problems.push(...unknownKeys(config, CONFIG_KEYS, configPath));
+  let aliasCount = 0;
+  try {
+    aliasCount = Object.keys(buildTrustAliases(config.aliases)).length;
+  } catch (error) {
+    check.problems.push(`${configPath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  try {
+    buildOwnerRegistry(config.owners);
+  } catch (error) {
+    check.problems.push(`${configPath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  try {
+    buildIdentityMap(config.identities);
+  } catch (error) {
+    check.problems.push(`${configPath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  if (config.team_roster !== undefined && !(typeof config.team_roster === "string" && (await fileExists(config.team_roster)))) {
+    check.problems.push(`${configPath}: team roster ${config.team_roster} not found`);
+  }
+  if (config.exclude !== undefined && !(Array.isArray(config.exclude) && config.exclude.every(e => typeof e === "string"))) {
+    check.problems.push(`${configPath}: exclude must be a list of patterns`);
+  }
+  if (config.vendor !== undefined && !(Array.isArray(config.vendor) && config.vendor.every(v => typeof v === "string"))) {
+    check.problems.push(`${configPath}: vendor must be a list of patterns`);
+  }
+  for (const hook of config.pre_apply_hooks ?? []) {
+    if (!(await fileExists(hook))) check.problems.push(`${configPath}: pre-apply hook ${hook} not found`);
+  }
+  for (const verifier of config.constraint_verifiers ?? []) {
+    if (!(await fileExists(verifier))) check.problems.push(`${configPath}: constraint verifier ${verifier} not found`);
+  }
+  for (const extractor of config.route_extractors ?? []) {
+    if (!(await fileExists(extractor))) check.problems.push(`${configPath}: route extractor ${extractor} not found`);
+  }
+  for (const hook of config.transfer_hooks ?? []) {
+    if (!(await fileExists(hook))) check.problems.push(`${configPath}: transfer hook ${hook} not found`);
+  }
+  try {
+    validateEffectfulCalls(config.lint?.effectful_calls);
+  } catch (error) {
+    check.problems.push(`${configPath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  const maxLines = config.lint?.read_only_helper_max_lines;
+  if (maxLines !== undefined && !(Number.isInteger(maxLines) && maxLines >= 1)) {
+    check.problems.push(`${configPath}: lint.read_only_helper_max_lines must be a positive integer`);
+  }
+  const limitSettings = [
+    { where: "lint", limits: config.lint ?? {} },
+    ...(config.lint?.overrides ?? []).map((o, i) => ({ where: `lint.overrides[${i}]`, limits: o })),
+  ];
+  for (const { where, limits } of limitSettings) {
+    for (const key of ["max_region_lines", "max_annotations_per_file"] as const) {
+      const value = limits[key];
+      if (value !== undefined && !(Number.isInteger(value) && value >= 0)) {
+        check.problems.push(`${configPath}: ${where}.${key} must be a non-negative integer`);
+      }
+    }
+  }
+  const agentProblem = validateAgentPolicies(config);
+  if (agentProblem) check.problems.push(`${configPath}: ${agentProblem}`);
+  if (config.enforcement !== undefined && !["enforce", "shadow"].includes(config.enforcement)) {
+    check.problems.push(`${configPath}: enforcement must be "enforce" or "shadow"`);
+  }
+  for (const [i, override] of (config.lint?.overrides ?? []).entries()) {
+    if (typeof override?.pattern !== "string") check.problems.push(`${configPath}: lint.overrides[${i}] needs a pattern`);
+  }
+  const priority = config.lint?.dedupe_priority;
+  if (priority !== undefined && !(Array.isArray(priority) && priority.every(p => typeof p === "string"))) {
+    check.problems.push(`${configPath}: lint.dedupe_priority must be a list of rules or codes`);
+  }
+  for (const [ext, patterns] of Object.entries(config.tests ?? {})) {
+    if (!Array.isArray(patterns) || !patterns.every(p => typeof p === "string")) {
+      check.problems.push(`${configPath}: tests.${ext} must be a list of patterns`);
+    }
+  }
+
+  check.summary = `${aliasCount} alias(es), ${config.pre_apply_hooks?.length ?? 0} pre-apply hook(s)`;
+  return check;
+}
+
+// Annotation errors do not depend on trust.yaml, so these run on the default config if it does not load
+async function checkAnnotations(paths: string[], config: TrustConfig): Promise<DoctorCheck> {
+  const check: DoctorCheck = { name: "annotations", summary: "", problems: [] };
+  const aliases = await loadTrustAliases().catch(() => buildTrustAliases());
+  const owners = await loadOwnerRegistry().catch(() => undefined);
+  const localizer = await loadLocalizer().catch((error: Error) => {
+    check.problems.push(error.message);
+    return loadLocalizer({ locale: DEFAULT_LOCALE });
+  });
+  const files = await expandPaths(paths);
+
+  for (const file of files) {
+    const result = await checkFile(config, file, aliases, { owners });
+    for (const v of result.violations) {
+      if (v.rule === "annotation" && v.severity === "error") {
+        check.problems.push(`${formatRange(rangeOf(v), v.file)}: [${v.code}] ${localizer.localize(v)}`);
+      }
+    }
+  }
+  check.summary = `${files.length} file(s) checked`;
+  return check;
+}
+
+export async function runDoctorChecks(
+  paths: string[] = [],
+  options: { profile?: string } = {}
+): Promise<DoctorReport> {
+  const trust: DoctorCheck = { name: "trust.yaml", summary: "", problems: [] };
+  const document = await readTrustDocument(trust);
+  if (document) {
+    trust.problems.push(...validatePolicyDocument(document, path.join(COLLAB_DIR, TRUST_FILE)));
+  }
+  const shared = await checkExtends(document);
+  const config = document || options.profile
+    ? await loadMergedConfig(trust, [...trust.problems, ...shared.problems], options.profile)
+    : undefined;
+
+  const checks = [
+    trust,
+    shared,
+    await checkConfigFile(),
+    await checkAnnotations(paths, config ?? { default_trust: "SUPERVISED", policies: [] }),
+  ];
+  return { checks, total_problems: checks.reduce((sum, c) => sum + c.problems.length, 0) };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatDoctorText(report: DoctorReport): string {
+  const width = Math.max(...report.checks.map(c => c.name.length));
+  const lines: string[] = [];
+  for (const check of report.checks) {
+    const status = check.problems.length === 0 ? "ok" : `${check.problems.length} problem(s)`;
+    lines.push(`${check.name.padEnd(width)}  ${status}  ${check.summary}`.trimEnd());
+    for (const problem of check.problems) {
+      lines.push(`  ${problem}`);
+    }
+  }
+  lines.push(
+    report.total_problems === 0 ? "No problems found" : `Found ${report.total_problems} problem(s)`
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runDoctor(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  const report = await runDoctorChecks(paths, { profile });
+  console.log(format === "json" ? stableStringify(report, 2) : formatDoctorText(report));
+  return report.total_problems > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+}
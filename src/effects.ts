@@ -0,0 +1,194 @@
+/**
+ * Effectful call detection
+ *
+ * Finds calls in a region's code that reach outside the program, such as
+ * charging a card, sending mail, or deleting rows, as listed in config.yaml:
+ *
+ *   lint:
+ *     effectful_calls:
+ *       - "stripeClient.*"            # Any call through stripeClient
+ *       - "mailer.Send"
+ *       - call: "*.ExecContext"       # Only when the arguments match `args`
+ *         args: "\\bDELETE\\b"
+ *
+ * `check` requires SUPERVISED and SUGGEST_ONLY regions making such calls to
+ * declare at least one constraint, so whoever edits them is told what must
+ * be preserved.
+ *
+ * This is lightweight matching, not a parse: a call is a dotted name followed
+ * by `(`, found outside comments and string literals, and its arguments run
+ * to the matching `)`. A pattern matches the whole name or a dotted suffix of
+ * it, so `stripeClient.*` matches `s.stripeClient.Charges.New(...)`; `*`
+ * matches any run of name characters and dots.
+ */
+
+import * as path from "path";
+
+// ============================================
+// Types
+// ============================================
+
+// A call pattern, or a pattern with a regex its argument text must match (case-insensitive)
+export type EffectfulCall = string | { call: string; args?: string };
+
+export interface CallSite {
+  callee: string; // Dotted name as written, whitespace removed: "tx.ExecContext"
+  args: string; // Text between the parentheses, comments removed
+  line: number; // 1-indexed, counted from the start of the scanned code
+}
+
+export interface EffectfulCallSite extends CallSite {
+  pattern: string; // The effectful_calls pattern it matched
+}
+
+// ============================================
+// Constants
+// ============================================
+
+const HASH_COMMENT_EXTENSIONS = ["py", "rb", "sh", "bash"];
+// Single quotes delimit one character here, and Rust lifetimes are not quoted at all
+const RUNE_QUOTE_EXTENSIONS = ["go", "rs", "java"];
+
+// Words followed by "(" that are not calls
+const NON_CALLS = new Set([
+  "if", "for", "while", "switch", "catch", "return", "func", "function", "def", "fn", "match",
+  "elif", "and", "or", "not", "in", "typeof", "sizeof", "super", "new", "throw", "await", "yield",
+]);
+
+const CALL_REGEX = /([\p{L}_$][\p{L}\p{N}_$]*(?:\s*\.\s*[\p{L}_$][\p{L}\p{N}_$]*)*)\s*\(/gu;
+
+// ============================================
+// Call Sites
+// ============================================
+
+/**
+ * Two copies of `code` with the same offsets: `code` without comments, and
+ * additionally without string contents, so parentheses and names inside
+ * literals are not mistaken for calls.
+ */
+function maskSource(code: string, ext: string): { uncommented: string; bare: string } {
+  const hashComments = HASH_COMMENT_EXTENSIONS.includes(ext);
+  const runeQuotes = RUNE_QUOTE_EXTENSIONS.includes(ext);
+  let uncommented = "";
+  let bare = "";
+  const blank = (text: string) => text.replace(/[^\n]/g, " ");
+
+  let i = 0;
+  while (i < code.length) {
+    const ch = code[i];
+    const rest = code.slice(i, i + 2);
+    let end = -1;
+    if (rest === "//" || (hashComments && ch === "#")) {
+      end = code.indexOf("\n", i);
+      if (end === -1) end = code.length;
+      uncommented += blank(code.slice(i, end));
+      bare += blank(code.slice(i, end));
+      i = end;
+      continue;
+    }
+    if (rest === "/*" && !hashComments) {
+      const close = code.indexOf("*/", i + 2);
+      end = close === -1 ? code.length : close + 2;
+      uncommented += blank(code.slice(i, end));
+      bare += blank(code.slice(i, end));
+      i = end;
+      continue;
+    }
+    if (ch === '"' || ch === "`" || (ch === "'" && (!runeQuotes || /^'(?:\\.|[^\\'])'/.test(code.slice(i, i + 4))))) {
+      let j = i + 1;
+      while (j < code.length && code[j] !== ch && (ch === "`" || code[j] !== "\n")) j += code[j] === "\\" && ch !== "`" ? 2 : 1;
+      end = Math.min(j + 1, code.length);
+      uncommented += code.slice(i, end);
+      bare += ch + blank(code.slice(i + 1, end - 1)) + (end - i > 1 ? code[end - 1] : "");
+      i = end;
+      continue;
+    }
+    uncommented += ch;
+    bare += ch;
+    i++;
+  }
+  return { uncommented, bare };
+}
+
+// Every call in `code`, including calls nested in another's arguments, in source order
+export function findCallSites(code: string, filePath: string): CallSite[] {
+  const ext = path.extname(filePath).toLowerCase().slice(1);
+  const { uncommented, bare } = maskSource(code, ext);
+  const sites: CallSite[] = [];
+
+  for (const match of bare.matchAll(CALL_REGEX)) {
+    const callee = match[1].replace(/\s+/g, "");
+    const before = bare.slice(0, match.index!);
+    // A keyword, or the name of a function being declared
+    if (NON_CALLS.has(callee) || /(?:\bfunc|\bfunction|\bdef|\bfn)\s*(?:\([^)]*\)\s*)?$/.test(before)) continue;
+
+    const open = match.index! + match[0].length - 1;
+    let depth = 0;
+    let close = open;
+    for (; close < bare.length; close++) {
+      if (bare[close] === "(") depth++;
+      else if (bare[close] === ")" && --depth === 0) break;
+    }
+    sites.push({
+      callee,
+      args: uncommented.slice(open + 1, close).trim(),
+      line: before.split("\n").length,
+    });
+  }
+  return sites;
+}
+
+// ============================================
+// Matching
+// ============================================
+
+function patternRegex(pattern: string): RegExp {
+  const source = pattern
+    .split("*")
+    .map(part => part.replace(/[.+?^${}()|[\]\\]/g, "\\$&"))
+    .join("[\\p{L}\\p{N}_$.]*");
+  return new RegExp(`^${source}$`, "u");
+}
+
+// Validate config.yaml `lint.effectful_calls`; throws on the first bad entry
+export function validateEffectfulCalls(calls: unknown): void {
+  if (calls === undefined) return;
+  if (!Array.isArray(calls)) throw new Error("lint.effectful_calls must be a list of call patterns");
+  calls.forEach((entry, index) => {
+    const where = `lint.effectful_calls[${index}]`;
+    const call = typeof entry === "string" ? entry : (entry as { call?: unknown } | null)?.call;
+    if (typeof call !== "string" || call.trim() === "") {
+      throw new Error(`${where} must be a call pattern or { call, args }`);
+    }
+    const args = typeof entry === "string" ? undefined : (entry as { args?: unknown }).args;
+    if (args === undefined) return;
+    if (typeof args !== "string") throw new Error(`${where}.args must be a regular expression`);
+    try {
+      new RegExp(args, "iu");
+    } catch (error) {
+      throw new Error(`${where}.args is not a valid regular expression: ${(error as Error).message}`);
+    }
+  });
+}
+
+/**
+ * The calls in `code` that match one of `calls`, each with the first pattern
+ * it matched. A call's name matches when the pattern matches it or one of its
+ * dotted suffixes.
+ */
+export function findEffectfulCalls(code: string, filePath: string, calls: EffectfulCall[]): EffectfulCallSite[] {
+  if (calls.length === 0) return [];
+  const compiled = calls.map(entry => {
+    const { call, args } = typeof entry === "string" ? { call: entry, args: undefined } : entry;
+    return { pattern: call, callee: patternRegex(call), args: args !== undefined ? new RegExp(args, "iu") : undefined };
+  });
+
+  const found: EffectfulCallSite[] = [];
+  for (const site of findCallSites(code, filePath)) {
+    const segments = site.callee.split(".");
+    const suffixes = segments.map((_, i) => segments.slice(i).join("."));
+    const match = compiled.find(c => suffixes.some(s => c.callee.test(s)) && (!c.args || c.args.test(site.args)));
+    if (match) found.push({ ...site, pattern: match.pattern });
+  }
+  return found;
+}
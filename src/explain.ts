@@ -0,0 +1,344 @@
+/**
+ * explain-policy command for collab-claude-code
+ *
+ * Prints the effective governance configuration for one file: the merged
+ * trust.yaml (local settings plus `extends` layers), the active profile and
+ * why it was selected, which policies match the file and which one applies,
+ * symbol policies and the declarations they govern, region overrides and
+ * annotations in the file, a Go package's doc.go default, and the trust
+ * aliases in use.
+ * Every setting is tagged with the source it came from.
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import {
+  COLLAB_DIR,
+  PROFILE_ENV,
+  DeclarationSpan,
+  PackageDefault,
+  RegionOverride,
+  SymbolPolicy,
+  TRUST_FILE,
+  TrustConfig,
+  TrustLevel,
+  TrustPolicy,
+  TrustProfile,
+  TrustResult,
+  detectGitBranch,
+  fileExists,
+  findMatchingPolicy,
+  formatOwner,
+  formatRange,
+  isGeneratedSource,
+  isGoTestSource,
+  loadCollabConfig,
+  loadPackageDefault,
+  loadTrustAliases,
+  matchesPattern,
+  matchesSymbolPattern,
+  parseAnnotationContent,
+  rangeOf,
+  regionMatchesFile,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import { EXIT_CLEAN, EXIT_TOOL_ERROR, loadTrustConfigStrict } from "./check.js";
+import { PolicyDocument, PolicyLayer, loadPolicyLayers, mergeConfigs } from "./policy.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+const BUILT_IN = "built-in default";
+
+export interface ExplainedPolicy extends TrustPolicy {
+  source: string;
+  matches: boolean;
+  applies: boolean; // The most specific matching policy; other matches are shadowed
+  tied?: boolean; // Matches exactly as specifically as the one that applies, with different trust
+}
+
+export interface ExplainedSymbolPolicy extends SymbolPolicy {
+  source: string;
+  declarations: DeclarationSpan[]; // Declarations in the file this policy is the first match for
+}
+
+export interface ExplainedProfile {
+  name: string;
+  source: string;
+  selected_by: string; // "--profile", the environment variable, or the matching branch
+  overrides: TrustProfile["overrides"];
+}
+
+export interface PolicyExplanation {
+  file: string;
+  trust_file: string | null;
+  extends: string[];
+  default_trust: { value: TrustLevel; source: string };
+  profile?: ExplainedProfile;
+  policies: ExplainedPolicy[];
+  symbols: ExplainedSymbolPolicy[];
+  regions: (RegionOverride & { source: string })[]; // Only those for this file
+  annotations: { line_start: number; line_end: number; trust?: TrustLevel; owner?: string | string[] }[];
+  package_default?: PackageDefault; // From the @collab:package directive in the package's doc.go
+  aliases: { name: string; level: TrustLevel; source: string }[];
+  effective: TrustResult; // Lines outside every region and annotation
+}
+
+// ============================================
+// Provenance
+// ============================================
+
+async function profileSelection(config: TrustConfig, explicit?: string): Promise<string> {
+  if (explicit) return "--profile";
+  if (process.env[PROFILE_ENV]) return PROFILE_ENV;
+
+  const branch = await detectGitBranch();
+  const profile = config.active_profile ? config.profiles?.[config.active_profile] : undefined;
+  const pattern = branch ? profile?.branches?.find(p => matchesPattern(branch, p)) : undefined;
+  return pattern ? `branch "${branch}" matches "${pattern}"` : "branch";
+}
+
+export async function explainPolicy(
+  filePath: string,
+  options: { profile?: string } = {}
+): Promise<PolicyExplanation> {
+  const config = await loadTrustConfigStrict(options.profile);
+  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+  const hasTrustFile = await fileExists(trustPath);
+
+  let local: PolicyDocument = {};
+  let layers: PolicyLayer[] = [];
+  if (hasTrustFile) {
+    local = (yaml.parse(await fs.readFile(trustPath, "utf-8")) as PolicyDocument | null) ?? {};
+    layers = local.extends ? await loadPolicyLayers(local.extends) : [];
+  }
+  const { config: merged, provenance } = mergeConfigs([...layers, { source: trustPath, document: local }]);
+  const normalizedPath = filePath.replace(/\\/g, "/");
+
+  let content: string | undefined;
+  try {
+    content = await fs.readFile(filePath, "utf-8");
+  } catch {
+    // A path that does not exist yet still has an effective policy
+  }
+  const declarations = content !== undefined ? findDeclarations(content, filePath) : [];
+
+  const policies: ExplainedPolicy[] = [];
+  const symbols: ExplainedSymbolPolicy[] = [];
+  const regions: PolicyExplanation["regions"] = [];
+  ((merged.policies ?? []) as TrustPolicy[]).forEach((policy, i) => {
+    const source = provenance[`policies[${i}]`];
+    policies.push({ ...policy, source, matches: matchesPattern(normalizedPath, policy.pattern), applies: false });
+  });
+  ((merged.symbols ?? []) as SymbolPolicy[]).forEach((symbol, i) => {
+    symbols.push({ ...symbol, source: provenance[`symbols[${i}]`], declarations: [] });
+  });
+  ((merged.regions ?? []) as RegionOverride[]).forEach((region, i) => {
+    if (regionMatchesFile(region, normalizedPath)) {
+      regions.push({ ...region, source: provenance[`regions[${i}]`] });
+    }
+  });
+
+  const winner = findMatchingPolicy(policies, normalizedPath);
+  for (const policy of policies) {
+    policy.applies = policy === winner?.policy;
+    if (winner?.ties.includes(policy)) policy.tied = true;
+  }
+
+  for (const declaration of declarations) {
+    const first = symbols.find(s => matchesSymbolPattern(declaration.qualified_name, s.pattern));
+    first?.declarations.push({
+      qualified_name: declaration.qualified_name,
+      line_start: declaration.line_start,
+      line_end: declaration.line_end,
+    });
+  }
+
+  let profile: ExplainedProfile | undefined;
+  if (config.active_profile) {
+    const name = config.active_profile;
+    profile = {
+      name,
+      source: provenance[`profiles.${name}`] ?? trustPath,
+      selected_by: await profileSelection(config, options.profile),
+      overrides: config.profiles?.[name]?.overrides,
+    };
+  }
+
+  const customAliases = (await loadCollabConfig()).aliases ?? {};
+  const aliases = Object.entries(await loadTrustAliases()).map(([name, level]) => ({
+    name,
+    level,
+    source: name in customAliases ? path.join(COLLAB_DIR, "config.yaml") : BUILT_IN,
+  }));
+
+  const packageDefault = await loadPackageDefault(filePath);
+
+  let annotations: PolicyExplanation["annotations"] = [];
+  if (content !== undefined) {
+    const parsed = parseAnnotationContent(content, filePath, { aliases: await loadTrustAliases() });
+    annotations = parsed.annotations.map(a => ({
+      line_start: a.line_start,
+      line_end: a.line_end,
+      trust: a.trust,
+      owner: a.owner,
+    }));
+  }
+
+  return {
+    file: filePath,
+    trust_file: hasTrustFile ? trustPath : null,
+    extends: layers.map(layer => layer.source),
+    default_trust: {
+      value: config.default_trust,
+      source: provenance.default_trust ?? BUILT_IN,
+    },
+    profile,
+    policies,
+    symbols,
+    regions,
+    annotations,
+    package_default: packageDefault,
+    aliases,
+    effective: resolveTrustWithAnnotations(
+      config, filePath, [], undefined, undefined, [], content !== undefined && isGeneratedSource(content), packageDefault,
+      content !== undefined && isGoTestSource(filePath, content)
+    ),
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatExplanationText(explanation: PolicyExplanation): string {
+  const e = explanation;
+  const lines = [
+    `Effective policy for ${e.file}`,
+    `  trust file: ${e.trust_file ?? "(none)"}`,
+  ];
+  if (e.extends.length > 0) {
+    lines.push(`  extends: ${e.extends.join(", ")}`);
+  }
+  lines.push(`  default_trust: ${e.default_trust.value} [${e.default_trust.source}]`);
+
+  if (e.profile) {
+    lines.push(`  profile: ${e.profile.name} (selected by ${e.profile.selected_by}) [${e.profile.source}]`);
+    for (const [from, to] of Object.entries(e.profile.overrides ?? {})) {
+      lines.push(`    ${from} -> ${to}`);
+    }
+  } else {
+    lines.push("  profile: (none)");
+  }
+
+  lines.push(
+    "",
+    "Policies (most specific match applies; * applies, ~ matches but is shadowed, ! ties with the one that applies):"
+  );
+  if (e.policies.length === 0) lines.push("  (none)");
+  const patternWidth = Math.max(0, ...e.policies.map(p => p.pattern.length));
+  for (const p of e.policies) {
+    const marker = p.applies ? "*" : p.tied ? "!" : p.matches ? "~" : " ";
+    const owner = formatOwner(p.owner);
+    lines.push(
+      `  ${marker} ${p.pattern.padEnd(patternWidth)}  ${p.trust.padEnd(12)}  [${p.source}]${owner ? `  owner ${owner}` : ""}`
+    );
+  }
+
+  if (e.symbols.length > 0) {
+    lines.push("", "Symbol policies (below annotations and regions, above path policies):");
+    for (const s of e.symbols) {
+      const owner = formatOwner(s.owner);
+      lines.push(`  ${s.pattern}  ${s.trust}  [${s.source}]${owner ? `  owner ${owner}` : ""}`);
+      for (const d of s.declarations) {
+        const annotated = e.annotations.some(a => a.trust && a.line_start <= d.line_end && a.line_end >= d.line_start);
+        lines.push(`    ${d.qualified_name}  ${formatRange(rangeOf(d))}${annotated ? "  (annotation takes precedence)" : ""}`);
+      }
+    }
+  }
+
+  lines.push("", "Regions for this file:");
+  if (e.regions.length === 0) lines.push("  (none)");
+  for (const r of e.regions) {
+    lines.push(`  ${formatRange(rangeOf(r))}  ${r.trust}${r.reason ? `  ${r.reason}` : ""}  [${r.source}]`);
+  }
+
+  lines.push("", "Annotations in this file:");
+  if (e.annotations.length === 0) lines.push("  (none)");
+  for (const a of e.annotations) {
+    const owner = formatOwner(a.owner);
+    lines.push(`  ${formatRange(rangeOf(a))}  ${a.trust ?? "-"}${owner ? `  owner ${owner}` : ""}`);
+  }
+
+  if (e.package_default) {
+    const p = e.package_default;
+    const owner = formatOwner(p.owner);
+    lines.push(
+      "",
+      "Package default (below every policy):",
+      `  ${p.trust ?? "-"}${owner ? `  owner ${owner}` : ""}  [${formatRange(rangeOf(p), p.file.replace(/\\/g, "/"))}]`
+    );
+  }
+
+  const custom = e.aliases.filter(a => a.source !== BUILT_IN);
+  lines.push(
+    "",
+    `Aliases: ${e.aliases.filter(a => a.source === BUILT_IN).map(a => `${a.name}=${a.level}`).join(", ")} [${BUILT_IN}]`
+  );
+  if (custom.length > 0) {
+    lines.push(`         ${custom.map(a => `${a.name}=${a.level}`).join(", ")} [${custom[0].source}]`);
+  }
+
+  const { effective } = e;
+  lines.push(
+    "",
+    `Elsewhere in the file: ${effective.level}` +
+      (effective.base_level ? ` (was ${effective.base_level} before profile ${effective.profile})` : "") +
+      ` from ${effective.source}${effective.pattern ? ` "${effective.pattern}"` : ""}` +
+      (effective.reason ? ` (${effective.reason})` : "")
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runExplainPolicy(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  const files: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      files.push(arg);
+    }
+  }
+
+  if (files.length !== 1) {
+    console.error("Usage: collab-claude-code explain-policy <file> [--format=text|json] [--profile=<name>]");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const explanation = await explainPolicy(files[0], { profile });
+    console.log(format === "json" ? stableStringify(explanation, 2) : formatExplanationText(explanation));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
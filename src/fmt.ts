@@ -0,0 +1,150 @@
+/**
+ * fmt command for collab-claude-code
+ *
+ * Rewrites @collab comments into the canonical form formatAnnotation
+ * produces, in place. Only annotation comments are touched. With --check
+ * nothing is written and the command fails if any file would change, for CI.
+ *
+ * Files named by trust.yaml regions are formatted without adding or removing
+ * lines, since regions are pinned to line numbers.
+ *
+ * Exit codes:
+ *   0 = Every file is formatted (or was just formatted)
+ *   1 = --check found files that need formatting
+ *   2 = Tool error (bad arguments, unreadable file, invalid config)
+ */
+
+import * as fs from "fs/promises";
+
+import { SkippedAnnotation, formatAnnotationComments, formatRange, loadTrustAliases, rangeOf, stableStringify } from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  EXIT_VIOLATIONS,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface FmtOptions extends CheckOptions {
+  check?: boolean; // Report files that would change without writing them
+}
+
+export interface FileFmtResult {
+  file: string;
+  changed: number; // Annotations rewritten (or that would be, with --check)
+  skipped: SkippedAnnotation[];
+}
+
+export interface FmtReport {
+  files: FileFmtResult[]; // Only files with changes or skipped annotations
+  checked: number;
+  total_changed_files: number;
+}
+
+// ============================================
+// Formatting
+// ============================================
+
+export async function formatFiles(paths: string[], options: FmtOptions = {}): Promise<FmtReport> {
+  const { signal } = options;
+  const config = await loadTrustConfigStrict();
+  const aliases = await loadTrustAliases();
+  const regionFiles = (config.regions ?? []).map(r => r.file.replace(/\\/g, "/"));
+  const files = await expandPaths(paths, options);
+
+  const results: FileFmtResult[] = [];
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    const normalizedPath = file.replace(/\\/g, "/");
+    const formatted = formatAnnotationComments(content, file, {
+      aliases,
+      preserveLineCount: regionFiles.some(r => normalizedPath === r || normalizedPath.endsWith(r)),
+    });
+    if (formatted.content !== content && !options.check) {
+      await fs.writeFile(file, formatted.content);
+    }
+    if (formatted.changed > 0 || formatted.skipped.length > 0) {
+      results.push({ file, changed: formatted.changed, skipped: formatted.skipped });
+    }
+  }
+
+  return {
+    files: results,
+    checked: files.length,
+    total_changed_files: results.filter(r => r.changed > 0).length,
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatFmtText(report: FmtReport, check = false): string {
+  const lines: string[] = [];
+  for (const result of report.files) {
+    if (result.changed > 0) {
+      lines.push(`${check ? "would reformat" : "reformatted"} ${result.file} (${result.changed} annotation(s))`);
+    }
+    for (const s of result.skipped) {
+      lines.push(`${formatRange(rangeOf(s), result.file)}: left unchanged: ${s.reason}`);
+    }
+  }
+
+  const changed = report.total_changed_files;
+  lines.push(
+    changed === 0
+      ? `Checked ${report.checked} file(s): all annotations formatted`
+      : `Checked ${report.checked} file(s): ${changed} ${check ? "need formatting" : "reformatted"}`
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runFmt(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let check = false;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg === "--check") {
+      check = true;
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await formatFiles(paths, { check, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatFmtText(report, check));
+    return check && report.total_changed_files > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
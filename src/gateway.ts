@@ -0,0 +1,501 @@
+/**
+ * HTTP middleware for agent gateways
+ *
+ * For teams that proxy an agent's edits through an HTTP service: a request
+ * handler that takes an edit (file, line range, new content) as JSON, resolves
+ * the trust level that governs it, and answers allow / propose / deny. For
+ * SUGGEST_ONLY code it also creates a pending proposal, exactly as
+ * collab_propose_change does, and hands it to a Notifier. With
+ * suggest.auto_approve_trivial, trivial edits to SUGGEST_ONLY code are
+ * allowed instead, as the pre-edit hook allows them.
+ *
+ * The handler has the Node (req, res) signature, so it works with
+ * http.createServer and mounts as a route in Express-style frameworks.
+ *
+ *   POST <mount path>  {"file_path": "src/pay.ts", "line_start": 10, "line_end": 12, "content": "..."}
+ *   200                {"decision": "propose", "trust_level": "SUGGEST_ONLY", "proposal_id": "k3j9x2a1", ...}
+ *
+ * In shadow mode every edit is allowed; would-be denials and proposals are
+ * recorded in .collab/audit.jsonl and reported as shadow_decision.
+ *
+ * The agent's identity is whatever GatewayOptions.authenticate verifies for
+ * the caller, never a field of the request body. config.yaml `agents` can cap
+ * what an identity may do: regions above its max_trust are proposed instead
+ * of edited, and an agent whose max_trust is SUGGEST_ONLY may edit the
+ * SUGGEST_ONLY regions it owns directly. Once any agent is listed, callers
+ * that are unauthenticated or not listed get the most restrictive cap.
+ * READ_ONLY is denied to everyone.
+ *
+ * classifyEditsByAuthorship serves PR bots the same way: given a PR's author
+ * and the ranges it changes, it separates the SUGGEST_ONLY regions the author
+ * owns, which can be approved automatically, from those needing review.
+ *
+ * Both count a region as owned by an identity on one of its owning teams, as
+ * an IdentityResolver (identity.ts) reports them.
+ */
+
+import * as fs from "fs/promises";
+import type { IncomingMessage, ServerResponse } from "http";
+import * as path from "path";
+
+import {
+  AgentPolicy,
+  OwnerContact,
+  Proposal,
+  TRUST_RESTRICTIVENESS,
+  TrustLevel,
+  TrustResult,
+  createProposal,
+  expandOwners,
+  fileExists,
+  getTrustLevelWithAnnotations,
+  isShadowMode,
+  loadCollabConfig,
+  loadOwnerRegistry,
+  loadTrustConfig,
+  ownerList,
+  recordAuditEntries,
+} from "./collab.js";
+import { IdentityResolver, createIdentityResolver, isOwnedBy } from "./identity.js";
+import { log } from "./log.js";
+import { isTrivialEdit } from "./trivial.js";
+
+// ============================================
+// Types
+// ============================================
+
+// Resolves the trust level governing lines of a file (the whole file without a range)
+export interface Resolver {
+  // `signal` aborts when the caller has gone, e.g. the HTTP client disconnected
+  resolve(filePath: string, lineStart?: number, lineEnd?: number, signal?: AbortSignal): Promise<TrustResult>;
+}
+
+// Told about every proposal the gateway creates, e.g. to message its owners
+export interface Notifier {
+  proposalCreated(proposal: Proposal, trust: TrustResult): Promise<void>;
+}
+
+export interface EditRequest {
+  file_path: string; // Relative to the project root (the server's working directory)
+  line_start?: number; // 1-indexed, inclusive; omit both to replace the whole file
+  line_end?: number; // Defaults to line_start
+  content: string; // Replacement for the lines
+  description?: string; // For the proposal, if one is created
+  rationale?: string;
+  confidence?: number; // 0-1 (default 0.5)
+}
+
+export type EditDecision = "allow" | "propose" | "deny";
+
+export interface EditResponse {
+  decision: EditDecision;
+  trust_level: TrustLevel;
+  reason?: string;
+  owner?: string | string[];
+  source?: TrustResult["source"];
+  profile?: string;
+  proposal_id?: string; // Set when decision is "propose"
+  shadow_decision?: EditDecision; // Shadow mode: what enforcement would have answered instead of "allow"
+  agent?: string; // The authenticated caller, when a config.yaml agent policy applied to it
+  agent_reason?: string; // Why the agent policy changed the decision the region's trust gives
+  auto_approved?: boolean; // A trivial SUGGEST_ONLY edit allowed without a proposal (suggest.auto_approve_trivial)
+  min_approvals?: number;
+  design_doc?: string; // Design doc the proposal's approvers must acknowledge
+  notify?: string[]; // Owners of the region the proposal replaces
+  notify_error?: string; // The proposal was saved but the Notifier failed
+}
+
+// The lines of a file a change touches; both lines omitted means the whole file
+export type EditedRange = Pick<EditRequest, "file_path" | "line_start" | "line_end">;
+
+export interface AuthorshipEntry extends EditedRange {
+  trust_level: TrustLevel;
+  owner?: string | string[];
+  via?: string; // Owned: the co-owner or group through which the author owns the region
+}
+
+export interface AuthorshipClassification {
+  owned: AuthorshipEntry[]; // SUGGEST_ONLY regions the author owns: eligible for auto-approval
+  needs_review: AuthorshipEntry[]; // SUGGEST_ONLY regions of other owners, and all READ_ONLY regions
+}
+
+export interface GatewayOptions {
+  resolver: Resolver;
+  notifier: Notifier;
+  maxBodyBytes?: number; // Larger request bodies get 413 (default: 1 MiB)
+  shadow?: boolean; // Allow everything, auditing would-be decisions (default: enforcement in config.yaml)
+  identities?: IdentityResolver; // Teams of an agent, for the regions it owns (default: config.yaml identities)
+  // The calling agent's verified identity, e.g. from a client certificate or token; undefined when
+  // unauthenticated, and a throw answers 401. Without it every caller is unauthenticated.
+  authenticate?: (req: IncomingMessage) => string | undefined | Promise<string | undefined>;
+}
+
+// ============================================
+// Constants
+// ============================================
+
+const DEFAULT_MAX_BODY_BYTES = 1024 * 1024;
+
+export const DECISIONS: Record<TrustLevel, EditDecision> = {
+  AUTONOMOUS: "allow",
+  SUPERVISED: "allow",
+  SUGGEST_ONLY: "propose",
+  READ_ONLY: "deny",
+};
+
+// For callers config.yaml `agents` does not list, once it lists any: edit AUTONOMOUS code, propose the rest
+export const UNLISTED_AGENT_POLICY: AgentPolicy = { max_trust: "AUTONOMOUS" };
+
+// ============================================
+// Agent Policies
+// ============================================
+
+/**
+ * The decision for `agent` editing a region, given its policy. Regions more
+ * restrictive than max_trust need a proposal; a SUGGEST_ONLY region the agent
+ * owns, itself or through one of its `teams`, is allowed when max_trust
+ * reaches SUGGEST_ONLY. `reason` is set when the result differs from the
+ * region's own decision.
+ */
+export function agentDecision(
+  trust: TrustResult,
+  agent: string,
+  policy: AgentPolicy,
+  teams: string[] = []
+): { decision: EditDecision; reason?: string } {
+  const decision = DECISIONS[trust.level];
+  if (trust.level === "READ_ONLY") return { decision };
+  if (TRUST_RESTRICTIVENESS[trust.level] > TRUST_RESTRICTIVENESS[policy.max_trust]) {
+    return decision === "allow"
+      ? { decision: "propose", reason: `${trust.level} is above agent "${agent}"'s max_trust ${policy.max_trust}` }
+      : { decision };
+  }
+  const via = trust.level === "SUGGEST_ONLY" ? ownerList(trust.owner).find(o => isOwnedBy(o, agent, teams)) : undefined;
+  if (via === agent) {
+    return { decision: "allow", reason: `agent "${agent}" owns this SUGGEST_ONLY region and may edit it directly` };
+  }
+  if (via !== undefined) {
+    return {
+      decision: "allow",
+      reason: `agent "${agent}" is on team "${via}", which owns this SUGGEST_ONLY region, and may edit it directly`,
+    };
+  }
+  return { decision };
+}
+
+// ============================================
+// Authorship
+// ============================================
+
+/**
+ * Sort a change's governed regions by whether `author` owns them. An author
+ * owns a SUGGEST_ONLY region when they are one of its owners or a member of
+ * one, through nested groups, or is on one of the owning teams `identities`
+ * reports; `owners` is the registry holding the groups (default: config.yaml
+ * for both). READ_ONLY regions always need review, and regions less
+ * restrictive than SUGGEST_ONLY are in neither list.
+ */
+export async function classifyEditsByAuthorship(
+  edits: EditedRange[],
+  author: string,
+  resolver: Resolver,
+  owners?: Record<string, OwnerContact>,
+  identities?: IdentityResolver
+): Promise<AuthorshipClassification> {
+  const registry = owners ?? (await loadOwnerRegistry()) ?? {};
+  const teams = await (identities ?? createIdentityResolver()).teamsFor(author);
+  const classification: AuthorshipClassification = { owned: [], needs_review: [] };
+  for (const edit of edits) {
+    const lineEnd = edit.line_start !== undefined ? edit.line_end ?? edit.line_start : undefined;
+    const trust = await resolver.resolve(edit.file_path, edit.line_start, lineEnd);
+    if (trust.level !== "SUGGEST_ONLY" && trust.level !== "READ_ONLY") continue;
+
+    const entry: AuthorshipEntry = { ...edit, line_end: lineEnd, trust_level: trust.level, owner: trust.owner };
+    const via = ownerList(trust.owner).find(key => expandOwners(key, registry).some(o => isOwnedBy(o, author, teams)));
+    if (trust.level === "SUGGEST_ONLY" && via !== undefined) {
+      classification.owned.push({ ...entry, via });
+    } else {
+      classification.needs_review.push(entry);
+    }
+  }
+  return classification;
+}
+
+// ============================================
+// Resolution
+// ============================================
+
+/**
+ * The default Resolver: annotations, trust.yaml, and the active profile, as
+ * collab_check_trust resolves them. trust.yaml is read on every request, so
+ * changes apply without restarting the gateway.
+ */
+export function createResolver(options: { profile?: string } = {}): Resolver {
+  return {
+    async resolve(filePath, lineStart, lineEnd, signal) {
+      const config = await loadTrustConfig({ profile: options.profile });
+      signal?.throwIfAborted();
+      return getTrustLevelWithAnnotations(config, filePath, lineStart, lineEnd);
+    },
+  };
+}
+
+// ============================================
+// Request Handling
+// ============================================
+
+export class EditRequestError extends Error {}
+
+function isLine(value: unknown): value is number {
+  return Number.isInteger(value) && (value as number) >= 1;
+}
+
+// Checks the documented request schema; throws EditRequestError on anything else
+export function parseEditRequest(body: unknown): EditRequest {
+  if (!body || typeof body !== "object" || Array.isArray(body)) {
+    throw new EditRequestError("request body must be a JSON object");
+  }
+  const request = body as Record<string, unknown>;
+
+  if (typeof request.file_path !== "string" || request.file_path === "") {
+    throw new EditRequestError("file_path is required");
+  }
+  const resolved = path.resolve(request.file_path);
+  const relative = path.relative(process.cwd(), resolved);
+  if (relative === "" || relative.startsWith("..") || path.isAbsolute(relative)) {
+    throw new EditRequestError(`file_path must be inside the project: ${request.file_path}`);
+  }
+  if (typeof request.content !== "string") {
+    throw new EditRequestError("content is required");
+  }
+  if (request.line_start !== undefined && !isLine(request.line_start)) {
+    throw new EditRequestError("line_start must be a positive integer");
+  }
+  if (request.line_end !== undefined && (!isLine(request.line_end) || request.line_start === undefined)) {
+    throw new EditRequestError("line_end must be a positive integer and needs line_start");
+  }
+  if (isLine(request.line_end) && (request.line_end as number) < (request.line_start as number)) {
+    throw new EditRequestError("line_end must not be before line_start");
+  }
+  for (const key of ["description", "rationale"]) {
+    if (request[key] !== undefined && typeof request[key] !== "string") {
+      throw new EditRequestError(`${key} must be a string`);
+    }
+  }
+  const confidence = request.confidence;
+  if (confidence !== undefined && !(typeof confidence === "number" && confidence >= 0 && confidence <= 1)) {
+    throw new EditRequestError("confidence must be a number from 0 to 1");
+  }
+
+  return {
+    ...(request as unknown as EditRequest),
+    file_path: relative.replace(/\\/g, "/"),
+  };
+}
+
+// The text an edit replaces: the requested lines, or the whole file
+async function replacedCode(request: EditRequest): Promise<string> {
+  let content: string;
+  try {
+    content = await fs.readFile(request.file_path, "utf-8");
+  } catch {
+    return ""; // A new file
+  }
+  if (request.line_start === undefined) return content;
+
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  return lines.slice(request.line_start - 1, request.line_end ?? request.line_start).join("\n");
+}
+
+/**
+ * Decide an edit request: the core of the HTTP handler, usable without HTTP.
+ * `agent` is the caller's authenticated identity, if any. SUGGEST_ONLY edits
+ * are saved as pending proposals and passed to the notifier, unless they are
+ * trivial (trivial.ts) and suggest.auto_approve_trivial is on. Once `signal`
+ * aborts, nothing more is recorded: no audit entry, proposal, or notification.
+ */
+export async function decideEdit(
+  request: EditRequest,
+  options: GatewayOptions,
+  agent?: string,
+  signal?: AbortSignal
+): Promise<EditResponse> {
+  signal?.throwIfAborted();
+  const lineEnd = request.line_start !== undefined ? request.line_end ?? request.line_start : undefined;
+  const trust = await options.resolver.resolve(request.file_path, request.line_start, lineEnd, signal);
+  signal?.throwIfAborted();
+  const config = await loadCollabConfig();
+  const agents = config.agents ?? {};
+  const listed = agent !== undefined && Object.hasOwn(agents, agent);
+  const policy = listed ? agents[agent!] : Object.keys(agents).length > 0 ? UNLISTED_AGENT_POLICY : undefined;
+  const response: EditResponse = {
+    decision: DECISIONS[trust.level],
+    trust_level: trust.level,
+    reason: trust.reason,
+    owner: trust.owner,
+    source: trust.source,
+    profile: trust.profile,
+  };
+  if (policy) {
+    const teams =
+      listed && trust.level === "SUGGEST_ONLY" ? await (options.identities ?? createIdentityResolver()).teamsFor(agent!) : [];
+    signal?.throwIfAborted();
+    const limited = agentDecision(trust, agent ?? "", policy, teams);
+    response.agent = agent;
+    response.decision = limited.decision;
+    if (limited.reason) {
+      const caller = agent === undefined ? "an unauthenticated caller" : `agent "${agent}"`;
+      response.agent_reason = listed
+        ? limited.reason
+        : `${caller} is not in config.yaml agents, so it is capped at max_trust ${policy.max_trust}`;
+    }
+  }
+  if (
+    response.decision === "propose" &&
+    trust.level === "SUGGEST_ONLY" &&
+    config.suggest?.auto_approve_trivial &&
+    (await fileExists(request.file_path)) &&
+    isTrivialEdit(await replacedCode(request), request.content, request.file_path)
+  ) {
+    signal?.throwIfAborted();
+    response.decision = "allow";
+    response.auto_approved = true;
+    log.info("auto-approved trivial edit", { file: request.file_path, line_start: request.line_start, line_end: lineEnd });
+  }
+  log.info("edit decision", {
+    file: request.file_path,
+    line_start: request.line_start,
+    line_end: lineEnd,
+    decision: response.decision,
+    trust: trust.level,
+    source: trust.source,
+    agent: response.agent,
+  });
+  if (response.decision !== "allow" && (options.shadow ?? isShadowMode(config))) {
+    signal?.throwIfAborted();
+    await recordAuditEntries([
+      {
+        timestamp: new Date().toISOString(),
+        mode: "shadow",
+        source: "gateway",
+        file_path: request.file_path,
+        line: request.line_start,
+        trust: trust.level,
+        would_decide: response.decision,
+        message: response.agent_reason ?? trust.reason ?? `${request.file_path} is ${trust.level}`,
+      },
+    ]);
+    response.shadow_decision = response.decision;
+    response.decision = "allow";
+    return response;
+  }
+  if (response.decision !== "propose") return response;
+
+  const oldCode = await replacedCode(request);
+  signal?.throwIfAborted();
+  const proposal = await createProposal({
+    file_path: request.file_path,
+    description: request.description ?? `Edit to ${request.file_path} via gateway`,
+    rationale: request.rationale,
+    old_code: oldCode,
+    new_code: request.content,
+    confidence: request.confidence ?? 0.5,
+    author: agent,
+    line_start: request.line_start,
+    line_end: lineEnd,
+  });
+  response.proposal_id = proposal.id;
+  response.min_approvals = proposal.min_approvals;
+  response.design_doc = proposal.design_doc;
+  response.notify = proposal.owners;
+
+  try {
+    await options.notifier.proposalCreated(proposal, trust);
+  } catch (error) {
+    response.notify_error = error instanceof Error ? error.message : String(error);
+    log.warn("notifier failed", { proposal_id: proposal.id, error: response.notify_error });
+  }
+  return response;
+}
+
+function send(res: ServerResponse, status: number, body: unknown, headers: Record<string, string> = {}): void {
+  res.writeHead(status, { "Content-Type": "application/json", ...headers });
+  res.end(JSON.stringify(body, null, 2));
+}
+
+async function readBody(req: IncomingMessage, maxBytes: number): Promise<string | null> {
+  const chunks: Buffer[] = [];
+  let size = 0;
+  for await (const chunk of req) {
+    size += (chunk as Buffer).length;
+    if (size > maxBytes) return null;
+    chunks.push(chunk as Buffer);
+  }
+  return Buffer.concat(chunks).toString("utf-8");
+}
+
+/**
+ * Build the gateway's request handler. Responds 200 with an EditResponse for
+ * every well-formed request, whatever the decision; 400 for a body that does
+ * not match EditRequest, 401 when `authenticate` rejects the caller, 405 for
+ * methods other than POST, 413 for oversized bodies, and 500 if resolution
+ * fails. Errors are `{"error": "..."}`.
+ *
+ * A body already parsed by framework middleware (req.body) is used as is.
+ * A client that disconnects before its answer aborts the decision (see
+ * decideEdit), so an abandoned request files no proposal.
+ */
+export function createEditGateway(
+  options: GatewayOptions
+): (req: IncomingMessage, res: ServerResponse) => Promise<void> {
+  const maxBytes = options.maxBodyBytes ?? DEFAULT_MAX_BODY_BYTES;
+
+  return async (req, res) => {
+    if (req.method !== "POST") {
+      send(res, 405, { error: "use POST" }, { Allow: "POST" });
+      return;
+    }
+
+    let request: EditRequest;
+    try {
+      let body = (req as IncomingMessage & { body?: unknown }).body;
+      if (body === undefined) {
+        const raw = await readBody(req, maxBytes);
+        if (raw === null) {
+          send(res, 413, { error: `request body exceeds ${maxBytes} bytes` });
+          return;
+        }
+        body = JSON.parse(raw);
+      }
+      request = parseEditRequest(body);
+    } catch (error) {
+      const message = error instanceof SyntaxError ? `invalid JSON: ${error.message}` : (error as Error).message;
+      send(res, 400, { error: message });
+      return;
+    }
+
+    let agent: string | undefined;
+    try {
+      agent = await options.authenticate?.(req);
+    } catch (error) {
+      send(res, 401, { error: error instanceof Error ? error.message : String(error) });
+      return;
+    }
+
+    // The response closing before it was written means the client went away
+    const disconnected = new AbortController();
+    res.on("close", () => {
+      if (!res.writableFinished) disconnected.abort(new Error("client disconnected"));
+    });
+
+    try {
+      send(res, 200, await decideEdit(request, options, agent, disconnected.signal));
+    } catch (error) {
+      if (disconnected.signal.aborted) {
+        log.info("edit request abandoned", { file: request.file_path });
+        return;
+      }
+      send(res, 500, { error: error instanceof Error ? error.message : String(error) });
+    }
+  };
+}
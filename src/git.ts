@@ -0,0 +1,173 @@
+/**
+ * Minimal git helpers for revision-aware features
+ */
+
+import { execFile } from "child_process";
+import * as path from "path";
+import { promisify } from "util";
+
+export async function git(args: string[], signal?: AbortSignal): Promise<string> {
+  // Promisified per call, so merely loading this module (as the browser bundle does) needs no util
+  const { stdout } = await promisify(execFile)("git", args, { maxBuffer: 64 * 1024 * 1024, signal });
+  return stdout;
+}
+
+// Full commit hash for a revision, or an error naming it
+export async function resolveCommit(revision: string, signal?: AbortSignal): Promise<string> {
+  try {
+    return (await git(["rev-parse", "--verify", "--quiet", `${revision}^{commit}`], signal)).trim();
+  } catch {
+    signal?.throwIfAborted();
+    throw new Error(`Unknown revision: ${revision}`);
+  }
+}
+
+// Returns null if the file does not exist at that revision
+export async function readFileAtRevision(
+  revision: string,
+  filePath: string,
+  signal?: AbortSignal
+): Promise<string | null> {
+  const gitPath = filePath.replace(/\\/g, "/").replace(/^\.\//, "");
+  try {
+    return await git(["show", `${revision}:${gitPath}`], signal);
+  } catch {
+    signal?.throwIfAborted();
+    return null;
+  }
+}
+
+// Whether the path is a file at that revision, without reading its content
+export async function fileExistsAtRevision(revision: string, filePath: string, signal?: AbortSignal): Promise<boolean> {
+  const gitPath = filePath.replace(/\\/g, "/").replace(/^\.\//, "");
+  try {
+    return (await git(["cat-file", "-t", `${revision}:${gitPath}`], signal)).trim() === "blob";
+  } catch {
+    signal?.throwIfAborted();
+    return false;
+  }
+}
+
+export interface ChangedFile {
+  status: "A" | "M" | "D" | "R"; // Copies are reported as additions
+  path: string; // Path in head (in base for deletions)
+  old_path?: string; // Path in base, for renames
+}
+
+// Like listChangedFiles, with git rename detection so moves are not remove + add
+export async function listChangedFilesWithRenames(
+  base: string,
+  head?: string,
+  signal?: AbortSignal
+): Promise<ChangedFile[]> {
+  const args = ["diff", "--name-status", "-M", "-z", base];
+  if (head) args.push(head);
+  args.push("--");
+  const fields = (await git(args, signal)).split("\0").filter(Boolean);
+
+  const files: ChangedFile[] = [];
+  for (let i = 0; i < fields.length; ) {
+    const status = fields[i++];
+    if (status.startsWith("R")) {
+      files.push({ status: "R", old_path: fields[i], path: fields[i + 1] });
+      i += 2;
+    } else if (status.startsWith("C")) {
+      files.push({ status: "A", path: fields[i + 1] });
+      i += 2;
+    } else {
+      const kind = status[0] === "A" || status[0] === "D" ? status[0] : "M";
+      files.push({ status: kind, path: fields[i++] });
+    }
+  }
+  return files.sort((a, b) => a.path.localeCompare(b.path));
+}
+
+/**
+ * Line ranges of a file in head (or the working tree) that differ from base,
+ * from a zero-context diff. A pure deletion is reported as the line the
+ * removed text sat next to, so deleting code still counts as touching it.
+ */
+export async function listChangedLines(
+  base: string,
+  head: string | undefined,
+  file: ChangedFile,
+  signal?: AbortSignal
+): Promise<{ line_start: number; line_end: number }[]> {
+  const args = ["diff", "-U0", "--no-color", "--no-ext-diff", "-M", base];
+  if (head) args.push(head);
+  args.push("--", file.path);
+  if (file.old_path) args.push(file.old_path);
+
+  const ranges: { line_start: number; line_end: number }[] = [];
+  for (const match of (await git(args, signal)).matchAll(/^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@/gm)) {
+    const start = parseInt(match[1], 10);
+    const count = match[2] === undefined ? 1 : parseInt(match[2], 10);
+    ranges.push(count === 0
+      ? { line_start: Math.max(1, start), line_end: Math.max(1, start) + 1 }
+      : { line_start: start, line_end: start + count - 1 });
+  }
+  return ranges;
+}
+
+// Files that differ between two revisions, or between a revision and the working tree
+export async function listChangedFiles(base: string, head?: string, signal?: AbortSignal): Promise<string[]> {
+  const args = ["diff", "--name-only", base];
+  if (head) args.push(head);
+  args.push("--");
+  const output = await git(args, signal);
+  return output.split("\n").map(l => l.trim()).filter(Boolean).sort();
+}
+
+/**
+ * "Name <email>" of the author of the latest commit in base..head that touched
+ * the file, or undefined if none did. Against the working tree, undefined when
+ * the file has uncommitted changes, since no commit made them yet.
+ */
+export async function lastAuthorSince(
+  base: string,
+  head: string | undefined,
+  files: string[],
+  signal?: AbortSignal
+): Promise<string | undefined> {
+  if (!head && (await git(["status", "--porcelain", "--", ...files], signal)).trim()) return undefined;
+  const output = await git(["log", "-1", "--format=%an <%ae>", `${base}..${head ?? "HEAD"}`, "--", ...files], signal);
+  return output.trim() || undefined;
+}
+
+export interface BlameLine {
+  line: number; // 1-indexed, in the working-tree file
+  commit: string;
+  author: string; // "Name <email>"
+  time: number; // Committer time, seconds since the epoch
+}
+
+// Lines start-end of the working-tree file as git blame attributes them; lines not committed yet are left out
+export async function blameLines(file: string, start: number, end: number, signal?: AbortSignal): Promise<BlameLine[]> {
+  const output = await git(["blame", "--line-porcelain", "-L", `${start},${end}`, "--", file], signal);
+  const lines: BlameLine[] = [];
+  let current: Partial<BlameLine> & { mail?: string } = {};
+  for (const row of output.split("\n")) {
+    const header = /^([0-9a-f]{40}) \d+ (\d+)/.exec(row);
+    if (header) {
+      current = { commit: header[1], line: parseInt(header[2], 10) };
+    } else if (row.startsWith("author ")) {
+      current.author = row.slice("author ".length);
+    } else if (row.startsWith("author-mail ")) {
+      current.mail = row.slice("author-mail ".length);
+    } else if (row.startsWith("committer-time ")) {
+      current.time = parseInt(row.slice("committer-time ".length), 10);
+    } else if (row.startsWith("\t") && current.commit && !/^0+$/.test(current.commit)) {
+      const { line, commit, author, mail, time } = current;
+      lines.push({ line: line!, commit, author: `${author} ${mail}`, time: time ?? 0 });
+    }
+  }
+  return lines;
+}
+
+// The files among `files` git tracks, as given
+export async function listTrackedFiles(files: string[], signal?: AbortSignal): Promise<string[]> {
+  const tracked = new Set(
+    (await git(["ls-files", "-z"], signal)).split("\0").filter(Boolean).map(f => path.normalize(f))
+  );
+  return files.filter(file => tracked.has(path.normalize(file)));
+}
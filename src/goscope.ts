@@ -0,0 +1,167 @@
+/**
+ * Go declaration span detection
+ *
+ * Finds where the Go declaration or statement starting at a given line ends,
+ * using the same rule as the Go parser: a statement ends at the first
+ * semicolon at bracket depth 0, where newlines become semicolons after an
+ * identifier, literal, `break`/`continue`/`fallthrough`/`return`, `++`, `--`,
+ * `)`, `]`, or `}` (see "Semicolons" in the Go spec).
+ *
+ * Strings, raw strings, runes, and comments are skipped, so braces inside them
+ * and in composite literals or `interface{}` signatures are handled correctly.
+ *
+ * Kept dependency-free so it can be used standalone.
+ */
+
+export type GoSpanResult = { end: number } | { error: string };
+
+// The only keywords after which a newline ends the statement
+const TERMINATING_KEYWORDS = ["break", "continue", "fallthrough", "return"];
+const KEYWORDS = [
+  "break", "case", "chan", "const", "continue", "default", "defer", "else",
+  "fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+  "map", "package", "range", "return", "select", "struct", "switch", "type", "var",
+];
+
+const CLOSERS: Record<string, string> = { ")": "(", "]": "[", "}": "{" };
+
+// Length in UTF-16 code units of the identifier character at `i`, or 0.
+// Go identifiers are Unicode letters and digits; reading by code point keeps
+// letters outside the BMP (surrogate pairs) together.
+function identCharLength(src: string, i: number): number {
+  const ch = String.fromCodePoint(src.codePointAt(i)!);
+  return /[\p{L}\p{Nd}_.]/u.test(ch) ? ch.length : 0;
+}
+
+/**
+ * Returns the 0-indexed line on which the declaration starting at `startLine`
+ * ends, or an error if the source cannot be tokenized from there.
+ */
+export function goDeclarationSpan(lines: string[], startLine: number): GoSpanResult {
+  const src = lines.slice(startLine).join("\n");
+  const stack: string[] = [];
+  let line = startLine;
+  let lastTokenLine = -1;
+  let terminates = false; // Whether a newline after the last token inserts a semicolon
+  let i = 0;
+
+  const token = (triggers: boolean) => {
+    lastTokenLine = line;
+    terminates = triggers;
+  };
+
+  while (i < src.length) {
+    const ch = src[i];
+
+    if (ch === "\n") {
+      if (stack.length === 0 && terminates) return { end: lastTokenLine };
+      line++;
+      i++;
+      continue;
+    }
+    if (ch === " " || ch === "\t" || ch === "\r") {
+      i++;
+      continue;
+    }
+
+    // Comments
+    if (ch === "/" && src[i + 1] === "/") {
+      while (i < src.length && src[i] !== "\n") i++;
+      continue;
+    }
+    if (ch === "/" && src[i + 1] === "*") {
+      const close = src.indexOf("*/", i + 2);
+      if (close === -1) return { error: `unterminated comment on line ${line + 1}` };
+      const newlines = src.slice(i, close).split("\n").length - 1;
+      // A general comment containing a newline acts like a newline
+      if (newlines > 0 && stack.length === 0 && terminates) return { end: lastTokenLine };
+      line += newlines;
+      i = close + 2;
+      continue;
+    }
+
+    // String, raw string, and rune literals
+    if (ch === '"' || ch === "'") {
+      let j = i + 1;
+      while (j < src.length && src[j] !== ch) {
+        if (src[j] === "\n") return { error: `unterminated literal on line ${line + 1}` };
+        j += src[j] === "\\" ? 2 : 1;
+      }
+      if (j >= src.length) return { error: `unterminated literal on line ${line + 1}` };
+      token(true);
+      i = j + 1;
+      continue;
+    }
+    if (ch === "`") {
+      const close = src.indexOf("`", i + 1);
+      if (close === -1) return { error: `unterminated raw string on line ${line + 1}` };
+      token(true);
+      line += src.slice(i, close).split("\n").length - 1;
+      lastTokenLine = line;
+      i = close + 1;
+      continue;
+    }
+
+    // Identifiers, keywords, and numbers
+    if (identCharLength(src, i) > 0) {
+      let j = i;
+      while (j < src.length && identCharLength(src, j) > 0) j += identCharLength(src, j);
+      const word = src.slice(i, j);
+      // A trailing "." continues a selector chain onto the next line
+      token((!KEYWORDS.includes(word) || TERMINATING_KEYWORDS.includes(word)) && !word.endsWith("."));
+      i = j;
+      continue;
+    }
+
+    // Brackets
+    if (ch === "(" || ch === "[" || ch === "{") {
+      stack.push(ch);
+      token(false);
+      i++;
+      continue;
+    }
+    if (ch === ")" || ch === "]" || ch === "}") {
+      if (stack.length === 0) {
+        // Closing an enclosing block: the statement ended just before it
+        return lastTokenLine === -1 ? { end: line } : { end: lastTokenLine };
+      }
+      if (stack.pop() !== CLOSERS[ch]) return { error: `mismatched "${ch}" on line ${line + 1}` };
+      token(true);
+      i++;
+      continue;
+    }
+
+    if (ch === ";" && stack.length === 0) {
+      return { end: line };
+    }
+
+    // Operators: only ++ and -- allow a semicolon after them
+    if ((ch === "+" || ch === "-") && src[i + 1] === ch) {
+      token(true);
+      i += 2;
+      continue;
+    }
+    token(false);
+    i++;
+  }
+
+  if (stack.length > 0) {
+    return { error: `unbalanced "${stack[stack.length - 1]}" before end of file` };
+  }
+  return { end: lastTokenLine === -1 ? startLine : lastTokenLine };
+}
+
+/**
+ * Why the whole file cannot be tokenized as Go (an unterminated literal or
+ * comment, or unbalanced brackets), or undefined. This is not a full parse:
+ * code that tokenizes but breaks the grammar is not reported.
+ */
+export function goSyntaxError(lines: string[]): string | undefined {
+  if (lines.length === 0) return undefined;
+  // As one block the file is a single statement, which a stray closer ends early
+  const wrapped = ["{" + lines[0], ...lines.slice(1), "}"];
+  const span = goDeclarationSpan(wrapped, 0);
+  if ("error" in span) return span.error;
+  if (span.end < wrapped.length - 1) return `unexpected closing bracket on line ${span.end + 1}`;
+  return undefined;
+}
@@ -0,0 +1,189 @@
+/**
+ * Heatmap command for collab-claude-code
+ *
+ * Aggregates resolved trust per file for governance dashboards, e.g. a treemap
+ * sized by line count and colored by how much of each file is locked. Every
+ * line is resolved exactly as collab_check_trust would resolve it, so lines
+ * outside any annotation or region count toward their policy or default level.
+ */
+
+import * as fs from "fs/promises";
+
+import {
+  TRUST_LEVELS,
+  TrustLevel,
+  comparePaths,
+  isGeneratedSource,
+  isGoTestSource,
+  loadPackageDefault,
+  loadTrustAliases,
+  ownerList,
+  parseAnnotationContent,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface FileHeatmap {
+  file: string;
+  total_lines: number;
+  levels: Record<TrustLevel, number>; // Lines resolving to each level; sums to total_lines
+  owners: Record<string, number>; // Lines per owner, for lines that have one
+  dominant_owner?: string; // Owner of the most lines; ties go to the first by name
+}
+
+export interface HeatmapReport {
+  profile?: string;
+  total_lines: number;
+  levels: Record<TrustLevel, number>;
+  files: FileHeatmap[];
+}
+
+// ============================================
+// Aggregation
+// ============================================
+
+function emptyLevels(): Record<TrustLevel, number> {
+  return Object.fromEntries(TRUST_LEVELS.map(level => [level, 0])) as Record<TrustLevel, number>;
+}
+
+function lineCount(content: string): number {
+  if (content === "") return 0;
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  return content.endsWith("\n") ? lines.length - 1 : lines.length;
+}
+
+export async function buildHeatmap(paths: string[], options: CheckOptions = {}): Promise<HeatmapReport> {
+  const { signal } = options;
+  const config = await loadTrustConfigStrict(options.profile);
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+  const files = await expandPaths(paths, options);
+
+  const report: HeatmapReport = {
+    profile: config.active_profile,
+    total_lines: 0,
+    levels: emptyLevels(),
+    files: [],
+  };
+
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    const { annotations } = parseAnnotationContent(content, file, { aliases });
+    const declarations = config.symbols?.length ? findDeclarations(content, file) : [];
+    const generated = isGeneratedSource(content);
+    const packageDefault = await loadPackageDefault(file, { aliases });
+    const testFile = isGoTestSource(file, content);
+    const entry: FileHeatmap = { file, total_lines: lineCount(content), levels: emptyLevels(), owners: {} };
+
+    for (let line = 1; line <= entry.total_lines; line++) {
+      const trust = resolveTrustWithAnnotations(
+        config, file, annotations, line, line, declarations, generated, packageDefault, testFile
+      );
+      entry.levels[trust.level]++;
+      // Co-owned lines count toward each owner
+      for (const owner of ownerList(trust.owner)) {
+        entry.owners[owner] = (entry.owners[owner] ?? 0) + 1;
+      }
+    }
+
+    entry.dominant_owner = Object.entries(entry.owners)
+      .sort(([a, x], [b, y]) => y - x || comparePaths(a, b))[0]?.[0];
+
+    report.total_lines += entry.total_lines;
+    for (const level of TRUST_LEVELS) report.levels[level] += entry.levels[level];
+    report.files.push(entry);
+  }
+
+  report.files.sort((a, b) => comparePaths(a.file, b.file));
+  return report;
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+function percent(lines: number, total: number): string {
+  return total === 0 ? "0%" : `${Math.round((lines / total) * 100)}%`;
+}
+
+export function formatHeatmapText(report: HeatmapReport): string {
+  const header = ["File", "Lines", ...TRUST_LEVELS, "Owner"];
+  const rows = report.files.map(f => [
+    f.file,
+    String(f.total_lines),
+    ...TRUST_LEVELS.map(level => percent(f.levels[level], f.total_lines)),
+    f.dominant_owner ?? "-",
+  ]);
+  rows.push([
+    "(total)",
+    String(report.total_lines),
+    ...TRUST_LEVELS.map(level => percent(report.levels[level], report.total_lines)),
+    "",
+  ]);
+
+  const widths = header.map((h, i) => Math.max(h.length, ...rows.map(r => r[i].length)));
+  const render = (cells: string[]) => cells.map((c, i) => c.padEnd(widths[i])).join("  ").trimEnd();
+  return [
+    ...(report.profile ? [`profile: ${report.profile}`] : []),
+    render(header),
+    ...rows.map(render),
+  ].join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runHeatmap(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await buildHeatmap(paths, { profile, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatHeatmapText(report));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
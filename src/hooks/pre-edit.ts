@@ -4,11 +4,20 @@
  * Pre-edit hook for Claude Code
  *
  * This hook runs BEFORE Edit/Write tool executions.
- * It checks trust levels and can block edits to protected regions.
+ * It checks trust levels and can block edits to protected regions: an Edit
+ * is resolved against the lines its old_string spans, a Write against the
+ * whole file.
  *
  * Exit codes:
  *   0 = Allow the edit
- *   1 = Block the edit (READ_ONLY region)
+ *   1 = Block the edit (READ_ONLY region or generated file; with
+ *       suggest.block_direct_edits, also a SUGGEST_ONLY region, unless the edit
+ *       is trivial and suggest.auto_approve_trivial is on)
+ *
+ * Other SUGGEST_ONLY edits get a warning and are allowed.
+ *
+ * With enforcement: shadow in .collab/config.yaml, edits that would be blocked
+ * are recorded in .collab/audit.jsonl and allowed.
  *
  * Usage in ~/.claude/settings.json:
  * {
@@ -23,7 +32,23 @@
  * The hook receives tool input via stdin as JSON.
  */
 
-import { loadTrustConfig, getTrustLevel, fileExists, COLLAB_DIR } from "./utils.js";
+import * as fs from "fs/promises";
+import {
+  loadTrustConfig,
+  loadCollabConfig,
+  getTrustLevel,
+  getGeneratedTrustLevel,
+  loadPackageDefault,
+  isGoTestFile,
+  recordAutoApproval,
+  recordAuditEntry,
+  fileExists,
+  findEditedLines,
+  COLLAB_DIR,
+} from "./utils.js";
+import type { TrustLevel } from "./utils.js";
+import { isTrivialEdit } from "../trivial.js";
+import { log } from "../log.js";
 
 interface EditToolInput {
   file_path: string;
@@ -39,6 +64,48 @@ interface WriteToolInput {
 
 type ToolInput = EditToolInput | WriteToolInput;
 
+// Returns the region text before and after the edit, if it can be determined
+async function getEditTexts(input: ToolInput): Promise<{ before: string; after: string } | null> {
+  if ("old_string" in input && input.old_string !== undefined && input.new_string !== undefined) {
+    return { before: input.old_string, after: input.new_string };
+  }
+  if (input.content !== undefined) {
+    try {
+      const before = await fs.readFile(input.file_path, "utf-8");
+      return { before, after: input.content };
+    } catch {
+      return null; // New file - never trivial
+    }
+  }
+  return null;
+}
+
+// The lines an Edit replaces; undefined for a Write, a new file, or an old_string that is not unique
+async function getEditedLines(input: ToolInput): Promise<{ line_start: number; line_end: number } | undefined> {
+  if (!("old_string" in input) || input.old_string === undefined) return undefined;
+  try {
+    return findEditedLines(await fs.readFile(input.file_path, "utf-8"), input.old_string);
+  } catch {
+    return undefined;
+  }
+}
+
+// In shadow mode, record the block the hook would have made and allow the edit
+async function allowIfShadow(filePath: string, trust: TrustLevel, message: string): Promise<void> {
+  if ((await loadCollabConfig()).enforcement !== "shadow") return;
+  await recordAuditEntry({
+    timestamp: new Date().toISOString(),
+    mode: "shadow",
+    source: "pre-edit",
+    file_path: filePath,
+    trust,
+    would_decide: "block",
+    message,
+  });
+  console.error(`SHADOW: would block edit: ${message}`);
+  process.exit(0);
+}
+
 async function readStdin(): Promise<string> {
   const chunks: Buffer[] = [];
   for await (const chunk of process.stdin) {
@@ -83,34 +150,101 @@ async function main() {
       process.exit(0);
     }
 
-    // Check trust level
-    const trust = getTrustLevel(trustConfig, filePath);
+    // Check trust level; generated files are governed whatever their policy says
+    const lines = await getEditedLines(input);
+    const trust =
+      (await getGeneratedTrustLevel(trustConfig, filePath)) ??
+      (await getTrustLevel(
+        trustConfig,
+        filePath,
+        lines?.line_start,
+        lines?.line_end,
+        await loadPackageDefault(filePath),
+        await isGoTestFile(filePath)
+      ));
+    log.info("pre-edit trust", {
+      file: filePath,
+      line_start: lines?.line_start,
+      line_end: lines?.line_end,
+      trust: trust.level,
+      source: trust.source,
+      profile: trust.profile,
+    });
+    const profileNote = trust.profile
+      ? ` (profile: ${trust.profile}${trust.base_level ? `, normally ${trust.base_level}` : ""})`
+      : "";
 
     switch (trust.level) {
       case "READ_ONLY":
+        await allowIfShadow(
+          filePath,
+          trust.level,
+          trust.source === "generated" ? `${filePath} is generated code` : `${filePath} is marked READ_ONLY${profileNote}`
+        );
+
         // Block the edit
-        console.error(`BLOCKED: ${filePath} is marked READ_ONLY`);
+        if (trust.source === "generated") {
+          console.error(`BLOCKED: ${filePath} is generated code [generated-file-edit]`);
+          console.error("Change the generator or its input and regenerate instead.");
+          process.exit(1);
+        }
+        console.error(`BLOCKED: ${filePath} is marked READ_ONLY${profileNote}`);
         if (trust.reason) {
           console.error(`Reason: ${trust.reason}`);
         }
         if (trust.owner) {
-          console.error(`Owner: ${trust.owner}`);
+          console.error(`Owner: ${[trust.owner].flat().join(", ")}`);
         }
         console.error("Use collab_propose_change to suggest modifications instead.");
         process.exit(1);
 
-      case "SUGGEST_ONLY":
-        // Warn but allow (user can configure stricter behavior)
-        console.error(`WARNING: ${filePath} is marked SUGGEST_ONLY`);
-        console.error("Consider using collab_propose_change for changes to this file.");
+      case "SUGGEST_ONLY": {
+        // Cosmetic edits (whitespace/comments only) may skip the proposal step
+        const suggest = (await loadCollabConfig()).suggest;
+        const autoApproveTrivial = suggest?.auto_approve_trivial;
+        if (autoApproveTrivial) {
+          const texts = await getEditTexts(input);
+          if (texts && isTrivialEdit(texts.before, texts.after, filePath)) {
+            await recordAutoApproval({
+              timestamp: new Date().toISOString(),
+              tool_name: "content" in input && !("old_string" in input) ? "Write" : "Edit",
+              file_path: filePath,
+              trust: trust.level,
+              reason: "Trivial edit (whitespace/comments only)",
+            });
+            console.error(`Auto-approved trivial edit to SUGGEST_ONLY file ${filePath}`);
+            process.exit(0);
+          }
+        }
+
+        // Warn but allow, unless configured to block as the gateway would answer
+        if (!suggest?.block_direct_edits) {
+          console.error(`WARNING: ${filePath} is marked SUGGEST_ONLY${profileNote}`);
+          console.error("Consider using collab_propose_change for changes to this file.");
+          if (trust.owner) {
+            console.error(`Owner: ${[trust.owner].flat().join(", ")}`);
+          }
+          process.exit(0);
+        }
+        await allowIfShadow(filePath, trust.level, `${filePath} is marked SUGGEST_ONLY${profileNote}`);
+        console.error(`BLOCKED: ${filePath} is marked SUGGEST_ONLY${profileNote}`);
+        if (trust.reason) {
+          console.error(`Reason: ${trust.reason}`);
+        }
         if (trust.owner) {
-          console.error(`Owner: ${trust.owner}`);
+          console.error(`Owner: ${[trust.owner].flat().join(", ")}`);
         }
-        process.exit(0);
+        console.error(
+          autoApproveTrivial
+            ? "Use collab_propose_change for this change; only whitespace and comment edits may be made directly."
+            : "Use collab_propose_change for this change instead."
+        );
+        process.exit(1);
+      }
 
       case "SUPERVISED":
         // Just log
-        console.error(`Note: ${filePath} is under SUPERVISED trust level`);
+        console.error(`Note: ${filePath} is under SUPERVISED trust level${profileNote}`);
         process.exit(0);
 
       case "AUTONOMOUS":
@@ -1,138 +1,165 @@
 import * as fs from "fs/promises";
 import * as path from "path";
 import * as yaml from "yaml";
+import {
+  AuditEntry,
+  AuthorshipRecord,
+  COLLAB_DIR,
+  DEFAULT_TRUST_ALIASES,
+  DeclarationSpan,
+  PackageDefault,
+  ParsedAnnotation,
+  TRUST_FILE,
+  TrustConfig,
+  TrustLevel,
+  TrustResult,
+  applyTrustProfile,
+  ensureCollabDir,
+  fileExists,
+  generatedTrustLevel,
+  isGeneratedSource,
+  isGoTestSource,
+  loadCollabConfig,
+  loadPackageDefault as loadPackageDefaultWithAliases,
+  loadTrustAliases,
+  parseAnnotationContent,
+  recordAuditEntries,
+  recordAuthorship,
+  resolveTrustWithAnnotations,
+  selectTrustProfile,
+} from "../collab.js";
+import { findDeclarations } from "../declarations.js";
+import { loadExtendedPolicies, mergePolicy } from "../policy.js";
+
+// Trust resolution is collab.ts's; what differs here is that a hook never
+// fetches, and never fails an edit over a config it cannot read
+export type { AuditEntry, AuthorshipRecord, CollabConfig, PackageDefault, TrustConfig, TrustLevel } from "../collab.js";
+export { COLLAB_DIR, fileExists, loadCollabConfig, recordAuthorship };
 
 // ============================================
-// Types (duplicated from MCP server for standalone use)
+// Types
 // ============================================
 
-export type TrustLevel = "AUTONOMOUS" | "SUGGEST_ONLY" | "READ_ONLY" | "SUPERVISED";
-
-export interface TrustPolicy {
-  pattern: string;
-  trust: TrustLevel;
-  owner?: string;
-  reason?: string;
-}
-
-export interface RegionOverride {
-  file: string;
-  line_start: number;
-  line_end: number;
-  trust: TrustLevel;
-  reason?: string;
-}
-
-export interface TrustConfig {
-  default_trust: TrustLevel;
-  policies: TrustPolicy[];
-  regions?: RegionOverride[];
-}
-
-export interface AuthorshipRecord {
+export interface AutoApprovalRecord {
   timestamp: string;
-  author: string;
-  model?: string;
+  tool_name: string;
   file_path: string;
-  line_start: number;
-  line_end: number;
-  confidence: number;
+  trust: TrustLevel;
+  reason: string;
 }
 
 // ============================================
 // Constants
 // ============================================
 
-export const COLLAB_DIR = ".collab";
-export const TRUST_FILE = "trust.yaml";
-export const META_DIR = "meta";
+export const AUTO_APPROVALS_FILE = "auto_approvals.jsonl";
 
 // ============================================
-// Utility Functions
+// Trust Management
 // ============================================
 
-export function sanitizeFilePath(filePath: string): string {
-  return filePath.replace(/[\/\\:]/g, "_");
+export async function loadTrustConfig(): Promise<TrustConfig | null> {
+  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+
+  let config: TrustConfig;
+  try {
+    const content = await fs.readFile(trustPath, "utf-8");
+    config = yaml.parse(content) as TrustConfig;
+  } catch {
+    return null;
+  }
+
+  // Hooks never fetch: shared policies come from the cache filled by the CLI/MCP server
+  if (config.extends) {
+    try {
+      config = mergePolicy(config, await loadExtendedPolicies(config.extends, { offline: true }));
+    } catch {
+      // Unreadable or mismatched shared policy: fall back to the local config
+    }
+    config.default_trust ??= "SUPERVISED";
+  }
+  config.policies ??= [];
+
+  // An unknown COLLAB_PROFILE selects nothing rather than failing the hook
+  config.active_profile = await selectTrustProfile(config).catch(() => undefined);
+  return config;
 }
 
-export async function ensureDir(dir: string): Promise<void> {
-  await fs.mkdir(dir, { recursive: true });
+// config.yaml aliases, or the built-in ones when config.yaml is invalid
+async function hookAliases(): Promise<Record<string, TrustLevel>> {
+  return loadTrustAliases().catch(() => DEFAULT_TRUST_ALIASES);
 }
 
-export async function fileExists(filePath: string): Promise<boolean> {
+// Generated files are governed ahead of every policy; null for other files
+export async function getGeneratedTrustLevel(config: TrustConfig, filePath: string): Promise<TrustResult | null> {
+  const level = generatedTrustLevel(config);
+  if (level === undefined) return null;
   try {
-    await fs.access(filePath);
-    return true;
+    if (!isGeneratedSource(await fs.readFile(filePath, "utf-8"))) return null;
   } catch {
-    return false;
+    return null; // New file
   }
+  return applyTrustProfile(config, {
+    level,
+    reason: "Generated code (Code generated ... DO NOT EDIT.); change the generator or its input instead",
+    source: "generated",
+  });
 }
 
-// ============================================
-// Trust Management
-// ============================================
-
-export async function loadTrustConfig(): Promise<TrustConfig | null> {
-  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
-
+// A *_test.go file importing "testing"; false for new files
+export async function isGoTestFile(filePath: string): Promise<boolean> {
+  if (!filePath.endsWith("_test.go")) return false;
   try {
-    const content = await fs.readFile(trustPath, "utf-8");
-    return yaml.parse(content) as TrustConfig;
+    return isGoTestSource(filePath, await fs.readFile(filePath, "utf-8"));
   } catch {
-    return null;
+    return false;
   }
 }
 
-function matchesPattern(filePath: string, pattern: string): boolean {
-  const regexPattern = pattern
-    .replace(/\*\*/g, ".*")
-    .replace(/\*/g, "[^/]*")
-    .replace(/\?/g, ".");
-  const regex = new RegExp(`^${regexPattern}$`);
-  return regex.test(filePath) || regex.test(filePath.replace(/\\/g, "/"));
+// A Go file's package default from the `@collab:package` directive in its directory's doc.go
+export async function loadPackageDefault(filePath: string): Promise<PackageDefault | undefined> {
+  return loadPackageDefaultWithAliases(filePath, { aliases: await hookAliases() });
 }
 
-export function getTrustLevel(
+// Resolved as getTrustLevelWithAnnotations resolves it: the file's @collab annotations, symbol
+// policies, and generated header count, read from disk; a file that cannot be read has none.
+// Pass the file's package default (see loadPackageDefault) to use it under every policy,
+// and whether it is a Go test file (see isGoTestFile) for test_trust to apply
+export async function getTrustLevel(
   config: TrustConfig,
   filePath: string,
   lineStart?: number,
-  lineEnd?: number
-): { level: TrustLevel; reason?: string; owner?: string } {
-  const normalizedPath = filePath.replace(/\\/g, "/");
-
-  // Check region overrides first
-  if (config.regions && lineStart !== undefined) {
-    for (const region of config.regions) {
-      const regionFile = region.file.replace(/\\/g, "/");
-      if (normalizedPath.endsWith(regionFile) || normalizedPath === regionFile) {
-        const end = lineEnd ?? lineStart;
-        if (lineStart <= region.line_end && end >= region.line_start) {
-          return { level: region.trust, reason: region.reason };
-        }
-      }
-    }
-  }
-
-  // Check pattern policies
-  for (const policy of config.policies) {
-    if (matchesPattern(normalizedPath, policy.pattern)) {
-      return { level: policy.trust, reason: policy.reason, owner: policy.owner };
-    }
+  lineEnd?: number,
+  packageDefault?: PackageDefault,
+  testFile = false
+): Promise<TrustResult> {
+  let annotations: ParsedAnnotation[] = [];
+  let declarations: DeclarationSpan[] = [];
+  let generated = false;
+  try {
+    const content = await fs.readFile(filePath, "utf-8");
+    annotations = parseAnnotationContent(content, filePath, { aliases: await hookAliases() }).annotations;
+    declarations = config.symbols?.length ? findDeclarations(content, filePath) : [];
+    generated = generatedTrustLevel(config) !== undefined && isGeneratedSource(content);
+  } catch {
+    // New or unparseable file: trust.yaml alone decides
   }
-
-  return { level: config.default_trust, reason: "Default trust level" };
+  return resolveTrustWithAnnotations(
+    config, filePath, annotations, lineStart, lineEnd, declarations, generated, packageDefault, testFile
+  );
 }
 
 // ============================================
-// Authorship Recording
+// Recording
 // ============================================
 
-export async function recordAuthorship(record: AuthorshipRecord): Promise<void> {
-  const metaDir = path.join(COLLAB_DIR, META_DIR);
-  await ensureDir(metaDir);
+export async function recordAutoApproval(record: AutoApprovalRecord): Promise<void> {
+  await ensureCollabDir();
+  await fs.appendFile(path.join(COLLAB_DIR, AUTO_APPROVALS_FILE), JSON.stringify(record) + "\n");
+}
 
-  const metaPath = path.join(metaDir, sanitizeFilePath(record.file_path) + ".jsonl");
-  await fs.appendFile(metaPath, JSON.stringify(record) + "\n");
+export async function recordAuditEntry(entry: AuditEntry): Promise<void> {
+  await recordAuditEntries([entry]);
 }
 
 // ============================================
@@ -142,3 +169,11 @@ export async function recordAuthorship(record: AuthorshipRecord): Promise<void>
 export function countLines(content: string): number {
   return content.split("\n").length;
 }
+
+// The lines of `content` an Edit's old_string spans, when it occurs exactly once
+export function findEditedLines(content: string, oldString: string): { line_start: number; line_end: number } | undefined {
+  const at = content.indexOf(oldString);
+  if (!oldString || at < 0 || content.indexOf(oldString, at + 1) >= 0) return undefined;
+  const lineStart = countLines(content.slice(0, at));
+  return { line_start: lineStart, line_end: lineStart + countLines(oldString) - 1 };
+}
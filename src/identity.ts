@@ -0,0 +1,149 @@
+/**
+ * Identity resolution for authorship-based decisions
+ *
+ * Maps a commit or request author to the teams they belong to, so a region
+ * owned by `payments` counts as owned by everyone on that team:
+ *
+ *   const identities = createIdentityResolver();
+ *   await identities.teamsFor("Alice <alice@acme.com>"); // ["payments", "platform"]
+ *
+ * The default resolver reads the roster from .collab/config.yaml: `identities`
+ * maps an identity (usually an email) to its teams, and `team_roster` names a
+ * file in CODEOWNERS syntax with a team where the path pattern would be:
+ *
+ *   @acme/payments  alice@acme.com  bob@acme.com
+ *
+ * The governance core only sees the IdentityResolver interface, so an org can
+ * plug in a roster derived from LDAP or SSO groups instead. Lookups are cached
+ * per resolver.
+ */
+
+import * as fs from "fs/promises";
+
+import { loadCollabConfig } from "./collab.js";
+import { ownerKey } from "./audit-owners.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface IdentityResolver {
+  // The teams `email` belongs to, as owner keys; empty for an unknown identity
+  teamsFor(email: string): Promise<string[]>;
+}
+
+export interface IdentitySource {
+  identities?: Record<string, string | string[]>; // Identity -> teams, as in config.yaml
+  roster?: string; // A team roster file in CODEOWNERS syntax
+}
+
+// ============================================
+// Roster
+// ============================================
+
+// "Alice <Alice@Acme.com>" and "alice@acme.com" are the same identity
+export function identityKey(identity: string): string {
+  const email = identity.match(/<([^>]+)>/);
+  return (email ? email[1] : identity).trim().toLowerCase();
+}
+
+// Validate config.yaml `identities`; throws on the first bad entry
+export function buildIdentityMap(identities: unknown): Map<string, string[]> {
+  const map = new Map<string, string[]>();
+  if (identities === undefined || identities === null) return map;
+  if (typeof identities !== "object" || Array.isArray(identities)) {
+    throw new Error("identities must map identities to a team or a list of teams");
+  }
+  for (const [identity, value] of Object.entries(identities as Record<string, unknown>)) {
+    const teams = Array.isArray(value) ? value : [value];
+    if (teams.length === 0 || teams.some(t => typeof t !== "string" || t.trim() === "")) {
+      throw new Error(`Identity "${identity}" must list one or more teams`);
+    }
+    addTeams(map, identity, teams as string[]);
+  }
+  return map;
+}
+
+// Each line is "<team> <member>..."; comments and blank lines are skipped
+export function parseTeamRoster(content: string): Map<string, string[]> {
+  const map = new Map<string, string[]>();
+  for (const raw of content.replace(/\r\n/g, "\n").split("\n")) {
+    const [team, ...members] = raw.replace(/(^|\s)#.*$/, "").trim().split(/\s+/).filter(Boolean);
+    for (const member of members) addTeams(map, member, [team]);
+  }
+  return map;
+}
+
+function addTeams(map: Map<string, string[]>, identity: string, teams: string[]): void {
+  const key = identityKey(identity);
+  const known = map.get(key) ?? [];
+  for (const team of teams.map(t => t.trim())) {
+    if (!known.includes(team)) known.push(team);
+  }
+  map.set(key, known);
+}
+
+async function loadRoster(source?: IdentitySource): Promise<Map<string, string[]>> {
+  const { identities, roster } = source ?? (await configSource());
+  const map = buildIdentityMap(identities);
+  if (roster) {
+    let content: string;
+    try {
+      content = await fs.readFile(roster, "utf-8");
+    } catch {
+      throw new Error(`Cannot read team roster ${roster}`);
+    }
+    for (const [identity, teams] of parseTeamRoster(content)) addTeams(map, identity, teams);
+  }
+  return map;
+}
+
+async function configSource(): Promise<IdentitySource> {
+  const config = await loadCollabConfig();
+  return { identities: config.identities, roster: config.team_roster };
+}
+
+// ============================================
+// Resolver
+// ============================================
+
+/**
+ * The default IdentityResolver: config.yaml `identities` and `team_roster`,
+ * or `source` instead. The roster is read on the first lookup and every
+ * answer is cached, so reuse one resolver for a batch of decisions and
+ * create a new one to pick up roster changes. A roster that fails to load
+ * rejects the lookup and is read again on the next one.
+ */
+export function createIdentityResolver(source?: IdentitySource): IdentityResolver {
+  let roster: Promise<Map<string, string[]>> | undefined;
+  const cache = new Map<string, string[]>();
+
+  return {
+    async teamsFor(email) {
+      const key = identityKey(email);
+      const cached = cache.get(key);
+      if (cached) return cached;
+
+      roster ??= loadRoster(source);
+      let map: Map<string, string[]>;
+      try {
+        map = await roster;
+      } catch (error) {
+        roster = undefined;
+        throw error;
+      }
+      const teams = map.get(key) ?? [];
+      cache.set(key, teams);
+      return teams;
+    },
+  };
+}
+
+/**
+ * Whether `owner` (an owner key, as expandOwners lists them) is `identity`
+ * itself or one of its `teams`. Teams compare as in audit-owners:
+ * `@acme/payments` matches `payments`.
+ */
+export function isOwnedBy(owner: string, identity: string, teams: string[]): boolean {
+  return owner === identity || teams.some(team => ownerKey(team) === ownerKey(owner));
+}
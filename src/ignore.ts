@@ -0,0 +1,182 @@
+/**
+ * .gitignore-aware file exclusion
+ *
+ * Implements the gitignore pattern rules used when scanning a project:
+ *
+ * - Every `.gitignore` below the root is honored, relative to its own directory,
+ *   along with `.git/info/exclude`
+ * - Later rules win, and rules in deeper `.gitignore` files win over shallower ones
+ * - `!pattern` re-includes, except inside a directory that is itself excluded
+ * - A trailing `/` matches directories only; a `/` elsewhere anchors the pattern
+ *   to its `.gitignore`, otherwise it matches at any depth
+ * - `*`, `?`, `[...]`, and `**` behave as in git
+ *
+ * The config.yaml `exclude:` list uses the same syntax and is applied last,
+ * relative to the project root.
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import { glob } from "glob";
+
+interface IgnoreRule {
+  base: string; // Directory of the .gitignore, relative to the root ("" for the root)
+  regex: RegExp;
+  negate: boolean;
+  dirOnly: boolean;
+}
+
+export interface IgnoreOptions {
+  noIgnore?: boolean; // Disable .gitignore files and `exclude` patterns
+  exclude?: string[]; // Extra patterns, e.g. from config.yaml
+  signal?: AbortSignal;
+}
+
+// Matches paths relative to the scan root, with "/" separators
+export type IgnoreFilter = (relativePath: string) => boolean;
+
+// ============================================
+// Pattern Compilation
+// ============================================
+
+function globToRegexBody(pattern: string): string {
+  let out = "";
+  let i = 0;
+
+  while (i < pattern.length) {
+    const ch = pattern[i];
+
+    if (ch === "*" && pattern[i + 1] === "*") {
+      const atSegmentStart = i === 0 || pattern[i - 1] === "/";
+      const atSegmentEnd = i + 2 === pattern.length || pattern[i + 2] === "/";
+      if (atSegmentStart && atSegmentEnd) {
+        if (i + 2 === pattern.length) {
+          out += ".*"; // Trailing "/**": everything inside
+          i += 2;
+        } else {
+          out += "(?:.*/)?"; // "**/": zero or more directories
+          i += 3;
+        }
+        continue;
+      }
+    }
+
+    if (ch === "*") {
+      out += "[^/]*";
+    } else if (ch === "?") {
+      out += "[^/]";
+    } else if (ch === "[") {
+      const close = pattern.indexOf("]", i + 2);
+      if (close === -1) {
+        out += "\\[";
+      } else {
+        let cls = pattern.slice(i + 1, close);
+        if (cls.startsWith("!")) cls = "^" + cls.slice(1);
+        out += `[${cls.replace(/\\/g, "\\\\")}]`;
+        i = close;
+      }
+    } else if (ch === "\\" && i + 1 < pattern.length) {
+      out += pattern[i + 1].replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+      i++;
+    } else {
+      out += ch.replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+    }
+    i++;
+  }
+
+  return out;
+}
+
+export function compileIgnoreRule(line: string, base: string = ""): IgnoreRule | null {
+  // Trailing spaces are ignored unless escaped
+  let pattern = line.replace(/(?<!\\)\s+$/, "");
+  if (!pattern || pattern.startsWith("#")) return null;
+
+  const negate = pattern.startsWith("!");
+  if (negate) pattern = pattern.slice(1);
+  if (pattern.startsWith("\\#") || pattern.startsWith("\\!")) pattern = pattern.slice(1);
+
+  const dirOnly = pattern.endsWith("/");
+  if (dirOnly) pattern = pattern.slice(0, -1);
+  if (!pattern) return null;
+
+  const anchored = pattern.includes("/");
+  if (pattern.startsWith("/")) pattern = pattern.slice(1);
+
+  const body = globToRegexBody(pattern);
+  const regex = new RegExp(anchored ? `^${body}$` : `^(?:.*/)?${body}$`);
+  return { base, regex, negate, dirOnly };
+}
+
+export function parseIgnoreFile(content: string, base: string = ""): IgnoreRule[] {
+  return content
+    .split(/\r?\n/)
+    .map(line => compileIgnoreRule(line, base))
+    .filter((rule): rule is IgnoreRule => rule !== null);
+}
+
+// ============================================
+// Matching
+// ============================================
+
+function matchRules(rules: IgnoreRule[], relativePath: string, isDir: boolean): boolean | undefined {
+  let ignored: boolean | undefined;
+  for (const rule of rules) {
+    if (rule.dirOnly && !isDir) continue;
+
+    let subject = relativePath;
+    if (rule.base) {
+      if (!relativePath.startsWith(rule.base + "/")) continue;
+      subject = relativePath.slice(rule.base.length + 1);
+    }
+    if (rule.regex.test(subject)) {
+      ignored = !rule.negate;
+    }
+  }
+  return ignored;
+}
+
+export function createIgnoreFilter(rules: IgnoreRule[]): IgnoreFilter {
+  return (relativePath: string) => {
+    const parts = relativePath.replace(/\\/g, "/").replace(/^\.\//, "").split("/");
+    if (parts[0] === "..") return false; // Outside the root: no rules apply
+
+    // A file inside an excluded directory cannot be re-included
+    for (let depth = 1; depth < parts.length; depth++) {
+      if (matchRules(rules, parts.slice(0, depth).join("/"), true)) return true;
+    }
+    return matchRules(rules, parts.join("/"), false) === true;
+  };
+}
+
+export async function loadIgnoreFilter(rootDir: string = ".", options: IgnoreOptions = {}): Promise<IgnoreFilter> {
+  if (options.noIgnore) return () => false;
+
+  const ignoreFiles = await glob("**/.gitignore", {
+    cwd: rootDir,
+    dot: true,
+    ignore: ["**/node_modules/**", "**/.git/**"],
+    signal: options.signal,
+  });
+
+  // Shallower files first, so deeper rules are applied later and win
+  const ordered = ignoreFiles
+    .map(f => f.replace(/\\/g, "/"))
+    .sort((a, b) => a.split("/").length - b.split("/").length || a.localeCompare(b));
+
+  const rules: IgnoreRule[] = [];
+  try {
+    rules.push(...parseIgnoreFile(await fs.readFile(path.join(rootDir, ".git", "info", "exclude"), "utf-8")));
+  } catch {
+    // No repository-local excludes
+  }
+
+  for (const file of ordered) {
+    const base = path.posix.dirname(file);
+    const content = await fs.readFile(path.join(rootDir, file), "utf-8");
+    rules.push(...parseIgnoreFile(content, base === "." ? "" : base));
+  }
+
+  rules.push(...parseIgnoreFile((options.exclude ?? []).join("\n")));
+  return createIgnoreFilter(rules);
+}
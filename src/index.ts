@@ -9,14 +9,16 @@ import {
 
 import {
   loadTrustConfig,
-  getTrustLevel,
   getTrustLevelWithAnnotations,
   parseAnnotations,
   saveIntent,
   loadIntents,
   saveProposal,
+  loadProposal,
   loadProposals,
-  deleteProposal,
+  createBatchProposals,
+  createProposal,
+  countApprovals,
   recordAuthorship,
   getFileStatus,
   getProjectStatus,
@@ -24,10 +26,17 @@ import {
   initializeCollabWithPolicies,
   getProjectStructure,
   scanProject,
-  generateId,
+  ProposalInput,
+  ProposalStatus,
+  ProposalTransitionError,
+  transitionProposal,
   TrustLevel,
   TrustPolicy,
 } from "./collab.js";
+import { formatPreApplyFailures } from "./apply-hooks.js";
+import { resolveByDeclaration } from "./declarations.js";
+import { approveProposal } from "./proposals.js";
+import { applyLogArgs, log } from "./log.js";
 
 // ============================================
 // Server Setup
@@ -74,6 +83,26 @@ IMPORTANT: Call this before editing files in src/core/, src/security/, or any cr
       required: ["file_path"],
     },
   },
+  {
+    name: "collab_check_declaration_trust",
+    description: `Check the trust level of a whole function, method, or type by name.
+Use this when you know the symbol to edit but not its lines. Methods are named Type.Method.
+Errors if the name is ambiguous; pass file_path or a Type.Method name to narrow it.`,
+    inputSchema: {
+      type: "object" as const,
+      properties: {
+        declaration: {
+          type: "string",
+          description: "Declaration name, e.g. ProcessPayment or PaymentService.ProcessPayment",
+        },
+        file_path: {
+          type: "string",
+          description: "File containing the declaration (optional; searches the project if omitted)",
+        },
+      },
+      required: ["declaration"],
+    },
+  },
   {
     name: "collab_propose_change",
     description: `Propose a code change instead of applying directly.
@@ -102,6 +131,14 @@ Human will review and apply if approved. Use /collab-proposals to list pending p
           type: "string",
           description: "Proposed replacement code",
         },
+        line_start: {
+          type: "number",
+          description: "First line old_code replaces (1-indexed); needed only if old_code is not in the file verbatim",
+        },
+        line_end: {
+          type: "number",
+          description: "Last line old_code replaces (default: line_start)",
+        },
         confidence: {
           type: "number",
           description: "Your confidence in this change (0.0-1.0)",
@@ -120,6 +157,38 @@ Human will review and apply if approved. Use /collab-proposals to list pending p
       required: ["file_path", "description", "old_code", "new_code", "confidence"],
     },
   },
+  {
+    name: "collab_propose_batch",
+    description: `Propose many code changes at once, e.g. for a refactor touching several SUGGEST_ONLY regions.
+Creates one proposal per edit, grouped by region owner so each owner is notified once with every region
+they own. Each proposal is still reviewed, applied, or rejected on its own.`,
+    inputSchema: {
+      type: "object" as const,
+      properties: {
+        edits: {
+          type: "array",
+          description: "The changes, each shaped like collab_propose_change arguments",
+          items: {
+            type: "object",
+            properties: {
+              file_path: { type: "string" },
+              description: { type: "string" },
+              rationale: { type: "string" },
+              old_code: { type: "string" },
+              new_code: { type: "string" },
+              line_start: { type: "number" },
+              line_end: { type: "number" },
+              confidence: { type: "number" },
+              risks: { type: "array", items: { type: "string" } },
+              tests_needed: { type: "array", items: { type: "string" } },
+            },
+            required: ["file_path", "description", "old_code", "new_code", "confidence"],
+          },
+        },
+      },
+      required: ["edits"],
+    },
+  },
   {
     name: "collab_record_intent",
     description: `Record the intent behind code changes.
@@ -261,7 +330,10 @@ Otherwise creates minimal defaults. Best used after collab_scan_project analysis
               pattern: { type: "string", description: "Glob pattern (e.g., '**/auth/**')" },
               trust: { type: "string", enum: ["AUTONOMOUS", "SUPERVISED", "SUGGEST_ONLY", "READ_ONLY"] },
               reason: { type: "string", description: "Why this trust level" },
-              owner: { type: "string", description: "Optional owner/team" },
+              owner: {
+                oneOf: [{ type: "string" }, { type: "array", items: { type: "string" } }],
+                description: "Optional owner/team, or a list of co-owners",
+              },
             },
             required: ["pattern", "trust", "reason"],
           },
@@ -277,14 +349,14 @@ Otherwise creates minimal defaults. Best used after collab_scan_project analysis
   },
   {
     name: "collab_list_proposals",
-    description: `List all pending change proposals.
-Returns proposals that are waiting for human review.`,
+    description: `List change proposals.
+Returns proposals that are waiting for human review, or those with the given status.`,
     inputSchema: {
       type: "object" as const,
       properties: {
         status: {
           type: "string",
-          enum: ["pending", "approved", "rejected", "all"],
+          enum: ["pending", "approved", "rejected", "applied", "all"],
           description: "Filter by status (default: pending)",
         },
       },
@@ -294,7 +366,12 @@ Returns proposals that are waiting for human review.`,
   {
     name: "collab_apply_proposal",
     description: `Apply a pending proposal (for use by skills/commands).
-This marks the proposal as approved. The actual code change should be made separately.`,
+Records an approval from the given approver. Once the region's min_approvals is met
+(distinct approvers, not counting the proposal author), the proposal is marked approved,
+unless the code changed since it was proposed (stale) or a pre-apply hook refuses it.
+If the region links a design doc, the approval is only recorded once the approver has
+read it and acknowledge_design_doc is true.
+The actual code change should be made separately.`,
     inputSchema: {
       type: "object" as const,
       properties: {
@@ -302,13 +379,22 @@ This marks the proposal as approved. The actual code change should be made separ
           type: "string",
           description: "ID of the proposal to apply",
         },
+        approver: {
+          type: "string",
+          description: "Identity of the human approving (default: human)",
+        },
+        acknowledge_design_doc: {
+          type: "boolean",
+          description: "The approver has read the design doc of the region, when it links one",
+        },
       },
       required: ["proposal_id"],
     },
   },
   {
     name: "collab_reject_proposal",
-    description: `Reject a pending proposal.`,
+    description: `Reject a pending or approved proposal.
+The proposal is kept in .collab/proposals/ with its history; a rejected proposal cannot be applied.`,
     inputSchema: {
       type: "object" as const,
       properties: {
@@ -324,17 +410,75 @@ This marks the proposal as approved. The actual code change should be made separ
       required: ["proposal_id"],
     },
   },
+  {
+    name: "collab_mark_proposal_applied",
+    description: `Mark an approved proposal as applied, after its change has been made.
+Only approved proposals can be marked applied.`,
+    inputSchema: {
+      type: "object" as const,
+      properties: {
+        proposal_id: {
+          type: "string",
+          description: "ID of the proposal that was applied",
+        },
+        by: {
+          type: "string",
+          description: "Who made the change",
+        },
+      },
+      required: ["proposal_id"],
+    },
+  },
 ];
 
 // ============================================
 // Tool Handlers
 // ============================================
 
+const TRUST_GUIDANCE: Record<TrustLevel, string> = {
+  AUTONOMOUS: "You may edit this region freely.",
+  SUGGEST_ONLY: "Use collab_propose_change instead of direct Edit.",
+  READ_ONLY: "Do not modify this region. Explain why changes are needed and ask the human to make them.",
+  SUPERVISED: "Proceed with caution. Consider using collab_propose_change for significant changes.",
+};
+
+// Move a proposal along its lifecycle and report the step, or why it is not allowed
+async function transitionTool(
+  proposalId: string,
+  status: ProposalStatus,
+  options: { by?: string; reason?: string }
+) {
+  const proposal = await loadProposal(proposalId);
+  let response: Record<string, unknown>;
+  if (!proposal) {
+    response = { error: `Proposal ${proposalId} not found` };
+  } else {
+    try {
+      transitionProposal(proposal, status, options);
+      await saveProposal(proposal);
+      response = { status, proposal_id: proposalId, ...options };
+    } catch (error) {
+      if (!(error instanceof ProposalTransitionError)) throw error;
+      response = { error: error.message };
+    }
+  }
+
+  return {
+    content: [
+      {
+        type: "text",
+        text: JSON.stringify(response, null, 2),
+      },
+    ],
+  };
+}
+
 server.setRequestHandler(ListToolsRequestSchema, async () => {
   return { tools: TOOLS };
 });
 
-server.setRequestHandler(CallToolRequestSchema, async (request) => {
+// extra.signal aborts when the client sends notifications/cancelled for the request
+server.setRequestHandler(CallToolRequestSchema, async (request, extra) => {
   const { name, arguments: args } = request.params;
 
   try {
@@ -348,17 +492,16 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
 
         const trustConfig = await loadTrustConfig();
 
-        // Use annotation-aware version when line numbers provided
-        const trust = line_start !== undefined
-          ? await getTrustLevelWithAnnotations(trustConfig, file_path, line_start, line_end)
-          : getTrustLevel(trustConfig, file_path, line_start, line_end);
-
-        const guidance: Record<TrustLevel, string> = {
-          AUTONOMOUS: "You may edit this region freely.",
-          SUGGEST_ONLY: "Use collab_propose_change instead of direct Edit.",
-          READ_ONLY: "Do not modify this region. Explain why changes are needed and ask the human to make them.",
-          SUPERVISED: "Proceed with caution. Consider using collab_propose_change for significant changes.",
-        };
+        // Annotations apply when line numbers are provided; generated-file headers always do
+        const trust = await getTrustLevelWithAnnotations(trustConfig, file_path, line_start, line_end);
+        log.info("trust check", {
+          file: file_path,
+          line_start,
+          line_end,
+          trust: trust.level,
+          source: trust.source,
+          profile: trust.profile,
+        });
 
         return {
           content: [
@@ -372,8 +515,12 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
                   owner: trust.owner,
                   intent: trust.intent,
                   constraints: trust.constraints,
+                  design_doc: trust.design_doc,
                   source: trust.source,
-                  guidance: guidance[trust.level],
+                  pattern: trust.pattern,
+                  profile: trust.profile,
+                  base_level: trust.base_level,
+                  guidance: TRUST_GUIDANCE[trust.level],
                 },
                 null,
                 2
@@ -383,43 +530,42 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
         };
       }
 
-      case "collab_propose_change": {
-        const {
-          file_path,
-          description,
-          rationale,
-          old_code,
-          new_code,
-          confidence,
-          risks,
-          tests_needed,
-        } = args as {
-          file_path: string;
-          description: string;
-          rationale?: string;
-          old_code: string;
-          new_code: string;
-          confidence: number;
-          risks?: string[];
-          tests_needed?: string[];
-        };
+      case "collab_check_declaration_trust": {
+        const { declaration, file_path } = args as { declaration: string; file_path?: string };
 
-        const proposal = {
-          id: generateId(),
-          created_at: new Date().toISOString(),
-          author: "claude",
-          status: "pending" as const,
-          file_path,
-          description,
-          rationale,
-          old_code,
-          new_code,
-          confidence,
-          risks,
-          tests_needed,
+        const trust = await resolveByDeclaration(await loadTrustConfig(), declaration, file_path);
+
+        return {
+          content: [
+            {
+              type: "text",
+              text: JSON.stringify(
+                {
+                  declaration: trust.declaration.qualified_name,
+                  file: trust.declaration.file,
+                  line_start: trust.declaration.line_start,
+                  line_end: trust.declaration.line_end,
+                  trust_level: trust.level,
+                  reason: trust.reason,
+                  owner: trust.owner,
+                  intent: trust.intent,
+                  constraints: trust.constraints,
+                  source: trust.source,
+                  profile: trust.profile,
+                  base_level: trust.base_level,
+                  guidance: TRUST_GUIDANCE[trust.level],
+                },
+                null,
+                2
+              ),
+            },
+          ],
         };
+      }
 
-        await saveProposal(proposal);
+      case "collab_propose_change": {
+        // The author is always the agent, so it can never approve its own proposal
+        const proposal = await createProposal({ ...(args as ProposalInput), author: "claude" });
 
         return {
           content: [
@@ -429,6 +575,10 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
                 {
                   proposal_id: proposal.id,
                   status: "pending",
+                  min_approvals: proposal.min_approvals,
+                  design_doc: proposal.design_doc,
+                  redacted: proposal.redacted ? true : undefined,
+                  notify: proposal.owners,
                   message: `Proposal ${proposal.id} created. Human can review with: /collab-proposals`,
                 },
                 null,
@@ -439,6 +589,38 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
         };
       }
 
+      case "collab_propose_batch": {
+        const { edits } = args as { edits: ProposalInput[] };
+        const batches = await createBatchProposals(edits.map((edit) => ({ ...edit, author: "claude" })));
+
+        return {
+          content: [
+            {
+              type: "text",
+              text: JSON.stringify(
+                {
+                  batches: batches.map((batch) => ({
+                    batch_id: batch.id,
+                    notify: batch.owners,
+                    proposals: batch.proposals.map((p) => ({
+                      proposal_id: p.id,
+                      file: p.file_path,
+                      lines: p.base ? `${p.base.line_start}-${p.base.line_end}` : undefined,
+                      min_approvals: p.min_approvals,
+                      design_doc: p.design_doc,
+                      redacted: p.redacted ? true : undefined,
+                    })),
+                  })),
+                  message: `${edits.length} proposal(s) created in ${batches.length} batch(es). Human can review with: /collab-proposals`,
+                },
+                null,
+                2
+              ),
+            },
+          ],
+        };
+      }
+
       case "collab_record_intent": {
         const { file_path, region_name, line_start, line_end, intent, constraints, non_goals } =
           args as {
@@ -568,6 +750,7 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
         const structure = await getProjectStructure(".", {
           includeSamples: include_file_samples,
           maxFiles: max_files,
+          signal: extra.signal,
         });
 
         return {
@@ -608,7 +791,7 @@ Call collab_init with your recommended policies array.`,
             pattern: string;
             trust: TrustLevel;
             reason: string;
-            owner?: string;
+            owner?: string | string[];
           }>;
           default_trust?: TrustLevel;
         };
@@ -710,6 +893,11 @@ Call collab_init with your recommended policies array.`,
                     description: p.description,
                     confidence: p.confidence,
                     status: p.status,
+                    approvals: `${countApprovals(p)}/${p.min_approvals ?? 1}`,
+                    design_doc: p.design_doc,
+                    redacted: p.redacted ? true : undefined,
+                    owners: p.owners,
+                    batch: p.batch,
                     created_at: p.created_at,
                   })),
                 },
@@ -722,38 +910,91 @@ Call collab_init with your recommended policies array.`,
       }
 
       case "collab_apply_proposal": {
-        const { proposal_id } = args as { proposal_id: string };
+        const { proposal_id, approver, acknowledge_design_doc } = args as {
+          proposal_id: string;
+          approver?: string;
+          acknowledge_design_doc?: boolean;
+        };
 
-        const proposals = await loadProposals();
-        const proposal = proposals.find((p) => p.id === proposal_id);
+        const proposal = await loadProposal(proposal_id);
 
-        if (!proposal) {
+        if (!proposal || proposal.status !== "pending") {
           return {
             content: [
               {
                 type: "text",
-                text: JSON.stringify({ error: `Proposal ${proposal_id} not found` }, null, 2),
+                text: JSON.stringify(
+                  {
+                    error: proposal
+                      ? `Proposal ${proposal_id} is ${proposal.status}, only pending proposals can be approved`
+                      : `Proposal ${proposal_id} not found`,
+                  },
+                  null,
+                  2
+                ),
               },
             ],
           };
         }
 
-        // Return the proposal details for the caller to apply
-        await deleteProposal(proposal_id);
+        const outcome = await approveProposal(proposal, approver || "human", {
+          acknowledgeDesignDoc: acknowledge_design_doc === true,
+        });
+        let response: Record<string, unknown>;
+        switch (outcome.status) {
+          case "design_doc_unacknowledged":
+            response = {
+              status: "design_doc_unacknowledged",
+              proposal_id,
+              design_doc: outcome.design_doc,
+              message: `This region links a design doc. Ask the approver to read ${outcome.design_doc}, then call again with acknowledge_design_doc: true.`,
+            };
+            break;
+          case "awaiting_approvals": {
+            const { approval } = outcome;
+            response = {
+              status: "awaiting_approvals",
+              proposal_id,
+              approvals: approval.approvals,
+              required: approval.required,
+              message: approval.counted
+                ? `Approval recorded. ${approval.required - approval.approvals} more approval(s) needed.`
+                : `Approval not counted (self-approval or repeat approver). ${approval.required - approval.approvals} more approval(s) needed.`,
+            };
+            break;
+          }
+          case "stale":
+            response = {
+              status: "stale",
+              proposal_id,
+              reason: outcome.error.code,
+              message: `${outcome.error.message}. Reject it and propose the change again against the current code.`,
+            };
+            break;
+          case "refused":
+            response = {
+              status: "refused",
+              proposal_id,
+              failures: outcome.failures,
+              message: formatPreApplyFailures(outcome.failures),
+            };
+            break;
+          default:
+            // Return the proposal details for the caller to apply
+            response = {
+              status: "approved",
+              proposal: proposal,
+              message: proposal.redacted
+                ? `Proposal approved. old_code is redacted, so the owner must apply it in place: replace lines ${proposal.redacted.line_start}-${proposal.redacted.line_end} of ${proposal.file_path} in place, after checking the code there still hashes to sha256:${proposal.redacted.sha256}. Then mark it applied with collab_mark_proposal_applied.`
+                : "Proposal approved. Apply the change using Edit tool, then mark it applied with collab_mark_proposal_applied.",
+            };
+        }
 
         return {
           content: [
             {
               type: "text",
-              text: JSON.stringify(
-                {
-                  status: "approved",
-                  proposal: proposal,
-                  message: "Proposal approved. Apply the change using Edit tool.",
-                },
-                null,
-                2
-              ),
+              text: JSON.stringify(response, null, 2),
             },
           ],
         };
@@ -762,24 +1003,13 @@ Call collab_init with your recommended policies array.`,
       case "collab_reject_proposal": {
         const { proposal_id, reason } = args as { proposal_id: string; reason?: string };
 
-        await deleteProposal(proposal_id);
+        return await transitionTool(proposal_id, "rejected", { reason: reason || "No reason provided" });
+      }
 
-        return {
-          content: [
-            {
-              type: "text",
-              text: JSON.stringify(
-                {
-                  status: "rejected",
-                  proposal_id,
-                  reason: reason || "No reason provided",
-                },
-                null,
-                2
-              ),
-            },
-          ],
-        };
+      case "collab_mark_proposal_applied": {
+        const { proposal_id, by } = args as { proposal_id: string; by?: string };
+
+        return await transitionTool(proposal_id, "applied", { by });
       }
 
       default:
@@ -794,6 +1024,11 @@ Call collab_init with your recommended policies array.`,
     }
   } catch (error) {
     const errorMessage = error instanceof Error ? error.message : String(error);
+    if (extra.signal.aborted) {
+      log.info("tool cancelled", { tool: name }); // The client no longer wants the result
+    } else {
+      log.error("tool failed", { tool: name, error: errorMessage });
+    }
     return {
       content: [
         {
@@ -810,6 +1045,7 @@ Call collab_init with your recommended policies array.`,
 // ============================================
 
 async function main() {
+  applyLogArgs(process.argv.slice(2));
   const transport = new StdioServerTransport();
   await server.connect(transport);
   console.error("Collab MCP Server running on stdio");
@@ -269,8 +269,57 @@ collab-claude-code - Human-LLM collaboration tracking for Claude Code
 Usage:
   collab-claude-code init       Install skills, MCP server, and hooks
   collab-claude-code uninstall  Remove all components
+  collab-claude-code check [--format=text|json|junit|github-comments] [--base=<rev>] [--profile=<name>] [--no-ignore] [--shadow] [--dedupe=region [--list-suppressed]] [paths...]
+                                Validate annotations and authorship for CI (--shadow: audit, never fail)
+  collab-claude-code fmt [--check] [--format=text|json] [--no-ignore] [paths...]
+                                Rewrite @collab annotations into canonical form (--check: fail instead)
+  collab-claude-code diff <base> [head] [--format=text|json] [--notify]
+                                Report trust upgrades/downgrades and ownership transfers between revisions
+  collab-claude-code audit-signoffs <base> [head] [--signoffs=<file>] [--format=text|json]
+                                Fail on downgrades and owner changes without a sign-off from the old owner
+  collab-claude-code scan <file...> [--files-from=<file>|-] [--format=text|json|ndjson] [--sort] [--group-by=receiver] [--strict] [--label=<label>] [--on-parse-error=skip|fail|warn] [--quiet] [--rev=<revision>] [--profile=<name>]
+                                Summarize trust regions, levels, and owners
+  collab-claude-code scan-archive <archive> [--format=text|json]
+                                Scan annotations in a .tar, .tgz, or .zip release without extracting it
+  collab-claude-code at --file=<file> --line=<line> [--col=<col>] [--stdin] [--format=text|json] [--profile=<name>]
+                                One-line trust level, owner, and intent at a cursor, for editor status lines
+  collab-claude-code heatmap [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+                                Count lines per trust level and owner for each file
+  collab-claude-code budget [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+                                Fail when AUTONOMOUS lines exceed a trust.yaml budget
+  collab-claude-code explain-policy <file> [--format=text|json] [--profile=<name>]
+                                Show the effective trust config for a file and where each setting came from
+  collab-claude-code simulate --config=<trust.yaml> [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+                                Preview which regions a trust.yaml change makes tighter or looser
+  collab-claude-code test-policy <fixtures-dir> [--format=text|json] [--profile=<name>]
+                                Check that fixture edits get their expected allow/propose/deny decision
+  collab-claude-code doctor [--format=text|json] [--profile=<name>] [paths...]
+                                Validate config, extends sources, and annotations before relying on CI results
+  collab-claude-code verify-constraints [--strict] [--format=text|json] [--no-ignore] [paths...]
+                                Quarantine regions whose code already breaks its constraints (--strict: fail)
+  collab-claude-code audit-owners [--roster=<file>] [--format=text|json] [--no-ignore] [paths...]
+                                List owners missing from CODEOWNERS or a roster, with the regions they govern
+  collab-claude-code verify-authors [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+                                Flag READ_ONLY and SUGGEST_ONLY regions last changed (git blame) by a non-owner
+  collab-claude-code constraints [--format=text|json] [--no-ignore] [paths...]
+                                List every distinct constraint with the regions that declare it
+  collab-claude-code manifest [--profile=<name>] [--no-ignore] [paths...]
+                                Print a JSON governance snapshot: resolved regions, merged config, caps, content hash
+  collab-claude-code warm [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+                                Refresh the extended policy cache and parse every file, before serving
+  collab-claude-code proposals list [--status=<status>|all] | show <id> | status <id> [<new-status>] [--by=<name>] [--reason=<text>] [--ack-design-doc]
+                                List proposals, or show one, or move it to approved, rejected, or applied
+  collab-claude-code completions --context=<text>
+                                Print JSON completions for a partial @collab annotation, for editor plugins
+  collab-claude-code routes [--map=<file>] [--format=json|openapi] [--profile=<name>] [--no-ignore] [paths...]
+                                Map HTTP routes to their handlers' trust level and owner
+  collab-claude-code reasons [--format=markdown|json]
+                                Print violation codes and trust level reference
   collab-claude-code --help     Show this help message
 
+Every command accepts --log-level=debug|info|warn|error and --log-format=text|json
+(logs go to stderr).
+
 After installation, use these commands in Claude Code:
   /collab-init      - Initialize collaboration in a project
   /collab-status    - View collaboration status
@@ -0,0 +1,143 @@
+/**
+ * Structured logging for operators
+ *
+ * Leveled records (debug, info, warn, error) with key-value attributes,
+ * written one per line to stderr so they never mix with command output on
+ * stdout. Text records look like `time=... level=INFO msg="trust check"
+ * file=src/pay.ts trust=READ_ONLY`; JSON records carry the same fields as one
+ * object per line.
+ *
+ * The CLI takes --log-level= and --log-format= before or after the command;
+ * the MCP server and hooks, which are started by Claude Code, read the
+ * COLLAB_LOG_LEVEL and COLLAB_LOG_FORMAT environment variables. The default
+ * is level warn, text format.
+ *
+ * Only depends on node so the hooks can use it.
+ */
+
+// ============================================
+// Types
+// ============================================
+
+export const LOG_LEVELS = ["debug", "info", "warn", "error"] as const;
+export type LogLevel = (typeof LOG_LEVELS)[number];
+
+export const LOG_FORMATS = ["text", "json"] as const;
+export type LogFormat = (typeof LOG_FORMATS)[number];
+
+export type LogAttributes = Record<string, unknown>;
+
+export interface LogSettings {
+  level: LogLevel;
+  format: LogFormat;
+  write: (line: string) => void; // Receives each record without its trailing newline
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const LOG_LEVEL_ENV = "COLLAB_LOG_LEVEL";
+export const LOG_FORMAT_ENV = "COLLAB_LOG_FORMAT";
+
+const DEFAULT_LEVEL: LogLevel = "warn";
+const DEFAULT_FORMAT: LogFormat = "text";
+
+let settings: LogSettings | undefined;
+
+// ============================================
+// Configuration
+// ============================================
+
+function isLogLevel(value: string): value is LogLevel {
+  return (LOG_LEVELS as readonly string[]).includes(value);
+}
+
+function isLogFormat(value: string): value is LogFormat {
+  return (LOG_FORMATS as readonly string[]).includes(value);
+}
+
+// Invalid environment values fall back to the defaults rather than breaking a hook.
+// Without a Node process (the browser bundle) records go to the console.
+function settingsFromEnv(env: NodeJS.ProcessEnv = globalThis.process?.env ?? {}): LogSettings {
+  const level = env[LOG_LEVEL_ENV]?.toLowerCase() ?? "";
+  const format = env[LOG_FORMAT_ENV]?.toLowerCase() ?? "";
+  return {
+    level: isLogLevel(level) ? level : DEFAULT_LEVEL,
+    format: isLogFormat(format) ? format : DEFAULT_FORMAT,
+    write: line => (globalThis.process?.stderr ? process.stderr.write(line + "\n") : console.error(line)),
+  };
+}
+
+export function configureLogging(options: Partial<LogSettings>): void {
+  settings = { ...(settings ?? settingsFromEnv()), ...options };
+}
+
+export function resetLogging(): void {
+  settings = undefined;
+}
+
+/**
+ * Remove --log-level= and --log-format= from the arguments and apply them.
+ * Throws on a value that is not a known level or format.
+ */
+export function applyLogArgs(args: string[]): string[] {
+  const rest: string[] = [];
+  for (const arg of args) {
+    if (arg.startsWith("--log-level=")) {
+      const level = arg.slice("--log-level=".length).toLowerCase();
+      if (!isLogLevel(level)) {
+        throw new Error(`Invalid --log-level "${level}" (expected ${LOG_LEVELS.join(", ")})`);
+      }
+      configureLogging({ level });
+    } else if (arg.startsWith("--log-format=")) {
+      const format = arg.slice("--log-format=".length).toLowerCase();
+      if (!isLogFormat(format)) {
+        throw new Error(`Invalid --log-format "${format}" (expected ${LOG_FORMATS.join(", ")})`);
+      }
+      configureLogging({ format });
+    } else {
+      rest.push(arg);
+    }
+  }
+  return rest;
+}
+
+// ============================================
+// Records
+// ============================================
+
+function textValue(value: unknown): string {
+  const text = typeof value === "string" ? value : JSON.stringify(value) ?? String(value);
+  return text === "" || /[\s"=]/.test(text) ? JSON.stringify(text) : text;
+}
+
+export function formatLogRecord(
+  format: LogFormat,
+  level: LogLevel,
+  msg: string,
+  attrs: LogAttributes = {},
+  time = new Date()
+): string {
+  // Attributes never override the record's own fields
+  const ordered: LogAttributes = { time: time.toISOString(), level: level.toUpperCase(), msg };
+  for (const [key, value] of Object.entries(attrs)) {
+    if (value !== undefined && !(key in ordered)) ordered[key] = value;
+  }
+
+  if (format === "json") return JSON.stringify(ordered);
+  return Object.entries(ordered).map(([key, value]) => `${key}=${textValue(value)}`).join(" ");
+}
+
+function emit(level: LogLevel, msg: string, attrs?: LogAttributes): void {
+  settings ??= settingsFromEnv();
+  if (LOG_LEVELS.indexOf(level) < LOG_LEVELS.indexOf(settings.level)) return;
+  settings.write(formatLogRecord(settings.format, level, msg, attrs));
+}
+
+export const log = {
+  debug: (msg: string, attrs?: LogAttributes) => emit("debug", msg, attrs),
+  info: (msg: string, attrs?: LogAttributes) => emit("info", msg, attrs),
+  warn: (msg: string, attrs?: LogAttributes) => emit("warn", msg, attrs),
+  error: (msg: string, attrs?: LogAttributes) => emit("error", msg, attrs),
+};
@@ -0,0 +1,233 @@
+/**
+ * manifest command for collab-claude-code
+ *
+ * A point-in-time governance snapshot to archive for compliance: every
+ * resolved region with its effective trust, owner, constraints, and where
+ * the level came from, together with the merged config, its caps and
+ * defaults, the tool version, and a hash of the files it covers:
+ *
+ *   collab-claude-code manifest [--profile=<name>] [--no-ignore] [paths...] > manifest.json
+ *
+ * The output is JSON only, with sorted keys, sorted files, and a versioned
+ * schema (MANIFEST_SCHEMA_VERSION), so two manifests of the same tree differ
+ * only in `generated_at` and the document can be signed as is. Regions are
+ * resolved exactly as collab_check_trust resolves them.
+ *
+ * Exit codes follow the check command:
+ *   0 = Manifest written
+ *   2 = Tool error (unreadable file, invalid config)
+ */
+
+import { createHash } from "crypto";
+import * as fs from "fs/promises";
+
+import {
+  AgentPolicy,
+  PackageDefault,
+  TrustBudget,
+  TrustConfig,
+  TrustLevel,
+  TrustResult,
+  comparePaths,
+  compareRegions,
+  generatedTrustLevel,
+  isGeneratedSource,
+  isGoTestSource,
+  loadCollabConfig,
+  loadPackageDefault,
+  loadTrustAliases,
+  parseAnnotationContent,
+  regionMatchesFile,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+// A region as resolved; the TrustResult fields are its effective trust and their provenance
+export interface ManifestRegion extends TrustResult {
+  line_start: number;
+  line_end: number;
+  declared_by: "annotation" | "region"; // An @collab annotation, or a trust.yaml `regions` entry
+  declared_trust?: TrustLevel; // The level it states, when it states one
+}
+
+export interface ManifestFile {
+  file: string;
+  sha256: string; // Of the file's content
+  fallback: TrustResult; // Applies to lines outside every region
+  package_default?: PackageDefault;
+  regions: ManifestRegion[];
+}
+
+export interface GovernanceManifest {
+  schema_version: number;
+  tool: { name: string; version: string };
+  generated_at: string; // ISO 8601
+  content_hash: string; // sha256 of the files' `sha256sum` lines, in file order
+  profile?: string;
+  config: TrustConfig; // trust.yaml with its extends merged and the profile applied
+  defaults: {
+    trust: TrustLevel;
+    generated: TrustLevel | false; // false when the generated-code guard is disabled
+    test: TrustLevel; // For Go test files
+  };
+  caps: {
+    budgets: TrustBudget[]; // trust.yaml limits on AUTONOMOUS code
+    agents: Record<string, AgentPolicy>; // config.yaml per-agent trust ceilings
+  };
+  files: ManifestFile[];
+}
+
+// ============================================
+// Constants
+// ============================================
+
+// Bumped whenever a field is removed or changes meaning; new fields may appear without a bump
+export const MANIFEST_SCHEMA_VERSION = 1;
+
+const TOOL_NAME = "collab-claude-code";
+const PACKAGE_JSON = new URL("../package.json", import.meta.url);
+
+// ============================================
+// Manifest
+// ============================================
+
+function sha256(content: string): string {
+  return createHash("sha256").update(content).digest("hex");
+}
+
+async function toolVersion(): Promise<string> {
+  try {
+    const { version } = JSON.parse(await fs.readFile(PACKAGE_JSON, "utf-8")) as { version?: string };
+    if (version) return version;
+  } catch {
+    // Reported below
+  }
+  throw new CheckToolError("Cannot read the tool version from package.json");
+}
+
+export async function buildManifest(paths: string[], options: CheckOptions = {}): Promise<GovernanceManifest> {
+  const { signal } = options;
+  const config = await loadTrustConfigStrict(options.profile);
+  const invalidConfig = (error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  };
+  const aliases = await loadTrustAliases().catch(invalidConfig);
+  const collabConfig = await loadCollabConfig().catch(invalidConfig);
+  const files = await expandPaths(paths, options);
+
+  const entries: ManifestFile[] = [];
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    const { annotations } = parseAnnotationContent(content, file, { aliases });
+    const declarations = config.symbols?.length ? findDeclarations(content, file) : [];
+    const generated = isGeneratedSource(content);
+    const packageDefault = await loadPackageDefault(file, { aliases });
+    const testFile = isGoTestSource(file, content);
+    const resolve = (start?: number, end?: number) =>
+      resolveTrustWithAnnotations(config, file, annotations, start, end, declarations, generated, packageDefault, testFile);
+
+    const regions: ManifestRegion[] = annotations
+      .filter(a => a.line_end >= a.line_start)
+      .map(a => ({
+        ...resolve(a.line_start, a.line_end),
+        line_start: a.line_start,
+        line_end: a.line_end,
+        declared_by: "annotation",
+        declared_trust: a.trust,
+      }));
+
+    for (const region of config.regions ?? []) {
+      if (regionMatchesFile(region, file)) {
+        regions.push({
+          ...resolve(region.line_start, region.line_end),
+          line_start: region.line_start,
+          line_end: region.line_end,
+          declared_by: "region",
+          declared_trust: region.trust,
+        });
+      }
+    }
+    regions.sort(compareRegions);
+
+    entries.push({
+      file,
+      sha256: sha256(content),
+      fallback: resolve(),
+      package_default: packageDefault,
+      regions,
+    });
+  }
+  entries.sort((a, b) => comparePaths(a.file, b.file));
+
+  return {
+    schema_version: MANIFEST_SCHEMA_VERSION,
+    tool: { name: TOOL_NAME, version: await toolVersion() },
+    generated_at: new Date().toISOString(),
+    content_hash: sha256(entries.map(e => `${e.sha256}  ${e.file}\n`).join("")),
+    profile: config.active_profile,
+    config,
+    defaults: {
+      trust: config.default_trust,
+      generated: generatedTrustLevel(config) ?? false,
+      test: config.test_trust ?? config.default_trust,
+    },
+    caps: {
+      budgets: config.budgets ?? [],
+      agents: collabConfig.agents ?? {},
+    },
+    files: entries,
+  };
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runManifest(args: string[]): Promise<number> {
+  let profile: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    console.log(stableStringify(await buildManifest(paths, { profile, noIgnore }), 2));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
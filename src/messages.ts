@@ -0,0 +1,153 @@
+/**
+ * Localized violation messages
+ *
+ * Reason codes are stable, so translations key on them. A catalog maps codes
+ * to message templates for one locale; {name} placeholders are filled from
+ * the violation's params. English ships with the package (the `message` of
+ * each reason code); other locales come from .collab/locales/<locale>.yaml or
+ * registerMessageCatalog():
+ *
+ *   # .collab/locales/de.yaml
+ *   unknown-trust-level: 'Unbekannte Vertrauensstufe "{value}" (erwartet: {expected})'
+ *
+ * The locale is config.yaml `locale`, else LC_ALL, LC_MESSAGES, or LANG.
+ * Codes a catalog lacks, and templates naming a param the violation does not
+ * have, fall back to the English message.
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import { COLLAB_DIR, loadCollabConfig } from "./collab.js";
+import { ReasonCodeId, reasonCodes } from "./reasons.js";
+
+// ============================================
+// Types
+// ============================================
+
+export type MessageCatalog = Partial<Record<ReasonCodeId, string>>;
+
+// Anything carrying a reason code and an English message: violations, annotation errors
+export interface Localizable {
+  code: ReasonCodeId;
+  message: string;
+  params?: Record<string, string>;
+}
+
+export interface Localizer {
+  locale: string;
+  localize(item: Localizable): string;
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const DEFAULT_LOCALE = "en";
+export const LOCALES_DIR = path.join(COLLAB_DIR, "locales");
+
+const registeredCatalogs = new Map<string, MessageCatalog>();
+
+// ============================================
+// Catalogs
+// ============================================
+
+// The templates English messages are built from, for translators to start from
+export function englishCatalog(): MessageCatalog {
+  return Object.fromEntries(reasonCodes().map(r => [r.code, r.message]));
+}
+
+// Catalogs registered here take precedence over .collab/locales files for the same locale
+export function registerMessageCatalog(locale: string, catalog: MessageCatalog): void {
+  const key = normalizeLocale(locale) ?? locale;
+  registeredCatalogs.set(key, { ...registeredCatalogs.get(key), ...catalog });
+}
+
+// "de_DE.UTF-8" -> "de-DE"; undefined for an empty value, "en" for the C locale
+export function normalizeLocale(raw?: string): string | undefined {
+  const base = raw?.trim().split(/[.@]/)[0];
+  if (!base) return undefined;
+  if (base === "C" || base === "POSIX") return DEFAULT_LOCALE;
+  const [language, region] = base.split(/[-_]/);
+  return region ? `${language.toLowerCase()}-${region.toUpperCase()}` : language.toLowerCase();
+}
+
+export function detectLocale(configured?: string, env: NodeJS.ProcessEnv = process.env): string {
+  return (
+    normalizeLocale(configured) ??
+    normalizeLocale(env.LC_ALL) ??
+    normalizeLocale(env.LC_MESSAGES) ??
+    normalizeLocale(env.LANG) ??
+    DEFAULT_LOCALE
+  );
+}
+
+// "de-DE" is looked up as de-DE, then de
+function localeChain(locale: string): string[] {
+  const language = locale.split("-")[0];
+  return language === locale ? [locale] : [locale, language];
+}
+
+async function readCatalogFile(locale: string, rootDir: string): Promise<MessageCatalog> {
+  const file = path.join(rootDir, LOCALES_DIR, `${locale}.yaml`);
+  let content: string;
+  try {
+    content = await fs.readFile(file, "utf-8");
+  } catch {
+    return {};
+  }
+
+  const parsed = yaml.parse(content) as unknown;
+  if (parsed === null) return {};
+  if (typeof parsed !== "object" || Array.isArray(parsed)) {
+    throw new Error(`${file}: expected a mapping of reason codes to messages`);
+  }
+  for (const [code, template] of Object.entries(parsed)) {
+    if (typeof template !== "string") {
+      throw new Error(`${file}: message for ${code} must be a string`);
+    }
+  }
+  return parsed as MessageCatalog;
+}
+
+// Fill {name} placeholders; undefined if the template names a param that is not given
+export function fillTemplate(template: string, params: Record<string, string> = {}): string | undefined {
+  let missing = false;
+  const filled = template.replace(/\{(\w+)\}/g, (_, name: string) => {
+    if (params[name] === undefined) missing = true;
+    return params[name] ?? "";
+  });
+  return missing ? undefined : filled;
+}
+
+/**
+ * A Localizer for the configured locale. English needs no catalog: messages
+ * are already built in English. Throws if a catalog file is malformed.
+ */
+export async function loadLocalizer(options: { locale?: string; rootDir?: string } = {}): Promise<Localizer> {
+  const locale = detectLocale(options.locale ?? (await loadCollabConfig()).locale);
+  const catalogs: MessageCatalog[] = [];
+  for (const candidate of localeChain(locale)) {
+    if (candidate === DEFAULT_LOCALE) break;
+    const fromFile = await readCatalogFile(candidate, options.rootDir ?? ".");
+    catalogs.push({ ...fromFile, ...registeredCatalogs.get(candidate) });
+  }
+
+  return {
+    locale,
+    localize(item) {
+      for (const catalog of catalogs) {
+        const template = catalog[item.code];
+        const filled = template === undefined ? undefined : fillTemplate(template, item.params);
+        if (filled !== undefined) return filled;
+      }
+      return item.message;
+    },
+  };
+}
+
+// Copies of the items with their messages localized
+export function localizeMessages<T extends Localizable>(items: T[], localizer: Localizer): T[] {
+  return items.map(item => ({ ...item, message: localizer.localize(item) }));
+}
@@ -0,0 +1,311 @@
+/**
+ * Shared trust policies via `extends:`
+ *
+ * trust.yaml may extend one or more central policy documents, fetched over
+ * HTTP(S) or read from a path such as a git submodule:
+ *
+ *   extends:
+ *     - url: https://example.com/org/trust.yaml
+ *       sha256: 3a7bd3e2...   # optional pin
+ *     - path: vendor/policies/trust.yaml
+ *
+ * Fetched documents are cached under .collab/cache/policies. Pinned documents
+ * are verified against their hash and reused from cache indefinitely;
+ * unpinned URLs are refreshed after CACHE_TTL_MS, falling back to the stale
+ * copy if the fetch fails. Extended documents cannot extend further. Cache
+ * files are replaced atomically, so `warm` can refresh them while a server or
+ * hook is reading the same directory.
+ *
+ * Only depends on node and yaml so the hooks can use it (offline, cache only).
+ */
+
+import { createHash } from "crypto";
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import { log } from "./log.js";
+
+// ============================================
+// Types
+// ============================================
+
+export type PolicySource = string | { url?: string; path?: string; sha256?: string };
+
+// The trust.yaml keys that take part in merging
+export interface PolicyDocument {
+  default_trust?: string;
+  policies?: unknown[];
+  regions?: unknown[];
+  symbols?: unknown[];
+  profiles?: Record<string, unknown>;
+  budgets?: unknown[];
+  generated_trust?: string | false;
+  test_trust?: string;
+  extends?: PolicySource | PolicySource[];
+}
+
+// An extended document and where it came from, for provenance reporting
+export interface PolicyLayer {
+  source: string; // The URL or path
+  document: PolicyDocument;
+}
+
+/**
+ * The layer each merged setting came from, by setting: "default_trust",
+ * "policies[0]", "profiles.dev", and so on. Settings no layer sets are absent.
+ */
+export type ConfigProvenance = Record<string, string>;
+
+export interface MergedConfig {
+  config: PolicyDocument;
+  provenance: ConfigProvenance;
+}
+
+export interface ExtendsOptions {
+  rootDir?: string;
+  offline?: boolean; // Never fetch; use cached or local documents only
+  refresh?: boolean; // Fetch unpinned URLs even when the cached copy is fresh
+  signal?: AbortSignal;
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const POLICY_CACHE_DIR = path.join(".collab", "cache", "policies");
+const CACHE_TTL_MS = 60 * 60 * 1000;
+const FETCH_TIMEOUT_MS = 10_000;
+
+// ============================================
+// Loading
+// ============================================
+
+function sha256(content: string): string {
+  return createHash("sha256").update(content).digest("hex");
+}
+
+function normalizeSource(source: PolicySource): { url?: string; path?: string; sha256?: string } {
+  if (typeof source !== "string") return source;
+  return /^https?:\/\//.test(source) ? { url: source } : { path: source };
+}
+
+function verifyPin(label: string, content: string, pin?: string): void {
+  if (pin && sha256(content) !== pin.toLowerCase()) {
+    throw new Error(`${label}: sha256 mismatch (pinned ${pin}, got ${sha256(content)})`);
+  }
+}
+
+async function readCached(file: string): Promise<{ content: string; age: number } | null> {
+  try {
+    const [content, stat] = await Promise.all([fs.readFile(file, "utf-8"), fs.stat(file)]);
+    return { content, age: Date.now() - stat.mtimeMs };
+  } catch {
+    return null;
+  }
+}
+
+async function fetchPolicy(url: string, signal?: AbortSignal): Promise<string> {
+  const response = await fetch(url, { signal: signal ?? AbortSignal.timeout(FETCH_TIMEOUT_MS) });
+  if (!response.ok) {
+    throw new Error(`HTTP ${response.status}`);
+  }
+  return response.text();
+}
+
+// Returns null only offline, when a URL has never been fetched
+async function loadRemote(
+  url: string,
+  pin: string | undefined,
+  options: ExtendsOptions
+): Promise<string | null> {
+  const rootDir = options.rootDir ?? ".";
+  const cacheFile = path.join(rootDir, POLICY_CACHE_DIR, `${sha256(url).slice(0, 16)}.yaml`);
+  const cached = await readCached(cacheFile);
+  const cacheMatchesPin = cached !== null && (!pin || sha256(cached.content) === pin.toLowerCase());
+
+  const fresh = cached !== null && cached.age < CACHE_TTL_MS && !options.refresh;
+  if (cached && cacheMatchesPin && (pin || options.offline || fresh)) {
+    log.debug("policy cache hit", { url, age_ms: cached.age, pinned: Boolean(pin) });
+    return cached.content;
+  }
+  if (options.offline) {
+    return null;
+  }
+
+  let content: string;
+  try {
+    content = await fetchPolicy(url, options.signal);
+  } catch (error) {
+    options.signal?.throwIfAborted();
+    const message = error instanceof Error ? error.message : String(error);
+    if (cached && cacheMatchesPin) {
+      console.error(`Warning: could not refresh ${url} (${message}); using cached copy`);
+      return cached.content;
+    }
+    throw new Error(`Cannot fetch extended policy ${url}: ${message}`);
+  }
+
+  verifyPin(url, content, pin);
+  log.debug("policy fetched", { url, cache: cached ? "stale" : "miss" });
+  await writeCacheFile(cacheFile, content);
+  return content;
+}
+
+// Write then rename, so a concurrent reader sees the old copy or the new one, never a partial file
+async function writeCacheFile(cacheFile: string, content: string): Promise<void> {
+  await fs.mkdir(path.dirname(cacheFile), { recursive: true });
+  const temp = `${cacheFile}.${process.pid}.${Math.random().toString(36).slice(2)}.tmp`;
+  try {
+    await fs.writeFile(temp, content);
+    await fs.rename(temp, cacheFile);
+  } catch (error) {
+    await fs.rm(temp, { force: true });
+    throw error;
+  }
+}
+
+export async function loadExtendedPolicies(
+  sources: PolicySource | PolicySource[],
+  options: ExtendsOptions = {}
+): Promise<PolicyDocument[]> {
+  return (await loadPolicyLayers(sources, options)).map(layer => layer.document);
+}
+
+export async function loadPolicyLayers(
+  sources: PolicySource | PolicySource[],
+  options: ExtendsOptions = {}
+): Promise<PolicyLayer[]> {
+  const layers: PolicyLayer[] = [];
+
+  for (const source of Array.isArray(sources) ? sources : [sources]) {
+    options.signal?.throwIfAborted();
+    const { url, path: policyPath, sha256: pin } = normalizeSource(source);
+
+    let label: string;
+    let content: string | null;
+    if (url) {
+      label = url;
+      content = await loadRemote(url, pin, options);
+    } else if (policyPath) {
+      label = policyPath;
+      try {
+        content = await fs.readFile(path.join(options.rootDir ?? ".", policyPath), "utf-8");
+      } catch {
+        throw new Error(`Cannot read extended policy ${policyPath}`);
+      }
+      verifyPin(policyPath, content, pin);
+    } else {
+      throw new Error("extends entries need a url or a path");
+    }
+
+    if (content === null) continue;
+    const parsed = yaml.parse(content) as PolicyDocument | null;
+    if (!parsed || typeof parsed !== "object") {
+      throw new Error(`Extended policy ${label} is not a YAML mapping`);
+    }
+    layers.push({ source: label, document: parsed });
+  }
+
+  return layers;
+}
+
+/**
+ * Load one extends entry bypassing the cache, so a URL is actually fetched.
+ * Throws if it cannot be read, fails its pin, or is not a YAML mapping.
+ * Used by `doctor` to check that every source is reachable.
+ */
+export async function probePolicySource(source: PolicySource, options: ExtendsOptions = {}): Promise<PolicyLayer> {
+  const { url, sha256: pin } = normalizeSource(source);
+  if (!url) {
+    const [layer] = await loadPolicyLayers(source, { ...options, offline: true });
+    return layer;
+  }
+
+  let content: string;
+  try {
+    content = await fetchPolicy(url, options.signal);
+  } catch (error) {
+    options.signal?.throwIfAborted();
+    throw new Error(`Cannot fetch extended policy ${url}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  verifyPin(url, content, pin);
+  const parsed = yaml.parse(content) as PolicyDocument | null;
+  if (!parsed || typeof parsed !== "object") {
+    throw new Error(`Extended policy ${url} is not a YAML mapping`);
+  }
+  return { source: url, document: parsed };
+}
+
+// ============================================
+// Merging
+// ============================================
+
+const SCALAR_KEYS = ["default_trust", "generated_trust", "test_trust"] as const;
+const LIST_KEYS = ["policies", "regions", "symbols", "budgets"] as const;
+
+/**
+ * Merge config layers, lowest precedence first (extends entries in order,
+ * then the local trust.yaml last), and record where each setting came from:
+ *
+ * - default_trust, generated_trust, test_trust: the last layer that sets it
+ * - policies, regions, symbols, budgets: appended, the last layer's entries
+ *   first, and every budget applies. Order does not make a local policy win:
+ *   the most specific policy applies, and equally specific ones that disagree
+ *   resolve to the most restrictive (findMatchingPolicy)
+ * - profiles: merged by name, a later layer's profile replacing an earlier one
+ * - any other key, such as extends: the last layer's value only
+ *
+ * Layers are not modified, and the same layers always give the same result.
+ */
+export function mergeConfigs(layers: PolicyLayer[]): MergedConfig {
+  const top = layers[layers.length - 1];
+  const highestFirst = [...layers].reverse();
+  const config: PolicyDocument = { ...(top?.document ?? {}) };
+  const provenance: ConfigProvenance = {};
+
+  for (const key of Object.keys(config)) {
+    if (config[key as keyof PolicyDocument] !== undefined) provenance[key] = top.source;
+  }
+
+  for (const key of SCALAR_KEYS) {
+    const from = highestFirst.find(layer => layer.document[key] !== undefined && layer.document[key] !== null);
+    (config as Record<string, unknown>)[key] = from?.document[key];
+    if (from) provenance[key] = from.source;
+    else delete provenance[key];
+  }
+
+  for (const key of LIST_KEYS) {
+    const entries: unknown[] = [];
+    delete provenance[key];
+    for (const layer of highestFirst) {
+      for (const entry of layer.document[key] ?? []) {
+        provenance[`${key}[${entries.length}]`] = layer.source;
+        entries.push(entry);
+      }
+    }
+    config[key] = entries;
+  }
+
+  const profiles: Record<string, unknown> = {};
+  delete provenance.profiles;
+  for (const layer of layers) {
+    for (const [name, profile] of Object.entries(layer.document.profiles ?? {})) {
+      profiles[name] = profile;
+      provenance[`profiles.${name}`] = layer.source;
+    }
+  }
+  config.profiles = profiles;
+
+  return { config, provenance };
+}
+
+/**
+ * Merge extended documents under the local config, as mergeConfigs does with
+ * the local document as the last layer. Local settings win, then later
+ * `extends` entries over earlier ones.
+ */
+export function mergePolicy<T extends PolicyDocument>(local: T, extended: PolicyDocument[]): T {
+  const layers = [...extended.map(document => ({ source: "extends", document })), { source: "local", document: local }];
+  return mergeConfigs(layers).config as T;
+}
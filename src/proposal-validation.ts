@@ -0,0 +1,165 @@
+/**
+ * Stale proposal detection
+ *
+ * A proposal records the lines it replaces when it is created (Proposal.base).
+ * Before it is applied, validateProposal compares that snapshot with the
+ * file as it is now and throws a StaleProposalError subclass if it no longer
+ * applies cleanly:
+ *
+ * - ProposalBaseChangedError: the replaced lines were edited or removed
+ * - ProposalRegionMovedError: the lines are unchanged but now sit elsewhere
+ * - ProposalTrustChangedError: the lines are now governed more strictly, or
+ *   need more approvals than the proposal collected
+ *
+ * Proposals created before base snapshots were recorded are only checked for
+ * old_code still being present.
+ */
+
+import {
+  PackageDefault,
+  ParseOptions,
+  Proposal,
+  ProposalBase,
+  TRUST_LEVELS,
+  TrustConfig,
+  TrustLevel,
+  hashLines,
+  isGeneratedSource,
+  isGoTestSource,
+  locateCode,
+  parseAnnotationContent,
+  resolveTrustWithAnnotations,
+} from "./collab.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Errors
+// ============================================
+
+export type StaleProposalCode = "base-changed" | "region-moved" | "trust-changed";
+
+type LineRange = { line_start: number; line_end: number };
+
+export class StaleProposalError extends Error {
+  readonly code: StaleProposalCode;
+  readonly proposal_id: string;
+
+  constructor(code: StaleProposalCode, proposalId: string, message: string) {
+    super(`Proposal ${proposalId}: ${message}`);
+    this.name = new.target.name;
+    this.code = code;
+    this.proposal_id = proposalId;
+  }
+}
+
+export class ProposalBaseChangedError extends StaleProposalError {
+  constructor(proposalId: string) {
+    super("base-changed", proposalId, "the code it replaces has changed since it was proposed");
+  }
+}
+
+export class ProposalRegionMovedError extends StaleProposalError {
+  readonly from: LineRange;
+  readonly to: LineRange; // Where the unchanged lines are now
+
+  constructor(proposalId: string, from: LineRange, to: LineRange) {
+    super(
+      "region-moved",
+      proposalId,
+      `the code it replaces moved from lines ${from.line_start}-${from.line_end} to ${to.line_start}-${to.line_end}`
+    );
+    this.from = { line_start: from.line_start, line_end: from.line_end };
+    this.to = to;
+  }
+}
+
+export class ProposalTrustChangedError extends StaleProposalError {
+  readonly before: TrustLevel;
+  readonly after: TrustLevel;
+
+  constructor(proposalId: string, before: TrustLevel, after: TrustLevel, detail: string) {
+    super("trust-changed", proposalId, detail);
+    this.before = before;
+    this.after = after;
+  }
+}
+
+// ============================================
+// Validation
+// ============================================
+
+// Same-length window of lines whose hash matches, nearest the original position first
+function findMovedRegion(content: string, base: ProposalBase): LineRange | null {
+  const length = base.line_end - base.line_start + 1;
+  const lineCount = content.replace(/\r\n/g, "\n").split("\n").length;
+  const starts = Array.from({ length: Math.max(0, lineCount - length + 1) }, (_, i) => i + 1)
+    .sort((a, b) => Math.abs(a - base.line_start) - Math.abs(b - base.line_start));
+
+  for (const start of starts) {
+    if (hashLines(content, start, start + length - 1) === base.sha256) {
+      return { line_start: start, line_end: start + length - 1 };
+    }
+  }
+  return null;
+}
+
+/**
+ * Check that a proposal still applies to `currentSource`, the file's content
+ * now. Throws a StaleProposalError subclass if not; returns normally if the
+ * proposal can be applied. `options.aliases` should match the project's
+ * config.yaml so annotations resolve as they do elsewhere, and
+ * `options.packageDefault` is the file's package default, if any.
+ */
+export function validateProposal(
+  proposal: Proposal,
+  currentSource: string,
+  config: TrustConfig,
+  options: ParseOptions & { packageDefault?: PackageDefault } = {}
+): void {
+  const { base } = proposal;
+  if (!base) {
+    if (!proposal.redacted && !locateCode(currentSource, proposal.old_code)) {
+      throw new ProposalBaseChangedError(proposal.id);
+    }
+    return;
+  }
+
+  if (hashLines(currentSource, base.line_start, base.line_end) !== base.sha256) {
+    const moved = findMovedRegion(currentSource, base);
+    if (!moved) throw new ProposalBaseChangedError(proposal.id);
+    throw new ProposalRegionMovedError(proposal.id, base, moved);
+  }
+
+  const { annotations } = parseAnnotationContent(currentSource, proposal.file_path, options);
+  const declarations = config.symbols?.length ? findDeclarations(currentSource, proposal.file_path) : [];
+  const trust = resolveTrustWithAnnotations(
+    config,
+    proposal.file_path,
+    annotations,
+    base.line_start,
+    base.line_end,
+    declarations,
+    isGeneratedSource(currentSource),
+    options.packageDefault,
+    isGoTestSource(proposal.file_path, currentSource)
+  );
+
+  if (TRUST_LEVELS.indexOf(trust.level) > TRUST_LEVELS.indexOf(base.trust)) {
+    throw new ProposalTrustChangedError(
+      proposal.id,
+      base.trust,
+      trust.level,
+      `lines ${base.line_start}-${base.line_end} are now ${trust.level} (were ${base.trust})`
+    );
+  }
+  const required = trust.min_approvals ?? 1;
+  const collected = proposal.min_approvals ?? 1;
+  if (required > collected) {
+    throw new ProposalTrustChangedError(
+      proposal.id,
+      base.trust,
+      trust.level,
+      `lines ${base.line_start}-${base.line_end} now need ${required} approvals (the proposal required ${collected})`
+    );
+  }
+}
@@ -0,0 +1,268 @@
+/**
+ * Proposal lifecycle and the proposals command for collab-claude-code
+ *
+ * A proposal moves pending -> approved -> applied, or to rejected from
+ * pending or approved (see PROPOSAL_TRANSITIONS). Every step is appended to
+ * its history in .collab/proposals/<id>.yaml, so the files are a plain,
+ * reviewable record of the workflow and nothing needs a server:
+ *
+ *   collab-claude-code proposals list [--status=pending|approved|rejected|applied|all]
+ *   collab-claude-code proposals show <id>
+ *   collab-claude-code proposals status <id> [approved|rejected|applied] [--by=<name>] [--reason=<text>] [--ack-design-doc]
+ *
+ * Approving from the command line goes through approveProposal, exactly as
+ * collab_apply_proposal does: approvals, stale checks, and pre-apply hooks.
+ * A proposal for a region with a design_doc is only approved with
+ * --ack-design-doc, confirming the approver has read it.
+ *
+ * Exit codes:
+ *   0 = Done
+ *   1 = The step was not taken (awaiting approvals, stale, refused, or not allowed)
+ *   2 = Tool error (bad arguments, unknown proposal)
+ */
+
+import * as fs from "fs/promises";
+
+import {
+  ApprovalStatus,
+  PROPOSAL_TRANSITIONS,
+  Proposal,
+  ProposalStatus,
+  ProposalTransitionError,
+  addApproval,
+  countApprovals,
+  formatOwner,
+  formatRange,
+  loadPackageDefault,
+  loadProposal,
+  loadProposals,
+  loadTrustAliases,
+  loadTrustConfig,
+  rangeOf,
+  saveProposal,
+  stableStringify,
+  transitionProposal,
+} from "./collab.js";
+import { EXIT_CLEAN, EXIT_TOOL_ERROR, EXIT_VIOLATIONS } from "./check.js";
+import { PreApplyFailure, formatPreApplyFailures, runPreApplyHooks } from "./apply-hooks.js";
+import { StaleProposalError, validateProposal } from "./proposal-validation.js";
+
+// ============================================
+// Types
+// ============================================
+
+export type ApprovalOutcome =
+  | { status: "design_doc_unacknowledged"; design_doc: string }
+  | { status: "awaiting_approvals"; approval: ApprovalStatus }
+  | { status: "stale"; error: StaleProposalError }
+  | { status: "refused"; failures: PreApplyFailure[] }
+  | { status: "approved" };
+
+const STATUSES = Object.keys(PROPOSAL_TRANSITIONS) as ProposalStatus[];
+
+// ============================================
+// Lifecycle
+// ============================================
+
+/**
+ * Record an approval and, once min_approvals is met and the proposal is
+ * neither stale nor refused by a pre-apply hook, move it to approved. For a
+ * region with a design_doc, nothing is recorded unless the approver
+ * acknowledges the doc. The proposal is saved whatever the outcome. Throws
+ * ProposalTransitionError unless the proposal is pending.
+ */
+export async function approveProposal(
+  proposal: Proposal,
+  approver: string,
+  options: { acknowledgeDesignDoc?: boolean } = {}
+): Promise<ApprovalOutcome> {
+  if (proposal.status !== "pending") {
+    throw new ProposalTransitionError(`Cannot approve proposal ${proposal.id}: it is ${proposal.status}`);
+  }
+
+  // Architecturally sensitive regions are only changed with their design in mind
+  if (proposal.design_doc && !options.acknowledgeDesignDoc) {
+    return { status: "design_doc_unacknowledged", design_doc: proposal.design_doc };
+  }
+
+  // Refuse until enough distinct non-author approvers have signed off
+  const approval = addApproval(proposal, approver);
+  if (!approval.satisfied) {
+    await saveProposal(proposal);
+    return { status: "awaiting_approvals", approval };
+  }
+
+  // Edits that landed since the proposal was made can make it unsafe to apply
+  try {
+    const current = await fs.readFile(proposal.file_path, "utf-8").catch(() => "");
+    const aliases = await loadTrustAliases();
+    validateProposal(proposal, current, await loadTrustConfig(), {
+      aliases,
+      packageDefault: await loadPackageDefault(proposal.file_path, { aliases }),
+    });
+  } catch (error) {
+    if (!(error instanceof StaleProposalError)) throw error;
+    await saveProposal(proposal);
+    return { status: "stale", error };
+  }
+
+  // Org-specific gates (tests, CI status, ...) can still refuse it
+  const failures = await runPreApplyHooks(proposal);
+  if (failures.length > 0) {
+    await saveProposal(proposal);
+    return { status: "refused", failures };
+  }
+
+  transitionProposal(proposal, "approved", { by: approver });
+  await saveProposal(proposal);
+  return { status: "approved" };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+function formatListText(proposals: Proposal[]): string {
+  if (proposals.length === 0) return "No proposals";
+  const rows = proposals.map(p => [p.id, p.status, `${countApprovals(p)}/${p.min_approvals ?? 1}`, p.file_path, p.description]);
+  const widths = [0, 1, 2, 3].map(i => Math.max(...rows.map(r => r[i].length)));
+  return rows.map(r => r.map((c, i) => (i < 4 ? c.padEnd(widths[i]) : c)).join("  ")).join("\n");
+}
+
+function formatHistoryText(proposal: Proposal): string[] {
+  return (proposal.history ?? []).map(
+    h => `  ${h.at}  ${h.status}${h.by ? `  by ${h.by}` : ""}${h.reason ? `  (${h.reason})` : ""}`
+  );
+}
+
+export function formatProposalText(proposal: Proposal): string {
+  const p = proposal;
+  const owner = formatOwner(p.owners);
+  const lines = [
+    `Proposal ${p.id}: ${p.status}`,
+    `  file: ${p.base ? formatRange(rangeOf(p.base), p.file_path) : p.file_path}`,
+    `  description: ${p.description}`,
+    `  author: ${p.author}`,
+    `  approvals: ${countApprovals(p)}/${p.min_approvals ?? 1}`,
+    ...(p.design_doc ? [`  design doc: ${p.design_doc} (approvers must acknowledge it)`] : []),
+    ...(owner ? [`  owners: ${owner}`] : []),
+    ...(p.batch ? [`  batch: ${p.batch}`] : []),
+    "",
+    "History:",
+    ...formatHistoryText(p),
+  ];
+  if (!p.redacted) {
+    lines.push("", "--- old", p.old_code, "+++ new", p.new_code);
+  }
+  return lines.join("\n");
+}
+
+function describeOutcome(id: string, outcome: ApprovalOutcome): string {
+  switch (outcome.status) {
+    case "design_doc_unacknowledged":
+      return `Proposal ${id} changes a region with a design doc: read ${outcome.design_doc}, then approve with --ack-design-doc`;
+    case "awaiting_approvals":
+      return `Approval recorded for ${id}: ${outcome.approval.approvals}/${outcome.approval.required}`;
+    case "stale":
+      return `Proposal ${id} is stale: ${outcome.error.message}`;
+    case "refused":
+      return formatPreApplyFailures(outcome.failures);
+    default:
+      return `Proposal ${id} approved`;
+  }
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+const USAGE =
+  "Usage: collab-claude-code proposals list [--status=<status>|all] | show <id> | " +
+  "status <id> [approved|rejected|applied] [--by=<name>] [--reason=<text>] [--ack-design-doc] [--format=text|json]";
+
+export async function runProposals(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let statusFilter: string | undefined;
+  let by: string | undefined;
+  let reason: string | undefined;
+  let acknowledgeDesignDoc = false;
+  const positional: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--status=")) {
+      statusFilter = arg.slice("--status=".length);
+    } else if (arg.startsWith("--by=")) {
+      by = arg.slice("--by=".length);
+    } else if (arg.startsWith("--reason=")) {
+      reason = arg.slice("--reason=".length);
+    } else if (arg === "--ack-design-doc") {
+      acknowledgeDesignDoc = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      positional.push(arg);
+    }
+  }
+
+  const [subcommand, id, target] = positional;
+  const print = (value: unknown, text: string) => console.log(format === "json" ? stableStringify(value, 2) : text);
+
+  try {
+    if (subcommand === "list" && !id) {
+      const filter = statusFilter ?? "pending";
+      if (filter !== "all" && !STATUSES.includes(filter as ProposalStatus)) {
+        console.error(`Error: unknown status "${filter}" (expected ${STATUSES.join(", ")}, all)`);
+        return EXIT_TOOL_ERROR;
+      }
+      const proposals = (await loadProposals())
+        .filter(p => filter === "all" || p.status === filter)
+        .sort((a, b) => a.created_at.localeCompare(b.created_at) || a.id.localeCompare(b.id));
+      print(proposals, formatListText(proposals));
+      return EXIT_CLEAN;
+    }
+
+    if ((subcommand !== "show" && subcommand !== "status") || !id || (subcommand === "show" && target)) {
+      console.error(USAGE);
+      return EXIT_TOOL_ERROR;
+    }
+
+    const proposal = await loadProposal(id);
+    if (!proposal) {
+      console.error(`Error: Proposal ${id} not found`);
+      return EXIT_TOOL_ERROR;
+    }
+
+    if (subcommand === "show") {
+      print(proposal, formatProposalText(proposal));
+      return EXIT_CLEAN;
+    }
+
+    if (!target) {
+      const history = proposal.history ?? [];
+      print({ id, status: proposal.status, history }, [`${id}: ${proposal.status}`, ...formatHistoryText(proposal)].join("\n"));
+      return EXIT_CLEAN;
+    }
+
+    if (target === "approved") {
+      const outcome = await approveProposal(proposal, by ?? "human", { acknowledgeDesignDoc });
+      print({ id, status: outcome.status, proposal_status: proposal.status }, describeOutcome(id, outcome));
+      return outcome.status === "approved" ? EXIT_CLEAN : EXIT_VIOLATIONS;
+    }
+
+    if (!STATUSES.includes(target as ProposalStatus)) {
+      console.error(`Error: unknown status "${target}" (expected approved, rejected, applied)`);
+      return EXIT_TOOL_ERROR;
+    }
+    transitionProposal(proposal, target as ProposalStatus, { by, reason });
+    await saveProposal(proposal);
+    print({ id, status: proposal.status }, `Proposal ${id} ${proposal.status}`);
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return error instanceof ProposalTransitionError ? EXIT_VIOLATIONS : EXIT_TOOL_ERROR;
+  }
+}
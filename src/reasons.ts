@@ -0,0 +1,477 @@
+/**
+ * Reason code taxonomy
+ *
+ * Every violation reported by `check` carries one of these codes. Codes are
+ * stable across versions: they are never renamed or reused, only marked
+ * deprecated, so dashboards and translations can key on them.
+ *
+ * Kept dependency-free so integrations can import it without the parser.
+ */
+
+// ============================================
+// Types
+// ============================================
+
+export type ReasonCodeId =
+  | "unknown-trust-level"
+  | "unknown-alias"
+  | "unparsed-annotation"
+  | "invalid-min-approvals"
+  | "invalid-lines"
+  | "lines-out-of-range"
+  | "lines-on-block"
+  | "block-end-mismatch"
+  | "invalid-block-end"
+  | "unclosed-block"
+  | "unmatched-block-end"
+  | "block-not-open"
+  | "duplicate-block-id"
+  | "conflicting-trust"
+  | "invalid-redact"
+  | "invalid-requires-tests"
+  | "invalid-inherit"
+  | "invalid-when"
+  | "invalid-design-doc"
+  | "misplaced-package-directive"
+  | "scope-fallback"
+  | "autonomous-with-constraints"
+  | "read-only-small-helper"
+  | "effectful-without-constraints"
+  | "region-too-large"
+  | "too-many-annotations"
+  | "unknown-owner"
+  | "read-only-edit"
+  | "vendored-read-only-edit"
+  | "generated-file-edit"
+  | "ambiguous-policy"
+  | "shadowed-test-trust";
+
+export interface ReasonCode {
+  code: ReasonCodeId;
+  category: "annotation" | "source" | "authorship" | "policy"; // "source": the code around an annotation, not the annotation
+  severity: "error" | "warning"; // Warnings are reported but do not fail `check`
+  title: string;
+  description: string;
+  message: string; // English message template; {name} is filled from the violation's params
+  since: string; // Package version that introduced the code
+  deprecated?: boolean;
+}
+
+export interface TrustLevelSemantics {
+  level: "AUTONOMOUS" | "SUPERVISED" | "SUGGEST_ONLY" | "READ_ONLY";
+  title: string;
+  description: string;
+}
+
+// ============================================
+// Taxonomy
+// ============================================
+
+const REASON_CODES: ReasonCode[] = [
+  {
+    code: "unknown-trust-level",
+    category: "annotation",
+    severity: "error",
+    title: "Unknown trust level",
+    description: "A trust= value is not one of AUTONOMOUS, SUPERVISED, SUGGEST_ONLY, or READ_ONLY. " +
+      "The annotation grants no protection until it is fixed.",
+    message: 'Unknown trust level "{value}" (expected {expected})',
+    since: "1.0.0",
+  },
+  {
+    code: "unknown-alias",
+    category: "annotation",
+    severity: "error",
+    title: "Unknown alias",
+    description: "A bare word in an @collab annotation is neither a trust level nor a known trust alias. " +
+      "Aliases come from the built-in set and config.yaml `aliases`.",
+    message: 'Unknown @collab alias "{word}" (known: {known})',
+    since: "1.0.0",
+  },
+  {
+    code: "unparsed-annotation",
+    category: "annotation",
+    severity: "error",
+    title: "Unparsed annotation",
+    description: "An @collab annotation is several bare words with no attributes, and the first is not a " +
+      "trust level or alias, so none of it can be read. A level or alias followed by other words keeps " +
+      "them as a note.",
+    message: '@collab "{text}" has no attributes and does not start with a trust level or alias',
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-min-approvals",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid min_approvals",
+    description: "min_approvals= must be a positive integer.",
+    message: 'Invalid min_approvals="{value}": expected a positive integer',
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-lines",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid lines range",
+    description: 'lines= must be "N" or "N-M". The whole annotated scope stays governed.',
+    message: 'Invalid lines="{value}": expected "N" or "N-M"',
+    since: "1.0.0",
+  },
+  {
+    code: "lines-out-of-range",
+    category: "annotation",
+    severity: "error",
+    title: "lines range outside scope",
+    description: "lines= refers to lines beyond the annotated function. The whole scope stays governed.",
+    message: 'lines="{value}" is out of range: annotated scope has {scope_lines} line(s)',
+    since: "1.0.0",
+  },
+  {
+    code: "lines-on-block",
+    category: "annotation",
+    severity: "error",
+    title: "lines on a block annotation",
+    description: "lines= is only supported on function annotations; @collab:begin blocks already name their range.",
+    message: "lines= is only supported on function annotations, not @collab:begin blocks",
+    since: "1.0.0",
+  },
+  {
+    code: "block-end-mismatch",
+    category: "annotation",
+    severity: "error",
+    title: "Block end does not match begin",
+    description: "An attribute echoed on a @collab:end line differs from its @collab:begin line, which " +
+      "usually means a block was copied and only one end was edited. The begin line is what applies.",
+    message: "@collab:end {key}={end_value} does not match @collab:begin {key}={begin_value} on line {begin_line}",
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-block-end",
+    category: "annotation",
+    severity: "error",
+    title: "Attributes on a block end",
+    description: "A @collab:end line sets an attribute its @collab:begin does not, or one that does not exist. " +
+      "The end line takes no attributes, or only ones echoing the begin line; it still closes the block, " +
+      "and the begin line is what applies.",
+    message: "@collab:end {detail}; it takes no attributes, or only ones matching @collab:begin on line {begin_line}",
+    since: "1.0.0",
+  },
+  {
+    code: "unclosed-block",
+    category: "annotation",
+    severity: "error",
+    title: "Unclosed block",
+    description: "A @collab:begin has no @collab:end after it, so the block governs no lines.",
+    message: "@collab:begin has no matching @collab:end",
+    since: "1.0.0",
+  },
+  {
+    code: "unmatched-block-end",
+    category: "annotation",
+    severity: "error",
+    title: "Block end without begin",
+    description: "A @collab:end comment does not close any @collab:begin, which usually means the begin " +
+      "line was deleted or the end line was copied.",
+    message: "@collab:end does not close any @collab:begin",
+    since: "1.0.0",
+  },
+  {
+    code: "block-not-open",
+    category: "annotation",
+    severity: "error",
+    title: "Named block end without an open block",
+    description: "A @collab:end names an id no open @collab:begin has: the block was already closed, the " +
+      "id is misspelled, or the begin line was deleted. The end closes nothing.",
+    message: '@collab:end id="{id}" does not close an open @collab:begin',
+    since: "1.0.0",
+  },
+  {
+    code: "duplicate-block-id",
+    category: "annotation",
+    severity: "error",
+    title: "Block id already open",
+    description: "A @collab:begin reuses the id of a block that is still open, so a named @collab:end " +
+      "could close either; it closes the innermost. Give nested blocks distinct ids.",
+    message: 'Block id "{id}" is already used by the @collab:begin on line {begin_line}, which is still open',
+    since: "1.0.0",
+  },
+  {
+    code: "conflicting-trust",
+    category: "annotation",
+    severity: "error",
+    title: "Conflicting trust levels",
+    description: "One annotation sets two different trust levels, through trust=, a bare level, or an alias, " +
+      "or across the lines of a multi-line annotation. On one line trust= wins over a bare level and a " +
+      "level over an alias; otherwise the last one applies.",
+    message: "Annotation sets trust to both {first} and {second}; {second} applies",
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-redact",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid redact value",
+    description: 'redact= must be "true" or "false". The region\'s content is not redacted from proposals.',
+    message: 'Invalid redact="{value}": expected "true" or "false"',
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-requires-tests",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid requires_tests value",
+    description: 'requires_tests= must be "true" or "false". Changes to the region are not checked for test changes.',
+    message: 'Invalid requires_tests="{value}": expected "true" or "false"',
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-inherit",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid inherit value",
+    description: 'inherit= must be "true" or "false". The annotation inherits from enclosing blocks as if it were unset.',
+    message: 'Invalid inherit="{value}": expected "true" or "false"',
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-when",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid when condition",
+    description: 'when= must be "path:<glob>". The annotation\'s clause never applies, so the region falls through to whatever governs it otherwise.',
+    message: 'Invalid when="{value}": expected "path:<glob>"',
+    since: "1.0.0",
+  },
+  {
+    code: "invalid-design-doc",
+    category: "annotation",
+    severity: "error",
+    title: "Invalid design doc link",
+    description: "design_doc= must be an http or https URL. Proposals still ask approvers to acknowledge it as written.",
+    message: 'Invalid design_doc="{value}": expected an http(s) URL',
+    since: "1.0.0",
+  },
+  {
+    code: "misplaced-package-directive",
+    category: "annotation",
+    severity: "error",
+    title: "Package directive outside doc.go",
+    description: "@collab:package sets a Go package's default trust, and is only read from the package's doc.go. " +
+      "Anywhere else it grants no protection.",
+    message: "@collab:package is only read from doc.go; it has no effect in {file}",
+    since: "1.0.0",
+  },
+  {
+    code: "scope-fallback",
+    category: "source",
+    severity: "error",
+    title: "Scope detection fallback",
+    description: "The source after the annotation could not be tokenized, so its scope was estimated " +
+      "by brace counting and may be wrong.",
+    message: "{detail}",
+    since: "1.0.0",
+  },
+  {
+    code: "autonomous-with-constraints",
+    category: "annotation",
+    severity: "warning",
+    title: "AUTONOMOUS with constraints",
+    description: "An AUTONOMOUS annotation also lists constraints or min_approvals. Free edits are never " +
+      "reviewed, so these are not enforced; the author likely meant SUGGEST_ONLY.",
+    message: "AUTONOMOUS region also sets {attributes}, which free edits never enforce; " +
+      "did you mean SUGGEST_ONLY?",
+    since: "1.0.0",
+  },
+  {
+    code: "annotation-after-declaration",
+    category: "annotation",
+    severity: "warning",
+    title: "Annotation inside a function body",
+    description: "An @collab comment is the first line inside a function's body, so it governs the " +
+      "statements below it rather than the function. Annotations go above the declaration they protect.",
+    message: "@collab on the first line of a function body governs the code below it, not the function " +
+      "opened on line {opening_line}; move it above the declaration",
+    since: "1.0.0",
+  },
+  {
+    code: "read-only-small-helper",
+    category: "annotation",
+    severity: "warning",
+    title: "READ_ONLY on a small unexported helper",
+    description: "A READ_ONLY annotation covers an unexported Go function no longer than " +
+      "lint.read_only_helper_max_lines in config.yaml. Locking small internal helpers is usually " +
+      "governance noise; consider SUGGEST_ONLY or removing the annotation. Only reported when the setting is present.",
+    message: "READ_ONLY on unexported {name} ({lines} line(s)) may be unnecessary; " +
+      "small internal helpers rarely need a lock",
+    since: "1.0.0",
+  },
+  {
+    code: "effectful-without-constraints",
+    category: "annotation",
+    severity: "error",
+    title: "Effectful region without constraints",
+    description: "A SUPERVISED or SUGGEST_ONLY region calls a function listed in lint.effectful_calls in " +
+      "config.yaml (payments, email, deletes) but neither it nor an enclosing annotation declares a constraint. " +
+      "Say what any edit must preserve. Only reported when the setting is present.",
+    message: "{level} region {lines} calls {call} (effectful: \"{pattern}\") on line {line} but declares no constraints; " +
+      "add constraints=[...] saying what edits must preserve",
+    since: "1.0.0",
+  },
+  {
+    code: "region-too-large",
+    category: "annotation",
+    severity: "warning",
+    title: "Annotated region over the size limit",
+    description: "An annotation governs more lines than lint.max_region_lines in config.yaml (default 500). " +
+      "Very large regions are usually a block whose @collab:end is misplaced or missing; govern narrower regions.",
+    message: "Annotation governs {count} lines ({lines}), over the limit of {limit}; " +
+      "govern narrower regions or check where the block ends",
+    since: "1.0.0",
+  },
+  {
+    code: "too-many-annotations",
+    category: "annotation",
+    severity: "warning",
+    title: "Too many annotations in a file",
+    description: "A file has more annotations than lint.max_annotations_per_file in config.yaml (default 100). " +
+      "A trust.yaml policy or fewer, wider regions are easier to review.",
+    message: "File has {count} annotations, over the limit of {limit}; consider a trust.yaml policy for it",
+    since: "1.0.0",
+  },
+  {
+    code: "unknown-owner",
+    category: "annotation",
+    severity: "error",
+    title: "Owner not in the registry",
+    description: "config.yaml defines owners, and an annotation names an owner key it does not define. " +
+      "Notifications for the region would have no one to reach; add the key to owners or fix the annotation.",
+    message: "Unknown owner \"{owner}\"; annotations must name an owner key defined under owners in config.yaml",
+    since: "1.0.0",
+  },
+  {
+    code: "read-only-edit",
+    category: "authorship",
+    severity: "error",
+    title: "Edit inside READ_ONLY code",
+    description: "An authorship record shows an LLM edit overlapping a region that resolves to READ_ONLY.",
+    message: "{author} edited lines {lines} of a READ_ONLY region ({reason})",
+    since: "1.0.0",
+  },
+  {
+    code: "vendored-read-only-edit",
+    category: "authorship",
+    severity: "error",
+    title: "Edit inside vendored READ_ONLY code",
+    description: "An authorship record shows an LLM edit overlapping a READ_ONLY region of vendored code " +
+      "(config.yaml `vendor`, default vendor/ directories). The region belongs to the upstream library; " +
+      "local edits drift from it and are lost on the next vendor update.",
+    message: "{author} edited lines {lines} of a vendored READ_ONLY region ({reason}); change it upstream and vendor it again",
+    since: "1.0.0",
+  },
+  {
+    code: "generated-file-edit",
+    category: "authorship",
+    severity: "error",
+    title: "Edit to generated code",
+    description: "An authorship record shows an LLM edit to a file with a `Code generated ... DO NOT EDIT.` " +
+      "header, which is READ_ONLY (or trust.yaml `generated_trust`) whatever its annotations say. " +
+      "Change the generator or its input and regenerate instead.",
+    message: "{author} edited lines {lines} of a generated file; " +
+      "change the generator or its input and regenerate instead",
+    since: "1.0.0",
+  },
+  {
+    code: "ambiguous-policy",
+    category: "policy",
+    severity: "error",
+    title: "Ambiguous trust.yaml policies",
+    description: "Two trust.yaml policies that match the file are equally specific but set different trust " +
+      "levels. The more restrictive one applies; make one pattern more specific or remove the other.",
+    message: "Policies {patterns} match this file equally specifically with different trust; {applied} applies",
+    since: "1.0.0",
+  },
+  {
+    code: "shadowed-test-trust",
+    category: "policy",
+    severity: "warning",
+    title: "test_trust shadowed by a path policy",
+    description: "trust.yaml sets test_trust, but a Go test file matches a path policy, which outranks it. " +
+      "Broad policies such as `src/**` hide test_trust from every test under them. READ_ONLY policies " +
+      "and patterns naming `_test` files are taken as deliberate and not reported.",
+    message: "test_trust ({test_trust}) does not apply: policy {pattern} ({trust}) outranks it; " +
+      "add a policy for this directory's *_test.go files to set their trust",
+    since: "1.0.0",
+  },
+];
+
+const TRUST_LEVEL_SEMANTICS: TrustLevelSemantics[] = [
+  {
+    level: "AUTONOMOUS",
+    title: "Autonomous",
+    description: "The LLM may edit freely.",
+  },
+  {
+    level: "SUPERVISED",
+    title: "Supervised",
+    description: "Edits are allowed and noted; significant changes should be proposed. This is the default.",
+  },
+  {
+    level: "SUGGEST_ONLY",
+    title: "Suggest only",
+    description: "Changes must go through collab_propose_change and be approved by a human.",
+  },
+  {
+    level: "READ_ONLY",
+    title: "Read only",
+    description: "Edits are blocked. Only humans change this code, and profiles cannot relax it.",
+  },
+];
+
+export function reasonCodes(): ReasonCode[] {
+  return REASON_CODES.map(code => ({ ...code }));
+}
+
+export function trustLevelSemantics(): TrustLevelSemantics[] {
+  return TRUST_LEVEL_SEMANTICS.map(level => ({ ...level }));
+}
+
+// ============================================
+// Reference Page
+// ============================================
+
+export function formatReasonReference(): string {
+  const lines = [
+    "# collab-claude-code Reference",
+    "",
+    "## Trust Levels",
+    "",
+    "| Level | Meaning |",
+    "|-------|---------|",
+    ...TRUST_LEVEL_SEMANTICS.map(t => `| \`${t.level}\` | ${t.description} |`),
+    "",
+    "## Violation Codes",
+    "",
+    "| Code | Category | Severity | Title | Description |",
+    "|------|----------|----------|-------|-------------|",
+    ...REASON_CODES.map(r =>
+      `| \`${r.code}\` | ${r.category} | ${r.severity} | ${r.title}${r.deprecated ? " (deprecated)" : ""} | ${r.description} |`
+    ),
+  ];
+  return lines.join("\n");
+}
+
+// Exit codes match the check command (0 = ok, 2 = tool error)
+export function runReasons(args: string[]): number {
+  const format = args.find(a => a.startsWith("--format="))?.slice("--format=".length) ?? "markdown";
+  if (format !== "markdown" && format !== "json") {
+    console.error(`Unknown format: ${format} (expected markdown, json)`);
+    return 2;
+  }
+
+  console.log(
+    format === "json"
+      ? JSON.stringify({ reason_codes: REASON_CODES, trust_levels: TRUST_LEVEL_SEMANTICS }, null, 2)
+      : formatReasonReference()
+  );
+  return 0;
+}
@@ -0,0 +1,401 @@
+/**
+ * routes command for collab-claude-code
+ *
+ * Maps each HTTP route to the governance of its handler, so security can see
+ * at a glance which endpoints are locked and who owns them:
+ *
+ *   collab-claude-code routes [--map=<file>] [--format=json|openapi] [paths...]
+ *
+ * Routes come from route extractors run over the source files, and from a
+ * mapping file given with --map for routers no extractor understands. Each
+ * handler is looked up by name among the declarations in the same files and
+ * resolved exactly as collab_check_trust would resolve its lines. A handler
+ * that is not a named declaration (a func literal, say) is governed by the
+ * line that registers it.
+ *
+ * A RouteExtractor returns the routes registered in one file. The built-in
+ * one reads Go net/http registrations: `http.HandleFunc`, `mux.Handle`, and
+ * Go 1.22 method patterns such as "GET /users/{id}". Others come from
+ * registerRouteExtractor and from modules listed under `route_extractors` in
+ * config.yaml, like constraint verifiers.
+ *
+ * `--format=openapi` prints an OpenAPI `paths` object with the governance as
+ * `x-collab-*` extensions and a `collab:<LEVEL>` tag on each operation, for
+ * merging into an existing spec.
+ *
+ * Exit codes:
+ *   0 = Report printed
+ *   2 = Tool error (bad arguments, unreadable files or mapping, an extractor fails to load)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import { pathToFileURL } from "url";
+import * as yaml from "yaml";
+
+import {
+  PackageDefault,
+  ParsedAnnotation,
+  TrustLevel,
+  TrustResult,
+  comparePaths,
+  isGeneratedSource,
+  isGoTestSource,
+  loadCollabConfig,
+  loadPackageDefault,
+  loadTrustAliases,
+  parseAnnotationContent,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+import { Declaration, findDeclarations, matchesDeclaration, normalizeDeclarationName } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface Route {
+  method: string; // Upper-case, or "*" when the registration accepts any method
+  path: string;
+  handler: string; // As written: "s.getUser", "UserService.Get", "func literal"
+  file?: string; // Where the route is registered, when extracted from source
+  line?: number;
+}
+
+export type RouteExtractor = (content: string, filePath: string) => Route[] | Promise<Route[]>;
+
+export interface GovernedRoute extends Route {
+  extractor: string; // Extractor name, or "map" for --map entries
+  declaration?: Pick<Declaration, "file" | "qualified_name" | "line_start" | "line_end">;
+  trust?: TrustLevel; // Unset when neither the handler nor a registration line was found
+  owner?: string | string[];
+  source?: TrustResult["source"];
+  reason?: string;
+}
+
+export interface RouteReport {
+  profile?: string;
+  routes: GovernedRoute[]; // By path, then method
+}
+
+// ============================================
+// Extractors
+// ============================================
+
+const registeredExtractors: { name: string; extract: RouteExtractor }[] = [];
+
+export function registerRouteExtractor(extract: RouteExtractor, name: string = extract.name || "anonymous"): void {
+  registeredExtractors.push({ name, extract });
+}
+
+export function clearRouteExtractors(): void {
+  registeredExtractors.length = 0;
+}
+
+// mux.HandleFunc("GET /users/{id}", ...), http.Handle("/static/", ...)
+const NET_HTTP_REGISTRATION_REGEX = /\b[\p{L}_][\p{L}\p{N}_]*\.(?:HandleFunc|Handle)\(\s*"([^"]*)"\s*,\s*/u;
+const METHOD_PATTERN_REGEX = /^([A-Z]+)\s+(\S.*)$/;
+const FUNC_LITERAL = "func literal";
+
+// Call arguments up to the parenthesis that closes the call: `a, f(b, c)) + x` -> ["a", "f(b, c)"]
+function splitArguments(source: string): string[] {
+  const args: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i < source.length; i++) {
+    const c = source[i];
+    if (c === "(" || c === "{" || c === "[") {
+      depth++;
+    } else if ((c === ")" || c === "}" || c === "]") && depth-- === 0) {
+      args.push(source.slice(start, i));
+      return args.map(a => a.trim()).filter(Boolean);
+    } else if (c === "," && depth === 0) {
+      args.push(source.slice(start, i));
+      start = i + 1;
+    }
+  }
+  args.push(source.slice(start));
+  return args.map(a => a.trim()).filter(Boolean);
+}
+
+/**
+ * The function behind a handler expression: wrappers such as
+ * `http.HandlerFunc(h)` or `requireAuth(h)` give their last argument, a call
+ * with no arguments such as `s.handleUsers()` gives the function building the
+ * handler, and `&UserHandler{...}` gives its ServeHTTP method.
+ */
+export function handlerName(expression: string): string {
+  let expr = expression.trim();
+  for (;;) {
+    if (/^func\s*\(/.test(expr)) return FUNC_LITERAL;
+    const literal = /^&?([\p{L}_][\p{L}\p{N}_.]*)\s*\{/u.exec(expr);
+    if (literal) return `${literal[1].split(".").pop()}.ServeHTTP`;
+    const call = /^([\p{L}_][\p{L}\p{N}_.]*)\s*\((.*)\)$/su.exec(expr);
+    if (!call) return expr;
+    const args = splitArguments(call[2]);
+    if (args.length === 0) return call[1];
+    expr = args[args.length - 1];
+  }
+}
+
+export const netHttpExtractor: RouteExtractor = (content, filePath) => {
+  if (!filePath.endsWith(".go")) return [];
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  const routes: Route[] = [];
+  lines.forEach((line, i) => {
+    if (/^\s*\/\//.test(line)) return;
+    const match = NET_HTTP_REGISTRATION_REGEX.exec(line);
+    if (!match) return;
+    const methodPattern = METHOD_PATTERN_REGEX.exec(match[1]);
+    const rest = line.slice(match.index + match[0].length) + "\n" + lines.slice(i + 1, i + 5).join("\n");
+    routes.push({
+      method: methodPattern ? methodPattern[1] : "*",
+      path: methodPattern ? methodPattern[2] : match[1],
+      handler: handlerName(splitArguments(rest)[0] ?? ""),
+      file: filePath,
+      line: i + 1,
+    });
+  });
+  return routes;
+};
+
+// A config module must export the extractor as its default export or as `extract`
+async function loadExtractorModule(modulePath: string): Promise<RouteExtractor> {
+  let loaded: Record<string, unknown>;
+  try {
+    loaded = await import(pathToFileURL(path.resolve(modulePath)).href);
+  } catch (error) {
+    throw new CheckToolError(
+      `Cannot load route extractor ${modulePath}: ${error instanceof Error ? error.message : String(error)}`
+    );
+  }
+  const extractor = loaded.default ?? loaded.extract;
+  if (typeof extractor !== "function") {
+    throw new CheckToolError(`${modulePath} does not export a route extractor (default or \`extract\`)`);
+  }
+  return extractor as RouteExtractor;
+}
+
+async function loadExtractors(): Promise<{ name: string; extract: RouteExtractor }[]> {
+  const modules = (await loadCollabConfig()).route_extractors ?? [];
+  return [
+    { name: "net/http", extract: netHttpExtractor },
+    ...registeredExtractors,
+    ...(await Promise.all(modules.map(async name => ({ name, extract: await loadExtractorModule(name) })))),
+  ];
+}
+
+// ============================================
+// Route Mapping
+// ============================================
+
+// --map entries: [{ method, path, handler }], in YAML or JSON
+export function parseRouteMap(content: string, source: string): Route[] {
+  let entries: unknown;
+  try {
+    entries = yaml.parse(content);
+  } catch (error) {
+    throw new CheckToolError(`Invalid route map ${source}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  if (!Array.isArray(entries)) throw new CheckToolError(`Invalid route map ${source}: expected a list of routes`);
+
+  return entries.map((entry, i) => {
+    const { method, path: routePath, handler } = (entry ?? {}) as Record<string, unknown>;
+    if (typeof routePath !== "string" || typeof handler !== "string") {
+      throw new CheckToolError(`Invalid route map ${source}: entry ${i + 1} needs a path and a handler`);
+    }
+    return { method: typeof method === "string" ? method.toUpperCase() : "*", path: routePath, handler };
+  });
+}
+
+// ============================================
+// Governance
+// ============================================
+
+// The declaration a handler names: in the registering file first, then its directory, then anywhere
+function findHandler(route: Route, declarations: Declaration[]): Declaration | undefined {
+  if (route.handler === FUNC_LITERAL) return undefined;
+  const query = normalizeDeclarationName(route.handler);
+  const qualified = declarations.filter(d => matchesDeclaration(d, query));
+  // "s.getUser" qualifies by a variable, not a type
+  const candidates = qualified.length > 0
+    ? qualified
+    : declarations.filter(d => matchesDeclaration(d, query.split(".").pop()!));
+
+  const tiers = route.file
+    ? [
+        candidates.filter(d => d.file === route.file),
+        candidates.filter(d => path.dirname(d.file) === path.dirname(route.file!)),
+        candidates,
+      ]
+    : [candidates];
+  // Ambiguous names resolve to nothing rather than to a guess
+  const tier = tiers.find(t => t.length > 0);
+  return tier?.length === 1 ? tier[0] : undefined;
+}
+
+// What resolveTrustWithAnnotations needs for one file
+interface FileGovernance {
+  annotations: ParsedAnnotation[];
+  declarations: Declaration[];
+  generated: boolean;
+  packageDefault?: PackageDefault;
+  testFile: boolean;
+}
+
+export async function buildRouteReport(
+  paths: string[],
+  options: CheckOptions & { map?: Route[] } = {}
+): Promise<RouteReport> {
+  const { signal } = options;
+  const config = await loadTrustConfigStrict(options.profile);
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+  const extractors = await loadExtractors();
+  const files = await expandPaths(paths, options);
+
+  const declarations: Declaration[] = [];
+  const governance = new Map<string, FileGovernance>();
+  const found: { route: Route; extractor: string }[] = [];
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+    const fileDeclarations = findDeclarations(content, file);
+    declarations.push(...fileDeclarations);
+    governance.set(file, {
+      annotations: parseAnnotationContent(content, file, { aliases }).annotations,
+      declarations: config.symbols?.length ? fileDeclarations : [],
+      generated: isGeneratedSource(content),
+      packageDefault: await loadPackageDefault(file, { aliases }),
+      testFile: isGoTestSource(file, content),
+    });
+    for (const { name, extract } of extractors) {
+      for (const route of await extract(content, file)) found.push({ route, extractor: name });
+    }
+  }
+
+  // Mapped routes replace extracted ones for the same method and path
+  const mapped = new Set((options.map ?? []).map(r => `${r.method} ${r.path}`));
+  const routes = [
+    ...found.filter(({ route }) => !mapped.has(`${route.method} ${route.path}`)),
+    ...(options.map ?? []).map(route => ({ route, extractor: "map" })),
+  ];
+
+  const report: RouteReport = { profile: config.active_profile, routes: [] };
+  for (const { route, extractor } of routes) {
+    const declaration = findHandler(route, declarations);
+    const target = declaration
+      ? { file: declaration.file, start: declaration.line_start, end: declaration.line_end }
+      : route.file && route.line
+        ? { file: route.file, start: route.line, end: route.line }
+        : undefined;
+    const g = target ? governance.get(target.file) : undefined;
+    const trust = target && g ? resolveTrustWithAnnotations(
+      config, target.file, g.annotations, target.start, target.end, g.declarations, g.generated, g.packageDefault, g.testFile
+    ) : undefined;
+    report.routes.push({
+      ...route,
+      extractor,
+      declaration: declaration && {
+        file: declaration.file,
+        qualified_name: declaration.qualified_name,
+        line_start: declaration.line_start,
+        line_end: declaration.line_end,
+      },
+      trust: trust?.level,
+      owner: trust?.owner,
+      source: trust?.source,
+      reason: trust?.reason,
+    });
+  }
+
+  report.routes.sort((a, b) => comparePaths(a.path, b.path) || comparePaths(a.method, b.method));
+  return report;
+}
+
+// ============================================
+// OpenAPI Output
+// ============================================
+
+/**
+ * An OpenAPI `paths` object for the routes. Go's `{name...}` wildcards become
+ * `{name}`, and routes registered for any method are listed under
+ * `x-collab-any-method`, since OpenAPI has no such operation.
+ */
+export function routesToOpenApi(report: RouteReport): { paths: Record<string, Record<string, unknown>> } {
+  const paths: Record<string, Record<string, unknown>> = {};
+  for (const route of report.routes) {
+    const routePath = route.path.replace(/\{([^}.]+)\.\.\.\}/g, "{$1}");
+    const operation = {
+      operationId: route.declaration?.qualified_name ?? (route.handler === FUNC_LITERAL ? undefined : route.handler),
+      tags: route.trust ? [`collab:${route.trust}`] : [],
+      "x-collab-trust": route.trust,
+      "x-collab-owner": route.owner,
+      "x-collab-source": route.source,
+    };
+    const method = route.method === "*" ? "x-collab-any-method" : route.method.toLowerCase();
+    paths[routePath] = { ...paths[routePath], [method]: operation };
+  }
+  return { paths };
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runRoutes(args: string[]): Promise<number> {
+  let format: "json" | "openapi" = "json";
+  let profile: string | undefined;
+  let noIgnore = false;
+  let mapFile: string | undefined;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=openapi") {
+      format = arg.slice("--format=".length) as "json" | "openapi";
+    } else if (arg.startsWith("--map=")) {
+      mapFile = arg.slice("--map=".length);
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    let map: Route[] | undefined;
+    if (mapFile) {
+      const content = await fs.readFile(mapFile, "utf-8").catch(() => {
+        throw new CheckToolError(`Cannot read ${mapFile}`);
+      });
+      map = parseRouteMap(content, mapFile);
+    }
+    const report = await buildRouteReport(paths, { profile, noIgnore, map });
+    console.log(stableStringify(format === "openapi" ? routesToOpenApi(report) : report, 2));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,797 @@
+/**
+ * Scan command for collab-claude-code
+ *
+ * Prints a per-file governance summary: every trust region with its line
+ * range, level, and owner, plus the level that applies everywhere else.
+ * Meant as a quick orientation for reviewers before reading the code.
+ *
+ * Colors are used only on a TTY and are disabled when NO_COLOR is set.
+ *
+ * Output is deterministic so it can be snapshotted and diffed: files are sorted
+ * by path, regions by start then end line, diagnostics by line, and JSON object
+ * keys are sorted at every level.
+ *
+ * With `--rev=<revision>`, files, trust.yaml, and config.yaml are read from
+ * that commit in the git object database instead of the working tree, so any
+ * past revision can be scanned without a checkout, including in a bare clone.
+ *
+ * The exception is `--format=ndjson`, which prints one record per line as each
+ * file is summarized, in argument order, so large scans can be stream-processed
+ * without holding every summary in memory. `--sort` buffers the records and
+ * prints them in the deterministic order instead.
+ *
+ * `--group-by=receiver` lists regions by the type their code belongs to
+ * instead of by file: a Go method's receiver (value and pointer receivers
+ * together), or the enclosing class, impl, or module elsewhere. Code outside
+ * any type, such as plain Go functions, goes in its package's `<package>`
+ * group. Groups span files, so a service split across files reads as one.
+ *
+ * `--files-from=<file>` (`-` for stdin) adds one path per line to the files
+ * named as arguments, e.g. `git diff --name-only | scan --files-from=-`.
+ * Listed paths that do not exist or cannot hold annotations are reported and
+ * left out; the rest are scanned.
+ *
+ * `--strict` prints one report of every annotation problem across the files
+ * instead of the summaries, and exits 1 if there are any: malformed
+ * attributes, unbalanced blocks, misplaced annotations, and conflicting
+ * trust, warnings included. Source the parser could not read around an
+ * annotation, such as Go that does not tokenize, is listed separately.
+ *
+ * `--label=<label>` (repeatable) keeps only regions annotated with one of the
+ * labels, and only files that have such a region.
+ *
+ * A Go file that does not tokenize is reported as unparseable along with the
+ * tokenizer's error, and the scan goes on. `--on-parse-error` picks what
+ * happens to it: `warn` (default) summarizes it anyway from line-based
+ * annotation detection, `skip` leaves it out, and `fail` summarizes it but
+ * exits 1 once the scan is done.
+ *
+ * When stderr is a terminal, a files-scanned counter is redrawn there while
+ * the scan runs, so a large scan does not look hung. Every scan ends with a
+ * one-line summary on stderr (files, annotations, annotation errors, elapsed
+ * time). `--quiet` turns both off; neither ever goes to stdout.
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import {
+  ANNOTATABLE_EXTENSIONS,
+  AnnotationError,
+  TrustLevel,
+  TrustResult,
+  COLLAB_DIR,
+  CONFIG_FILE,
+  CollabConfig,
+  Owner,
+  OwnerContact,
+  PackageDefault,
+  TrustConfig,
+  applyTrustProfile,
+  buildOwnerRegistry,
+  buildTrustAliases,
+  compareRegions,
+  comparePaths,
+  formatOwner,
+  formatRange,
+  isGoTestSource,
+  loadCollabConfig,
+  ownerList,
+  packageDocPath,
+  parseAnnotationContent,
+  parsePackageDirective,
+  rangeOf,
+  regionMatchesFile,
+  resolveOwner,
+  resolveTrustWithAnnotations,
+  sourceSyntaxError,
+  stableStringify,
+} from "./collab.js";
+import { CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, EXIT_VIOLATIONS, loadTrustConfigStrict } from "./check.js";
+import { findDeclarations } from "./declarations.js";
+import { reasonCodes } from "./reasons.js";
+import { fileExistsAtRevision, readFileAtRevision, resolveCommit } from "./git.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface RegionSummary {
+  line_start: number;
+  line_end: number;
+  trust?: TrustLevel; // Unset for annotations that only document owner/intent
+  effective?: TrustLevel; // Level after the active profile, when it differs
+  owner?: string | string[];
+  intent?: string;
+  labels?: string[];
+  contacts?: Owner[]; // Records for the owners the registry in config.yaml defines, when it has one
+  source: "annotation" | "region";
+}
+
+// A --files-from entry that was not scanned
+export interface SkippedFile {
+  file: string;
+  reason: "not found" | "unsupported file type" | "unparseable";
+  error?: string; // For "unparseable": what the tokenizer reported
+}
+
+// One line of --format=ndjson output
+export type ScanRecord =
+  | { kind: "file"; file: string; profile?: string; fallback: TrustResult; parse_error?: string }
+  | ({ kind: "skipped" } & SkippedFile)
+  | ({ kind: "region"; file: string } & RegionSummary)
+  | ({ kind: "error" | "warning" } & AnnotationError);
+
+export interface FileSummary {
+  file: string;
+  profile?: string;
+  fallback: TrustResult; // Applies to lines outside every region
+  parse_error?: string; // The file does not tokenize as its language; regions come from line-based detection
+  regions: RegionSummary[];
+  errors: AnnotationError[];
+  warnings: AnnotationError[];
+}
+
+export const PACKAGE_GROUP = "<package>";
+
+// A region listed under the type its code belongs to
+export interface GroupedRegion extends RegionSummary {
+  file: string;
+  declaration?: string; // Innermost declaration containing the region's first line
+}
+
+export interface ReceiverGroup {
+  package: string; // Go package name; the file's directory for other languages
+  receiver: string; // Type name, or PACKAGE_GROUP
+  regions: GroupedRegion[];
+}
+
+export interface StrictIssue extends AnnotationError {
+  severity: "error" | "warning";
+  category: "annotation" | "source"; // "source": the code around the annotation is malformed
+}
+
+export interface StrictReport {
+  files_scanned: number;
+  files_with_issues: number;
+  annotation_issues: number;
+  source_issues: number;
+  issues: StrictIssue[]; // By file, then line
+}
+
+// ============================================
+// Summaries
+// ============================================
+
+export type OnParseError = "skip" | "fail" | "warn";
+
+export interface SummarizeOptions {
+  signal?: AbortSignal;
+  profile?: string;
+  revision?: string; // Read from this commit rather than the working tree
+  context?: ScanContext; // Loaded once for a whole scan (default: loaded for this file)
+}
+
+// What every file of a scan shares: its configs, and the package defaults read so far
+export interface ScanContext {
+  config: TrustConfig;
+  aliases: Record<string, TrustLevel>;
+  owners?: Record<string, OwnerContact>;
+  packageDefaults: Map<string, Promise<PackageDefault | undefined>>; // By doc.go path
+}
+
+async function loadCollabConfigAtRevision(revision: string, signal?: AbortSignal): Promise<CollabConfig> {
+  const content = await readFileAtRevision(revision, path.join(COLLAB_DIR, CONFIG_FILE), signal);
+  return ((content && yaml.parse(content)) as CollabConfig | null) ?? {};
+}
+
+// Unknown owner keys are check's to report; a summary keeps the ones that resolve
+function regionContacts(owner: string | string[] | undefined, owners: Record<string, OwnerContact>): Owner[] | undefined {
+  const contacts = ownerList(owner).filter(key => Object.hasOwn(owners, key)).map(key => resolveOwner(key, owners));
+  return contacts.length > 0 ? contacts : undefined;
+}
+
+// trust.yaml and config.yaml, from `revision` when there is one
+export async function loadScanContext(options: Omit<SummarizeOptions, "context"> = {}): Promise<ScanContext> {
+  const config = await loadTrustConfigStrict(options.profile, options.revision);
+  const collabConfig = await (options.revision
+    ? loadCollabConfigAtRevision(options.revision, options.signal)
+    : loadCollabConfig());
+  try {
+    return {
+      config,
+      aliases: buildTrustAliases(collabConfig.aliases),
+      owners: collabConfig.owners === undefined ? undefined : buildOwnerRegistry(collabConfig.owners),
+      packageDefaults: new Map(),
+    };
+  } catch (error) {
+    throw new CheckToolError(`Invalid config.yaml: ${error instanceof Error ? error.message : String(error)}`);
+  }
+}
+
+async function readSource(filePath: string, options: SummarizeOptions): Promise<string> {
+  const { revision, signal } = options;
+  if (revision) {
+    const content = await readFileAtRevision(revision, filePath, signal);
+    if (content === null) throw new CheckToolError(`Cannot read ${filePath} at ${revision}`);
+    return content;
+  }
+  try {
+    return await fs.readFile(filePath, { encoding: "utf-8", signal });
+  } catch {
+    signal?.throwIfAborted();
+    throw new CheckToolError(`Cannot read ${filePath}`);
+  }
+}
+
+// The package default from the doc.go at the same revision, read once per package
+function packageDefaultFor(filePath: string, context: ScanContext, options: SummarizeOptions): Promise<PackageDefault | undefined> {
+  const docPath = packageDocPath(filePath);
+  if (!docPath) return Promise.resolve(undefined);
+  let found = context.packageDefaults.get(docPath);
+  if (!found) {
+    found = readSource(docPath, options).then(
+      content => parsePackageDirective(content, docPath, { aliases: context.aliases }),
+      () => undefined
+    );
+    context.packageDefaults.set(docPath, found);
+  }
+  return found;
+}
+
+export async function summarizeFile(filePath: string, options: SummarizeOptions = {}): Promise<FileSummary> {
+  return summarizeSource(filePath, await readSource(filePath, options), options);
+}
+
+// summarizeFile for content already read, e.g. kept for --group-by
+export async function summarizeSource(filePath: string, content: string, options: SummarizeOptions = {}): Promise<FileSummary> {
+  const context = options.context ?? (await loadScanContext(options));
+  const { config, aliases, owners } = context;
+
+  const { annotations, errors, warnings } = parseAnnotationContent(content, filePath, { aliases });
+  const regions: RegionSummary[] = annotations.map(a => ({
+    line_start: a.line_start,
+    line_end: a.line_end,
+    trust: a.trust,
+    owner: a.owner,
+    intent: a.intent,
+    labels: a.labels,
+    contacts: owners && regionContacts(a.owner, owners),
+    source: "annotation",
+  }));
+
+  for (const region of config.regions ?? []) {
+    if (regionMatchesFile(region, filePath)) {
+      regions.push({
+        line_start: region.line_start,
+        line_end: region.line_end,
+        trust: region.trust,
+        intent: region.reason,
+        source: "region",
+      });
+    }
+  }
+
+  for (const region of regions) {
+    if (!region.trust) continue;
+    const effective = applyTrustProfile(config, { level: region.trust }).level;
+    if (effective !== region.trust) region.effective = effective;
+  }
+
+  // Annotations before trust.yaml regions on the same lines
+  regions.sort((a, b) => compareRegions(a, b) || comparePaths(a.source, b.source));
+  const byLine = (a: AnnotationError, b: AnnotationError) => a.line - b.line || comparePaths(a.code, b.code);
+  const packageDefault = await packageDefaultFor(filePath, context, options);
+  return {
+    file: filePath,
+    profile: config.active_profile,
+    fallback: resolveTrustWithAnnotations(
+      config, filePath, [], undefined, undefined, [], false, packageDefault, isGoTestSource(filePath, content)
+    ),
+    parse_error: sourceSyntaxError(content, filePath),
+    regions,
+    errors: [...errors].sort(byLine),
+    warnings: [...warnings].sort(byLine),
+  };
+}
+
+// Summaries narrowed to regions carrying any of `labels`; files without one are dropped
+export function filterByLabels(summaries: FileSummary[], labels: string[]): FileSummary[] {
+  return summaries
+    .map(s => ({ ...s, regions: s.regions.filter(r => r.labels?.some(label => labels.includes(label))) }))
+    .filter(s => s.regions.length > 0);
+}
+
+// The file and its fallback level, its regions in line order, then errors and warnings
+export function summaryRecords(summary: FileSummary): ScanRecord[] {
+  return [
+    { kind: "file", file: summary.file, profile: summary.profile, fallback: summary.fallback, parse_error: summary.parse_error },
+    ...summary.regions.map(r => ({ kind: "region" as const, file: summary.file, ...r })),
+    ...summary.errors.map(e => ({ kind: "error" as const, ...e })),
+    ...summary.warnings.map(w => ({ kind: "warning" as const, ...w })),
+  ];
+}
+
+// ============================================
+// Strict Report
+// ============================================
+
+export function strictReport(summaries: FileSummary[]): StrictReport {
+  const categories = new Map(reasonCodes().map(r => [r.code, r.category]));
+  const issues: StrictIssue[] = [];
+  for (const summary of [...summaries].sort((a, b) => comparePaths(a.file, b.file))) {
+    const found = [
+      ...summary.errors.map(e => ({ ...e, severity: "error" as const })),
+      ...summary.warnings.map(w => ({ ...w, severity: "warning" as const })),
+    ];
+    for (const issue of found.sort((a, b) => a.line - b.line || comparePaths(a.code, b.code))) {
+      issues.push({ ...issue, category: categories.get(issue.code) === "source" ? "source" : "annotation" });
+    }
+  }
+  return {
+    files_scanned: summaries.length,
+    files_with_issues: new Set(issues.map(i => i.file)).size,
+    annotation_issues: issues.filter(i => i.category === "annotation").length,
+    source_issues: issues.filter(i => i.category === "source").length,
+    issues,
+  };
+}
+
+// ============================================
+// Grouping
+// ============================================
+
+const GO_PACKAGE_REGEX = /^package\s+([\p{L}\p{N}_]+)/mu;
+const GO_TYPE_REGEX = /^type\s/;
+
+/**
+ * Regroup file summaries by receiver type. A region belongs to the innermost
+ * declaration containing its first line: a method goes to its type ("Type" of
+ * "Type.Method"), a Go type declaration to itself, and anything else to the
+ * package group. `sources` holds each summarized file's content.
+ */
+export function groupByReceiver(summaries: FileSummary[], sources: Record<string, string>): ReceiverGroup[] {
+  const groups = new Map<string, ReceiverGroup>();
+  for (const summary of summaries) {
+    const content = sources[summary.file] ?? "";
+    const lines = content.replace(/\r\n/g, "\n").split("\n");
+    const pkg = summary.file.endsWith(".go")
+      ? GO_PACKAGE_REGEX.exec(content)?.[1] ?? path.dirname(summary.file)
+      : path.dirname(summary.file);
+    const declarations = findDeclarations(content, summary.file);
+
+    for (const region of summary.regions) {
+      const declaration = declarations
+        .filter(d => d.line_start <= region.line_start && region.line_start <= d.line_end)
+        .sort((a, b) => b.line_start - a.line_start || a.line_end - b.line_end)[0];
+
+      let receiver = PACKAGE_GROUP;
+      if (declaration?.qualified_name.includes(".")) {
+        receiver = declaration.qualified_name.slice(0, declaration.qualified_name.lastIndexOf("."));
+      } else if (declaration && GO_TYPE_REGEX.test(lines[declaration.line_start - 1] ?? "")) {
+        receiver = declaration.name;
+      }
+
+      const key = `${pkg}\0${receiver}`;
+      const group = groups.get(key) ?? { package: pkg, receiver, regions: [] };
+      group.regions.push({ file: summary.file, ...region, declaration: declaration?.qualified_name });
+      groups.set(key, group);
+    }
+  }
+
+  // Types in name order, each package's own group after its types
+  const order = (g: ReceiverGroup) => (g.receiver === PACKAGE_GROUP ? 1 : 0);
+  return [...groups.values()]
+    .map(g => ({ ...g, regions: g.regions.sort((a, b) => comparePaths(a.file, b.file) || compareRegions(a, b)) }))
+    .sort((a, b) => comparePaths(a.package, b.package) || order(a) - order(b) || comparePaths(a.receiver, b.receiver));
+}
+
+// ============================================
+// File Lists
+// ============================================
+
+// One path per line; blank lines are ignored and CRLF is accepted
+export function parseFileList(content: string): string[] {
+  return content
+    .split(/\r?\n/)
+    .map(line => line.trim())
+    .filter(Boolean);
+}
+
+async function readFileListSource(source: string): Promise<string> {
+  if (source !== "-") {
+    return fs.readFile(source, "utf-8").catch(() => {
+      throw new CheckToolError(`Cannot read ${source}`);
+    });
+  }
+  const chunks: Buffer[] = [];
+  for await (const chunk of process.stdin) chunks.push(chunk as Buffer);
+  return Buffer.concat(chunks).toString("utf-8");
+}
+
+/**
+ * Split listed paths into those scan can summarize and those it cannot: paths
+ * missing from the working tree (or from `revision`), and files whose
+ * extension takes no annotations.
+ */
+export async function selectListedFiles(
+  listed: string[],
+  options: { revision?: string; signal?: AbortSignal } = {}
+): Promise<{ files: string[]; skipped: SkippedFile[] }> {
+  const files: string[] = [];
+  const skipped: SkippedFile[] = [];
+  for (const file of listed) {
+    options.signal?.throwIfAborted();
+    const exists = options.revision
+      ? await fileExistsAtRevision(options.revision, file, options.signal)
+      : await fs.stat(file).then(s => s.isFile(), () => false);
+    if (!exists) {
+      skipped.push({ file, reason: "not found" });
+    } else if (!ANNOTATABLE_EXTENSIONS.includes(path.extname(file).slice(1).toLowerCase())) {
+      skipped.push({ file, reason: "unsupported file type" });
+    } else {
+      files.push(file);
+    }
+  }
+  return { files, skipped };
+}
+
+// ============================================
+// Progress
+// ============================================
+
+export interface ScanStats {
+  files: number; // Files summarized, including unparseable ones that were skipped
+  annotations: number;
+  errors: number; // Annotation errors
+  elapsed_ms: number;
+}
+
+export interface ScanProgress {
+  tick(): void; // One more file scanned
+  clear(): void; // Erase the counter, e.g. before other stderr output; the next tick redraws it
+}
+
+// Redraw at most this often, so a fast scan does not spend its time on the terminal
+const PROGRESS_INTERVAL_MS = 100;
+
+/**
+ * A "Scanning N/total files" counter redrawn in place on `stream`. Does
+ * nothing unless the stream is a terminal, so piped and redirected stderr
+ * never gets carriage returns.
+ */
+export function createScanProgress(
+  total: number,
+  stream: { isTTY?: boolean; write(text: string): unknown } = process.stderr
+): ScanProgress {
+  if (!stream.isTTY) return { tick() {}, clear() {} };
+  let done = 0;
+  let drawn = false;
+  let lastDraw = 0;
+  return {
+    tick() {
+      done++;
+      const now = Date.now();
+      if (done < total && now - lastDraw < PROGRESS_INTERVAL_MS) return;
+      lastDraw = now;
+      stream.write(`\rScanning ${done}/${total} files`);
+      drawn = true;
+    },
+    clear() {
+      if (drawn) stream.write("\r\x1b[K");
+      drawn = false;
+      lastDraw = 0;
+    },
+  };
+}
+
+// "Scanned 412 file(s): 96 annotation(s), 2 error(s) in 3.4s"
+export function formatScanStats(stats: ScanStats): string {
+  return `Scanned ${stats.files} file(s): ${stats.annotations} annotation(s), ${stats.errors} error(s) ` +
+    `in ${(stats.elapsed_ms / 1000).toFixed(1)}s`;
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+const TRUST_COLORS: Record<TrustLevel, string> = {
+  AUTONOMOUS: "32",   // green
+  SUPERVISED: "36",   // cyan
+  SUGGEST_ONLY: "33", // yellow
+  READ_ONLY: "31",    // red
+};
+
+export function useColor(stream: { isTTY?: boolean } = process.stdout): boolean {
+  return !process.env.NO_COLOR && Boolean(stream.isTTY);
+}
+
+function paint(text: string, code: string, color: boolean): string {
+  return color ? `\x1b[${code}m${text}\x1b[0m` : text;
+}
+
+export function formatSummaryText(summary: FileSummary, color: boolean = false): string {
+  const { fallback } = summary;
+  const lines = [
+    paint(summary.file, "1", color) + (summary.profile ? ` [profile: ${summary.profile}]` : ""),
+    `  elsewhere: ${paint(fallback.level, TRUST_COLORS[fallback.level], color)}` +
+      (fallback.base_level ? ` (was ${fallback.base_level})` : "") +
+      ` (${fallback.reason ?? fallback.source})`,
+  ];
+  if (summary.parse_error) {
+    lines.push(`  ${paint("unparseable", "33", color)}: ${summary.parse_error}; annotations found line by line`);
+  }
+
+  if (summary.regions.length === 0) {
+    lines.push("  no trust regions");
+  } else {
+    const rows = summary.regions.map(r => ({
+      range: formatRange(rangeOf(r)),
+      level: r.effective ?? r.trust,
+      trust: r.trust ? (r.effective ? `${r.effective} (was ${r.trust})` : r.trust) : "-",
+      owner: formatOwner(r.owner) ?? "-",
+      note: [
+        r.source === "region" ? `[trust.yaml] ${r.intent ?? ""}`.trim() : r.intent ?? "",
+        r.labels?.length ? `[${r.labels.join(", ")}]` : "",
+      ].filter(Boolean).join(" "),
+    }));
+    const rangeWidth = Math.max("Lines".length, ...rows.map(r => r.range.length));
+    const trustWidth = Math.max("Trust".length, ...rows.map(r => r.trust.length));
+    const ownerWidth = Math.max("Owner".length, ...rows.map(r => r.owner.length));
+
+    lines.push(
+      `  ${"Lines".padEnd(rangeWidth)}  ${"Trust".padEnd(trustWidth)}  ${"Owner".padEnd(ownerWidth)}  Intent`
+    );
+    for (const row of rows) {
+      // Pad before painting so escape codes do not skew the columns
+      const trust = row.trust.padEnd(trustWidth);
+      const painted = row.level ? paint(trust, TRUST_COLORS[row.level], color) : trust;
+      lines.push(
+        `  ${row.range.padEnd(rangeWidth)}  ${painted}  ${row.owner.padEnd(ownerWidth)}  ${row.note}`.trimEnd()
+      );
+    }
+  }
+
+  for (const issue of [...summary.errors, ...summary.warnings].sort((a, b) => a.line - b.line)) {
+    lines.push(`  ${paint("!", "33", color)} line ${issue.line}: ${issue.message}`);
+  }
+  return lines.join("\n");
+}
+
+export function formatStrictReportText(report: StrictReport, color: boolean = false): string {
+  if (report.issues.length === 0) return `Scanned ${report.files_scanned} file(s): no annotation issues`;
+
+  const lines: string[] = [];
+  for (const [category, title] of [["annotation", "Annotation issues"], ["source", "Source issues"]] as const) {
+    const issues = report.issues.filter(i => i.category === category);
+    if (issues.length === 0) continue;
+    if (lines.length > 0) lines.push("");
+    lines.push(paint(`${title} (${issues.length}):`, "1", color));
+    for (const issue of issues) {
+      const severity = paint(issue.severity, issue.severity === "error" ? "31" : "33", color);
+      lines.push(`  ${formatRange(rangeOf(issue), issue.file)}: ${severity} ${issue.code}: ${issue.message}`);
+    }
+  }
+  lines.push(
+    "",
+    `Scanned ${report.files_scanned} file(s): ${report.annotation_issues} annotation issue(s), ` +
+      `${report.source_issues} source issue(s) in ${report.files_with_issues} file(s)`
+  );
+  return lines.join("\n");
+}
+
+export function formatReceiverGroupsText(groups: ReceiverGroup[], color: boolean = false): string {
+  if (groups.length === 0) return "no trust regions";
+  return groups
+    .map(group => {
+      const rows = group.regions.map(r => ({
+        location: formatRange(rangeOf(r), r.file),
+        declaration: r.declaration ?? "-",
+        level: r.effective ?? r.trust,
+        trust: r.trust ? (r.effective ? `${r.effective} (was ${r.trust})` : r.trust) : "-",
+        owner: formatOwner(r.owner) ?? "-",
+      }));
+      const width = (key: "location" | "declaration" | "trust") => Math.max(...rows.map(r => r[key].length));
+      return [
+        paint(group.receiver, "1", color) + ` (package ${group.package})`,
+        ...rows.map(row => {
+          const trust = row.trust.padEnd(width("trust"));
+          const painted = row.level ? paint(trust, TRUST_COLORS[row.level], color) : trust;
+          return `  ${row.location.padEnd(width("location"))}  ${row.declaration.padEnd(width("declaration"))}  ${painted}  ${row.owner}`;
+        }),
+      ].join("\n");
+    })
+    .join("\n\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+const SCAN_FORMATS = ["text", "json", "ndjson"] as const;
+const ON_PARSE_ERROR: OnParseError[] = ["skip", "fail", "warn"];
+
+export async function runScan(args: string[]): Promise<number> {
+  let format: (typeof SCAN_FORMATS)[number] = "text";
+  let profile: string | undefined;
+  let sort = false;
+  let revision: string | undefined;
+  let filesFrom: string | undefined;
+  let groupBy: "receiver" | undefined;
+  let strict = false;
+  const labels: string[] = [];
+  let onParseError: OnParseError = "warn";
+  let quiet = false;
+  const files: string[] = [];
+
+  for (const arg of args) {
+    if (arg.startsWith("--format=")) {
+      const value = arg.slice("--format=".length) as (typeof SCAN_FORMATS)[number];
+      if (!SCAN_FORMATS.includes(value)) {
+        console.error(`Unknown format: ${value} (expected ${SCAN_FORMATS.join(", ")})`);
+        return EXIT_TOOL_ERROR;
+      }
+      format = value;
+    } else if (arg === "--sort") {
+      sort = true;
+    } else if (arg.startsWith("--rev=")) {
+      revision = arg.slice("--rev=".length);
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg.startsWith("--files-from=")) {
+      filesFrom = arg.slice("--files-from=".length);
+    } else if (arg === "--group-by=receiver") {
+      groupBy = "receiver";
+    } else if (arg === "--strict") {
+      strict = true;
+    } else if (arg === "--quiet") {
+      quiet = true;
+    } else if (arg.startsWith("--on-parse-error=")) {
+      const value = arg.slice("--on-parse-error=".length) as OnParseError;
+      if (!ON_PARSE_ERROR.includes(value)) {
+        console.error(`Unknown --on-parse-error: ${value} (expected ${ON_PARSE_ERROR.join(", ")})`);
+        return EXIT_TOOL_ERROR;
+      }
+      onParseError = value;
+    } else if (arg.startsWith("--label=")) {
+      const label = arg.slice("--label=".length);
+      if (!label) {
+        console.error("Error: --label needs a value");
+        return EXIT_TOOL_ERROR;
+      }
+      labels.push(label);
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      files.push(arg);
+    }
+  }
+
+  if (files.length === 0 && !filesFrom) {
+    console.error(
+      "Usage: collab-claude-code scan <file...> [--files-from=<file>|-] [--format=text|json|ndjson] [--sort] " +
+        "[--group-by=receiver] [--strict] [--label=<label>] [--on-parse-error=skip|fail|warn] [--quiet] [--rev=<revision>] [--profile=<name>]"
+    );
+    return EXIT_TOOL_ERROR;
+  }
+  if (groupBy && format === "ndjson") {
+    console.error("Error: --group-by=receiver does not apply to --format=ndjson");
+    return EXIT_TOOL_ERROR;
+  }
+  if (strict && (groupBy || format === "ndjson")) {
+    console.error(`Error: --strict prints its own report and does not combine with ${groupBy ? "--group-by" : "--format=ndjson"}`);
+    return EXIT_TOOL_ERROR;
+  }
+  if (strict && labels.length > 0) {
+    console.error("Error: --strict reports every annotation issue and does not combine with --label");
+    return EXIT_TOOL_ERROR;
+  }
+  const selectLabeled = (summaries: FileSummary[]) => (labels.length > 0 ? filterByLabels(summaries, labels) : summaries);
+
+  // Unparseable files are reported on stderr as they are found; only "skip" drops them
+  let parseFailed = false;
+  let progress: ScanProgress = { tick() {}, clear() {} };
+  const stats: ScanStats = { files: 0, annotations: 0, errors: 0, elapsed_ms: 0 };
+  const started = Date.now();
+  let context: ScanContext | undefined;
+  const sources: Record<string, string> = {};
+  const summarize = async (file: string): Promise<FileSummary[]> => {
+    const content = await readSource(file, { revision });
+    if (groupBy) sources[file] = content; // Kept rather than read again for grouping
+    const summary = await summarizeSource(file, content, { profile, revision, context });
+    stats.files++;
+    stats.annotations += summary.regions.filter(r => r.source === "annotation").length;
+    stats.errors += summary.errors.length;
+    progress.tick();
+    if (!summary.parse_error) return [summary];
+    progress.clear();
+    if (onParseError === "skip") {
+      console.error(`Skipped ${file}: unparseable (${summary.parse_error})`);
+      if (format === "ndjson") {
+        console.log(stableStringify({ kind: "skipped", file, reason: "unparseable", error: summary.parse_error }));
+      }
+      return [];
+    }
+    if (onParseError === "fail") parseFailed = true;
+    console.error(`${onParseError === "fail" ? "Error" : "Warning"}: ${file} is unparseable (${summary.parse_error})`);
+    return [summary];
+  };
+  // The summary follows the output, so it is the last thing on a terminal
+  const finish = (violations = false) => {
+    progress.clear();
+    stats.elapsed_ms = Date.now() - started;
+    if (!quiet) console.error(formatScanStats(stats));
+    return violations || parseFailed ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  };
+
+  try {
+    // Pin the commit so every file is read from the same one, even if a branch moves
+    if (revision) revision = await resolveCommit(revision);
+    context = await loadScanContext({ profile, revision });
+
+    let skipped: SkippedFile[] = [];
+    if (filesFrom) {
+      const listed = await selectListedFiles(parseFileList(await readFileListSource(filesFrom)), { revision });
+      files.push(...listed.files);
+      skipped = listed.skipped;
+    }
+    // Reported on stderr so JSON and text output keep their shape; ndjson also gets records
+    for (const { file, reason } of skipped) {
+      console.error(`Skipped ${file}: ${reason}`);
+    }
+    if (format === "ndjson") {
+      for (const entry of skipped) console.log(stableStringify({ kind: "skipped", ...entry }));
+    }
+    if (!quiet) progress = createScanProgress(files.length);
+
+    if (format === "ndjson" && !sort) {
+      for (const file of files) {
+        const found = selectLabeled(await summarize(file));
+        if (found.length > 0) progress.clear();
+        for (const summary of found) {
+          for (const record of summaryRecords(summary)) console.log(stableStringify(record));
+        }
+      }
+      return finish();
+    }
+
+    let summaries: FileSummary[] = [];
+    for (const file of files) {
+      summaries.push(...(await summarize(file)));
+    }
+    progress.clear();
+    summaries = selectLabeled(summaries).sort((a, b) => comparePaths(a.file, b.file));
+
+    if (format === "ndjson") {
+      for (const record of summaries.flatMap(summaryRecords)) {
+        console.log(stableStringify(record));
+      }
+      return finish();
+    }
+
+    const color = useColor();
+    if (strict) {
+      const report = strictReport(summaries);
+      console.log(format === "json" ? stableStringify(report, 2) : formatStrictReportText(report, color));
+      return finish(report.issues.length > 0);
+    }
+    if (groupBy) {
+      const groups = groupByReceiver(summaries, sources);
+      console.log(format === "json" ? stableStringify(groups, 2) : formatReceiverGroupsText(groups, color));
+      return finish();
+    }
+    console.log(
+      format === "json"
+        ? stableStringify(summaries, 2)
+        : summaries.map(s => formatSummaryText(s, color)).join("\n\n")
+    );
+    return finish();
+  } catch (error) {
+    progress.clear();
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
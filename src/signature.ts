@@ -0,0 +1,275 @@
+/**
+ * Signature comparison
+ *
+ * The building block for body-only edits to locked functions: given the old
+ * and new text of a region, decide whether the declaration's signature (name,
+ * receiver, type parameters, parameters, and results) is unchanged, whatever
+ * happened to its body.
+ *
+ *   signatureUnchanged("go", oldRegion, newRegion)  // true if only the body changed
+ *
+ * Signatures are compared token by token, so spacing, line breaks, comments,
+ * and a trailing comma in a parameter list do not count as changes; renaming a
+ * parameter does. Each region must hold exactly one declaration, optionally
+ * preceded by comments such as its doc comment or @collab annotation.
+ *
+ * Only Go is supported so far. Kept dependency-free so it can be used standalone.
+ */
+
+// ============================================
+// Types
+// ============================================
+
+export interface Signature {
+  name: string;
+  receiver?: string; // "(s *Server)" for methods
+  type_params?: string; // "[K comparable, V any]" for generic functions
+  params: string; // "(ctx context.Context, id string)"
+  results: string; // "(*User, error)", "error", or "" for none
+}
+
+export class SignatureParseError extends Error {
+  readonly side: "old" | "new";
+
+  constructor(side: "old" | "new", lang: string, reason: string) {
+    super(`${side} region does not parse as a single ${lang} declaration: ${reason}`);
+    this.name = new.target.name;
+    this.side = side;
+  }
+}
+
+interface Token {
+  text: string;
+  line: number; // 1-indexed within the region
+  spaced: boolean; // Whitespace or a comment comes before it
+}
+
+// ============================================
+// Go
+// ============================================
+
+const GO_OPENERS: Record<string, string> = { "(": ")", "[": "]", "{": "}" };
+
+// Identifiers are Unicode letters and digits, as in the Go spec
+const GO_IDENT_REGEX = /[\p{L}\p{Nd}_]/u;
+
+// Tokens of Go source without whitespace and comments; literals are kept whole
+function tokenizeGo(src: string): Token[] | string {
+  const tokens: Token[] = [];
+  let line = 1;
+  let i = 0;
+  let spaced = false;
+  const push = (text: string, tokenLine: number) => {
+    tokens.push({ text, line: tokenLine, spaced });
+    spaced = false;
+  };
+
+  while (i < src.length) {
+    const ch = src[i];
+    if (ch === "\n") {
+      line++;
+      i++;
+      spaced = true;
+      continue;
+    }
+    if (ch === " " || ch === "\t" || ch === "\r") {
+      i++;
+      spaced = true;
+      continue;
+    }
+
+    if (ch === "/" && src[i + 1] === "/") {
+      while (i < src.length && src[i] !== "\n") i++;
+      spaced = true;
+      continue;
+    }
+    if (ch === "/" && src[i + 1] === "*") {
+      const close = src.indexOf("*/", i + 2);
+      if (close === -1) return `unterminated comment on line ${line}`;
+      line += src.slice(i, close).split("\n").length - 1;
+      i = close + 2;
+      spaced = true;
+      continue;
+    }
+
+    if (ch === '"' || ch === "'") {
+      let j = i + 1;
+      while (j < src.length && src[j] !== ch && src[j] !== "\n") j += src[j] === "\\" ? 2 : 1;
+      if (src[j] !== ch) return `unterminated literal on line ${line}`;
+      push(src.slice(i, j + 1), line);
+      i = j + 1;
+      continue;
+    }
+    if (ch === "`") {
+      const close = src.indexOf("`", i + 1);
+      if (close === -1) return `unterminated raw string on line ${line}`;
+      push(src.slice(i, close + 1), line);
+      line += src.slice(i, close).split("\n").length - 1;
+      i = close + 1;
+      continue;
+    }
+
+    const start = i;
+    if (GO_IDENT_REGEX.test(String.fromCodePoint(src.codePointAt(i)!))) {
+      while (i < src.length) {
+        const cp = String.fromCodePoint(src.codePointAt(i)!);
+        if (!GO_IDENT_REGEX.test(cp)) break;
+        i += cp.length;
+      }
+    } else if (src.startsWith("...", i)) {
+      i += 3;
+    } else if (src.startsWith("<-", i)) {
+      i += 2;
+    } else {
+      i++;
+    }
+    push(src.slice(start, i), line);
+  }
+
+  return tokens;
+}
+
+// Index just past the bracket group opening at `start`, or an error
+function skipGroup(tokens: Token[], start: number): number | string {
+  const stack: string[] = [];
+  for (let i = start; i < tokens.length; i++) {
+    const text = tokens[i].text;
+    if (GO_OPENERS[text]) {
+      stack.push(GO_OPENERS[text]);
+    } else if (text === ")" || text === "]" || text === "}") {
+      if (stack.pop() !== text) return `mismatched "${text}" on line ${tokens[i].line}`;
+      if (stack.length === 0) return i + 1;
+    }
+  }
+  return `unclosed "${tokens[start].text}" on line ${tokens[start].line}`;
+}
+
+// A list of tokens as one string, dropping a trailing comma before the closing bracket
+function renderGo(tokens: Token[]): string {
+  const kept = tokens.filter((t, i) => !(t.text === "," && (tokens[i + 1]?.text === ")" || tokens[i + 1]?.text === "]")));
+  let out = "";
+  for (const [i, token] of kept.entries()) {
+    const prev = kept[i - 1]?.text;
+    const tight =
+      i === 0 ||
+      token.text === "," || token.text === ")" || token.text === "]" || token.text === "." ||
+      prev === "(" || prev === "[" || prev === "]" || prev === "." || prev === "*" || prev === "..." ||
+      (token.text === "(" && prev !== ",") ||
+      // Type arguments and map keys, not a slice type after a parameter name
+      (token.text === "[" && (prev === "map" || !token.spaced) && prev !== ",") ||
+      (token.text === "{" && (prev === "interface" || prev === "struct"));
+    out += (tight ? "" : " ") + token.text;
+  }
+  return out;
+}
+
+/**
+ * Parse the signature of the single Go function or method declared in
+ * `region`. Returns the reason as a string if the region is anything else.
+ */
+export function parseGoSignature(region: string): Signature | string {
+  const tokens = tokenizeGo(region);
+  if (typeof tokens === "string") return tokens;
+  if (tokens.length === 0) return "no declaration found";
+  if (tokens[0].text !== "func") return `expected "func" on line ${tokens[0].line}, found "${tokens[0].text}"`;
+
+  let i = 1;
+  let receiver: string | undefined;
+  if (tokens[i]?.text === "(") {
+    const end = skipGroup(tokens, i);
+    if (typeof end === "string") return end;
+    receiver = renderGo(tokens.slice(i, end));
+    i = end;
+  }
+
+  const name = tokens[i];
+  if (!name || !GO_IDENT_REGEX.test(String.fromCodePoint(name.text.codePointAt(0)!))) {
+    return name ? `expected a function name on line ${name.line}, found "${name.text}"` : "missing function name";
+  }
+  i++;
+
+  let typeParams: string | undefined;
+  if (tokens[i]?.text === "[") {
+    const end = skipGroup(tokens, i);
+    if (typeof end === "string") return end;
+    typeParams = renderGo(tokens.slice(i, end));
+    i = end;
+  }
+
+  if (tokens[i]?.text !== "(") return `expected a parameter list after ${name.text}`;
+  const paramsEnd = skipGroup(tokens, i);
+  if (typeof paramsEnd === "string") return paramsEnd;
+  const params = renderGo(tokens.slice(i, paramsEnd));
+  i = paramsEnd;
+
+  // Results run up to the body; brackets are skipped whole, so interface{} and
+  // struct{...} result types do not end them
+  const resultsStart = i;
+  while (i < tokens.length && tokens[i].text !== "{") {
+    if (tokens[i].text === "(" || tokens[i].text === "[") {
+      const end = skipGroup(tokens, i);
+      if (typeof end === "string") return end;
+      i = end;
+    } else if ((tokens[i].text === "interface" || tokens[i].text === "struct") && tokens[i + 1]?.text === "{") {
+      const end = skipGroup(tokens, i + 1);
+      if (typeof end === "string") return end;
+      i = end;
+    } else {
+      i++;
+    }
+  }
+  const results = renderGo(tokens.slice(resultsStart, i));
+
+  // A declaration without a body (implemented in assembly) ends here
+  if (i < tokens.length) {
+    const bodyEnd = skipGroup(tokens, i);
+    if (typeof bodyEnd === "string") return bodyEnd;
+    i = bodyEnd;
+  }
+  if (i < tokens.length) {
+    return `unexpected "${tokens[i].text}" on line ${tokens[i].line} after the declaration of ${name.text}`;
+  }
+
+  const signature: Signature = { name: name.text, params, results };
+  if (receiver !== undefined) signature.receiver = receiver;
+  if (typeParams !== undefined) signature.type_params = typeParams;
+  return signature;
+}
+
+// ============================================
+// Comparison
+// ============================================
+
+const SIGNATURE_PARSERS: Record<string, (region: string) => Signature | string> = {
+  go: parseGoSignature,
+};
+
+const LANGUAGE_NAMES: Record<string, string> = { go: "Go" };
+
+/**
+ * Whether the declaration in `newRegion` has the same signature as the one in
+ * `oldRegion`. `lang` is a language name or file extension ("go"). Throws a
+ * SignatureParseError if either region is not a single declaration.
+ */
+export function signatureUnchanged(lang: string, oldRegion: string, newRegion: string): boolean {
+  const key = lang.toLowerCase().replace(/^\./, "");
+  const parse = SIGNATURE_PARSERS[key];
+  if (!parse) {
+    throw new Error(`Signature comparison is not supported for "${lang}" (supported: ${Object.keys(SIGNATURE_PARSERS).join(", ")})`);
+  }
+
+  const before = parse(oldRegion);
+  if (typeof before === "string") throw new SignatureParseError("old", LANGUAGE_NAMES[key], before);
+  const after = parse(newRegion);
+  if (typeof after === "string") throw new SignatureParseError("new", LANGUAGE_NAMES[key], after);
+
+  // Rendering keeps the source's spacing before "[", which is not a change either
+  const same = (a?: string, b?: string) => a?.replace(/ \[/g, "[") === b?.replace(/ \[/g, "[");
+  return (
+    before.name === after.name &&
+    same(before.receiver, after.receiver) &&
+    same(before.type_params, after.type_params) &&
+    same(before.params, after.params) &&
+    same(before.results, after.results)
+  );
+}
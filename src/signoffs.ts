@@ -0,0 +1,221 @@
+/**
+ * audit-signoffs command for collab-claude-code
+ *
+ * Enforces "you can't unlock your own code without the owner agreeing": every
+ * change in the annotation diff between two revisions that loosens an owned
+ * region or hands it over (a trust downgrade or removal, a deleted region, an
+ * owner removed or changed) must be matched by a sign-off from one of the
+ * region's owners in base:
+ *
+ *   collab-claude-code audit-signoffs <base> [head] [--signoffs=<file>] [--format=text|json]
+ *
+ * Sign-offs are read from .collab/signoffs.yaml, or the file given, as a list:
+ *
+ *   - file: src/crypto/keys.go
+ *     owner: crypto-team          # One of the region's owners before the change
+ *     by: alice                   # Optional: who signed for the owner
+ *     symbol: "func Sign("        # Optional: the region, by its first line as diff reports it
+ *
+ * Each finding names the author of the latest commit in the range that touched
+ * the file, when there is one.
+ *
+ * Exit codes follow the check command:
+ *   0 = Every such change is signed off
+ *   1 = One or more changes without a sign-off
+ *   2 = Tool error (bad arguments, unknown revision, invalid sign-off file)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import { COLLAB_DIR, comparePaths, fileExists, formatRange, rangeOf, stableStringify } from "./collab.js";
+import { CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, EXIT_VIOLATIONS } from "./check.js";
+import { TrustChange, describeChange, diffAnnotations } from "./diff.js";
+import { ownerKey } from "./audit-owners.js";
+import { lastAuthorSince } from "./git.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface Signoff {
+  file: string;
+  owner: string; // The owner agreeing to the change
+  by?: string; // Who signed for the owner
+  symbol?: string; // First line of the region's code; unset covers every region in the file
+}
+
+export interface UnauthorizedChange {
+  change: TrustChange;
+  owners: string[]; // The region's owners in base; a sign-off from any one of them suffices
+  changed_by?: string; // "Name <email>" of the latest commit in the range touching the file
+}
+
+export interface SignoffAuditReport {
+  base: string;
+  head: string; // "WORKTREE" when comparing against uncommitted files
+  signoffs: number;
+  governed_changes: number; // Changes that needed a sign-off
+  findings: UnauthorizedChange[];
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const SIGNOFF_FILE = "signoffs.yaml";
+
+// ============================================
+// Sign-offs
+// ============================================
+
+export async function loadSignoffs(file?: string): Promise<Signoff[]> {
+  const signoffPath = file ?? path.join(COLLAB_DIR, SIGNOFF_FILE);
+  if (!(await fileExists(signoffPath))) {
+    if (file) throw new CheckToolError(`Cannot read ${file}`);
+    return [];
+  }
+
+  let parsed: unknown;
+  try {
+    parsed = yaml.parse(await fs.readFile(signoffPath, "utf-8")) ?? [];
+  } catch (error) {
+    throw new CheckToolError(`Invalid ${signoffPath}: ${error instanceof Error ? error.message : String(error)}`);
+  }
+  if (!Array.isArray(parsed)) {
+    throw new CheckToolError(`Invalid ${signoffPath}: expected a list of sign-offs`);
+  }
+  return parsed.map((entry, index) => {
+    const signoff = entry as Partial<Signoff> | null;
+    if (typeof signoff?.file !== "string" || typeof signoff.owner !== "string") {
+      throw new CheckToolError(`Invalid ${signoffPath}: sign-off ${index + 1} needs a file and an owner`);
+    }
+    return signoff as Signoff;
+  });
+}
+
+// A change that loosens a region or hands it over, and the owners who had to agree
+function requiredOwners(change: TrustChange): string[] {
+  const owners = change.owners_before ?? [];
+  switch (change.kind) {
+    case "downgrade":
+    case "owner_removed":
+    case "owner_changed":
+      return owners;
+    case "removed":
+    case "region_removed":
+      return change.priority === "high" ? owners : [];
+    default:
+      return [];
+  }
+}
+
+/**
+ * The changes among `changes` (from diffAnnotations) that loosen an owned
+ * region or change its owners without a matching sign-off. A sign-off matches
+ * when it names the change's file (or the path it was renamed from), one of
+ * the region's owners in base, and, if it sets one, the region's symbol.
+ * Owners compare as in audit-owners: `@org/team` matches `team`.
+ */
+export function unauthorizedGovernanceChanges(changes: TrustChange[], signoffs: Signoff[]): UnauthorizedChange[] {
+  const findings: UnauthorizedChange[] = [];
+  for (const change of changes) {
+    const owners = requiredOwners(change);
+    if (owners.length === 0) continue;
+
+    const keys = new Set(owners.map(ownerKey));
+    const signed = signoffs.some(
+      s =>
+        (s.file === change.file || s.file === change.renamed_from) &&
+        keys.has(ownerKey(s.owner)) &&
+        (s.symbol === undefined || s.symbol.trim() === change.symbol)
+    );
+    if (!signed) findings.push({ change, owners });
+  }
+  return findings.sort((a, b) => comparePaths(a.change.file, b.change.file) || a.change.line - b.change.line);
+}
+
+export async function auditSignoffs(
+  base: string,
+  head?: string,
+  options: { signoffs?: string; signal?: AbortSignal } = {}
+): Promise<SignoffAuditReport> {
+  const { signal } = options;
+  const signoffs = await loadSignoffs(options.signoffs);
+  const { changes } = await diffAnnotations(base, head, { signal });
+  const findings = unauthorizedGovernanceChanges(changes, signoffs);
+
+  for (const finding of findings) {
+    const { file, renamed_from } = finding.change;
+    const files = renamed_from ? [file, renamed_from] : [file];
+    finding.changed_by = await lastAuthorSince(base, head, files, signal).catch(() => {
+      signal?.throwIfAborted();
+      return undefined;
+    });
+  }
+
+  return {
+    base,
+    head: head ?? "WORKTREE",
+    signoffs: signoffs.length,
+    governed_changes: changes.filter(c => requiredOwners(c).length > 0).length,
+    findings,
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatSignoffAuditText(report: SignoffAuditReport): string {
+  const lines = report.findings.map(({ change, owners, changed_by }) =>
+    `${formatRange(rangeOf(change), change.file)}: ${describeChange(change)} without a sign-off from ` +
+      `${owners.join(" or ")}${changed_by ? ` (changed by ${changed_by})` : ""}  ${change.symbol}`
+  );
+  const count = report.findings.length;
+  lines.push(
+    `Audited ${report.governed_changes} governance change(s) in ${report.base}..${report.head} ` +
+      `against ${report.signoffs} sign-off(s): ` +
+      (count === 0 ? "all signed off" : `${count} without an owner sign-off`)
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runAuditSignoffs(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let signoffs: string | undefined;
+  const revisions: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--signoffs=")) {
+      signoffs = arg.slice("--signoffs=".length);
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      revisions.push(arg);
+    }
+  }
+
+  if (revisions.length < 1 || revisions.length > 2) {
+    console.error("Usage: collab-claude-code audit-signoffs <base> [head] [--signoffs=<file>] [--format=text|json]");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const report = await auditSignoffs(revisions[0], revisions[1], { signoffs });
+    console.log(format === "json" ? stableStringify(report, 2) : formatSignoffAuditText(report));
+    return report.findings.length > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,254 @@
+/**
+ * simulate command for collab-claude-code
+ *
+ * Previews a trust.yaml change before it lands: every line of the files is
+ * resolved twice, once with the current .collab/trust.yaml and once with a
+ * candidate file, exactly as heatmap resolves them. Runs of lines whose level
+ * changes are reported as regions that become tighter (more restrictive) or
+ * looser, with a summary of the blast radius.
+ *
+ *   collab-claude-code simulate --config=trust.new.yaml src
+ *
+ * Exit codes:
+ *   0 = Simulation ran, whatever it found
+ *   2 = Tool error (bad arguments, unreadable or invalid config)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+
+import {
+  COLLAB_DIR,
+  TRUST_FILE,
+  TRUST_RESTRICTIVENESS,
+  TrustLevel,
+  TrustResult,
+  comparePaths,
+  fileExists,
+  formatRange,
+  isGeneratedSource,
+  isGoTestSource,
+  loadPackageDefault,
+  loadTrustAliases,
+  parseAnnotationContent,
+  rangeOf,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+import { findDeclarations } from "./declarations.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface SimulatedChange {
+  file: string;
+  line_start: number;
+  line_end: number;
+  direction: "tighter" | "looser";
+  trust_before: TrustLevel;
+  trust_after: TrustLevel;
+  source_after?: TrustResult["source"];
+  reason_after?: string; // What governs the lines under the candidate config
+}
+
+export interface SimulationReport {
+  current: string; // Path of the config in effect
+  candidate: string; // Path of the simulated config
+  profile?: string;
+  files_compared: number;
+  lines_compared: number;
+  summary: {
+    files_changed: number;
+    lines_tighter: number;
+    lines_looser: number;
+    regions: number;
+  };
+  changes: SimulatedChange[];
+}
+
+export interface SimulateOptions extends CheckOptions {
+  config: string; // The candidate trust.yaml
+}
+
+// ============================================
+// Simulation
+// ============================================
+
+function lineCount(content: string): number {
+  if (content === "") return 0;
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  return content.endsWith("\n") ? lines.length - 1 : lines.length;
+}
+
+export async function simulatePolicy(paths: string[], options: SimulateOptions): Promise<SimulationReport> {
+  const { signal } = options;
+  if (!(await fileExists(options.config))) {
+    throw new CheckToolError(`Cannot read ${options.config}`);
+  }
+  const current = await loadTrustConfigStrict(options.profile);
+  const candidate = await loadTrustConfigStrict(options.profile, undefined, options.config);
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+  const files = await expandPaths(paths, options);
+  const needsDeclarations = Boolean(current.symbols?.length || candidate.symbols?.length);
+
+  const changes: SimulatedChange[] = [];
+  let linesCompared = 0;
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    const { annotations } = parseAnnotationContent(content, file, { aliases });
+    const declarations = needsDeclarations ? findDeclarations(content, file) : [];
+    const generated = isGeneratedSource(content);
+    const packageDefault = await loadPackageDefault(file, { aliases });
+    const testFile = isGoTestSource(file, content);
+    const total = lineCount(content);
+    linesCompared += total;
+
+    // Consecutive lines with the same before/after pair and governing rule form one region
+    let open: (SimulatedChange & { key: string }) | undefined;
+    const close = () => {
+      if (!open) return;
+      const { key: _key, ...change } = open;
+      changes.push(change);
+      open = undefined;
+    };
+    for (let line = 1; line <= total; line++) {
+      const before = resolveTrustWithAnnotations(
+        current, file, annotations, line, line, declarations, generated, packageDefault, testFile
+      );
+      const after = resolveTrustWithAnnotations(
+        candidate, file, annotations, line, line, declarations, generated, packageDefault, testFile
+      );
+      if (before.level === after.level) {
+        close();
+        continue;
+      }
+
+      const key = `${before.level}\0${after.level}\0${after.reason ?? ""}`;
+      if (open && open.key === key && open.line_end === line - 1) {
+        open.line_end = line;
+        continue;
+      }
+      close();
+      open = {
+        key,
+        file,
+        line_start: line,
+        line_end: line,
+        direction: TRUST_RESTRICTIVENESS[after.level] > TRUST_RESTRICTIVENESS[before.level] ? "tighter" : "looser",
+        trust_before: before.level,
+        trust_after: after.level,
+        source_after: after.source,
+        reason_after: after.reason,
+      };
+    }
+    close();
+  }
+
+  changes.sort((a, b) => comparePaths(a.file, b.file) || a.line_start - b.line_start);
+  const linesIn = (direction: SimulatedChange["direction"]) =>
+    changes.filter(c => c.direction === direction).reduce((sum, c) => sum + c.line_end - c.line_start + 1, 0);
+
+  return {
+    current: path.join(COLLAB_DIR, TRUST_FILE),
+    candidate: options.config,
+    profile: candidate.active_profile,
+    files_compared: files.length,
+    lines_compared: linesCompared,
+    summary: {
+      files_changed: new Set(changes.map(c => c.file)).size,
+      lines_tighter: linesIn("tighter"),
+      lines_looser: linesIn("looser"),
+      regions: changes.length,
+    },
+    changes,
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatSimulationText(report: SimulationReport): string {
+  const lines: string[] = [];
+  for (const c of report.changes) {
+    lines.push(
+      `${formatRange(rangeOf(c), c.file)}: ${c.trust_before} -> ${c.trust_after} (${c.direction})` +
+        (c.reason_after ? `  [${c.reason_after}]` : "")
+    );
+  }
+
+  const { summary } = report;
+  lines.push(
+    `Simulated ${report.candidate} against ${report.current}` +
+      (report.profile ? ` (profile ${report.profile})` : "") +
+      ` over ${report.files_compared} file(s), ${report.lines_compared} line(s): ` +
+      (summary.regions === 0
+        ? "no trust changes"
+        : `${summary.lines_tighter} line(s) tighter, ${summary.lines_looser} looser ` +
+          `in ${summary.regions} region(s) across ${summary.files_changed} file(s)`)
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runSimulate(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let config: string | undefined;
+  let profile: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--config=")) {
+      config = arg.slice("--config=".length);
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  if (!config) {
+    console.error("Usage: collab-claude-code simulate --config=<trust.yaml> [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const report = await simulatePolicy(paths, { config, profile, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatSimulationText(report));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,341 @@
+/**
+ * test-policy command for collab-claude-code
+ *
+ * Regression tests for a project's governance: each fixture names a file, an
+ * edit to it as a unified diff, and the decision the gateway should make for
+ * that edit (allow, propose, or deny). The edit's lines are resolved against
+ * the real .collab/trust.yaml and annotations, exactly as the gateway resolves
+ * them, and the fixture passes when the decision matches.
+ *
+ *   collab-claude-code test-policy policy-tests/
+ *
+ * Fixture files are YAML or JSON, anywhere under the directory, and hold one
+ * fixture or a list of them:
+ *
+ *   - name: refunds stay locked
+ *     file: src/pay/refund.go
+ *     diff: |
+ *       @@ -12,2 +12,2 @@
+ *       -	return nil
+ *       +	return errRefund
+ *     expect: deny
+ *
+ * A fixture may also set `content` (the file as the test should see it,
+ * instead of reading it from disk), `profile`, and `expect_trust`. Each hunk
+ * is judged by the lines it replaces, an insertion by the line it follows,
+ * and the edit gets the strictest decision of its hunks.
+ *
+ * Exit codes:
+ *   0 = Every fixture passed
+ *   1 = One or more fixtures failed or could not be run
+ *   2 = Tool error (bad arguments, unreadable directory, no fixtures, invalid trust.yaml)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+import { glob } from "glob";
+
+import {
+  TRUST_LEVELS,
+  TrustConfig,
+  TrustLevel,
+  TrustResult,
+  comparePaths,
+  formatRange,
+  isGeneratedSource,
+  isGoTestSource,
+  loadPackageDefault,
+  loadTrustAliases,
+  parseAnnotationContent,
+  rangeOf,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import { CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, EXIT_VIOLATIONS, loadTrustConfigStrict } from "./check.js";
+import { findDeclarations } from "./declarations.js";
+import { DECISIONS, EditDecision } from "./gateway.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface PolicyFixture {
+  name?: string; // Default: "<fixture file>#<index>"
+  file?: string; // Default: the diff's "+++" path
+  content?: string; // The file as the test should see it; read from disk if unset
+  diff: string; // Unified diff of the edit; only the hunk headers' line ranges are used
+  profile?: string;
+  expect: EditDecision;
+  expect_trust?: TrustLevel;
+}
+
+// Lines of the original file an edit replaces (1-indexed, inclusive)
+export interface EditRange {
+  line_start: number;
+  line_end: number;
+}
+
+export interface FixtureResult {
+  name: string;
+  source: string; // Fixture file it came from
+  status: "pass" | "fail" | "error";
+  expected?: EditDecision;
+  actual?: EditDecision;
+  expected_trust?: TrustLevel;
+  trust?: TrustLevel;
+  reason?: string; // Why the resolver chose that level
+  line_start?: number; // The hunk that decided
+  line_end?: number;
+  message?: string; // For "error": what was wrong with the fixture
+}
+
+export interface PolicyTestReport {
+  fixtures: number;
+  passed: number;
+  failed: number; // Including fixtures that could not be run
+  results: FixtureResult[];
+}
+
+// ============================================
+// Constants
+// ============================================
+
+const FIXTURE_GLOB = "**/*.{yaml,yml,json}";
+const HUNK_HEADER_REGEX = /^@@ -(\d+)(?:,(\d+))? \+\d+(?:,\d+)? @@/;
+const NEW_FILE_HEADER_REGEX = /^\+\+\+ (?:b\/)?(\S+)/;
+
+// Higher is stricter, for picking the decision of a multi-hunk edit
+const DECISION_ORDER: Record<EditDecision, number> = { allow: 0, propose: 1, deny: 2 };
+
+// ============================================
+// Diffs
+// ============================================
+
+/**
+ * The original-file line ranges a unified diff replaces, one per hunk. A hunk
+ * that only inserts covers the line it follows (line 1 at the top of a file).
+ */
+export function parseEditRanges(diff: string): EditRange[] {
+  const ranges: EditRange[] = [];
+  for (const line of diff.split(/\r?\n/)) {
+    const hunk = HUNK_HEADER_REGEX.exec(line);
+    if (!hunk) continue;
+    const start = parseInt(hunk[1], 10);
+    const count = hunk[2] === undefined ? 1 : parseInt(hunk[2], 10);
+    ranges.push(
+      count === 0
+        ? { line_start: Math.max(start, 1), line_end: Math.max(start, 1) }
+        : { line_start: start, line_end: start + count - 1 }
+    );
+  }
+  return ranges;
+}
+
+function diffTarget(diff: string): string | undefined {
+  for (const line of diff.split(/\r?\n/)) {
+    const header = NEW_FILE_HEADER_REGEX.exec(line);
+    if (header && header[1] !== "/dev/null") return header[1];
+  }
+  return undefined;
+}
+
+// ============================================
+// Fixtures
+// ============================================
+
+function validateFixture(value: unknown): string | undefined {
+  if (!value || typeof value !== "object" || Array.isArray(value)) return "a fixture must be a mapping";
+  const fixture = value as Record<string, unknown>;
+  if (typeof fixture.diff !== "string") return "diff must be a unified diff";
+  if (!Object.keys(DECISION_ORDER).includes(fixture.expect as string)) {
+    return `expect must be one of ${Object.keys(DECISION_ORDER).join(", ")}`;
+  }
+  if (fixture.expect_trust !== undefined && !TRUST_LEVELS.includes(fixture.expect_trust as TrustLevel)) {
+    return `expect_trust must be one of ${TRUST_LEVELS.join(", ")}`;
+  }
+  for (const key of ["name", "file", "content", "profile"]) {
+    if (fixture[key] !== undefined && typeof fixture[key] !== "string") return `${key} must be a string`;
+  }
+  return undefined;
+}
+
+/**
+ * Every fixture under `dir`, in path order and then file order. Fixtures that
+ * do not validate are kept with the reason, so they are reported as errors
+ * rather than silently skipped.
+ */
+export async function loadPolicyFixtures(
+  dir: string
+): Promise<{ source: string; name: string; fixture?: PolicyFixture; error?: string }[]> {
+  const stat = await fs.stat(dir).catch(() => null);
+  if (!stat?.isDirectory()) throw new CheckToolError(`Not a directory: ${dir}`);
+
+  const files = (await glob(FIXTURE_GLOB, { cwd: dir, nodir: true, posix: true })).sort(comparePaths);
+  const fixtures: { source: string; name: string; fixture?: PolicyFixture; error?: string }[] = [];
+  for (const file of files) {
+    const source = path.join(dir, file);
+    let parsed: unknown;
+    try {
+      const content = await fs.readFile(source, "utf-8");
+      parsed = file.endsWith(".json") ? JSON.parse(content) : yaml.parse(content);
+    } catch (error) {
+      fixtures.push({ source, name: file, error: error instanceof Error ? error.message : String(error) });
+      continue;
+    }
+    const entries = Array.isArray(parsed) ? parsed : [parsed];
+    for (const [index, entry] of entries.entries()) {
+      const error = validateFixture(entry);
+      const named = typeof entry?.name === "string" ? entry.name : `${file}#${index + 1}`;
+      fixtures.push(error ? { source, name: named, error } : { source, name: named, fixture: entry as PolicyFixture });
+    }
+  }
+  return fixtures;
+}
+
+// ============================================
+// Running
+// ============================================
+
+/**
+ * Decide one fixture's edit. `loadConfig` is called for the fixture's profile;
+ * testPolicy caches it so trust.yaml is read once per profile.
+ */
+export async function runPolicyFixture(
+  fixture: PolicyFixture,
+  loadConfig: (profile?: string) => Promise<TrustConfig>,
+  aliases: Record<string, TrustLevel> = {}
+): Promise<Omit<FixtureResult, "name" | "source">> {
+  const file = fixture.file ?? diffTarget(fixture.diff);
+  if (!file) return { status: "error", message: "no file (set file or add a +++ header to the diff)" };
+  const ranges = parseEditRanges(fixture.diff);
+  if (ranges.length === 0) return { status: "error", message: "diff has no @@ hunks" };
+
+  let content = fixture.content;
+  if (content === undefined) {
+    try {
+      content = await fs.readFile(file, "utf-8");
+    } catch {
+      return { status: "error", message: `cannot read ${file} (set content to test a file that does not exist)` };
+    }
+  }
+
+  const config = await loadConfig(fixture.profile);
+  const { annotations } = parseAnnotationContent(content, file, { aliases });
+  const declarations = config.symbols?.length ? findDeclarations(content, file) : [];
+  const generated = isGeneratedSource(content);
+  const packageDefault = await loadPackageDefault(file, { aliases });
+
+  let decided: { decision: EditDecision; trust: TrustResult; range: EditRange } | undefined;
+  for (const range of ranges) {
+    const trust = resolveTrustWithAnnotations(
+      config, file, annotations, range.line_start, range.line_end, declarations, generated, packageDefault,
+      isGoTestSource(file, content)
+    );
+    const decision = DECISIONS[trust.level];
+    if (!decided || DECISION_ORDER[decision] > DECISION_ORDER[decided.decision]) decided = { decision, trust, range };
+  }
+
+  const { decision, trust, range } = decided!;
+  const pass = decision === fixture.expect && (fixture.expect_trust === undefined || trust.level === fixture.expect_trust);
+  return {
+    status: pass ? "pass" : "fail",
+    expected: fixture.expect,
+    actual: decision,
+    expected_trust: fixture.expect_trust,
+    trust: trust.level,
+    reason: trust.reason,
+    line_start: range.line_start,
+    line_end: range.line_end,
+  };
+}
+
+export async function testPolicy(dir: string, options: { profile?: string } = {}): Promise<PolicyTestReport> {
+  const fixtures = await loadPolicyFixtures(dir);
+  if (fixtures.length === 0) throw new CheckToolError(`No fixtures (.yaml, .yml, .json) under ${dir}`);
+
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+  const configs = new Map<string, Promise<TrustConfig>>();
+  const loadConfig = (profile = options.profile) => {
+    const key = profile ?? "";
+    if (!configs.has(key)) configs.set(key, loadTrustConfigStrict(profile));
+    return configs.get(key)!;
+  };
+
+  const results: FixtureResult[] = [];
+  for (const { source, name, fixture, error } of fixtures) {
+    results.push(
+      fixture
+        ? { name, source, ...(await runPolicyFixture(fixture, loadConfig, aliases)) }
+        : { name, source, status: "error", message: error }
+    );
+  }
+  const passed = results.filter(r => r.status === "pass").length;
+  return { fixtures: results.length, passed, failed: results.length - passed, results };
+}
+
+// ============================================
+// Output
+// ============================================
+
+export function formatPolicyTestText(report: PolicyTestReport): string {
+  const lines = report.results.map(r => {
+    switch (r.status) {
+      case "pass":
+        return `PASS  ${r.name} (${r.actual}, ${r.trust})`;
+      case "fail": {
+        const expected = r.expected_trust ? `${r.expected} (${r.expected_trust})` : r.expected;
+        return `FAIL  ${r.name}: expected ${expected}, got ${r.actual} (${r.trust}` +
+          (r.reason ? `: ${r.reason}` : "") + `) at line(s) ${formatRange(rangeOf({ line_start: r.line_start!, line_end: r.line_end! }))} [${r.source}]`;
+      }
+      case "error":
+        return `ERROR ${r.name}: ${r.message} [${r.source}]`;
+    }
+  });
+  const errors = report.results.filter(r => r.status === "error").length;
+  lines.push(
+    `${report.fixtures} fixture(s): ${report.passed} passed, ${report.failed - errors} failed` +
+      (errors > 0 ? `, ${errors} could not be run` : "")
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runTestPolicy(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  const dirs: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      dirs.push(arg);
+    }
+  }
+
+  if (dirs.length !== 1) {
+    console.error("Usage: collab-claude-code test-policy <fixtures-dir> [--format=text|json] [--profile=<name>]");
+    return EXIT_TOOL_ERROR;
+  }
+
+  try {
+    const report = await testPolicy(dirs[0], { profile });
+    console.log(format === "json" ? stableStringify(report, 2) : formatPolicyTestText(report));
+    return report.failed > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,110 @@
+/**
+ * Ownership transfer hooks
+ *
+ * A TransferHook is told about every region `diff --notify` finds changing
+ * hands, for example to message the previous owners that their region was
+ * reassigned. Hooks only observe: a failing hook is reported but does not
+ * change the diff or its exit code.
+ *
+ * Hooks come from two places, and run in this order for each transfer:
+ *
+ * 1. Hooks registered with registerTransferHook, in registration order
+ * 2. Modules listed under `transfer_hooks` in config.yaml, in list order
+ *
+ * When config.yaml defines `owners`, each transfer's `contacts` holds the
+ * records of its previous and new owners, so hooks can route by channel or
+ * email. An owner key the registry lacks is reported as a failure of the
+ * transfer; hooks still run with the contacts that did resolve.
+ */
+
+import * as path from "path";
+import { pathToFileURL } from "url";
+import { Owner, OwnerContact, UnknownOwnerError, loadCollabConfig, loadOwnerRegistry, resolveOwner } from "./collab.js";
+import type { OwnershipTransfer } from "./diff.js";
+
+export type TransferHook = (transfer: OwnershipTransfer) => void | Promise<void>;
+
+export interface TransferHookFailure {
+  hook: string; // Function name or module path
+  file: string;
+  line: number;
+  message: string;
+}
+
+const registeredHooks: TransferHook[] = [];
+
+export function registerTransferHook(hook: TransferHook): void {
+  registeredHooks.push(hook);
+}
+
+export function clearTransferHooks(): void {
+  registeredHooks.length = 0;
+}
+
+// A config module must export the hook as its default export or as `onTransfer`
+async function loadHookModule(modulePath: string): Promise<TransferHook> {
+  const loaded = await import(pathToFileURL(path.resolve(modulePath)).href);
+  const hook = loaded.default ?? loaded.onTransfer;
+  if (typeof hook !== "function") {
+    throw new Error("module does not export a transfer hook (default or `onTransfer`)");
+  }
+  return hook as TransferHook;
+}
+
+// Registry records for a transfer's previous and new owners; keys it lacks are reported as failures
+function transferContacts(
+  transfer: OwnershipTransfer,
+  registry: Record<string, OwnerContact>,
+  failures: TransferHookFailure[]
+): Owner[] {
+  const contacts: Owner[] = [];
+  for (const key of new Set([...transfer.from, ...transfer.to])) {
+    try {
+      contacts.push(resolveOwner(key, registry));
+    } catch (error) {
+      if (!(error instanceof UnknownOwnerError)) throw error;
+      failures.push({ hook: "owners", file: transfer.file, line: transfer.line, message: error.message });
+    }
+  }
+  return contacts;
+}
+
+/**
+ * Run every hook for every transfer. Returns one failure per hook and
+ * transfer that threw, per transfer for a module that could not be loaded,
+ * and per owner key the registry does not define.
+ */
+export async function runTransferHooks(transfers: OwnershipTransfer[]): Promise<TransferHookFailure[]> {
+  if (transfers.length === 0) return [];
+  const hooks: { name: string; hook?: TransferHook; error?: string }[] = [
+    ...registeredHooks.map(hook => ({ name: hook.name || "anonymous", hook })),
+  ];
+  for (const modulePath of (await loadCollabConfig()).transfer_hooks ?? []) {
+    try {
+      hooks.push({ name: modulePath, hook: await loadHookModule(modulePath) });
+    } catch (error) {
+      hooks.push({ name: modulePath, error: error instanceof Error ? error.message : String(error) });
+    }
+  }
+
+  const registry = await loadOwnerRegistry();
+  const failures: TransferHookFailure[] = [];
+  for (const found of transfers) {
+    const transfer = registry ? { ...found, contacts: transferContacts(found, registry, failures) } : found;
+    for (const { name, hook, error } of hooks) {
+      try {
+        if (!hook) throw new Error(error);
+        // Each hook gets its own copy so one cannot alter what the next sees
+        await hook(structuredClone(transfer));
+      } catch (failure) {
+        failures.push({
+          hook: name,
+          file: transfer.file,
+          line: transfer.line,
+          message: failure instanceof Error ? failure.message : String(failure),
+        });
+      }
+    }
+  }
+  return failures;
+}
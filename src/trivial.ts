@@ -0,0 +1,138 @@
+/**
+ * Trivial edit classification
+ *
+ * An edit is "trivial" when the code before and after is identical once
+ * comments are removed and whitespace between tokens is ignored:
+ *
+ * - String and template literals are compared verbatim, whitespace included
+ * - Comment text may change, be added, or be removed
+ * - Line breaks and runs of spaces between tokens may change
+ * - Whitespace may not be added or removed *between* two tokens that were
+ *   adjacent (`a+b` vs `a + b` is substantive, conservatively)
+ * - In Python, indentation is significant and must not change
+ *
+ * Kept dependency-free so the hooks can use it standalone.
+ */
+
+import * as path from "path";
+
+type CommentStyle = "c" | "hash" | "none";
+
+const C_STYLE = ["ts", "tsx", "js", "jsx", "mjs", "cjs", "go", "rs", "java", "c", "cpp", "cs", "swift", "kt", "scala"];
+const HASH_STYLE = ["py", "rb", "sh", "bash", "yaml", "yml"];
+
+function commentStyleFor(filePath: string): CommentStyle {
+  const ext = path.extname(filePath).toLowerCase().slice(1);
+  if (C_STYLE.includes(ext)) return "c";
+  if (HASH_STYLE.includes(ext)) return "hash";
+  return "none";
+}
+
+/**
+ * Split source into comparable tokens, dropping comments and whitespace.
+ * Each token is a maximal run of non-whitespace characters or a string literal.
+ */
+export function codeTokens(text: string, filePath: string): string[] {
+  const style = commentStyleFor(filePath);
+  const indentSensitive = path.extname(filePath).toLowerCase() === ".py";
+  const src = text.replace(/\r\n/g, "\n").replace(/\r/g, "\n");
+
+  const tokens: string[] = [];
+  let current = "";
+  let atLineStart = true;
+  let i = 0;
+
+  const flush = () => {
+    if (current) {
+      tokens.push(current);
+      current = "";
+    }
+  };
+
+  while (i < src.length) {
+    const ch = src[i];
+
+    // Python: record indentation of each non-blank line as its own token
+    if (atLineStart && indentSensitive) {
+      let j = i;
+      while (j < src.length && (src[j] === " " || src[j] === "\t")) j++;
+      const blank = j >= src.length || src[j] === "\n" || src[j] === "#";
+      if (!blank) tokens.push(`\u0000indent:${src.slice(i, j)}`);
+      atLineStart = false;
+      i = j;
+      continue;
+    }
+    atLineStart = false;
+
+    // Comments
+    if (style === "c" && ch === "/" && src[i + 1] === "/") {
+      flush();
+      while (i < src.length && src[i] !== "\n") i++;
+      continue;
+    }
+    if (style === "c" && ch === "/" && src[i + 1] === "*") {
+      flush();
+      const close = src.indexOf("*/", i + 2);
+      i = close === -1 ? src.length : close + 2;
+      continue;
+    }
+    // Only a '#' that starts a token opens a comment (avoids `$#` in shell)
+    if (style === "hash" && ch === "#" && current === "") {
+      while (i < src.length && src[i] !== "\n") i++;
+      continue;
+    }
+
+    // String literals: kept verbatim as part of the current token
+    if (ch === '"' || ch === "'" || ch === "`") {
+      const triple = indentSensitive && src.startsWith(ch.repeat(3), i);
+      const quote = triple ? ch.repeat(3) : ch;
+      let j = i + quote.length;
+      while (j < src.length) {
+        if (src[j] === "\\") {
+          j += 2;
+          continue;
+        }
+        if (src.startsWith(quote, j)) {
+          j += quote.length;
+          break;
+        }
+        // Single-line quotes end at a newline; backticks and triples may span lines
+        if (src[j] === "\n" && quote !== "`" && !triple) break;
+        j++;
+      }
+      current += src.slice(i, j);
+      i = j;
+      continue;
+    }
+
+    if (ch === "\n") {
+      flush();
+      atLineStart = true;
+      i++;
+      continue;
+    }
+
+    if (ch === " " || ch === "\t") {
+      flush();
+      i++;
+      continue;
+    }
+
+    current += ch;
+    i++;
+  }
+
+  flush();
+  return tokens;
+}
+
+/**
+ * True if `after` differs from `before` only by comments and whitespace.
+ */
+export function isTrivialEdit(before: string, after: string, filePath: string): boolean {
+  if (before === after) return true;
+
+  const a = codeTokens(before, filePath);
+  const b = codeTokens(after, filePath);
+  return a.length === b.length && a.every((token, i) => token === b[i]);
+}
@@ -0,0 +1,291 @@
+/**
+ * verify-authors command for collab-claude-code
+ *
+ * A retrospective audit of live enforcement: for every owned READ_ONLY and
+ * SUGGEST_ONLY region, git blame finds the latest commit that changed one of
+ * its lines, and the region is flagged when that commit's author is neither
+ * one of its owners nor on an owning team:
+ *
+ *   collab-claude-code verify-authors [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+ *
+ * Agents cannot edit these regions directly, so a change by someone outside
+ * the owning team usually means enforcement was bypassed (hooks disabled, a
+ * proposal applied by hand) and the change never had an owner's eyes on it.
+ * Team membership comes from the IdentityResolver (config.yaml `identities`
+ * and `team_roster`); owner groups from config.yaml `owners` are expanded.
+ *
+ * A region is an annotation's scope, or outside annotations a run of lines
+ * governed by the same trust.yaml setting: a `regions` entry, a symbol
+ * policy, a `policies` glob, or a package default. Lines not committed yet
+ * are left out, as are files git does not track.
+ *
+ * Exit codes follow the check command:
+ *   0 = Every region was last changed by an owner
+ *   1 = One or more regions last changed by someone else
+ *   2 = Tool error (not a git repository, unreadable file, invalid config)
+ */
+
+import * as fs from "fs/promises";
+
+import {
+  ParsedAnnotation,
+  TrustLevel,
+  TrustResult,
+  comparePaths,
+  expandOwners,
+  formatOwner,
+  formatRange,
+  isGeneratedSource,
+  isGoTestSource,
+  loadOwnerRegistry,
+  loadPackageDefault,
+  loadTrustAliases,
+  ownerList,
+  parseAnnotationContent,
+  rangeOf,
+  resolveTrustWithAnnotations,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  EXIT_VIOLATIONS,
+  expandPaths,
+  loadTrustConfigStrict,
+} from "./check.js";
+import { findDeclarations } from "./declarations.js";
+import { BlameLine, blameLines, listTrackedFiles } from "./git.js";
+import { IdentityResolver, createIdentityResolver, identityKey, isOwnedBy } from "./identity.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface LastChange {
+  file: string;
+  line_start: number;
+  line_end: number;
+  trust: TrustLevel;
+  owner: string | string[];
+  source: TrustResult["source"]; // What governs the region: "annotation", "region", "policy", ...
+  pattern?: string; // For source "policy": the glob
+  commit: string; // Latest commit that changed a line of the region
+  author: string; // Its author, "Name <email>"
+  date: string; // Its committer date, ISO 8601
+}
+
+export interface AuthorAuditReport {
+  checked_files: number;
+  audited_regions: number; // Owned READ_ONLY and SUGGEST_ONLY regions with committed lines, of any source
+  findings: LastChange[];
+}
+
+export interface VerifyAuthorsOptions extends CheckOptions {
+  identities?: IdentityResolver; // Default: config.yaml identities and team_roster
+}
+
+// ============================================
+// Constants
+// ============================================
+
+const AUDITED_LEVELS: TrustLevel[] = ["READ_ONLY", "SUGGEST_ONLY"];
+
+// ============================================
+// Audit
+// ============================================
+
+// A span of lines one setting governs, with the trust it resolves to
+interface GovernedSpan {
+  line_start: number;
+  line_end: number;
+  trust: TrustResult;
+}
+
+function lineCount(content: string): number {
+  if (content === "") return 0;
+  const lines = content.replace(/\r\n/g, "\n").split("\n");
+  return content.endsWith("\n") ? lines.length - 1 : lines.length;
+}
+
+/**
+ * Each annotation's scope, then the runs of lines outside every annotation
+ * that resolve to the same level, owner, and governing setting.
+ */
+function governedSpans(
+  annotations: ParsedAnnotation[],
+  totalLines: number,
+  resolve: (start: number, end: number) => TrustResult
+): GovernedSpan[] {
+  const spans: GovernedSpan[] = [];
+  const annotated = new Set<number>();
+  for (const { line_start, line_end } of annotations) {
+    if (line_end < line_start) continue;
+    spans.push({ line_start, line_end, trust: resolve(line_start, line_end) });
+    for (let line = line_start; line <= line_end; line++) annotated.add(line);
+  }
+
+  let run: (GovernedSpan & { key: string }) | undefined;
+  for (let line = 1; line <= totalLines + 1; line++) {
+    const trust = line <= totalLines && !annotated.has(line) ? resolve(line, line) : undefined;
+    const key = trust
+      ? [trust.level, trust.source, trust.reason, trust.pattern, formatOwner(trust.owner)].join("\0")
+      : undefined;
+    if (run && key === run.key) {
+      run.line_end = line;
+      continue;
+    }
+    if (run) spans.push({ line_start: run.line_start, line_end: run.line_end, trust: run.trust });
+    run = trust && key ? { line_start: line, line_end: line, trust, key } : undefined;
+  }
+  return spans;
+}
+
+export async function verifyAuthors(paths: string[], options: VerifyAuthorsOptions = {}): Promise<AuthorAuditReport> {
+  const { signal } = options;
+  const config = await loadTrustConfigStrict(options.profile);
+  const invalidConfig = (error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  };
+  const aliases = await loadTrustAliases().catch(invalidConfig);
+  const registry = (await loadOwnerRegistry().catch(invalidConfig)) ?? {};
+  const identities = options.identities ?? createIdentityResolver();
+  const files = await expandPaths(paths, options);
+  const tracked = await listTrackedFiles(files, signal).catch(() => {
+    signal?.throwIfAborted();
+    throw new CheckToolError("verify-authors needs a git repository");
+  });
+
+  const report: AuthorAuditReport = { checked_files: files.length, audited_regions: 0, findings: [] };
+  for (const file of tracked) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    const { annotations } = parseAnnotationContent(content, file, { aliases });
+    const declarations = config.symbols?.length ? findDeclarations(content, file) : [];
+    const generated = isGeneratedSource(content);
+    const packageDefault = await loadPackageDefault(file, { aliases });
+    const testFile = isGoTestSource(file, content);
+
+    const totalLines = lineCount(content);
+    const spans = governedSpans(annotations, totalLines, (start, end) =>
+      resolveTrustWithAnnotations(config, file, annotations, start, end, declarations, generated, packageDefault, testFile)
+    ).filter(span => AUDITED_LEVELS.includes(span.trust.level) && ownerList(span.trust.owner).length > 0);
+    if (spans.length === 0) continue;
+
+    // One blame of the file serves every region in it
+    const blame = await blameLines(file, 1, totalLines, signal).catch((error: Error) => {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot blame ${file}: ${error.message.trim()}`);
+    });
+    const blameByLine = new Map<number, BlameLine>(blame.map(line => [line.line, line]));
+
+    for (const { line_start, line_end, trust } of spans) {
+      const blamed: BlameLine[] = [];
+      for (let line = line_start; line <= line_end; line++) {
+        const entry = blameByLine.get(line);
+        if (entry) blamed.push(entry);
+      }
+      if (blamed.length === 0) continue;
+      report.audited_regions++;
+
+      const last = blamed.reduce((latest, line) => (line.time > latest.time ? line : latest));
+      const identity = identityKey(last.author);
+      const teams = await identities.teamsFor(last.author);
+      const owned = ownerList(trust.owner).some(key =>
+        expandOwners(key, registry).some(owner => isOwnedBy(owner, identity, teams))
+      );
+      if (owned) continue;
+      report.findings.push({
+        file,
+        line_start,
+        line_end,
+        trust: trust.level,
+        owner: trust.owner!,
+        source: trust.source,
+        pattern: trust.pattern,
+        commit: last.commit,
+        author: last.author,
+        date: new Date(last.time * 1000).toISOString(),
+      });
+    }
+  }
+
+  report.findings.sort((a, b) => comparePaths(a.file, b.file) || a.line_start - b.line_start);
+  return report;
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+function describeSource(finding: LastChange): string {
+  switch (finding.source) {
+    case "policy":
+      return `code (trust.yaml policy "${finding.pattern}")`;
+    case "region":
+      return "region (trust.yaml regions)";
+    case "symbol":
+      return "code (trust.yaml symbol policy)";
+    case "package":
+      return "code (package default)";
+    default:
+      return "region";
+  }
+}
+
+export function formatAuthorAuditText(report: AuthorAuditReport): string {
+  const lines = report.findings.map(f =>
+    `${formatRange(rangeOf(f), f.file)}: ${f.trust} ${describeSource(f)} owned by ${formatOwner(f.owner)} last changed by ` +
+      `${f.author} in ${f.commit.slice(0, 12)} (${f.date.slice(0, 10)})`
+  );
+  const count = report.findings.length;
+  lines.push(
+    `Verified ${report.audited_regions} owned region(s) in ${report.checked_files} file(s): ` +
+      (count === 0 ? "all last changed by an owner" : `${count} last changed by someone outside the owning team`)
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runVerifyAuthors(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await verifyAuthors(paths, { profile, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatAuthorAuditText(report));
+    return report.findings.length > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,290 @@
+/**
+ * verify-constraints command for collab-claude-code
+ *
+ * Runs constraint verifiers over every annotated region with `constraints`
+ * and quarantines the regions whose current code already breaks them. This
+ * catches drift: code that changed without anyone noticing that an invariant
+ * it promised no longer holds.
+ *
+ * A ConstraintVerifier is called once per constraint of each region and
+ * throws (or rejects) when the code violates it; verifiers ignore constraints
+ * they do not understand. Verifiers come from registerConstraintVerifier and
+ * from modules listed under `constraint_verifiers` in config.yaml, like
+ * pre-apply hooks. The quarantine is written to .collab/quarantine.json and
+ * a region leaves it on the first run that no longer finds it failing.
+ *
+ * Exit codes:
+ *   0 = No quarantined regions (or --strict not given)
+ *   1 = --strict and one or more regions are quarantined
+ *   2 = Tool error (bad arguments, no verifiers, a verifier module fails to load)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import { pathToFileURL } from "url";
+
+import {
+  COLLAB_DIR,
+  TrustLevel,
+  ensureCollabDir,
+  formatRange,
+  loadCollabConfig,
+  loadTrustAliases,
+  parseAnnotationContent,
+  rangeOf,
+  stableStringify,
+} from "./collab.js";
+import {
+  CheckOptions,
+  CheckToolError,
+  EXIT_CLEAN,
+  EXIT_TOOL_ERROR,
+  EXIT_VIOLATIONS,
+  expandPaths,
+} from "./check.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface ConstraintRegion {
+  file: string;
+  line_start: number;
+  line_end: number;
+  constraint: string; // The constraint to verify
+  constraints: string[]; // Every constraint on the region
+  trust?: TrustLevel;
+  owner?: string | string[];
+  intent?: string;
+  code: string; // The governed lines
+}
+
+export type ConstraintVerifier = (region: ConstraintRegion) => void | Promise<void>;
+
+export interface QuarantinedRegion {
+  file: string;
+  line_start: number;
+  line_end: number;
+  constraint: string;
+  verifier: string; // Function name or module path
+  message: string;
+  trust?: TrustLevel;
+  owner?: string | string[];
+  first_seen: string; // ISO timestamp of the first run that quarantined it
+}
+
+export interface QuarantineReport {
+  generated_at: string;
+  verifiers: string[];
+  checked_files: number;
+  checked_regions: number; // Regions with at least one constraint
+  quarantined: QuarantinedRegion[];
+}
+
+export interface VerifyOptions extends CheckOptions {
+  now?: Date;
+}
+
+// ============================================
+// Constants
+// ============================================
+
+export const QUARANTINE_FILE = "quarantine.json";
+
+const registeredVerifiers: ConstraintVerifier[] = [];
+
+// ============================================
+// Verifiers
+// ============================================
+
+export function registerConstraintVerifier(verifier: ConstraintVerifier): void {
+  registeredVerifiers.push(verifier);
+}
+
+export function clearConstraintVerifiers(): void {
+  registeredVerifiers.length = 0;
+}
+
+// A config module must export the verifier as its default export or as `verify`
+async function loadVerifierModule(modulePath: string): Promise<ConstraintVerifier> {
+  let loaded: Record<string, unknown>;
+  try {
+    loaded = await import(pathToFileURL(path.resolve(modulePath)).href);
+  } catch (error) {
+    throw new CheckToolError(
+      `Cannot load constraint verifier ${modulePath}: ${error instanceof Error ? error.message : String(error)}`
+    );
+  }
+  const verifier = loaded.default ?? loaded.verify;
+  if (typeof verifier !== "function") {
+    throw new CheckToolError(`${modulePath} does not export a constraint verifier (default or \`verify\`)`);
+  }
+  return verifier as ConstraintVerifier;
+}
+
+async function loadVerifiers(): Promise<{ name: string; verify: ConstraintVerifier }[]> {
+  const modules = (await loadCollabConfig()).constraint_verifiers ?? [];
+  return [
+    ...registeredVerifiers.map(verify => ({ name: verify.name || "anonymous", verify })),
+    ...(await Promise.all(modules.map(async name => ({ name, verify: await loadVerifierModule(name) })))),
+  ];
+}
+
+// ============================================
+// Quarantine
+// ============================================
+
+function quarantineKey(entry: { file: string; constraint: string; verifier: string }): string {
+  return `${entry.file}\0${entry.constraint}\0${entry.verifier}`;
+}
+
+export async function loadQuarantine(): Promise<QuarantineReport | null> {
+  try {
+    return JSON.parse(await fs.readFile(path.join(COLLAB_DIR, QUARANTINE_FILE), "utf-8")) as QuarantineReport;
+  } catch {
+    return null;
+  }
+}
+
+export async function saveQuarantine(report: QuarantineReport): Promise<void> {
+  const dir = await ensureCollabDir();
+  await fs.writeFile(path.join(dir, QUARANTINE_FILE), stableStringify(report, 2) + "\n");
+}
+
+/**
+ * Run every verifier against every constraint in the files. Regions already
+ * in the saved quarantine keep their first_seen time. Nothing is written;
+ * see saveQuarantine.
+ */
+export async function verifyConstraints(paths: string[], options: VerifyOptions = {}): Promise<QuarantineReport> {
+  const { signal } = options;
+  const verifiers = await loadVerifiers();
+  if (verifiers.length === 0) {
+    throw new CheckToolError(
+      `No constraint verifiers registered; list modules under constraint_verifiers in ${COLLAB_DIR}/config.yaml`
+    );
+  }
+
+  const now = (options.now ?? new Date()).toISOString();
+  const previous = new Map(((await loadQuarantine())?.quarantined ?? []).map(q => [quarantineKey(q), q.first_seen]));
+  const aliases = await loadTrustAliases();
+  const files = await expandPaths(paths, options);
+
+  const quarantined: QuarantinedRegion[] = [];
+  let checkedRegions = 0;
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+
+    const lines = content.replace(/\r\n/g, "\n").split("\n");
+    const { annotations } = parseAnnotationContent(content, file, { aliases });
+    for (const annotation of annotations) {
+      const constraints = annotation.constraints ?? [];
+      if (constraints.length === 0) continue;
+      checkedRegions++;
+
+      const region = {
+        file,
+        line_start: annotation.line_start,
+        line_end: annotation.line_end,
+        constraints,
+        trust: annotation.trust,
+        owner: annotation.owner,
+        intent: annotation.intent,
+        code: lines.slice(annotation.line_start - 1, annotation.line_end).join("\n"),
+      };
+      for (const constraint of constraints) {
+        for (const { name, verify } of verifiers) {
+          try {
+            // Each verifier gets its own copy so one cannot alter what the next sees
+            await verify(structuredClone({ ...region, constraint }));
+          } catch (error) {
+            const entry = { file, constraint, verifier: name };
+            quarantined.push({
+              ...entry,
+              line_start: annotation.line_start,
+              line_end: annotation.line_end,
+              message: error instanceof Error ? error.message : String(error),
+              trust: annotation.trust,
+              owner: annotation.owner,
+              first_seen: previous.get(quarantineKey(entry)) ?? now,
+            });
+          }
+        }
+      }
+    }
+  }
+
+  return {
+    generated_at: now,
+    verifiers: verifiers.map(v => v.name),
+    checked_files: files.length,
+    checked_regions: checkedRegions,
+    quarantined,
+  };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatQuarantineText(report: QuarantineReport): string {
+  const lines: string[] = [];
+  for (const q of report.quarantined) {
+    lines.push(
+      `${formatRange(rangeOf(q), q.file)}: constraint "${q.constraint}" fails ${q.verifier}: ${q.message}` +
+        (q.first_seen !== report.generated_at ? ` (quarantined since ${q.first_seen})` : "")
+    );
+  }
+
+  const count = report.quarantined.length;
+  lines.push(
+    `Verified ${report.checked_regions} constrained region(s) in ${report.checked_files} file(s) ` +
+      `with ${report.verifiers.length} verifier(s): ` +
+      (count === 0 ? "none quarantined" : `${count} quarantined`)
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runVerifyConstraints(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let strict = false;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg === "--strict") {
+      strict = true;
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await verifyConstraints(paths, { noIgnore });
+    await saveQuarantine(report);
+    console.log(format === "json" ? stableStringify(report, 2) : formatQuarantineText(report));
+    return strict && report.quarantined.length > 0 ? EXIT_VIOLATIONS : EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}
@@ -0,0 +1,135 @@
+/**
+ * warm command for collab-claude-code
+ *
+ * Prepares a checkout before the MCP server or hooks start taking requests:
+ *
+ *   collab-claude-code warm [--format=text|json] [--profile=<name>] [--no-ignore] [paths...]
+ *
+ * Every `extends` URL in trust.yaml is fetched into .collab/cache/policies,
+ * even when the cached copy is still fresh, so the hooks (which never fetch)
+ * and the first tool call see the current shared policy without a network
+ * round trip. The merged config is then validated and every file is parsed
+ * once, so a broken annotation surfaces here rather than on the first edit.
+ *
+ * Cache files are replaced atomically (see policy.ts), so it is safe to run
+ * while a server reading the same .collab directory is up, e.g. from cron.
+ *
+ * Exit codes:
+ *   0 = Cache warmed
+ *   2 = Tool error (uncached unreachable or mismatched extended policy, invalid config, unreadable file)
+ */
+
+import * as fs from "fs/promises";
+import * as path from "path";
+import * as yaml from "yaml";
+
+import { COLLAB_DIR, TRUST_FILE, fileExists, loadTrustAliases, parseAnnotationContent, stableStringify } from "./collab.js";
+import { CheckOptions, CheckToolError, EXIT_CLEAN, EXIT_TOOL_ERROR, expandPaths, loadTrustConfigStrict } from "./check.js";
+import { PolicyDocument, loadPolicyLayers } from "./policy.js";
+
+// ============================================
+// Types
+// ============================================
+
+export interface WarmReport {
+  policies: string[]; // Extended policy sources loaded, URLs refreshed into the cache
+  checked_files: number;
+  annotations: number;
+}
+
+// ============================================
+// Warming
+// ============================================
+
+export async function warmCaches(paths: string[], options: CheckOptions = {}): Promise<WarmReport> {
+  const { signal } = options;
+  const trustPath = path.join(COLLAB_DIR, TRUST_FILE);
+
+  let policies: string[] = [];
+  if (await fileExists(trustPath)) {
+    let local: PolicyDocument | null;
+    try {
+      local = yaml.parse(await fs.readFile(trustPath, "utf-8")) as PolicyDocument | null;
+    } catch (error) {
+      throw new CheckToolError(`Invalid ${trustPath}: ${error instanceof Error ? error.message : String(error)}`);
+    }
+    if (local?.extends) {
+      const layers = await loadPolicyLayers(local.extends, { refresh: true, signal }).catch((error: Error) => {
+        signal?.throwIfAborted();
+        throw new CheckToolError(error.message);
+      });
+      policies = layers.map(layer => layer.source);
+    }
+  }
+
+  // Reads the cache just written, and fails on anything a server would fail on
+  await loadTrustConfigStrict(options.profile);
+  const aliases = await loadTrustAliases().catch((error: Error) => {
+    throw new CheckToolError(`Invalid config.yaml: ${error.message}`);
+  });
+
+  const files = await expandPaths(paths, options);
+  let annotations = 0;
+  for (const file of files) {
+    signal?.throwIfAborted();
+    let content: string;
+    try {
+      content = await fs.readFile(file, { encoding: "utf-8", signal });
+    } catch {
+      signal?.throwIfAborted();
+      throw new CheckToolError(`Cannot read ${file}`);
+    }
+    annotations += parseAnnotationContent(content, file, { aliases }).annotations.length;
+  }
+
+  return { policies, checked_files: files.length, annotations };
+}
+
+// ============================================
+// Text Output
+// ============================================
+
+export function formatWarmText(report: WarmReport): string {
+  const lines = report.policies.map(source => `  ${source}`);
+  lines.push(
+    `Warmed ${report.policies.length} extended policy document(s); ` +
+      `parsed ${report.checked_files} file(s) with ${report.annotations} annotation(s)`
+  );
+  return lines.join("\n");
+}
+
+// ============================================
+// CLI Entry
+// ============================================
+
+export async function runWarm(args: string[]): Promise<number> {
+  let format: "text" | "json" = "text";
+  let profile: string | undefined;
+  let noIgnore = false;
+  const paths: string[] = [];
+
+  for (const arg of args) {
+    if (arg === "--format=json" || arg === "--format=text") {
+      format = arg.slice("--format=".length) as "text" | "json";
+    } else if (arg.startsWith("--profile=")) {
+      profile = arg.slice("--profile=".length);
+    } else if (arg === "--no-ignore") {
+      noIgnore = true;
+    } else if (arg.startsWith("--")) {
+      console.error(`Unknown option: ${arg}`);
+      return EXIT_TOOL_ERROR;
+    } else {
+      paths.push(arg);
+    }
+  }
+
+  try {
+    const report = await warmCaches(paths, { profile, noIgnore });
+    console.log(format === "json" ? stableStringify(report, 2) : formatWarmText(report));
+    return EXIT_CLEAN;
+  } catch (error) {
+    const message = error instanceof Error ? error.message : String(error);
+    console.error(`Error: ${message}`);
+    return EXIT_TOOL_ERROR;
+  }
+}